@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var (
+	stripAnnotations = flag.String("strip-annotations", "",
+		"compare labels with any suffix matching the regular expression `re` removed from their printed form")
+	respectAnnotations = flag.Bool("respect-annotations", false,
+		"compare annotated labels as printed, so that a@1 and a@2 differ (the default)")
+)
+
+// globQuote escapes the characters of s that path.Match treats specially, so
+// that the pattern matches s only.
+func globQuote(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// annotationClasses returns, for -strip-annotations, an observation class for
+// every printed form that a label of left or right takes once its annotation
+// is stripped, if some label had one. Each class matches the labels with that
+// form, annotated or not, and is named after them, so that outputs still show
+// the annotations. Silent labels are left alone.
+func annotationClasses(left, right pifra.Lts) ([]ObservationClass, error) {
+	if *stripAnnotations == "" {
+		return nil, nil
+	}
+	if *respectAnnotations {
+		return nil, errors.New("-strip-annotations and -respect-annotations are exclusive")
+	}
+	re, err := regexp.Compile("(?:" + *stripAnnotations + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("-strip-annotations: %v", err)
+	}
+	texts := make(map[string]map[string]bool)
+	stripped := make(map[string]bool)
+	for _, lts := range []pifra.Lts{left, right} {
+		for _, trans := range lts.Transitions {
+			if IsTau(trans.Label) {
+				continue
+			}
+			text := trans.Label.PrettyPrintGraph()
+			base := re.ReplaceAllString(text, "")
+			if texts[base] == nil {
+				texts[base] = make(map[string]bool)
+			}
+			texts[base][text] = true
+			if base != text {
+				stripped[base] = true
+			}
+		}
+	}
+	bases := make([]string, 0, len(stripped))
+	for base := range stripped {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+	var classes []ObservationClass
+	for _, base := range bases {
+		members := make([]string, 0, len(texts[base]))
+		for text := range texts[base] {
+			members = append(members, text)
+		}
+		sort.Strings(members)
+		class := ObservationClass{Name: base + " (" + strings.Join(members, ", ") + ")"}
+		for _, text := range members {
+			class.Patterns = append(class.Patterns, globQuote(text))
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestAnnotationClasses(t *testing.T) {
+	setFlag(t, "strip-annotations", " [0-9]+")
+	left := pifra.Lts{Transitions: []pifra.Transition{
+		{Label: inputLabel(1, 1)}, {Label: inputLabel(1, 2)}, {Label: tauLabel},
+	}}
+	right := pifra.Lts{Transitions: []pifra.Transition{{Label: inputLabel(2, 1)}, {Label: inputLabel(1, 3)}}}
+	classes, err := annotationClasses(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ObservationClass{
+		{Name: "1 (1 1, 1 2, 1 3)", Patterns: []string{"1 1", "1 2", "1 3"}},
+		{Name: "2 (2 1)", Patterns: []string{"2 1"}},
+	}
+	if !reflect.DeepEqual(classes, want) {
+		t.Errorf("got %+v, want %+v", classes, want)
+	}
+	if got := globQuote(`a*b?[c]\`); got != `a\*b\?\[c]\\` {
+		t.Errorf("globQuote quoted %q", got)
+	}
+}
+
+// TestStripAnnotations compares a.b with a.c, where the payloads 1 and 2 of
+// b and c stand for annotations: stripping them makes the LTSs bisimilar.
+func TestStripAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n")
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{nil, 1},
+		{[]string{"-respect-annotations"}, 1},
+		{[]string{"-strip-annotations", " [0-9]+"}, 0},
+		{[]string{"-strip-annotations", " 2"}, 1},
+	} {
+		out := filepath.Join(dir, "out")
+		args := append(append([]string{}, test.args...), left, right, out)
+		stdout, stderr, code := runPisim(t, dir, args...)
+		if code != test.code {
+			t.Errorf("%v: status %d, want %d: %s%s", test.args, code, test.code, stdout, stderr)
+		}
+		if code != 0 {
+			continue
+		}
+		dot, err := os.ReadFile(out + "-right.dot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(dot), `label="2 2"`) {
+			t.Errorf("%v: the coloured LTS lost the annotated label:\n%s", test.args, dot)
+		}
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-strip-annotations", " 1", "-respect-annotations"}, "-strip-annotations and -respect-annotations are exclusive"},
+		{[]string{"-strip-annotations", "("}, "-strip-annotations: error parsing regexp"},
+	} {
+		_, stderr, code := runPisim(t, dir, append(append([]string{"-quiet"}, test.args...), left, right)...)
+		if code == 0 || !strings.Contains(stderr, test.want) {
+			t.Errorf("%v: status %d and %q, want an error holding %q", test.args, code, stderr, test.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// TestAutRoundTrip checks that readAut reads back what slice -format aut
+// writes, up to the numbering of the states.
+func TestAutRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		lts := reference.Random(r, 1+r.Intn(8), r.Intn(16), 3, 0.3)
+		data, err := ltsAut(context.Background(), lts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, _, err := readAut(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("LTS %d: %v\n%s", i, err, data)
+		}
+		if len(read.Transitions) != len(lts.Transitions) || !reference.Strong(lts, read) {
+			t.Fatalf("LTS %d read back as %v, want %v", i, read.Transitions, lts.Transitions)
+		}
+	}
+}
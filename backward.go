@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// Backward bisimilarity matches the moves into states rather than those out
+// of them: if s and t are related and s' moves to s, then some t' moves to t
+// by the same label, with s' and t' related. The initial states have no
+// history to match, so they are only related to each other, and the LTSs are
+// backward bisimilar if, in addition, every state of either side is related
+// to a state of the other. Forward-backward bisimilarity asks for both
+// directions at once, and for related initial states as forward
+// bisimilarity does; without silent moves it coincides with -undirected.
+var (
+	backward = flag.Bool("backward", false,
+		"decide backward bisimilarity, matching the moves into states rather than out of them")
+	forwardBackward = flag.Bool("forward-backward", false,
+		"decide forward-backward bisimilarity, matching the moves both out of and into states")
+)
+
+func validateDirection() error {
+	if !*backward && !*forwardBackward {
+		return nil
+	}
+	if *backward && *forwardBackward {
+		return errors.New("-backward and -forward-backward cannot be combined")
+	}
+	if _, ok := refinements[*equivalence]; !ok || *sim || *compareStats {
+		return errors.New("-backward and -forward-backward need an equivalence decided by partition refinement, " +
+			"without -sim or -compare-stats")
+	}
+	if *undirected || *prereduce || *ignoreInitial {
+		return errors.New("-backward and -forward-backward cannot be combined with -undirected, -prereduce " +
+			"or -ignore-initial")
+	}
+	return nil
+}
+
+// direction names the direction of the bisimilarity decided, as a prefix
+// for "bisimilar" and "bisimulation": "" for the usual forward one.
+func direction() string {
+	switch {
+	case *backward:
+		return "backward "
+	case *forwardBackward:
+		return "forward-backward "
+	}
+	return ""
+}
+
+// reverseLTS returns lts with every transition reversed, keeping its label.
+// Its index of moves by source is the index of lts by destination.
+func reverseLTS(lts pifra.Lts) pifra.Lts {
+	rev := lts
+	rev.Transitions = make([]pifra.Transition, len(lts.Transitions))
+	for i, trans := range lts.Transitions {
+		trans.Source, trans.Destination = trans.Destination, trans.Source
+		rev.Transitions[i] = trans
+	}
+	return rev
+}
+
+// directed returns lts, saturated if need be, as refineSides refines it for
+// the direction: reversed under -backward, and with the reverse of every move
+// added under -forward-backward.
+func directed(lts pifra.Lts) pifra.Lts {
+	switch {
+	case *backward:
+		return reverseLTS(lts)
+	case *forwardBackward:
+		return addReverse(lts)
+	}
+	return lts
+}
+
+// seedInitialStates separates the initial states of left and right from the
+// other states under -backward, keeping any seed already set.
+func seedInitialStates(left, right pifra.Lts) {
+	if !*backward {
+		return
+	}
+	colours := make(map[int]uint64, len(left.States)+len(right.States))
+	for _, lts := range []pifra.Lts{left, right} {
+		for state := range lts.States {
+			colours[state] = initialColours[state] << 1
+			if state == initialStates[LeftSide] || state == initialStates[RightSide] {
+				colours[state] |= 1
+			}
+		}
+	}
+	initialColours = colours
+}
+
+// backwardReason explains why part, refined under -backward, does not make
+// the LTSs backward bisimilar.
+func backwardReason(part Partition) string {
+	if part.initialsSplit() {
+		return "the moves into the initial states cannot be matched"
+	}
+	var unmatched []int
+	for _, block := range part.blocks {
+		if !part.mixed(block) {
+			unmatched = append(unmatched, block.States()...)
+		}
+	}
+	sort.Ints(unmatched)
+	first := unmatched[0]
+	return fmt.Sprintf("%d states have no backward bisimilar state on the other side, the first %s state %s",
+		len(unmatched), part.sides[first].Side, stateName(part.sides, first))
+}
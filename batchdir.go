@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pisim/result"
+)
+
+// batchResults is the directory under the batch directory that receives the
+// outputs of each model.
+const batchResults = "results"
+
+// namePattern matches the files of one side of a model: the model name
+// between a fixed prefix and suffix.
+type namePattern struct {
+	prefix, suffix string
+}
+
+// match returns the model name of file, if it matches p.
+func (p namePattern) match(file string) (string, bool) {
+	if len(file) <= len(p.prefix)+len(p.suffix) ||
+		!strings.HasPrefix(file, p.prefix) || !strings.HasSuffix(file, p.suffix) {
+		return "", false
+	}
+	return file[len(p.prefix) : len(file)-len(p.suffix)], true
+}
+
+func (p namePattern) file(name string) string {
+	return p.prefix + name + p.suffix
+}
+
+// parsePairPattern parses a -pattern of the form left:right, where each side
+// holds {name} once, such as {name}_spec.gob:{name}_impl.gob.
+func parsePairPattern(s string) ([2]namePattern, error) {
+	var pats [2]namePattern
+	sides := strings.Split(s, ":")
+	if len(sides) != 2 {
+		return pats, fmt.Errorf("-pattern %q: expected two file patterns separated by ':'", s)
+	}
+	for i, side := range sides {
+		if strings.Count(side, "{name}") != 1 || strings.ContainsAny(side, `/\`) {
+			return pats, fmt.Errorf("-pattern %q: each file pattern must hold {name} once, and no directory", s)
+		}
+		parts := strings.SplitN(side, "{name}", 2)
+		pats[i] = namePattern{parts[0], parts[1]}
+	}
+	if pats[0] == pats[1] {
+		return pats, fmt.Errorf("-pattern %q: the two file patterns are the same", s)
+	}
+	return pats, nil
+}
+
+// discoverPairs returns the names of the models with a file for both sides in
+// dir, in order, and the files with no counterpart on the other side.
+func discoverPairs(dir string, pats [2]namePattern) (names, unpaired []string, err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var found [2]map[string]bool
+	for side := range found {
+		found[side] = make(map[string]bool)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		for side, pat := range pats {
+			if name, ok := pat.match(entry.Name()); ok {
+				found[side][name] = true
+			}
+		}
+	}
+	for name := range found[LeftSide] {
+		if found[RightSide][name] {
+			names = append(names, name)
+		} else {
+			unpaired = append(unpaired, pats[LeftSide].file(name))
+		}
+	}
+	for name := range found[RightSide] {
+		if !found[LeftSide][name] {
+			unpaired = append(unpaired, pats[RightSide].file(name))
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(unpaired)
+	return names, unpaired, nil
+}
+
+// batchVerdict returns the verdict of the comparison of a model from its
+// -result-json object in resultFile, or describes its failure, which
+// otherwise shares the exit status of a negative verdict.
+func batchVerdict(resultFile string, err error) string {
+	f, openErr := os.Open(resultFile)
+	if openErr == nil {
+		defer closeFile(f)
+		if c, decodeErr := result.Decode(f); decodeErr == nil {
+			return c.Verdict
+		}
+	}
+	if err == nil {
+		err = errors.New("no result")
+	}
+	return fmt.Sprintf("failed (%v), see %s", err, filepath.Join(filepath.Dir(resultFile), "output.txt"))
+}
+
+func batchDirCommand(args []string) {
+	pattern := flag.String("pattern", "{name}_spec.gob:{name}_impl.gob",
+		"pair the files matching `left:right`, where {name} stands for the model name")
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(),
+			"usage: pisim batch-dir [flags] dir\n\n"+
+				"Compares the pairs of LTSs in dir named by -pattern, taking the same flags\n"+
+				"as a comparison of two files. Each comparison runs in dir/results/{name},\n"+
+				"which receives its coloured LTSs as {name}-left.dot and {name}-right.dot,\n"+
+				"the files named by output flags, its -result-json object in result.json\n"+
+				"and its messages in output.txt. Give input files named by flags, such as\n"+
+				"-prop, by absolute path.")
+		flag.PrintDefaults()
+	}
+	args = parseArgs(flag.CommandLine, args)
+	check(loadConfig(flag.CommandLine))
+	if len(args) != 1 {
+		flag.CommandLine.Usage()
+		os.Exit(2)
+	}
+	pats, err := parsePairPattern(*pattern)
+	check(err)
+	dir, err := filepath.Abs(args[0])
+	check(err)
+	names, unpaired, err := discoverPairs(dir, pats)
+	check(err)
+	for _, file := range unpaired {
+		log.Printf("warning: %s has no counterpart under -pattern %s", file, *pattern)
+	}
+	if len(names) == 0 {
+		check(fmt.Errorf("no pairs of files in %s match -pattern %s", args[0], *pattern))
+	}
+	self, err := os.Executable()
+	check(err)
+	// The flags, from the command line or the config file, carry over to the
+	// comparisons, which do not see the config file from their directories.
+	var flags []string
+	flag.CommandLine.Visit(func(f *flag.Flag) {
+		if f.Name != "pattern" && f.Name != "config" && f.Name != "result-json" {
+			flags = append(flags, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+	flags = append(flags, "-result-json")
+
+	failed := false
+	for _, name := range names {
+		out := filepath.Join(dir, batchResults, name)
+		check(os.MkdirAll(out, 0755))
+		resultFile := filepath.Join(out, "result.json")
+		stdout, err := os.Create(resultFile)
+		check(err)
+		stderr, err := os.Create(filepath.Join(out, "output.txt"))
+		check(err)
+		args := append(flags[:len(flags):len(flags)],
+			filepath.Join(dir, pats[LeftSide].file(name)),
+			filepath.Join(dir, pats[RightSide].file(name)),
+			name)
+		cmd := exec.Command(self, args...)
+		cmd.Dir = out
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		err = cmd.Run()
+		closeFile(stdout)
+		closeFile(stderr)
+		verdict := batchVerdict(resultFile, err)
+		failed = failed || verdict != result.Equivalent
+		fmt.Printf("%s: %s\n", name, verdict)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePairPattern(t *testing.T) {
+	pats, err := parsePairPattern("m_{name}.gob:{name}_impl.aut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [2]namePattern{{"m_", ".gob"}, {"", "_impl.aut"}}; pats != want {
+		t.Errorf("got %v, want %v", pats, want)
+	}
+	for _, test := range []struct {
+		file, name string
+		ok         bool
+	}{
+		{"m_x.gob", "x", true},
+		{"m_x_y.gob", "x_y", true},
+		{"m_.gob", "", false},
+		{"x.gob", "", false},
+		{"m_x.aut", "", false},
+	} {
+		if name, ok := pats[LeftSide].match(test.file); name != test.name || ok != test.ok {
+			t.Errorf("%s: got %q and %v, want %q and %v", test.file, name, ok, test.name, test.ok)
+		}
+	}
+	for _, bad := range []string{
+		"{name}.gob",
+		"{name}.gob:{name}.aut:{name}.json",
+		"spec.gob:{name}_impl.gob",
+		"{name}{name}.gob:{name}_impl.gob",
+		"specs/{name}.gob:{name}_impl.gob",
+		"{name}.gob:{name}.gob",
+	} {
+		if _, err := parsePairPattern(bad); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+// batchTree writes the files of a batch directory: models a and b with both
+// sides, a bisimilar and b not, model c with only a specification, and a
+// directory and a file that match no pattern.
+func batchTree(t *testing.T) string {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a_spec.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n")
+	writeTestFile(t, dir, "a_impl.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	writeTestFile(t, dir, "b_spec.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	writeTestFile(t, dir, "b_impl.aut", "des (0, 1, 2)\n(0, \"2 2\", 1)\n")
+	writeTestFile(t, dir, "c_spec.aut", "des (0, 0, 1)\n")
+	writeTestFile(t, dir, "notes.txt", "not a model")
+	if err := os.Mkdir(filepath.Join(dir, "d_spec.aut"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestDiscoverPairs(t *testing.T) {
+	dir := batchTree(t)
+	pats, err := parsePairPattern("{name}_spec.aut:{name}_impl.aut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, unpaired, err := discoverPairs(dir, pats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %q, want %q", names, want)
+	}
+	if want := []string{"c_spec.aut"}; !reflect.DeepEqual(unpaired, want) {
+		t.Errorf("unpaired %q, want %q", unpaired, want)
+	}
+	if _, _, err := discoverPairs(filepath.Join(dir, "missing"), pats); err == nil {
+		t.Error("no error for a missing directory")
+	}
+}
+
+func TestBatchDir(t *testing.T) {
+	dir := batchTree(t)
+	stdout, stderr, code := runPisim(t, dir, "batch-dir", "-pattern", "{name}_spec.aut:{name}_impl.aut", dir)
+	if code != 1 {
+		t.Errorf("status %d, want 1: %s", code, stderr)
+	}
+	if want := "a: equivalent\nb: not equivalent\n"; stdout != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+	if !strings.Contains(stderr, "warning: c_spec.aut has no counterpart") {
+		t.Errorf("no warning of c_spec.aut in %q", stderr)
+	}
+	for _, file := range []string{"a/a-left.dot", "a/a-right.dot", "a/result.json", "a/output.txt",
+		"b/result.json", "b/output.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, batchResults, file)); err != nil {
+			t.Error(err)
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, batchResults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("%d result directories, want 2", len(entries))
+	}
+
+	if _, stderr, code := runPisim(t, dir, "batch-dir", "-pattern", "{name}.gob:{name}.json", dir); code == 0 ||
+		!strings.Contains(stderr, "no pairs of files") {
+		t.Errorf("status %d and %q, want an error of no pairs", code, stderr)
+	}
+}
@@ -0,0 +1,419 @@
+// Package bisim decides strong, weak, delay and η-bisimilarity of pifra LTSs,
+// as pisim does, for use from other programs. It keeps no global state: every
+// call is configured by its Options alone, and calls may run concurrently.
+//
+// CheckBisimilar and Compare check two LTSs, and Minimize reduces one to its
+// quotient. A Refiner drives the refinement of the partition of their states
+// one split at a time, for tests and debugging.
+package bisim
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/result"
+)
+
+// Options configures the comparisons. The zero value checks strong
+// bisimilarity of the LTSs as given, as pisim does without flags.
+type Options struct {
+	// Algorithm selects the refinement of strong, weak and delay
+	// bisimilarity: "" or "ks" for Kanellakis-Smolka, "hopcroft" for
+	// Hopcroft's algorithm, which refines deterministic LTSs in a single
+	// step and others as "ks" does, or "levelwise" to split every block at
+	// each step, against the partition of the previous level.
+	// η-bisimilarity always uses its own refinement.
+	Algorithm string
+	// Bound stops a level-wise refinement after that many levels, deciding
+	// k-step bisimilarity for k = Bound, as pisim's -bounded does. Zero
+	// refines until the partition is stable.
+	Bound int
+	// Equivalence is strong, the default, weak, delay or eta.
+	Equivalence string
+	// Hide lists glob patterns of labels to treat as silent, as pisim's
+	// -hide does.
+	Hide []string
+	// DropSelfLoops lists glob patterns of the self-loops to drop before
+	// comparing, as pisim's -drop-self-loops does.
+	DropSelfLoops []string
+	// Silent, if set, tells which labels are silent, besides tau and the
+	// labels Hide hides.
+	Silent func(pifra.Label) bool
+	// Graded counts the moves of a state into each block rather than
+	// whether there is one, as pisim's -graded does.
+	Graded bool
+	// NoFingerprints compares the blocks states reach in full, rather than
+	// their hashes first.
+	NoFingerprints bool
+	// UpTo groups the states whose moves are identical up to the groups of
+	// their destinations before refining, as pisim's -up-to does: the states
+	// of a group are bisimilar, and refinement compares each group once.
+	UpTo bool
+	// Colour, if set, seeds the refinement: states start in the same block
+	// only if they have the same colour. Colours must not separate
+	// equivalent states.
+	Colour func(State) uint64
+	// Progress, if set, is called with the progress of the refinement, at
+	// most every 200ms and once when it ends.
+	Progress func(Progress)
+	// Split, if set, is called after every split of a block.
+	Split func(Split)
+	// Prune drops the states the initial state cannot reach first.
+	Prune bool
+	// Spill, if positive, keeps the moves of the states and the members of
+	// the blocks in temporary files rather than in memory, read back Spill
+	// values at a time, as pisim's -low-mem does. Refinement is then slower,
+	// and Hopcroft's algorithm refines as "ks" does. A Refiner's files stay
+	// until it is closed.
+	Spill int
+}
+
+// algorithms are the values of Options.Algorithm.
+var algorithms = map[string]bool{
+	"":          true,
+	"ks":        true,
+	"hopcroft":  true,
+	"levelwise": true,
+}
+
+func (o Options) equivalence() string {
+	if o.Equivalence == "" {
+		return "strong"
+	}
+	return o.Equivalence
+}
+
+func (o Options) validate() error {
+	if !algorithms[o.Algorithm] {
+		return fmt.Errorf("unknown algorithm %q", o.Algorithm)
+	}
+	if o.Bound < 0 {
+		return fmt.Errorf("negative bound %d", o.Bound)
+	}
+	if o.Bound > 0 && o.Algorithm != "levelwise" {
+		return fmt.Errorf("a bound needs the levelwise algorithm")
+	}
+	if o.UpTo && o.Graded {
+		return fmt.Errorf("refinement up to bisimilarity cannot count moves")
+	}
+	if !bisimilarities[o.equivalence()] {
+		return fmt.Errorf("equivalence %q is not decided by partition refinement", o.Equivalence)
+	}
+	for _, patterns := range [][]string{o.Hide, o.DropSelfLoops} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("bad pattern %q", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// silent reports whether label is silent under o: tau, or a label Silent
+// accepts. The labels Hide hides are replaced by tau before refinement.
+func (o Options) silent(label pifra.Label) bool {
+	return isTau(label) || o.Silent != nil && o.Silent(label)
+}
+
+// CheckBisimilar reports whether the initial states of left and right are
+// equivalent under opts. It fails with the error of ctx if ctx is done first.
+func CheckBisimilar(ctx context.Context, left, right pifra.Lts, opts Options) (bool, error) {
+	r, err := NewRefiner(left, right, opts)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	part, err := r.Run(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !part.initialsSplit(), nil
+}
+
+// Compare checks left and right as CheckBisimilar does, and returns the
+// Result pisim would print for them, with a bisimulation relating the initial
+// states for a positive verdict, or a witness for a negative one.
+func Compare(ctx context.Context, left, right pifra.Lts, opts Options) (Result, error) {
+	start := time.Now()
+	r, err := NewRefiner(left, right, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	defer r.Close()
+	part, err := r.Run(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Equivalence: opts.equivalence(),
+		Stats: Stats{
+			Left:    result.Side{States: len(left.States), Transitions: len(left.Transitions)},
+			Right:   result.Side{States: len(right.States), Transitions: len(right.Transitions)},
+			Classes: part.size(),
+		},
+	}
+	if part.initialsSplit() {
+		res.Verdict = NotEquivalent
+		res.Witness = r.witness()
+	} else {
+		res.Verdict = Equivalent
+		res.Relation = r.relation()
+	}
+	if err := r.Err(); err != nil {
+		return Result{}, err
+	}
+	res.Stats.Elapsed = time.Since(start)
+	return res, nil
+}
+
+// Minimize returns the quotient of lts under the equivalence of opts, with
+// the class of the initial state as state 0 and the other classes numbered in
+// the order of their smallest state. Its transitions keep the labels of lts.
+func Minimize(ctx context.Context, lts pifra.Lts, opts Options) (pifra.Lts, error) {
+	if err := opts.validate(); err != nil {
+		return pifra.Lts{}, err
+	}
+	prepared, observed := opts.prepare(lts)
+	r, err := newRefiner(observed, pifra.Lts{}, opts)
+	if err != nil {
+		return pifra.Lts{}, err
+	}
+	defer r.Close()
+	part, err := r.Run(ctx)
+	if err != nil {
+		return pifra.Lts{}, err
+	}
+	class := map[int]int{part.blockOf[r.g.initial[Left]]: 0}
+	classOf := make(map[int]int)
+	quotient := pifra.Lts{
+		States:         make(map[int]pifra.Configuration),
+		RegSizeReached: make(map[int]bool),
+	}
+	for _, id := range stateIDs(prepared) {
+		block := part.blockOf[r.g.index[State{Left, id}]]
+		c, ok := class[block]
+		if !ok {
+			c = len(class)
+			class[block] = c
+		}
+		classOf[id] = c
+		if _, ok := quotient.States[c]; !ok {
+			quotient.States[c] = prepared.States[id]
+		}
+		if prepared.RegSizeReached[id] {
+			quotient.RegSizeReached[c] = true
+		}
+	}
+	seen := make(map[pifra.Transition]bool)
+	for _, trans := range prepared.Transitions {
+		trans.Source, trans.Destination = classOf[trans.Source], classOf[trans.Destination]
+		if !seen[trans] {
+			seen[trans] = true
+			quotient.Transitions = append(quotient.Transitions, trans)
+		}
+	}
+	return quotient, nil
+}
+
+// relation returns the pairs of left and right states sharing a block that
+// are reachable from the initial pair by moves with equal labels. This is a
+// bisimulation once the partition is stable and relates the initial states.
+func (r *Refiner) relation() Relation {
+	rel := make(Relation)
+	type pair struct{ s, t int }
+	queue := []pair{{r.g.initial[Left], r.g.initial[Right]}}
+	seen := map[pair]bool{queue[0]: true}
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		rel[Pair{r.g.states[p.s].ID, r.g.states[p.t].ID}] = struct{}{}
+		for _, ms := range r.g.succ(p.s) {
+			for _, mt := range r.g.moves(p.t, ms.action) {
+				next := pair{ms.dst, mt.dst}
+				if r.blockOf[next.s] == r.blockOf[next.t] && !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return rel
+}
+
+// witness explains why the initial states are in different blocks of the
+// stable partition.
+func (r *Refiner) witness() *Witness {
+	w := new(Witness)
+	var used [2]map[int]bool
+	for side := range used {
+		used[side] = make(map[int]bool)
+	}
+	for s := range r.g.states {
+		for _, m := range r.g.succ(s) {
+			used[r.g.states[s].Side][m.action] = true
+		}
+	}
+	for action, label := range r.g.labels {
+		switch {
+		case used[Left][action] && !used[Right][action]:
+			w.OnlyLeft = append(w.OnlyLeft, label.PrettyPrintGraph())
+		case used[Right][action] && !used[Left][action]:
+			w.OnlyRight = append(w.OnlyRight, label.PrettyPrintGraph())
+		}
+	}
+	s, t := r.g.states[r.g.initial[Left]], r.g.states[r.g.initial[Right]]
+	if action, ok := r.Distinction(s, t); ok {
+		w.Action = action.PrettyPrintGraph()
+	}
+	for _, action := range r.Trace(s, t) {
+		w.Trace = append(w.Trace, action.PrettyPrintGraph())
+	}
+	return w
+}
+
+// Distinction returns an action by which s and t reach different blocks of
+// the current partition, preferring visible actions, since after saturation
+// every state silently reaches its own block. Under η-bisimilarity it returns
+// an action in the η-signature of exactly one of them.
+func (r *Refiner) Distinction(s, t State) (pifra.Label, bool) {
+	i, ok := r.g.index[s]
+	j, ok2 := r.g.index[t]
+	if !ok || !ok2 {
+		return pifra.Label{}, false
+	}
+	distinction := r.distinction
+	if r.eta {
+		distinction = r.etaDistinction
+	}
+	action, ok := distinction(i, j)
+	if !ok {
+		return pifra.Label{}, false
+	}
+	return r.g.labels[action], true
+}
+
+// Trace follows distinguishing actions from s and t: at each step the side
+// reaching a block the other cannot moves into it, and the other takes its
+// first move by the same action, until the other cannot move or a pair
+// repeats. Under η-bisimilarity the trace is the action Distinction returns.
+func (r *Refiner) Trace(s, t State) []pifra.Label {
+	i, ok := r.g.index[s]
+	j, ok2 := r.g.index[t]
+	if !ok || !ok2 {
+		return nil
+	}
+	if r.eta {
+		if action, ok := r.etaDistinction(i, j); ok {
+			return []pifra.Label{r.g.labels[action]}
+		}
+		return nil
+	}
+	var trace []pifra.Label
+	type pair struct{ s, t int }
+	for seen := make(map[pair]bool); !seen[pair{i, j}]; {
+		seen[pair{i, j}] = true
+		action, ok := r.distinction(i, j)
+		if !ok {
+			break
+		}
+		trace = append(trace, r.g.labels[action])
+		if next, ok := r.unmatched(i, j, action); ok {
+			i, j = next, r.firstMove(j, action)
+		} else if next, ok := r.unmatched(j, i, action); ok {
+			i, j = r.firstMove(i, action), next
+		} else {
+			break
+		}
+		if i < 0 || j < 0 {
+			break
+		}
+	}
+	return trace
+}
+
+// Destinations returns the sorted IDs of the blocks of the current partition
+// that s reaches by action, as a set, or as a multiset counting each move
+// under Options.Graded. The moves are those refined, after saturation.
+func (r *Refiner) Destinations(s State, action pifra.Label) []int {
+	i, ok := r.g.index[s]
+	if !ok {
+		return nil
+	}
+	n := sort.Search(len(r.g.labels), func(n int) bool { return !LabelLess(r.g.labels[n], action) })
+	if n == len(r.g.labels) || r.g.labels[n] != action {
+		return nil
+	}
+	return r.destinations(i, n)
+}
+
+// distinction returns an action by which s and t reach different blocks,
+// preferring visible actions.
+func (r *Refiner) distinction(s, t int) (int, bool) {
+	for _, silent := range []bool{false, true} {
+		for action, label := range r.g.labels {
+			if r.opts.silent(label) == silent &&
+				!equalInts(r.destinations(s, action), r.destinations(t, action)) {
+				return action, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// etaDistinction returns an action in the η-signature of exactly one of s
+// and t, preferring visible actions.
+func (r *Refiner) etaDistinction(s, t int) (int, bool) {
+	in := func(sig []sigEntry) map[sigEntry]bool {
+		set := make(map[sigEntry]bool, len(sig))
+		for _, e := range sig {
+			set[e] = true
+		}
+		return set
+	}
+	ss, ts := in(r.signature(s)), in(r.signature(t))
+	var diff []int
+	for _, pair := range [][2]map[sigEntry]bool{{ss, ts}, {ts, ss}} {
+		for e := range pair[0] {
+			if !pair[1][e] {
+				diff = append(diff, e.action)
+			}
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		vi, vj := !r.opts.silent(r.g.labels[diff[i]]), !r.opts.silent(r.g.labels[diff[j]])
+		if vi != vj {
+			return vi
+		}
+		return diff[i] < diff[j]
+	})
+	if len(diff) == 0 {
+		return 0, false
+	}
+	return diff[0], true
+}
+
+// unmatched returns the smallest state s moves to by action in a block that
+// t cannot reach by action.
+func (r *Refiner) unmatched(s, t, action int) (int, bool) {
+	reached := make(map[int]bool)
+	for _, m := range r.g.moves(t, action) {
+		reached[r.blockOf[m.dst]] = true
+	}
+	for _, m := range r.g.moves(s, action) {
+		if !reached[r.blockOf[m.dst]] {
+			return m.dst, true
+		}
+	}
+	return 0, false
+}
+
+// firstMove returns the smallest state s moves to by action, or -1.
+func (r *Refiner) firstMove(s, action int) int {
+	if moves := r.g.moves(s, action); len(moves) > 0 {
+		return moves[0].dst
+	}
+	return -1
+}
@@ -0,0 +1,326 @@
+package bisim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/ltsfile"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// permuted returns lts with its states renamed by a random permutation that
+// keeps the initial state 0.
+func permuted(r *rand.Rand, lts pifra.Lts) pifra.Lts {
+	perm := r.Perm(len(lts.States) - 1)
+	name := func(state int) int {
+		if state == 0 {
+			return 0
+		}
+		return perm[state-1] + 1
+	}
+	out := makeLTS(0)
+	for state, conf := range lts.States {
+		out.States[name(state)] = conf
+	}
+	for _, t := range lts.Transitions {
+		out.Transitions = append(out.Transitions, trans(name(t.Source), t.Label, name(t.Destination)))
+	}
+	return out
+}
+
+// randomPair returns two small random LTSs with silent moves, isomorphic one
+// time in three, so that both verdicts are common.
+func randomPair(r *rand.Rand) (pifra.Lts, pifra.Lts) {
+	left := reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0.3)
+	if r.Intn(3) == 0 {
+		return left, permuted(r, left)
+	}
+	return left, reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0.3)
+}
+
+// relabelled returns lts with the labels f maps, and without the transitions
+// f drops.
+func relabelled(lts pifra.Lts, f func(pifra.Transition) (pifra.Label, bool)) pifra.Lts {
+	out := lts
+	out.Transitions = nil
+	for _, t := range lts.Transitions {
+		if label, ok := f(t); ok {
+			t.Label = label
+			out.Transitions = append(out.Transitions, t)
+		}
+	}
+	return out
+}
+
+func hideB(t pifra.Transition) (pifra.Label, bool) {
+	if t.Label == b {
+		return tauLabel, true
+	}
+	return t.Label, true
+}
+
+func dropLoops(t pifra.Transition) (pifra.Label, bool) {
+	return t.Label, t.Source != t.Destination
+}
+
+func TestOptions(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		opts   Options
+		decide func(left, right pifra.Lts) bool
+	}{
+		{"zero", Options{}, reference.Strong},
+		{"ks", Options{Algorithm: "ks", Equivalence: "strong"}, reference.Strong},
+		{"hopcroft", Options{Algorithm: "hopcroft"}, reference.Strong},
+		{"levelwise", Options{Algorithm: "levelwise"}, reference.Strong},
+		{"up to", Options{UpTo: true}, reference.Strong},
+		{"no fingerprints", Options{NoFingerprints: true}, reference.Strong},
+		{"weak levelwise", Options{Equivalence: "weak", Algorithm: "levelwise"}, reference.Weak},
+		{"spilled", Options{Spill: 3}, reference.Strong},
+		{"spilled hopcroft", Options{Algorithm: "hopcroft", Spill: 1}, reference.Strong},
+		{"spilled weak", Options{Equivalence: "weak", Spill: 2}, reference.Weak},
+		{"weak", Options{Equivalence: "weak"}, reference.Weak},
+		{"delay", Options{Equivalence: "delay"}, reference.Delay},
+		{"prune", Options{Prune: true}, reference.Strong},
+		{"weak hiding", Options{Equivalence: "weak", Hide: []string{"2 *"}}, func(l, r pifra.Lts) bool {
+			return reference.Weak(relabelled(l, hideB), relabelled(r, hideB))
+		}},
+		{"dropping self-loops", Options{DropSelfLoops: []string{"*"}}, func(l, r pifra.Lts) bool {
+			return reference.Strong(relabelled(l, dropLoops), relabelled(r, dropLoops))
+		}},
+		{"delay pruning and hiding", Options{Equivalence: "delay", Prune: true, Hide: []string{"2 *"}},
+			func(l, r pifra.Lts) bool {
+				return reference.Delay(relabelled(l, hideB), relabelled(r, hideB))
+			}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			var verdicts [2]int
+			for i := 0; i < 300; i++ {
+				left, right := randomPair(r)
+				want := test.decide(left, right)
+				got, err := CheckBisimilar(context.Background(), left, right, test.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("pair %d: CheckBisimilar = %v, reference says %v\nleft: %v\nright: %v",
+						i, got, want, left.Transitions, right.Transitions)
+				}
+				if want {
+					verdicts[1]++
+				} else {
+					verdicts[0]++
+				}
+			}
+			if verdicts[0] == 0 || verdicts[1] == 0 {
+				t.Errorf("%d negative and %d positive verdicts, want both", verdicts[0], verdicts[1])
+			}
+		})
+	}
+}
+
+// TestEtaBetweenStrongAndWeak checks η-bisimilarity against the reference
+// equivalences it lies between: strongly bisimilar LTSs are η-bisimilar, and
+// η-bisimilar ones are weakly bisimilar. pisim's tests check it exactly
+// against its own refinement.
+func TestEtaBetweenStrongAndWeak(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var coarser int
+	for i := 0; i < 300; i++ {
+		left, right := randomPair(r)
+		eta, err := CheckBisimilar(context.Background(), left, right, Options{Equivalence: "eta"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		strong, weak := reference.Strong(left, right), reference.Weak(left, right)
+		if strong && !eta || eta && !weak {
+			t.Fatalf("pair %d: strong %v, η %v, weak %v\nleft: %v\nright: %v",
+				i, strong, eta, weak, left.Transitions, right.Transitions)
+		}
+		if eta && !strong {
+			coarser++
+		}
+	}
+	if coarser == 0 {
+		t.Error("no pair η but not strongly bisimilar")
+	}
+}
+
+func TestOptionErrors(t *testing.T) {
+	for _, opts := range []Options{
+		{Algorithm: "paige-tarjan"},
+		{Equivalence: "trace"},
+		{Hide: []string{"["}},
+		{DropSelfLoops: []string{"["}},
+	} {
+		if _, err := CheckBisimilar(context.Background(), chain(2), chain(2), opts); err == nil {
+			t.Errorf("CheckBisimilar accepted %+v", opts)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var reports []Progress
+	opts := Options{Progress: func(p Progress) { reports = append(reports, p) }}
+	if _, err := CheckBisimilar(context.Background(), chain(4), chain(4), opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) == 0 {
+		t.Fatal("no progress reported")
+	}
+	want := Progress{Steps: 3, Blocks: 4, States: 8, Splits: 3, Done: true}
+	if last := reports[len(reports)-1]; last != want {
+		t.Errorf("last report %+v, want %+v", last, want)
+	}
+
+	// A report just made holds back the others but the last.
+	reports = nil
+	r, err := NewRefiner(chain(4), chain(4), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.reported = time.Now()
+	for r.Step() {
+	}
+	if len(reports) != 1 || !reports[0].Done {
+		t.Errorf("got reports %+v, want only the last one", reports)
+	}
+}
+
+// TestConcurrentCalls runs comparisons with different options at once, which
+// the race detector checks share no state.
+func TestConcurrentCalls(t *testing.T) {
+	left := makeLTS(3, trans(0, tauLabel, 1), trans(1, a, 2))
+	right := makeLTS(2, trans(0, a, 1))
+	want := map[string]bool{"strong": false, "weak": true, "delay": true, "eta": true}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for eq, bisimilar := range want {
+			wg.Add(1)
+			go func(eq string, bisimilar bool) {
+				defer wg.Done()
+				got, err := CheckBisimilar(context.Background(), left, right, Options{Equivalence: eq})
+				if err != nil || got != bisimilar {
+					t.Errorf("%s: got %v, %v, want %v", eq, got, err, bisimilar)
+				}
+			}(eq, bisimilar)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCompare(t *testing.T) {
+	left := makeLTS(4, trans(0, a, 1), trans(1, b, 2), trans(1, c, 3))
+	right := makeLTS(5, trans(0, a, 1), trans(0, a, 2), trans(1, b, 3), trans(2, c, 4))
+	res, err := Compare(context.Background(), left, right, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Witness{Action: "1 1", Trace: []string{"1 1", "3 1"}}
+	if res.Verdict != NotEquivalent || !reflect.DeepEqual(res.Witness, want) || res.Relation != nil {
+		t.Errorf("got %v %+v %v, want not equivalent and %+v", res.Verdict, res.Witness, res.Relation, want)
+	}
+	if res.Stats.Classes != 6 || res.Stats.Left.States != 4 || res.Stats.Right.Transitions != 4 {
+		t.Errorf("unexpected stats %+v", res.Stats)
+	}
+
+	right = makeLTS(5, trans(0, a, 1), trans(0, a, 2), trans(1, b, 3), trans(1, c, 4), trans(2, b, 3), trans(2, c, 4))
+	res, err = Compare(context.Background(), left, right, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRel := Relation{{0, 0}: {}, {1, 1}: {}, {1, 2}: {}, {2, 3}: {}, {3, 4}: {}}
+	if res.Verdict != Equivalent || res.Witness != nil || !reflect.DeepEqual(res.Relation, wantRel) {
+		t.Errorf("got %v %+v %v, want equivalent by %v", res.Verdict, res.Witness, res.Relation, wantRel)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		lts    pifra.Lts
+		opts   Options
+		states int
+		trans  int
+	}{
+		{"strong", makeLTS(5, trans(0, a, 1), trans(0, a, 2), trans(1, b, 3), trans(2, b, 4)), Options{}, 3, 2},
+		{"weak", makeLTS(3, trans(0, tauLabel, 1), trans(1, a, 2)), Options{Equivalence: "weak"}, 2, 2},
+		{"pruned", makeLTS(4, trans(0, a, 1), trans(2, b, 3)), Options{Prune: true}, 2, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := Minimize(context.Background(), test.lts, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(q.States) != test.states || len(q.Transitions) != test.trans {
+				t.Errorf("quotient of %d states and %v, want %d states and %d transitions",
+					len(q.States), q.Transitions, test.states, test.trans)
+			}
+			if _, ok := q.States[0]; !ok {
+				t.Error("the quotient has no initial state 0")
+			}
+			ok, err := CheckBisimilar(context.Background(), test.lts, q, test.opts)
+			if err != nil || !ok {
+				t.Errorf("the quotient is not equivalent to the LTS: %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestLoadLTS(t *testing.T) {
+	lts := makeLTS(3, trans(0, a, 1), trans(1, tauLabel, 2))
+	var plain, headed bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(lts); err != nil {
+		t.Fatal(err)
+	}
+	if err := ltsfile.WriteHeader(&headed); err != nil {
+		t.Fatal(err)
+	}
+	headed.Write(plain.Bytes())
+	for name, buf := range map[string]*bytes.Buffer{"pifra": &plain, "pisim": &headed} {
+		got, err := LoadLTS(buf)
+		if err != nil {
+			t.Fatalf("%s gob: %v", name, err)
+		}
+		if !reflect.DeepEqual(got.Transitions, lts.Transitions) || len(got.States) != len(lts.States) {
+			t.Errorf("%s gob decoded as %+v, want %+v", name, got, lts)
+		}
+	}
+	if _, err := LoadLTS(bytes.NewBufferString("des (0, 0, 1)\n")); err == nil {
+		t.Error("LoadLTS decoded an Aldebaran file as a gob")
+	}
+}
+
+// TestLoadLTSFromGzip decodes through a reader wrapping another, as for a
+// compressed file or a request body.
+func TestLoadLTSFromGzip(t *testing.T) {
+	lts := chain(3)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(lts); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadLTS(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Transitions, lts.Transitions) {
+		t.Errorf("decoded %v, want %v", got.Transitions, lts.Transitions)
+	}
+}
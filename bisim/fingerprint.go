@@ -0,0 +1,37 @@
+package bisim
+
+// fingerprint hashes the IDs of the blocks s reaches by action into 64 bits,
+// so that states with different fingerprints are known to reach different
+// blocks. It reads the moves directly, which is much cheaper than collecting
+// and sorting the destinations.
+func (r *Refiner) fingerprint(s, action int) uint64 {
+	var fp uint64
+	for _, m := range r.g.moves(s, action) {
+		fp = addFingerprint(fp, r.blockOf[m.dst], r.opts.Graded)
+	}
+	return fp
+}
+
+// addFingerprint adds the block ID id to the fingerprint fp. The fingerprint
+// of a set is a Bloom filter with one bit per block, which ignores repeated
+// blocks as destinations does; when graded, where repeats count, it is the
+// sum of the hashes instead. Equal fingerprints prove nothing, and the
+// destinations must then be compared.
+func addFingerprint(fp uint64, id int, graded bool) uint64 {
+	h := mix64(uint64(id))
+	if graded {
+		return fp + h
+	}
+	return fp | 1<<(h>>58)
+}
+
+// mix64 is the finaliser of SplitMix64, which spreads consecutive block IDs
+// over all bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,155 @@
+package bisim
+
+import "sort"
+
+// stepHopcroft refines the partition of deterministic LTSs to the coarsest
+// stable one in a single step, by Hopcroft's algorithm for partial automata,
+// and reports whether it split a block. Over deterministic LTSs a state has
+// at most one move per action, so two states of a block stay together
+// exactly if, for every action, both or neither move into the splitter; the
+// "process the smaller half" rule then bounds the work by O(m log n) for m
+// transitions and n states. Minimising both sides and comparing the results
+// up to isomorphism would decide the same: refining their union, as here,
+// puts the initial states in one block exactly if the minimal automata are
+// isomorphic.
+func (r *Refiner) stepHopcroft() bool {
+	r.work.Hopcroft = true
+	n := len(r.g.states)
+
+	// Incoming moves of every state.
+	inStart := make([]int, n+1)
+	for s := range r.g.states {
+		for _, m := range r.g.succ(s) {
+			inStart[m.dst+1]++
+		}
+	}
+	for i := 0; i < n; i++ {
+		inStart[i+1] += inStart[i]
+	}
+	inAction := make([]int, inStart[n])
+	inSource := make([]int, inStart[n])
+	next := append([]int(nil), inStart[:n]...)
+	for s := range r.g.states {
+		for _, m := range r.g.succ(s) {
+			inAction[next[m.dst]] = m.action
+			inSource[next[m.dst]] = s
+			next[m.dst]++
+		}
+	}
+
+	// Blocks are ranges first..end of elems, whose marked members come
+	// first while a splitter is processed; ids holds their block IDs.
+	elems := make([]int, 0, n)
+	loc := make([]int, n)
+	blockOf := make([]int, n)
+	var first, end, marked, ids []int
+	var pending []int
+	var inPending []bool
+	for b, id := range r.blockIDs() {
+		first = append(first, len(elems))
+		for _, s := range r.members.get(id) {
+			loc[s] = len(elems)
+			blockOf[s] = b
+			elems = append(elems, s)
+		}
+		end = append(end, len(elems))
+		marked = append(marked, 0)
+		ids = append(ids, id)
+		// Every block of a seeded partition may split the others.
+		pending = append(pending, b)
+		inPending = append(inPending, true)
+	}
+
+	initial := len(first)
+
+	type inMove struct {
+		action, src int
+	}
+	var moves []inMove
+	var touched []int
+	for len(pending) > 0 {
+		splitter := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		inPending[splitter] = false
+		moves = moves[:0]
+		for _, s := range elems[first[splitter]:end[splitter]] {
+			for i := inStart[s]; i < inStart[s+1]; i++ {
+				moves = append(moves, inMove{inAction[i], inSource[i]})
+			}
+		}
+		sort.Slice(moves, func(i, j int) bool {
+			if moves[i].action != moves[j].action {
+				return moves[i].action < moves[j].action
+			}
+			return moves[i].src < moves[j].src
+		})
+		for lo := 0; lo < len(moves); {
+			hi := lo
+			for hi < len(moves) && moves[hi].action == moves[lo].action {
+				hi++
+			}
+			touched = touched[:0]
+			for _, m := range moves[lo:hi] {
+				b := blockOf[m.src]
+				if marked[b] == 0 {
+					touched = append(touched, b)
+				}
+				// Swap m.src with the first unmarked member of its block.
+				pos := first[b] + marked[b]
+				other := elems[pos]
+				elems[pos], elems[loc[m.src]] = m.src, other
+				loc[other], loc[m.src] = loc[m.src], pos
+				marked[b]++
+			}
+			for _, b := range touched {
+				split := first[b] + marked[b]
+				marked[b] = 0
+				if split == end[b] {
+					continue
+				}
+				nb := len(first)
+				first = append(first, first[b])
+				end = append(end, split)
+				marked = append(marked, 0)
+				inPending = append(inPending, false)
+				ids = append(ids, r.nextID)
+				r.nextID++
+				first[b] = split
+				for _, s := range elems[first[nb]:end[nb]] {
+					blockOf[s] = nb
+				}
+				r.work.Splits++
+				if r.opts.Split != nil {
+					r.opts.Split(Split{
+						Step:   r.work.Steps + 1,
+						Block:  ids[b],
+						Parts:  []Block{r.block(ids[nb], elems[first[nb]:end[nb]])},
+						Action: r.g.labels[moves[lo].action],
+					})
+				}
+				switch {
+				case inPending[b] || end[nb]-first[nb] <= end[b]-first[b]:
+					pending = append(pending, nb)
+					inPending[nb] = true
+				default:
+					pending = append(pending, b)
+					inPending[b] = true
+				}
+			}
+			lo = hi
+		}
+	}
+
+	members := make(memMembers, len(first))
+	for b := range first {
+		states := append([]int(nil), elems[first[b]:end[b]]...)
+		sort.Ints(states)
+		members[ids[b]] = states
+		for _, s := range states {
+			r.blockOf[s] = ids[b]
+		}
+	}
+	r.setMembers(members)
+	r.queue, r.queued = nil, make(map[int]bool)
+	return len(first) > initial
+}
@@ -0,0 +1,69 @@
+package bisim
+
+// stepLevel refines the partition by one level: it splits every block by the
+// level signatures of its states, the blocks of the previous level they
+// reach by each action, so that after level k two states share a block
+// exactly if they are k-step bisimilar. It reports whether a block split,
+// and stops after Options.Bound levels.
+func (r *Refiner) stepLevel() bool {
+	if r.opts.Bound > 0 && r.work.Steps == r.opts.Bound {
+		r.bounded = !r.levelStable()
+		return false
+	}
+	keys := r.levelKeys()
+	split := false
+	for _, id := range r.blockIDs() {
+		if r.splitBy(id, func(s int) string { return keys[s] }) {
+			split = true
+		}
+	}
+	if split {
+		r.levels = append(r.levels, r.members.len())
+	}
+	return split
+}
+
+// levelStable tells whether the next level would split no block.
+func (r *Refiner) levelStable() bool {
+	keys := r.levelKeys()
+	for _, id := range r.members.ids() {
+		states := r.members.get(id)
+		for _, s := range states[1:] {
+			if keys[s] != keys[states[0]] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// levelKeys returns the level signature of every state as a key: the sorted
+// pairs of an action and a block the state reaches by it, as a set, or as a
+// multiset counting each move under Options.Graded.
+func (r *Refiner) levelKeys() []string {
+	keys := make([]string, len(r.g.states))
+	var sig []sigEntry
+	for s := range r.g.states {
+		sig = sig[:0]
+		for _, m := range r.g.succ(s) {
+			sig = append(sig, sigEntry{m.action, r.blockOf[m.dst]})
+		}
+		sortEntries(sig)
+		if !r.opts.Graded {
+			sig = uniqueEntries(sig)
+		}
+		keys[s] = sigKey(sig)
+	}
+	return keys
+}
+
+// uniqueEntries removes the repeated entries of the sorted sig in place.
+func uniqueEntries(sig []sigEntry) []sigEntry {
+	out := sig[:0]
+	for i, e := range sig {
+		if i == 0 || e != sig[i-1] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,245 @@
+package bisim
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/ltsfile"
+)
+
+// LoadLTS decodes an LTS gob, as written by pifra or pisim, from r.
+func LoadLTS(r io.Reader) (lts pifra.Lts, err error) {
+	br, err := ltsfile.SniffLTS(bufio.NewReader(r))
+	if err != nil {
+		return
+	}
+	header, err := ltsfile.ReadHeader(br)
+	if err != nil {
+		return
+	}
+	if err = gob.NewDecoder(br).Decode(&lts); err != nil {
+		err = ltsfile.ExplainDecodeError(err, header)
+	}
+	return
+}
+
+// tauLabel is the canonical silent action, used for saturated and hidden moves.
+var tauLabel = pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}
+
+func isTau(label pifra.Label) bool {
+	return label.Symbol.Type == pifra.SymbolTypTau
+}
+
+// matchesAny reports whether the printed form of label matches one of the
+// glob patterns.
+func matchesAny(patterns []string, label pifra.Label) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, label.PrettyPrintGraph()); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func symbolLess(a, b pifra.Symbol) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.Value < b.Value
+}
+
+// LabelLess orders labels by their internal representation. Refinement
+// numbers actions in this order, and pisim lists labels in it.
+func LabelLess(a, b pifra.Label) bool {
+	if a.Symbol != b.Symbol {
+		return symbolLess(a.Symbol, b.Symbol)
+	}
+	return symbolLess(a.Symbol2, b.Symbol2)
+}
+
+// initialState returns the initial state of lts: state 0, as pifra numbers
+// it, or the smallest state if there is no state 0.
+func initialState(lts pifra.Lts) int {
+	if _, ok := lts.States[0]; ok || len(lts.States) == 0 {
+		return 0
+	}
+	first := true
+	var initial int
+	for state := range lts.States {
+		if first || state < initial {
+			initial, first = state, false
+		}
+	}
+	return initial
+}
+
+// prepare returns a copy of lts without the self-loops o drops, and without
+// the states its initial state cannot reach if o prunes them. observed is the
+// copy with the labels o hides replaced by tau.
+func (o Options) prepare(lts pifra.Lts) (prepared, observed pifra.Lts) {
+	prepared = pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(lts.States)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for state, conf := range lts.States {
+		prepared.States[state] = conf
+	}
+	// Without self-loops to drop, the transitions are shared rather than
+	// copied, since nothing changes them.
+	prepared.Transitions = lts.Transitions
+	if len(o.DropSelfLoops) > 0 {
+		prepared.Transitions = nil
+		for _, trans := range lts.Transitions {
+			if trans.Source == trans.Destination && matchesAny(o.DropSelfLoops, trans.Label) {
+				continue
+			}
+			prepared.Transitions = append(prepared.Transitions, trans)
+		}
+	}
+	if o.Prune {
+		prepared = pruned(prepared)
+	}
+	observed = prepared
+	if len(o.Hide) > 0 {
+		observed.Transitions = make([]pifra.Transition, len(prepared.Transitions))
+		for i, trans := range prepared.Transitions {
+			if matchesAny(o.Hide, trans.Label) {
+				trans.Label = tauLabel
+			}
+			observed.Transitions[i] = trans
+		}
+	}
+	return prepared, observed
+}
+
+// pruned returns lts without the states its initial state cannot reach.
+func pruned(lts pifra.Lts) pifra.Lts {
+	succs := make(map[int][]int)
+	for _, trans := range lts.Transitions {
+		succs[trans.Source] = append(succs[trans.Source], trans.Destination)
+	}
+	initial := initialState(lts)
+	reached := map[int]bool{initial: true}
+	for stack := []int{initial}; len(stack) > 0; {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, t := range succs[s] {
+			if !reached[t] {
+				reached[t] = true
+				stack = append(stack, t)
+			}
+		}
+	}
+	out := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(reached)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for state, conf := range lts.States {
+		if reached[state] {
+			out.States[state] = conf
+		}
+	}
+	for _, trans := range lts.Transitions {
+		if reached[trans.Source] {
+			out.Transitions = append(out.Transitions, trans)
+		}
+	}
+	return out
+}
+
+// tauClosure maps every state to the states it reaches by silent moves,
+// including itself.
+func tauClosure(lts pifra.Lts, silent func(pifra.Label) bool) map[int][]int {
+	taus := make(map[int][]int)
+	for _, trans := range lts.Transitions {
+		if silent(trans.Label) {
+			taus[trans.Source] = append(taus[trans.Source], trans.Destination)
+		}
+	}
+	closure := make(map[int][]int, len(lts.States))
+	for _, state := range stateIDs(lts) {
+		seen := map[int]bool{state: true}
+		reach := []int{state}
+		for i := 0; i < len(reach); i++ {
+			for _, t := range taus[reach[i]] {
+				if !seen[t] {
+					seen[t] = true
+					reach = append(reach, t)
+				}
+			}
+		}
+		closure[state] = reach
+	}
+	return closure
+}
+
+// Saturate returns lts with its transitions replaced by weak transitions, as
+// weak and delay bisimilarity compare them: s =τ=> t whenever s reaches t by
+// silent moves, and s =a=> t whenever s τ* -a-> τ* t for a visible action a.
+// Without trailing, visible moves are not followed by silent moves,
+// s τ* -a-> t, as in delay bisimulation. silent tells which labels are
+// silent besides tau, and may be nil.
+func Saturate(lts pifra.Lts, trailing bool, silent func(pifra.Label) bool) pifra.Lts {
+	return saturate(lts, trailing, Options{Silent: silent}.silent)
+}
+
+func saturate(lts pifra.Lts, trailing bool, silent func(pifra.Label) bool) pifra.Lts {
+	closure := tauClosure(lts, silent)
+	visible := make(map[int][]pifra.Transition)
+	for _, trans := range lts.Transitions {
+		if !silent(trans.Label) {
+			visible[trans.Source] = append(visible[trans.Source], trans)
+		}
+	}
+	seen := make(map[pifra.Transition]bool)
+	sat := lts
+	sat.Transitions = nil
+	add := func(trans pifra.Transition) {
+		if !seen[trans] {
+			seen[trans] = true
+			sat.Transitions = append(sat.Transitions, trans)
+		}
+	}
+	for _, state := range stateIDs(lts) {
+		for _, s := range closure[state] {
+			add(pifra.Transition{Source: state, Destination: s, Label: tauLabel})
+			for _, trans := range visible[s] {
+				ends := []int{trans.Destination}
+				if trailing {
+					ends = closure[trans.Destination]
+				}
+				for _, t := range ends {
+					add(pifra.Transition{Source: state, Destination: t, Label: trans.Label})
+				}
+			}
+		}
+	}
+	return sat
+}
+
+// stateIDs returns the states of lts, with those only named by its
+// transitions, in increasing order.
+func stateIDs(lts pifra.Lts) []int {
+	seen := make(map[int]bool, len(lts.States))
+	ids := make([]int, 0, len(lts.States))
+	add := func(state int) {
+		if !seen[state] {
+			seen[state] = true
+			ids = append(ids, state)
+		}
+	}
+	for state := range lts.States {
+		add(state)
+	}
+	for _, trans := range lts.Transitions {
+		add(trans.Source)
+		add(trans.Destination)
+	}
+	add(initialState(lts))
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,230 @@
+package bisim
+
+import (
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// Side tells which of the two compared LTSs a state comes from.
+type Side int
+
+const (
+	Left Side = iota
+	Right
+)
+
+func (s Side) String() string {
+	if s == Right {
+		return "right"
+	}
+	return "left"
+}
+
+// State is a state of one of the compared LTSs, by its ID in that LTS.
+type State struct {
+	Side Side
+	ID   int
+}
+
+// move is a transition of a state of a graph, by action index.
+type move struct {
+	action, dst int
+}
+
+// graph holds the moves of the states of two LTSs. The states are numbered
+// densely, those of the left LTS first, each side in increasing order of ID,
+// and the labels are numbered in their order.
+type graph struct {
+	states  []State
+	index   map[State]int
+	initial [2]int
+	labels  []pifra.Label
+	// succs holds the moves of every state, sorted by action and
+	// destination, and preds the sources of the moves into every state,
+	// without repetition. Unless graded, succs holds each move once. Under
+	// Options.Spill, spilled holds both instead.
+	succs   [][]move
+	preds   [][]int
+	spilled *spilledGraph
+	// deterministic is set if no state has two moves by the same action.
+	deterministic bool
+}
+
+// newGraph returns the graph of left and right, with its moves spilled to sp
+// if sp is not nil.
+func newGraph(left, right pifra.Lts, graded bool, sp *spill) *graph {
+	g := &graph{index: make(map[State]int)}
+	ltss := [2]pifra.Lts{left, right}
+	for side, lts := range ltss {
+		for _, id := range stateIDs(lts) {
+			g.index[State{Side(side), id}] = len(g.states)
+			g.states = append(g.states, State{Side(side), id})
+		}
+		g.initial[side] = g.index[State{Side(side), initialState(lts)}]
+	}
+	actions := make(map[pifra.Label]int)
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if _, ok := actions[trans.Label]; !ok {
+				actions[trans.Label] = 0
+				g.labels = append(g.labels, trans.Label)
+			}
+		}
+	}
+	sort.Slice(g.labels, func(i, j int) bool {
+		return LabelLess(g.labels[i], g.labels[j])
+	})
+	for i, label := range g.labels {
+		actions[label] = i
+	}
+	g.deterministic = true
+	if sp != nil {
+		g.spillMoves(ltss, actions, graded, sp)
+		return g
+	}
+	g.succs = make([][]move, len(g.states))
+	g.preds = make([][]int, len(g.states))
+	for side, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			s := g.index[State{Side(side), trans.Source}]
+			t := g.index[State{Side(side), trans.Destination}]
+			g.succs[s] = append(g.succs[s], move{actions[trans.Label], t})
+		}
+	}
+	for s, moves := range g.succs {
+		g.succs[s] = g.tidy(moves, graded, func(dst int) {
+			g.preds[dst] = append(g.preds[dst], s)
+		})
+	}
+	return g
+}
+
+// tidy sorts the moves of a state by action and destination, and drops the
+// repeated ones unless graded. It calls pred with the destination of every
+// move but the repeated ones, and clears g.deterministic if the state has two
+// moves by one action.
+func (g *graph) tidy(moves []move, graded bool, pred func(dst int)) []move {
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].action != moves[j].action {
+			return moves[i].action < moves[j].action
+		}
+		return moves[i].dst < moves[j].dst
+	})
+	unique := moves[:0]
+	for i, m := range moves {
+		repeated := i > 0 && m == moves[i-1]
+		if !repeated {
+			pred(m.dst)
+		}
+		if i > 0 && m.action == moves[i-1].action && (graded || !repeated) {
+			g.deterministic = false
+		}
+		if graded || !repeated {
+			unique = append(unique, m)
+		}
+	}
+	return unique
+}
+
+// succ returns the moves of s.
+func (g *graph) succ(s int) []move {
+	if g.spilled != nil {
+		return decodeMoves(g.spilled.succs.row(s))
+	}
+	return g.succs[s]
+}
+
+// pred returns the sources of the moves into s.
+func (g *graph) pred(s int) []int {
+	if g.spilled != nil {
+		return g.spilled.preds.row(s)
+	}
+	return g.preds[s]
+}
+
+// moves returns the moves of s by action.
+func (g *graph) moves(s, action int) []move {
+	succs := g.succ(s)
+	lo := sort.Search(len(succs), func(i int) bool { return succs[i].action >= action })
+	hi := lo
+	for hi < len(succs) && succs[hi].action == action {
+		hi++
+	}
+	return succs[lo:hi]
+}
+
+// Partition is a partition of the states of two LTSs into blocks. It does not
+// change once returned.
+type Partition struct {
+	g       *graph
+	blockOf []int
+}
+
+// Block is a block of a Partition.
+type Block struct {
+	id     int
+	states []State
+}
+
+// ID returns the identifier of b, unique among the blocks of its partition.
+func (b Block) ID() int {
+	return b.id
+}
+
+// States returns the states of b, those of the left LTS first, each side in
+// increasing order of ID.
+func (b Block) States() []State {
+	return append([]State(nil), b.states...)
+}
+
+// Blocks returns the blocks of p in increasing order of ID.
+func (p Partition) Blocks() []Block {
+	members := make(map[int][]State)
+	for s, id := range p.blockOf {
+		members[id] = append(members[id], p.g.states[s])
+	}
+	blocks := make([]Block, 0, len(members))
+	for id, states := range members {
+		blocks = append(blocks, Block{id, states})
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].id < blocks[j].id
+	})
+	return blocks
+}
+
+// BlockOf returns the block containing state, and whether state belongs to p.
+func (p Partition) BlockOf(state State) (Block, bool) {
+	s, ok := p.g.index[state]
+	if !ok {
+		return Block{}, false
+	}
+	b := Block{id: p.blockOf[s]}
+	for t, id := range p.blockOf {
+		if id == b.id {
+			b.states = append(b.states, p.g.states[t])
+		}
+	}
+	return b, true
+}
+
+// Initial returns the initial state of side.
+func (p Partition) Initial(side Side) State {
+	return p.g.states[p.g.initial[side]]
+}
+
+// initialsSplit reports whether the initial states of the sides are in
+// different blocks.
+func (p Partition) initialsSplit() bool {
+	return p.blockOf[p.g.initial[Left]] != p.blockOf[p.g.initial[Right]]
+}
+
+// size returns the number of blocks of p.
+func (p Partition) size() int {
+	ids := make(map[int]bool)
+	for _, id := range p.blockOf {
+		ids[id] = true
+	}
+	return len(ids)
+}
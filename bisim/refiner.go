@@ -0,0 +1,611 @@
+package bisim
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yungene/pifra"
+)
+
+// Progress describes a refinement in progress. Blocks only grows, and never
+// beyond States, which bounds the work left.
+type Progress struct {
+	Steps  int
+	Blocks int
+	States int
+	Splits int
+	// Done is set in the last report, when the refinement ends.
+	Done bool
+}
+
+// progressInterval is the least time between two progress reports.
+const progressInterval = 200 * time.Millisecond
+
+// Split describes the split of a block, as passed to Options.Split.
+type Split struct {
+	// Step is the step splitting the block, from 1.
+	Step int
+	// Block is the ID of the block split, which keeps the states that did
+	// not move to Parts.
+	Block int
+	// Parts are the new blocks the other states moved to.
+	Parts []Block
+	// Action is the action by which the states of Parts reach other blocks
+	// than those left in Block, unless Signature is set. The states then
+	// differ by their signatures, the pairs of an action and a block they
+	// reach, as in a level-wise refinement or under η-bisimilarity.
+	Action    pifra.Label
+	Signature bool
+}
+
+// Work counts the work of a refinement, as pisim's -stats reports it.
+type Work struct {
+	// Steps counts the steps that split a block, and Splits the blocks
+	// split.
+	Steps, Splits int
+	// Attempts counts the blocks tried: once per action under the
+	// Kanellakis-Smolka algorithm, and once per signature otherwise.
+	Attempts int
+	// Hopcroft is set if Hopcroft's algorithm refined the LTSs.
+	Hopcroft bool
+}
+
+// Refiner refines the partition of the states of two LTSs one split at a
+// time, until the states sharing a block are equivalent:
+//
+//	r, err := bisim.NewRefiner(left, right, opts)
+//	...
+//	defer r.Close()
+//	for r.Step() {
+//		inspect(r.Partition())
+//	}
+//	if err := r.Err(); err != nil {
+//		...
+//	}
+//
+// Strong, weak and delay bisimilarity are decided by the Kanellakis-Smolka
+// algorithm, or by another that opts selects, weak and delay bisimilarity
+// over the saturated LTSs, and η-bisimilarity by splitting blocks by the
+// η-signatures of their states.
+type Refiner struct {
+	g    *graph
+	opts Options
+	eta  bool
+	// closure holds, for η-bisimilarity, the states every state reaches by
+	// silent moves, itself included.
+	closure [][]int
+	blockOf []int
+	members members
+	nextID  int
+	// reps maps every state to the representative of its group under
+	// Options.UpTo, and is nil otherwise.
+	reps []int
+	// queue holds the IDs of the blocks that may not be stable, in the order
+	// they were found, and queued tells which IDs it holds. Every other block
+	// is stable: no action splits it.
+	queue  []int
+	queued map[int]bool
+	// levels holds the number of blocks at each level of a level-wise
+	// refinement, from the initial partition.
+	levels []int
+	work   Work
+	done   bool
+	// bounded is set if a level-wise refinement stopped at Options.Bound
+	// before the partition was stable.
+	bounded  bool
+	reported time.Time
+	// spill holds the temporary files under Options.Spill, and is nil
+	// otherwise.
+	spill *spill
+}
+
+// NewRefiner returns a Refiner starting from a single block holding the
+// states of left and right, or a block per colour under opts.Colour, prepared
+// as opts says. The initial state of each is state 0, or its smallest state
+// if it has no state 0. It fails if opts are invalid, or if the temporary
+// files of opts.Spill cannot be written.
+func NewRefiner(left, right pifra.Lts, opts Options) (*Refiner, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	_, left = opts.prepare(left)
+	_, right = opts.prepare(right)
+	return newRefiner(left, right, opts)
+}
+
+// newRefiner returns a Refiner of left and right, prepared and validated. It
+// fails only if the temporary files of Options.Spill fail.
+func newRefiner(left, right pifra.Lts, opts Options) (*Refiner, error) {
+	eq := opts.equivalence()
+	if eq == "weak" || eq == "delay" {
+		left, right = saturate(left, eq == "weak", opts.silent), saturate(right, eq == "weak", opts.silent)
+	}
+	r := &Refiner{
+		opts:   opts,
+		eta:    eq == "eta",
+		queued: make(map[int]bool),
+	}
+	if opts.Spill > 0 {
+		r.spill = &spill{chunk: opts.Spill}
+	}
+	r.g = newGraph(left, right, opts.Graded, r.spill)
+	r.blockOf = make([]int, len(r.g.states))
+	r.seed()
+	if err := r.Err(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if r.eta {
+		r.closure = r.tauClosure()
+	}
+	if opts.UpTo {
+		r.reps = r.knownEquivalent()
+	}
+	if r.levelwise() {
+		r.levels = []int{r.members.len()}
+	}
+	return r, nil
+}
+
+// seed puts the states in blocks by their colour under Options.Colour, or
+// all in one block, and queues the blocks. The blocks are numbered in the
+// order of their first state.
+func (r *Refiner) seed() {
+	members := make(memMembers)
+	ids := make(map[uint64]int)
+	for s, state := range r.g.states {
+		var colour uint64
+		if r.opts.Colour != nil {
+			colour = r.opts.Colour(state)
+		}
+		id, ok := ids[colour]
+		if !ok {
+			id = r.nextID
+			r.nextID++
+			ids[colour] = id
+			r.enqueue(id)
+		}
+		r.blockOf[s] = id
+		members[id] = append(members[id], s)
+	}
+	r.setMembers(members)
+}
+
+// setMembers sets the members of the blocks, spilled under Options.Spill.
+func (r *Refiner) setMembers(m memMembers) {
+	r.members = m
+	if r.spill != nil {
+		r.members = r.spill.spillMembers(m)
+	}
+}
+
+func (r *Refiner) levelwise() bool {
+	return !r.eta && r.opts.Algorithm == "levelwise"
+}
+
+func (r *Refiner) enqueue(id int) {
+	if !r.queued[id] {
+		r.queued[id] = true
+		r.queue = append(r.queue, id)
+	}
+}
+
+// Step refines the partition, and reports whether it split a block. It
+// returns false once the partition is stable, or once a level-wise
+// refinement reached Options.Bound.
+//
+// A step splits one block under the Kanellakis-Smolka algorithm and
+// η-bisimilarity, every block of a level under a level-wise refinement, and
+// refines deterministic LTSs to the end under Hopcroft's algorithm.
+//
+// The Kanellakis-Smolka algorithm and η-bisimilarity only try the blocks in
+// their queue: initially every block, then, under the Kanellakis-Smolka
+// algorithm, the halves of each split block and the blocks with a move into
+// the smaller half. A block with no move into the split block keeps its
+// destinations, and a stable block whose states all reach the split block
+// either all reach the smaller half or differ there, so the other blocks
+// stay stable. Each state is in the smaller half of O(log n) splits, which
+// bounds the number of attempts by O(|actions|·m·log n). The η-signature of
+// a state depends on the silent moves inside its block, so a split under
+// η-bisimilarity queues every block.
+func (r *Refiner) Step() bool {
+	if r.done {
+		return false
+	}
+	var split bool
+	switch {
+	case r.eta:
+		split = r.stepQueue(r.splitEta)
+	case r.levelwise():
+		split = r.stepLevel()
+	case r.opts.Algorithm == "hopcroft" && r.g.deterministic && !r.work.Hopcroft && r.spill == nil:
+		split = r.stepHopcroft()
+	default:
+		split = r.stepQueue(r.splitKS)
+	}
+	if split && r.Err() == nil {
+		r.work.Steps++
+		r.report(false)
+		return true
+	}
+	r.done = true
+	r.report(true)
+	return false
+}
+
+// stepQueue tries the blocks of the queue in turn with split, until one
+// splits.
+func (r *Refiner) stepQueue(split func(id int) bool) bool {
+	for len(r.queue) > 0 {
+		id := r.queue[0]
+		r.queue = r.queue[1:]
+		delete(r.queued, id)
+		if split(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run steps r until the partition is stable, and returns it. It fails with
+// the error of ctx if ctx is done first, and with that of Err if the
+// temporary files of Options.Spill fail.
+func (r *Refiner) Run(ctx context.Context) (Partition, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Partition{}, err
+		}
+		if !r.Step() {
+			if err := r.Err(); err != nil {
+				return Partition{}, err
+			}
+			return r.Partition(), nil
+		}
+	}
+}
+
+// Err returns the first error reading or writing the temporary files of
+// Options.Spill. A failure ends the refinement, and Step then returns false.
+func (r *Refiner) Err() error {
+	if r.spill == nil {
+		return nil
+	}
+	return r.spill.err
+}
+
+// Close removes the temporary files of Options.Spill, and returns the first
+// error Err would report or closing them met. The moves of the states are
+// gone once it returns; the partitions returned stay valid.
+func (r *Refiner) Close() error {
+	if r.spill == nil {
+		return nil
+	}
+	return r.spill.close()
+}
+
+// Partition returns a copy of the current partition, which later steps leave
+// unchanged.
+func (r *Refiner) Partition() Partition {
+	return Partition{g: r.g, blockOf: append([]int(nil), r.blockOf...)}
+}
+
+// Stable reports whether the refinement ended with a stable partition,
+// rather than at Options.Bound, on an error, or not at all.
+func (r *Refiner) Stable() bool {
+	return r.done && !r.bounded && r.Err() == nil
+}
+
+// Work returns the work of the refinement so far.
+func (r *Refiner) Work() Work {
+	return r.work
+}
+
+// Levels returns the number of blocks at each level of a level-wise
+// refinement: in the initial partition, then after every level that split a
+// block. It returns nil for the other algorithms.
+func (r *Refiner) Levels() []int {
+	return append([]int(nil), r.levels...)
+}
+
+func (r *Refiner) report(done bool) {
+	if r.opts.Progress == nil {
+		return
+	}
+	now := time.Now()
+	if !done && now.Sub(r.reported) < progressInterval {
+		return
+	}
+	r.reported = now
+	r.opts.Progress(Progress{
+		Steps:  r.work.Steps,
+		Blocks: r.members.len(),
+		States: len(r.g.states),
+		Splits: r.work.Splits,
+		Done:   done,
+	})
+}
+
+// block returns the block with the given ID holding states.
+func (r *Refiner) block(id int, states []int) Block {
+	sorted := append([]int(nil), states...)
+	sort.Ints(sorted)
+	b := Block{id: id, states: make([]State, len(sorted))}
+	for i, s := range sorted {
+		b.states[i] = r.g.states[s]
+	}
+	return b
+}
+
+// emit counts a split of a block, and passes it to Options.Split with the
+// new blocks parts.
+func (r *Refiner) emit(split Split, parts ...int) {
+	r.work.Splits++
+	if r.opts.Split == nil {
+		return
+	}
+	split.Step = r.work.Steps + 1
+	for _, id := range parts {
+		split.Parts = append(split.Parts, r.block(id, r.members.get(id)))
+	}
+	r.opts.Split(split)
+}
+
+// destinations returns the sorted IDs of the blocks that s reaches by action,
+// as a set, or as a multiset counting each move under Options.Graded.
+func (r *Refiner) destinations(s, action int) []int {
+	var dests []int
+	for _, m := range r.g.moves(s, action) {
+		dests = append(dests, r.blockOf[m.dst])
+	}
+	sort.Ints(dests)
+	if r.opts.Graded {
+		return dests
+	}
+	out := dests[:0]
+	for i, id := range dests {
+		if i == 0 || id != dests[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// divide returns the states of states reaching the same blocks by action as
+// the first, and the others. Different fingerprints settle that destinations
+// differ without computing them, and under Options.UpTo the states of a
+// group share the outcome of its first member.
+func (r *Refiner) divide(states []int, action int) (same, other []int) {
+	first := states[0]
+	var fp uint64
+	if !r.opts.NoFingerprints {
+		fp = r.fingerprint(first, action)
+	}
+	var dests []int
+	computed := false
+	agrees := func(s int) bool {
+		if !r.opts.NoFingerprints && r.fingerprint(s, action) != fp {
+			return false
+		}
+		if !computed {
+			dests, computed = r.destinations(first, action), true
+		}
+		return equalInts(r.destinations(s, action), dests)
+	}
+	var byRep map[int]bool
+	if r.reps != nil {
+		byRep = map[int]bool{r.reps[first]: true}
+	}
+	for _, s := range states {
+		var ok bool
+		switch {
+		case s == first:
+			ok = true
+		case byRep != nil:
+			var known bool
+			if ok, known = byRep[r.reps[s]]; !known {
+				ok = agrees(s)
+				byRep[r.reps[s]] = ok
+			}
+		default:
+			ok = agrees(s)
+		}
+		if ok {
+			same = append(same, s)
+		} else {
+			other = append(other, s)
+		}
+	}
+	return same, other
+}
+
+// splitKS splits the block id by the first action by which some of its
+// states reach other blocks than its first state, and reports whether it
+// found one. The states agreeing with the first state keep the ID.
+func (r *Refiner) splitKS(id int) bool {
+	states := r.members.get(id)
+	if len(states) < 2 {
+		return false
+	}
+	for action := range r.g.labels {
+		r.work.Attempts++
+		same, other := r.divide(states, action)
+		if len(other) == 0 {
+			continue
+		}
+		moved := r.divideBlock(id, same, other)[0]
+		r.enqueue(id)
+		r.enqueue(moved)
+		smaller := same
+		if len(other) < len(same) {
+			smaller = other
+		}
+		for _, s := range smaller {
+			for _, pred := range r.g.pred(s) {
+				r.enqueue(r.blockOf[pred])
+			}
+		}
+		r.emit(Split{Block: id, Action: r.g.labels[action]}, moved)
+		return true
+	}
+	return false
+}
+
+// divideBlock keeps the states of groups[0] in the block id, and moves
+// every other group to a new block. It returns the IDs of the new blocks.
+func (r *Refiner) divideBlock(id int, groups ...[]int) []int {
+	ids := make([]int, len(groups)-1)
+	for i, states := range groups[1:] {
+		ids[i] = r.nextID
+		r.nextID++
+		for _, s := range states {
+			r.blockOf[s] = ids[i]
+		}
+	}
+	r.members.divide(id, groups, ids)
+	return ids
+}
+
+// splitBy splits the block id by the keys of its states, keeping the states
+// with the key of its first state and moving the others to a new block per
+// key, in the order of their first state. It reports whether the block split.
+func (r *Refiner) splitBy(id int, key func(s int) string) bool {
+	states := r.members.get(id)
+	if len(states) < 2 {
+		return false
+	}
+	r.work.Attempts++
+	var keys []string
+	groups := make(map[string][]int)
+	for _, s := range states {
+		k := key(s)
+		if groups[k] == nil {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+	if len(keys) == 1 {
+		return false
+	}
+	divided := make([][]int, len(keys))
+	for i, k := range keys {
+		divided[i] = groups[k]
+	}
+	r.emit(Split{Block: id, Signature: true}, r.divideBlock(id, divided...)...)
+	return true
+}
+
+// blockIDs returns the IDs of the blocks in increasing order.
+func (r *Refiner) blockIDs() []int {
+	return r.members.ids()
+}
+
+// tauClosure returns the states every state reaches by silent moves,
+// including itself.
+func (r *Refiner) tauClosure() [][]int {
+	closure := make([][]int, len(r.g.states))
+	for state := range closure {
+		seen := map[int]bool{state: true}
+		reach := []int{state}
+		for i := 0; i < len(reach); i++ {
+			for _, m := range r.g.succ(reach[i]) {
+				if r.opts.silent(r.g.labels[m.action]) && !seen[m.dst] {
+					seen[m.dst] = true
+					reach = append(reach, m.dst)
+				}
+			}
+		}
+		closure[state] = reach
+	}
+	return closure
+}
+
+// sigEntry is a move observed by a signature: an action into a block.
+type sigEntry struct {
+	action int
+	block  int
+}
+
+// sortEntries orders sig by action, then block.
+func sortEntries(sig []sigEntry) {
+	sort.Slice(sig, func(i, j int) bool {
+		if sig[i].action != sig[j].action {
+			return sig[i].action < sig[j].action
+		}
+		return sig[i].block < sig[j].block
+	})
+}
+
+// signature returns the sorted η-signature of s: the pairs (a, B) such that s
+// reaches some s1 by silent moves inside its own block, and s1 -a-> s2 τ* s3
+// with s3 in B, leaving out silent moves that stay in the block of s. States
+// with equal signatures in a stable partition are η-bisimilar.
+func (r *Refiner) signature(s int) []sigEntry {
+	home := r.blockOf[s]
+	seen := map[int]bool{s: true}
+	inert := []int{s}
+	entries := make(map[sigEntry]bool)
+	for i := 0; i < len(inert); i++ {
+		for _, m := range r.g.succ(inert[i]) {
+			tau := r.opts.silent(r.g.labels[m.action])
+			if tau && r.blockOf[m.dst] == home {
+				if !seen[m.dst] {
+					seen[m.dst] = true
+					inert = append(inert, m.dst)
+				}
+				continue
+			}
+			for _, t := range r.closure[m.dst] {
+				if tau && r.blockOf[t] == home {
+					continue
+				}
+				entries[sigEntry{m.action, r.blockOf[t]}] = true
+			}
+		}
+	}
+	sig := make([]sigEntry, 0, len(entries))
+	for e := range entries {
+		sig = append(sig, e)
+	}
+	sortEntries(sig)
+	return sig
+}
+
+func sigKey(sig []sigEntry) string {
+	var b strings.Builder
+	for _, e := range sig {
+		b.WriteString(strconv.Itoa(e.action))
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(e.block))
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// splitEta splits the block id by the η-signatures of its states, and
+// reports whether they differ. The states sharing the signature of its first
+// state keep the ID, and the others move to a new block per signature, in
+// the order of their first state.
+func (r *Refiner) splitEta(id int) bool {
+	if !r.splitBy(id, func(s int) string { return sigKey(r.signature(s)) }) {
+		return false
+	}
+	for _, id := range r.blockIDs() {
+		r.enqueue(id)
+	}
+	return true
+}
@@ -0,0 +1,345 @@
+package bisim
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// action returns the input action on channel, printed "channel 1".
+func action(channel int) pifra.Label {
+	return pifra.Label{
+		Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: channel},
+		Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 1},
+	}
+}
+
+var (
+	a = action(1)
+	b = action(2)
+	c = action(3)
+)
+
+// makeLTS returns an LTS with states 0 to n-1 and the given transitions.
+func makeLTS(n int, trans ...pifra.Transition) pifra.Lts {
+	lts := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, n),
+		RegSizeReached: make(map[int]bool),
+		Transitions:    trans,
+	}
+	for s := 0; s < n; s++ {
+		lts.States[s] = pifra.Configuration{}
+	}
+	return lts
+}
+
+func trans(src int, label pifra.Label, dst int) pifra.Transition {
+	return pifra.Transition{Source: src, Destination: dst, Label: label}
+}
+
+// chain returns the LTS moving by a from state 0 to state n-1.
+func chain(n int) pifra.Lts {
+	lts := makeLTS(n)
+	for s := 0; s+1 < n; s++ {
+		lts.Transitions = append(lts.Transitions, trans(s, a, s+1))
+	}
+	return lts
+}
+
+// steps runs a Refiner of left and right to the end and returns the number
+// of steps that split a block and the final partition.
+func steps(t *testing.T, left, right pifra.Lts, opts Options) (int, Partition) {
+	t.Helper()
+	r, err := NewRefiner(left, right, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for r.Step() {
+		n++
+	}
+	if r.Step() {
+		t.Error("Step split a block after returning false")
+	}
+	return n, r.Partition()
+}
+
+// TestRefinerSteps checks the number of splits on examples worked out by
+// hand. Each split of a chain cuts off its last state that still moves, so a
+// chain of n states takes n-1 steps. a.(b+c) and a.b+a.c need five: the
+// states moving by a from the others, those moving by b, the a-successor of
+// the left from the one of the right by c, the other state moving by c from
+// the deadlocked ones, and last the initial states. τ.a and a are told apart
+// from their deadlocked states in a single weak step.
+func TestRefinerSteps(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		left, right pifra.Lts
+		opts        Options
+		steps       int
+		blocks      int
+	}{
+		{"empty", makeLTS(1), makeLTS(1), Options{}, 0, 1},
+		{"chain 2", chain(2), chain(2), Options{}, 1, 2},
+		{"chain 5", chain(5), chain(5), Options{}, 4, 5},
+		{"chains 3 and 4", chain(3), chain(4), Options{}, 3, 4},
+		{
+			"a.(b+c) vs a.b+a.c",
+			makeLTS(4, trans(0, a, 1), trans(1, b, 2), trans(1, c, 3)),
+			makeLTS(5, trans(0, a, 1), trans(0, a, 2), trans(1, b, 3), trans(2, c, 4)),
+			Options{}, 5, 6,
+		},
+		{
+			"τ.a vs a",
+			makeLTS(3, trans(0, tauLabel, 1), trans(1, a, 2)),
+			makeLTS(2, trans(0, a, 1)),
+			Options{Equivalence: "weak"}, 1, 2,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n, part := steps(t, test.left, test.right, test.opts)
+			if n != test.steps {
+				t.Errorf("%d steps, want %d", n, test.steps)
+			}
+			if got := len(part.Blocks()); got != test.blocks {
+				t.Errorf("%d blocks, want %d", got, test.blocks)
+			}
+		})
+	}
+}
+
+func TestRefinerPartitionIsACopy(t *testing.T) {
+	r, err := NewRefiner(chain(3), chain(3), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.Partition()
+	for r.Step() {
+	}
+	if n := len(before.Blocks()); n != 1 {
+		t.Errorf("the partition taken before refining has %d blocks after, want 1", n)
+	}
+	if n := len(r.Partition().Blocks()); n != 3 {
+		t.Errorf("the stable partition has %d blocks, want 3", n)
+	}
+}
+
+func TestPartitionBlocks(t *testing.T) {
+	_, part := steps(t, chain(3), chain(3), Options{})
+	want := [][]State{
+		{{Left, 0}, {Right, 0}},
+		{{Left, 1}, {Right, 1}},
+		{{Left, 2}, {Right, 2}},
+	}
+	blocks := part.Blocks()
+	if len(blocks) != len(want) {
+		t.Fatalf("%d blocks, want %d", len(blocks), len(want))
+	}
+	ids := make(map[int]bool)
+	for _, block := range blocks {
+		ids[block.ID()] = true
+		states := block.States()
+		for _, state := range states {
+			got, ok := part.BlockOf(state)
+			if !ok || got.ID() != block.ID() {
+				t.Errorf("BlockOf(%v) = %d, %v, want block %d", state, got.ID(), ok, block.ID())
+			}
+		}
+		found := false
+		for _, w := range want {
+			found = found || reflect.DeepEqual(states, w)
+		}
+		if !found {
+			t.Errorf("unexpected block %d: %v", block.ID(), states)
+		}
+	}
+	if len(ids) != len(blocks) {
+		t.Errorf("block IDs %v are not unique", ids)
+	}
+	if _, ok := part.BlockOf(State{Left, 7}); ok {
+		t.Error("BlockOf found a block for a state of neither LTS")
+	}
+	if got := part.Initial(Right); got != (State{Right, 0}) {
+		t.Errorf("Initial(Right) = %v, want state 0 of the right", got)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	r, err := NewRefiner(chain(50), chain(50), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Step()
+	cancel()
+	if _, err := r.Run(ctx); err != context.Canceled {
+		t.Errorf("Run = %v, want %v", err, context.Canceled)
+	}
+	// The refinement can resume under another context.
+	part, err := r.Run(context.Background())
+	if err != nil || len(part.Blocks()) != 50 {
+		t.Errorf("Run resumed to %d blocks and %v, want 50 blocks", len(part.Blocks()), err)
+	}
+}
+
+// TestSplits checks that applying the splits a Refiner reports, each block
+// keeping the states that did not move, rebuilds its partition after every
+// step, with every algorithm.
+func TestSplits(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Algorithm: "hopcroft"},
+		{Algorithm: "levelwise"},
+		{Equivalence: "weak"},
+		{Equivalence: "eta"},
+		{Spill: 2},
+		{Algorithm: "levelwise", Spill: 1},
+		{Equivalence: "eta", Spill: 3},
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			left, right := randomPair(rnd)
+			if opts.Algorithm == "hopcroft" && i%2 == 0 {
+				left = reference.Random(rnd, 1+rnd.Intn(5), 0, 2, 0)
+				right = permuted(rnd, left)
+			}
+			blockOf := make(map[State]int)
+			opts.Split = func(split Split) {
+				if len(split.Parts) == 0 {
+					t.Fatalf("%+v: pair %d: split of block %d into no parts", opts, i, split.Block)
+				}
+				for _, part := range split.Parts {
+					for _, s := range part.States() {
+						if blockOf[s] != split.Block {
+							t.Fatalf("%+v: pair %d: state %v moved from block %d, not %d",
+								opts, i, s, blockOf[s], split.Block)
+						}
+						blockOf[s] = part.ID()
+					}
+				}
+			}
+			r, err := NewRefiner(left, right, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, block := range r.Partition().Blocks() {
+				for _, s := range block.States() {
+					blockOf[s] = block.ID()
+				}
+			}
+			for r.Step() {
+				for _, block := range r.Partition().Blocks() {
+					for _, s := range block.States() {
+						if blockOf[s] != block.ID() {
+							t.Fatalf("%+v: pair %d: splits put state %v in block %d, not %d",
+								opts, i, s, blockOf[s], block.ID())
+						}
+					}
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// TestUpToGroups checks that the groups of states with identical moves never
+// straddle two blocks of the stable partition.
+func TestUpToGroups(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	grouped := 0
+	for i := 0; i < 200; i++ {
+		left := reference.Random(rnd, 1+rnd.Intn(8), rnd.Intn(14), 2, 0)
+		r, err := NewRefiner(left, permuted(rnd, left), Options{UpTo: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r.Step() {
+		}
+		for s, rep := range r.reps {
+			if r.blockOf[s] != r.blockOf[rep] {
+				t.Fatalf("pair %d: state %v is grouped with %v in another block", i, r.g.states[s], r.g.states[rep])
+			}
+			if s != rep {
+				grouped++
+			}
+		}
+	}
+	if grouped == 0 {
+		t.Error("no states were grouped")
+	}
+}
+
+// TestSpill checks that a Refiner finds the same partitions, by the same
+// splits, with its moves and blocks spilled in chunks of a few values as in
+// memory, and that Close removes its files.
+func TestSpill(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(rnd)
+		for _, opts := range []Options{{}, {Algorithm: "levelwise"}, {Equivalence: "eta"}, {UpTo: true}, {Graded: true}} {
+			var want []Split
+			opts.Split = func(split Split) { want = append(want, split) }
+			r, err := NewRefiner(left, right, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPart, _ := r.Run(context.Background())
+
+			var got []Split
+			opts.Split = func(split Split) { got = append(got, split) }
+			opts.Spill = 1 + i%4
+			r, err = NewRefiner(left, right, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part, err := r.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(part.Blocks(), wantPart.Blocks()) || !reflect.DeepEqual(got, want) {
+				t.Errorf("pair %d, %+v: spilled splits %+v, want %+v", i, opts, got, want)
+			}
+			files := append([]*os.File(nil), r.spill.files...)
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range files {
+				if _, err := os.Stat(file.Name()); !os.IsNotExist(err) {
+					t.Errorf("pair %d: %s left behind: %v", i, file.Name(), err)
+				}
+				if err := file.Close(); !errors.Is(err, os.ErrClosed) {
+					t.Errorf("pair %d: %s left open", i, file.Name())
+				}
+			}
+		}
+	}
+}
+
+// TestSpillError checks that a failing temporary file ends the refinement
+// with an error rather than a panic.
+func TestSpillError(t *testing.T) {
+	r, err := NewRefiner(chain(6), chain(5), Options{Spill: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range r.spill.files {
+		file.Close()
+	}
+	if _, err := r.Run(context.Background()); err == nil {
+		t.Error("Run succeeded over closed files")
+	}
+	if r.Stable() {
+		t.Error("the refinement is stable after an error")
+	}
+	if err := r.Close(); err == nil {
+		t.Error("Close did not report the error")
+	}
+}
@@ -0,0 +1,185 @@
+package bisim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yungene/pisim/internal/ltsfile"
+	"github.com/yungene/pisim/result"
+)
+
+// Verdict is the outcome of a comparison.
+type Verdict int
+
+const (
+	NotEquivalent Verdict = iota
+	Equivalent
+	// Unknown is the verdict when the refinement stopped before it could
+	// tell the sides apart, as with pisim's -anytime or -bounded.
+	Unknown
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Equivalent:
+		return result.Equivalent
+	case Unknown:
+		return result.Unknown
+	}
+	return result.NotEquivalent
+}
+
+// bisimilarities are the equivalences whose negative verdict String reports
+// as "Not bisimilar". The others, such as trace equivalence, have a Reason.
+var bisimilarities = map[string]bool{
+	"strong": true,
+	"weak":   true,
+	"delay":  true,
+	"eta":    true,
+}
+
+// Stats describes the inputs of a comparison and its cost.
+type Stats struct {
+	Left, Right result.Side
+	// Classes counts the classes of the final partition, for the
+	// equivalences decided by partition refinement.
+	Classes int
+	// Levels holds the number of blocks at each depth of a level-wise
+	// refinement, from the initial partition.
+	Levels  []int
+	Elapsed time.Duration
+}
+
+// Witness explains a negative verdict. Actions are given in their printed
+// form.
+type Witness struct {
+	// OnlyLeft and OnlyRight are the actions occurring on one side only.
+	OnlyLeft, OnlyRight []string
+	// Action distinguishes the initial states, and Trace leads from them to
+	// a move one side can make and the other cannot match, for a verdict by
+	// partition refinement.
+	Action string
+	Trace  []string
+	// Reason explains the verdict otherwise, and for a backward comparison.
+	Reason string
+}
+
+// Pair is a pair of states, one of the left LTS and one of the right, by
+// their IDs.
+type Pair struct {
+	Left, Right int
+}
+
+// Relation is a set of state pairs.
+type Relation map[Pair]struct{}
+
+// Pairs returns the pairs of rel in order of Left, then of Right.
+func (rel Relation) Pairs() []Pair {
+	pairs := make([]Pair, 0, len(rel))
+	for p := range rel {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Left != pairs[j].Left {
+			return pairs[i].Left < pairs[j].Left
+		}
+		return pairs[i].Right < pairs[j].Right
+	})
+	return pairs
+}
+
+// Result is the outcome of a comparison, as pisim prints it: String gives the
+// text printed on stdout, and MarshalJSON the -result-json object.
+type Result struct {
+	// Equivalence is the equivalence checked, as named by -equivalence, or
+	// "simulation" under -sim.
+	Equivalence string
+	// Direction is "backward" or "forward-backward" under -backward or
+	// -forward-backward, and empty for the usual forward comparison.
+	Direction string
+	Verdict   Verdict
+	// Relation is a bisimulation relating the initial states, for a
+	// positive verdict of Compare.
+	Relation Relation
+	Stats    Stats
+	// Directions holds, under -sim, a line per direction telling whether one
+	// side is simulated by the other, with a certificate if not.
+	Directions []string
+	// Witness is set for a negative verdict, but for -sim, whose Directions
+	// explain it.
+	Witness *Witness
+}
+
+// String renders r as pisim prints it on stdout. A positive verdict prints
+// nothing but the directions of -sim, since pisim then writes its outputs.
+func (r Result) String() string {
+	var b strings.Builder
+	for _, line := range r.Directions {
+		fmt.Fprintln(&b, line)
+	}
+	switch {
+	case r.Verdict == Unknown:
+		fmt.Fprintln(&b, "Unknown (bisimilar up to the splits performed)")
+	case r.Verdict == Equivalent || r.Witness == nil:
+	default:
+		w := r.Witness
+		if len(w.OnlyLeft) > 0 || len(w.OnlyRight) > 0 {
+			fmt.Fprintf(&b, "only in left: {%s}, only in right: {%s}\n",
+				strings.Join(w.OnlyLeft, ", "), strings.Join(w.OnlyRight, ", "))
+		}
+		if !bisimilarities[r.Equivalence] {
+			fmt.Fprintf(&b, "Not %s equivalent: %s\n", r.Equivalence, w.Reason)
+			break
+		}
+		direction := r.Direction
+		if direction != "" {
+			direction += " "
+		}
+		fmt.Fprintf(&b, "Not %sbisimilar\n", direction)
+		if w.Reason != "" {
+			fmt.Fprintln(&b, w.Reason)
+		} else if w.Action != "" {
+			fmt.Fprintf(&b, "initial states are distinguished by %s\n", w.Action)
+		}
+	}
+	return b.String()
+}
+
+// comparison returns r as the -result-json object.
+func (r Result) comparison() result.Comparison {
+	c := result.Comparison{
+		Schema:         result.Schema,
+		Version:        ltsfile.Version,
+		Equivalence:    r.Equivalence,
+		Direction:      r.Direction,
+		Verdict:        r.Verdict.String(),
+		Equivalent:     r.Verdict == Equivalent,
+		Exhaustive:     r.Verdict != Unknown,
+		Left:           r.Stats.Left,
+		Right:          r.Stats.Right,
+		Classes:        r.Stats.Classes,
+		Levels:         r.Stats.Levels,
+		ElapsedSeconds: r.Stats.Elapsed.Seconds(),
+	}
+	if r.Witness != nil {
+		c.DistinguishingTrace = r.Witness.Trace
+		c.Reason = r.Witness.Reason
+	}
+	return c
+}
+
+// MarshalJSON encodes r as the -result-json object, described by package
+// result.
+func (r Result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.comparison()); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
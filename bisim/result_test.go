@@ -0,0 +1,65 @@
+package bisim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/yungene/pisim/result"
+)
+
+func TestResultString(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		r    Result
+		want string
+	}{
+		{"equivalent", Result{Equivalence: "strong", Verdict: Equivalent}, ""},
+		{"unknown", Result{Equivalence: "strong", Verdict: Unknown},
+			"Unknown (bisimilar up to the splits performed)\n"},
+		{"not bisimilar", Result{
+			Equivalence: "strong",
+			Verdict:     NotEquivalent,
+			Witness:     &Witness{Action: "1 1", Trace: []string{"1 1", "3 1"}},
+		}, "Not bisimilar\ninitial states are distinguished by 1 1\n"},
+		{"alphabets", Result{
+			Equivalence: "weak",
+			Verdict:     NotEquivalent,
+			Witness:     &Witness{OnlyLeft: []string{"1 1", "2 1"}, Action: "1 1"},
+		}, "only in left: {1 1, 2 1}, only in right: {}\nNot bisimilar\ninitial states are distinguished by 1 1\n"},
+		{"backward", Result{
+			Equivalence: "strong",
+			Direction:   "backward",
+			Verdict:     NotEquivalent,
+			Witness:     &Witness{Reason: "no state of right matches state 1 of left"},
+		}, "Not backward bisimilar\nno state of right matches state 1 of left\n"},
+		{"trace", Result{
+			Equivalence: "trace",
+			Verdict:     NotEquivalent,
+			Witness:     &Witness{Reason: "trace 1 1: only left"},
+		}, "Not trace equivalent: trace 1 1: only left\n"},
+		{"simulation", Result{
+			Equivalence: "simulation",
+			Verdict:     NotEquivalent,
+			Directions:  []string{"left is simulated by right", "right is not simulated by left"},
+		}, "left is simulated by right\nright is not simulated by left\n"},
+	} {
+		if got := test.r.String(); got != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUnknownIsNotEquivalent(t *testing.T) {
+	data, err := json.Marshal(Result{Equivalence: "strong", Verdict: Unknown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c result.Comparison
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Equivalent || c.Exhaustive || c.Verdict != result.Unknown {
+		t.Errorf("unknown verdict encoded as equivalent=%v exhaustive=%v verdict=%q",
+			c.Equivalent, c.Exhaustive, c.Verdict)
+	}
+}
@@ -0,0 +1,374 @@
+package bisim
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// spill keeps the moves of a graph and the members of the blocks of a
+// Refiner in temporary files under Options.Spill, reading them back chunk
+// values at a time. It records the first error reading or writing them,
+// after which reads return nothing.
+type spill struct {
+	chunk int
+	files []*os.File
+	err   error
+}
+
+func (sp *spill) fail(err error) {
+	if sp.err == nil {
+		sp.err = err
+	}
+}
+
+// create creates a temporary file, closed by close. Where the system allows
+// it, the file is removed at once and stays readable through its handle, so
+// that nothing is left behind if the process is killed; close removes it
+// otherwise.
+func (sp *spill) create() *os.File {
+	file, err := ioutil.TempFile("", "pisim-spill-*")
+	if err != nil {
+		sp.fail(err)
+		return nil
+	}
+	os.Remove(file.Name())
+	sp.files = append(sp.files, file)
+	return file
+}
+
+// remove closes and removes file.
+func (sp *spill) remove(file *os.File) {
+	if file == nil {
+		return
+	}
+	for i, f := range sp.files {
+		if f == file {
+			sp.files = append(sp.files[:i], sp.files[i+1:]...)
+			break
+		}
+	}
+	if err := file.Close(); err != nil {
+		sp.fail(err)
+	}
+	if err := os.Remove(file.Name()); err != nil && !os.IsNotExist(err) {
+		sp.fail(err)
+	}
+}
+
+// close closes and removes the files of sp, and returns the first error sp
+// met.
+func (sp *spill) close() error {
+	for len(sp.files) > 0 {
+		sp.remove(sp.files[0])
+	}
+	return sp.err
+}
+
+// valueSize is the size of a value on disk, an int64.
+const valueSize = 8
+
+// readValues reads n values of file from the value at pos on.
+func (sp *spill) readValues(file *os.File, pos, n int) []int {
+	if sp.err != nil {
+		return nil
+	}
+	buf := make([]byte, n*valueSize)
+	if _, err := file.ReadAt(buf, int64(pos)*valueSize); err != nil {
+		sp.fail(err)
+		return nil
+	}
+	values := make([]int, n)
+	for i := range values {
+		values[i] = int(int64(binary.LittleEndian.Uint64(buf[i*valueSize:])))
+	}
+	return values
+}
+
+// writeValues writes values to file from the value at pos on.
+func (sp *spill) writeValues(file *os.File, pos int, values []int) {
+	if sp.err != nil {
+		return
+	}
+	buf := make([]byte, len(values)*valueSize)
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(buf[i*valueSize:], uint64(int64(v)))
+	}
+	if _, err := file.WriteAt(buf, int64(pos)*valueSize); err != nil {
+		sp.fail(err)
+	}
+}
+
+// table is a list of values per row, spilled to a file in row order. Only
+// the offsets of the rows stay in memory.
+type table struct {
+	sp     *spill
+	file   *os.File
+	starts []int
+	// cache holds the values read last, from cacheStart on. A read replaces
+	// it rather than overwriting it, so that the rows returned stay valid.
+	cacheStart int
+	cache      []int
+}
+
+// newTable returns a table of rows with the given lengths, to be filled by a
+// scatter or a rowWriter.
+func (sp *spill) newTable(lengths []int) *table {
+	t := &table{sp: sp, file: sp.create(), starts: make([]int, len(lengths)+1)}
+	for i, n := range lengths {
+		t.starts[i+1] = t.starts[i] + n
+	}
+	return t
+}
+
+// row returns the values of row i.
+func (t *table) row(i int) []int {
+	lo, hi := t.starts[i], t.starts[i+1]
+	if lo < t.cacheStart || hi > t.cacheStart+len(t.cache) {
+		n := hi - lo
+		if n < t.sp.chunk {
+			n = t.sp.chunk
+		}
+		if total := t.starts[len(t.starts)-1]; lo+n > total {
+			n = total - lo
+		}
+		values := t.sp.readValues(t.file, lo, n)
+		if values == nil {
+			return nil
+		}
+		t.cacheStart, t.cache = lo, values
+	}
+	return t.cache[lo-t.cacheStart : hi-t.cacheStart : hi-t.cacheStart]
+}
+
+// scatter writes the values of a table in any order, combining the writes of
+// consecutive values.
+type scatter struct {
+	t       *table
+	next    []int
+	start   int
+	pending []int
+}
+
+func (t *table) scatter() *scatter {
+	return &scatter{t: t, next: append([]int(nil), t.starts[:len(t.starts)-1]...)}
+}
+
+// add appends values to row i.
+func (w *scatter) add(i int, values ...int) {
+	pos := w.next[i]
+	w.next[i] += len(values)
+	if pos != w.start+len(w.pending) || len(w.pending) >= w.t.sp.chunk {
+		w.flush()
+		w.start = pos
+	}
+	w.pending = append(w.pending, values...)
+}
+
+func (w *scatter) flush() {
+	if len(w.pending) > 0 {
+		w.t.sp.writeValues(w.t.file, w.start, w.pending)
+		w.pending = w.pending[:0]
+	}
+}
+
+// rowWriter appends rows of any length to a table in order, for rows whose
+// lengths are not known in advance.
+type rowWriter struct {
+	t   *table
+	w   *bufio.Writer
+	buf [valueSize]byte
+}
+
+func (sp *spill) rowWriter(rows int) *rowWriter {
+	t := &table{sp: sp, file: sp.create(), starts: make([]int, 1, rows+1)}
+	if t.file == nil {
+		return &rowWriter{t: t, w: bufio.NewWriter(ioutil.Discard)}
+	}
+	return &rowWriter{t: t, w: bufio.NewWriter(t.file)}
+}
+
+// add appends values as the next row.
+func (rw *rowWriter) add(values []int) {
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(rw.buf[:], uint64(int64(v)))
+		if _, err := rw.w.Write(rw.buf[:]); err != nil {
+			rw.t.sp.fail(err)
+		}
+	}
+	rw.t.starts = append(rw.t.starts, rw.t.starts[len(rw.t.starts)-1]+len(values))
+}
+
+// table returns the table written.
+func (rw *rowWriter) table() *table {
+	if err := rw.w.Flush(); err != nil {
+		rw.t.sp.fail(err)
+	}
+	return rw.t
+}
+
+// spilledGraph holds the moves of a graph, as pairs of an action and a
+// destination, and the sources of the moves into every state, in tables.
+type spilledGraph struct {
+	succs, preds *table
+}
+
+// spillMoves fills g.spilled with the moves of the transitions of ltss, as
+// newGraph does in memory. Only counts per state stay in memory: the moves
+// are written to a file as they are read, then sorted state by state into
+// another.
+func (g *graph) spillMoves(ltss [2]pifra.Lts, actions map[pifra.Label]int, graded bool, sp *spill) {
+	n := len(g.states)
+	lengths := make([]int, n)
+	for side, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			lengths[g.index[State{Side(side), trans.Source}]] += 2
+		}
+	}
+	raw := sp.newTable(lengths)
+	w := raw.scatter()
+	for side, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			s := g.index[State{Side(side), trans.Source}]
+			t := g.index[State{Side(side), trans.Destination}]
+			w.add(s, actions[trans.Label], t)
+		}
+	}
+	w.flush()
+
+	succs := sp.rowWriter(n)
+	indegrees := make([]int, n)
+	for s := 0; s < n; s++ {
+		moves := g.tidy(decodeMoves(raw.row(s)), graded, func(dst int) { indegrees[dst]++ })
+		succs.add(encodeMoves(moves))
+	}
+	sp.remove(raw.file)
+	g.spilled = &spilledGraph{succs: succs.table(), preds: sp.newTable(indegrees)}
+
+	preds := g.spilled.preds.scatter()
+	for s := 0; s < n; s++ {
+		moves := decodeMoves(g.spilled.succs.row(s))
+		for i, m := range moves {
+			if i == 0 || m != moves[i-1] {
+				preds.add(m.dst, s)
+			}
+		}
+	}
+	preds.flush()
+}
+
+func decodeMoves(values []int) []move {
+	moves := make([]move, len(values)/2)
+	for i := range moves {
+		moves[i] = move{values[2*i], values[2*i+1]}
+	}
+	return moves
+}
+
+func encodeMoves(moves []move) []int {
+	values := make([]int, 0, 2*len(moves))
+	for _, m := range moves {
+		values = append(values, m.action, m.dst)
+	}
+	return values
+}
+
+// members holds the states of the blocks of a Refiner.
+type members interface {
+	// get returns the states of the block id.
+	get(id int) []int
+	// divide keeps the states of groups[0] in the block id and moves those of
+	// groups[i] to the new block ids[i-1]. The groups hold the states of the
+	// block between them.
+	divide(id int, groups [][]int, ids []int)
+	len() int
+	// ids returns the IDs of the blocks in increasing order.
+	ids() []int
+}
+
+type memMembers map[int][]int
+
+func (m memMembers) get(id int) []int {
+	return m[id]
+}
+
+func (m memMembers) divide(id int, groups [][]int, ids []int) {
+	m[id] = groups[0]
+	for i, group := range groups[1:] {
+		m[ids[i]] = group
+	}
+}
+
+func (m memMembers) len() int {
+	return len(m)
+}
+
+func (m memMembers) ids() []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// span is a range of positions in a file.
+type span struct {
+	first, end int
+}
+
+// fileMembers spills the states of the blocks to a file, where every block
+// is a span of the states. A block divides within its span, so that the
+// file keeps the size of the states, and only the spans stay in memory.
+type fileMembers struct {
+	sp    *spill
+	file  *os.File
+	spans map[int]span
+}
+
+// spillMembers returns fileMembers holding the blocks of m.
+func (sp *spill) spillMembers(m memMembers) *fileMembers {
+	fm := &fileMembers{sp: sp, file: sp.create(), spans: make(map[int]span, len(m))}
+	pos := 0
+	for _, id := range m.ids() {
+		sp.writeValues(fm.file, pos, m[id])
+		fm.spans[id] = span{pos, pos + len(m[id])}
+		pos += len(m[id])
+	}
+	return fm
+}
+
+func (fm *fileMembers) get(id int) []int {
+	s := fm.spans[id]
+	return fm.sp.readValues(fm.file, s.first, s.end-s.first)
+}
+
+func (fm *fileMembers) divide(id int, groups [][]int, ids []int) {
+	pos := fm.spans[id].first
+	for i, group := range groups {
+		fm.sp.writeValues(fm.file, pos, group)
+		if i == 0 {
+			fm.spans[id] = span{pos, pos + len(group)}
+		} else {
+			fm.spans[ids[i-1]] = span{pos, pos + len(group)}
+		}
+		pos += len(group)
+	}
+}
+
+func (fm *fileMembers) len() int {
+	return len(fm.spans)
+}
+
+func (fm *fileMembers) ids() []int {
+	ids := make([]int, 0, len(fm.spans))
+	for id := range fm.spans {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,71 @@
+package bisim
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// knownEquivalent groups the states whose moves are identical up to the
+// groups of their destinations, and which share a block, and returns the
+// representative of the group of every state, its smallest member. Groups
+// are merged until none can be: every merge relates states whose moves match
+// up to the pairs already related, so the groups are a bisimulation up to
+// equivalence, and their members are bisimilar. The groups only catch states
+// with literally matching moves, such as the copies pifra generates for
+// structurally congruent processes.
+func (r *Refiner) knownEquivalent() []int {
+	parent := make([]int, len(r.g.states))
+	for s := range parent {
+		parent[s] = s
+	}
+	var find func(int) int
+	find = func(s int) int {
+		if parent[s] != s {
+			parent[s] = find(parent[s])
+		}
+		return parent[s]
+	}
+	for changed := true; changed; {
+		changed = false
+		groups := make(map[string]int)
+		for s := range r.g.states {
+			moves := r.g.succ(s)
+			var key strings.Builder
+			key.WriteString(strconv.Itoa(r.blockOf[s]))
+			renamed := make([]move, len(moves))
+			for i, m := range moves {
+				renamed[i] = move{m.action, find(m.dst)}
+			}
+			sort.Slice(renamed, func(i, j int) bool {
+				if renamed[i].action != renamed[j].action {
+					return renamed[i].action < renamed[j].action
+				}
+				return renamed[i].dst < renamed[j].dst
+			})
+			for i, m := range renamed {
+				if i == 0 || m != renamed[i-1] {
+					fmt.Fprintf(&key, " %d:%d", m.action, m.dst)
+				}
+			}
+			other, ok := groups[key.String()]
+			if !ok {
+				groups[key.String()] = s
+				continue
+			}
+			if a, b := find(s), find(other); a != b {
+				if a < b {
+					a, b = b, a
+				}
+				parent[a] = b
+				changed = true
+			}
+		}
+	}
+	reps := make([]int, len(parent))
+	for s := range reps {
+		reps[s] = find(s)
+	}
+	return reps
+}
@@ -0,0 +1,232 @@
+package bisim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/ltsfile"
+)
+
+// checkEvery is the number of states or transitions written between two
+// checks of the context by the writers.
+const checkEvery = 1024
+
+// emitter writes an LTS through a buffer, checking its context every
+// checkEvery states or transitions. It keeps the first error, after which it
+// writes nothing.
+type emitter struct {
+	ctx context.Context
+	w   *bufio.Writer
+	n   int
+	err error
+}
+
+func newEmitter(ctx context.Context, w io.Writer) *emitter {
+	return &emitter{ctx: ctx, w: bufio.NewWriter(w), err: ctx.Err()}
+}
+
+// next counts a state or transition about to be written, and reports
+// whether to go on.
+func (e *emitter) next() bool {
+	if e.err == nil && e.n%checkEvery == 0 {
+		e.err = e.ctx.Err()
+	}
+	e.n++
+	return e.err == nil
+}
+
+func (e *emitter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *emitter) write(data []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(data)
+	}
+}
+
+// close flushes what was written and returns the first error.
+func (e *emitter) close() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// sortedStates returns the IDs of the states of lts in increasing order.
+func sortedStates(lts pifra.Lts) []int {
+	states := make([]int, 0, len(lts.States))
+	for state := range lts.States {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	return states
+}
+
+func prettyConfiguration(conf pifra.Configuration) string {
+	if conf.Process == nil {
+		return ""
+	}
+	return pifra.PrettyPrintConfiguration(conf)
+}
+
+// WriteDOT writes lts to w as a Graphviz graph, its states labelled by their
+// IDs, the initial state drawn double and the states where pifra hit its
+// register bound triple. It stops with the error of ctx once ctx is done.
+func WriteDOT(ctx context.Context, w io.Writer, lts pifra.Lts) error {
+	e := newEmitter(ctx, w)
+	initial := initialState(lts)
+	e.printf("digraph {\n")
+	for _, state := range sortedStates(lts) {
+		if !e.next() {
+			break
+		}
+		var attrs string
+		if lts.RegSizeReached[state] {
+			attrs = "peripheries=3,"
+		} else if state == initial {
+			attrs = "peripheries=2,"
+		}
+		e.printf("    %d [%slabel=\"%d\"]\n", state, attrs, state)
+	}
+	e.printf("\n")
+	for _, trans := range lts.Transitions {
+		if !e.next() {
+			break
+		}
+		label := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(trans.Label.PrettyPrintGraph())
+		e.printf("    %d -> %d [label=\"%s\"]\n", trans.Source, trans.Destination, label)
+	}
+	e.printf("}\n")
+	return e.close()
+}
+
+// xmlText escapes s for XML character data and attribute values. Characters
+// that XML cannot represent, such as most control characters, are replaced by
+// U+FFFD.
+func xmlText(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// WriteGraphML writes lts to w as GraphML, with configurations on nodes and
+// labels on edges. It stops with the error of ctx once ctx is done.
+func WriteGraphML(ctx context.Context, w io.Writer, lts pifra.Lts) error {
+	e := newEmitter(ctx, w)
+	e.printf("%s", xml.Header)
+	e.printf(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	e.printf(`  <key id="configuration" for="node" attr.name="configuration" attr.type="string"/>` + "\n")
+	e.printf(`  <key id="truncated" for="node" attr.name="truncated" attr.type="boolean"/>` + "\n")
+	e.printf(`  <key id="label" for="edge" attr.name="label" attr.type="string"/>` + "\n")
+	e.printf(`  <graph id="lts" edgedefault="directed">` + "\n")
+	for _, state := range sortedStates(lts) {
+		if !e.next() {
+			break
+		}
+		e.printf("    <node id=\"s%d\">\n", state)
+		e.printf("      <data key=\"configuration\">%s</data>\n",
+			xmlText(prettyConfiguration(lts.States[state])))
+		if lts.RegSizeReached[state] {
+			e.printf("      <data key=\"truncated\">true</data>\n")
+		}
+		e.printf("    </node>\n")
+	}
+	for _, trans := range lts.Transitions {
+		if !e.next() {
+			break
+		}
+		e.printf("    <edge source=\"s%d\" target=\"s%d\">\n", trans.Source, trans.Destination)
+		e.printf("      <data key=\"label\">%s</data>\n", xmlText(trans.Label.PrettyPrintGraph()))
+		e.printf("    </edge>\n")
+	}
+	e.printf("  </graph>\n</graphml>\n")
+	return e.close()
+}
+
+// WriteJSON writes lts to w as the JSON object pisim reads and writes under
+// -output-encoding json, with its states in increasing order. It stops with
+// the error of ctx once ctx is done.
+func WriteJSON(ctx context.Context, w io.Writer, lts pifra.Lts) error {
+	e := newEmitter(ctx, w)
+	// The object is written as json.MarshalIndent would with an indent of
+	// two spaces, an element at a time.
+	element := func(i int, v interface{}) {
+		if i > 0 {
+			e.printf(",")
+		}
+		data, err := json.MarshalIndent(v, "    ", "  ")
+		if err != nil {
+			e.err = err
+		}
+		e.printf("\n    ")
+		e.write(data)
+	}
+	states := sortedStates(lts)
+	e.printf("{\n  \"states\": [")
+	for i, state := range states {
+		if !e.next() {
+			break
+		}
+		element(i, ltsfile.JSONState{
+			ID:             state,
+			Configuration:  prettyConfiguration(lts.States[state]),
+			RegSizeReached: lts.RegSizeReached[state],
+		})
+	}
+	if len(states) > 0 {
+		e.printf("\n  ")
+	}
+	e.printf("],\n  \"transitions\": [")
+	for i, trans := range lts.Transitions {
+		if !e.next() {
+			break
+		}
+		element(i, ltsfile.JSONTransition{
+			Source:      trans.Source,
+			Destination: trans.Destination,
+			Label:       trans.Label.PrettyPrintGraph(),
+		})
+	}
+	if len(lts.Transitions) > 0 {
+		e.printf("\n  ")
+	}
+	e.printf("]\n}\n")
+	return e.close()
+}
+
+// WriteAut writes lts to w in the Aldebaran format, which pisim reads. The
+// states are numbered from 0 in increasing order of ID, silent labels are
+// written i and the others quoted as pifra prints them. It stops with the
+// error of ctx once ctx is done.
+func WriteAut(ctx context.Context, w io.Writer, lts pifra.Lts) error {
+	e := newEmitter(ctx, w)
+	states := stateIDs(lts)
+	number := make(map[int]int, len(states))
+	for i, state := range states {
+		number[state] = i
+	}
+	e.printf("des (%d, %d, %d)\n", number[initialState(lts)], len(lts.Transitions), len(states))
+	for _, trans := range lts.Transitions {
+		if !e.next() {
+			break
+		}
+		label := "i"
+		if !isTau(trans.Label) {
+			label = strconv.Quote(trans.Label.PrettyPrintGraph())
+		}
+		e.printf("(%d, %s, %d)\n", number[trans.Source], label, number[trans.Destination])
+	}
+	return e.close()
+}
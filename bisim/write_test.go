@@ -0,0 +1,127 @@
+package bisim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/ltsfile"
+)
+
+func TestWriteAut(t *testing.T) {
+	// The states are numbered by increasing ID from 0, so state 5 becomes 2.
+	lts := makeLTS(0, trans(0, a, 3), trans(3, tauLabel, 5), trans(5, b, 0))
+	for _, s := range []int{0, 3, 5} {
+		lts.States[s] = pifra.Configuration{}
+	}
+	var buf bytes.Buffer
+	if err := WriteAut(context.Background(), &buf, lts); err != nil {
+		t.Fatal(err)
+	}
+	want := "des (0, 3, 3)\n(0, \"1 1\", 1)\n(1, i, 2)\n(2, \"2 1\", 0)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestWritersEscape gives a state a configuration holding characters that
+// XML and JSON must escape, and checks that both outputs parse back to it,
+// but for the control byte XML cannot hold.
+func TestWritersEscape(t *testing.T) {
+	lts := makeLTS(0, trans(0, a, 1))
+	const name = "x<y&z\x01"
+	lts.States[0] = pifra.Configuration{
+		Process:   &pifra.ElemNil{},
+		Registers: pifra.Registers{Size: 1, Registers: map[int]string{1: name}},
+	}
+	lts.States[1] = pifra.Configuration{}
+
+	var graphml bytes.Buffer
+	if err := WriteGraphML(context.Background(), &graphml, lts); err != nil {
+		t.Fatal(err)
+	}
+	d := xml.NewDecoder(&graphml)
+	var text string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("GraphML does not parse: %v", err)
+		}
+		if data, ok := tok.(xml.CharData); ok && strings.Contains(string(data), "x<y") {
+			text = string(data)
+		}
+	}
+	if !strings.Contains(text, "x<y&z\uFFFD") {
+		t.Errorf("GraphML configuration %q does not hold %q", text, "x<y&z\uFFFD")
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(context.Background(), &js, lts); err != nil {
+		t.Fatal(err)
+	}
+	var out ltsfile.JSONLTS
+	if err := json.Unmarshal(js.Bytes(), &out); err != nil {
+		t.Fatalf("JSON does not parse: %v", err)
+	}
+	if len(out.States) != 2 || !strings.Contains(out.States[0].Configuration, name) {
+		t.Errorf("JSON states %+v do not hold %q", out.States, name)
+	}
+}
+
+// cancellingWriter cancels its context on the first write it receives.
+type cancellingWriter struct {
+	cancel  context.CancelFunc
+	written int
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	w.written += len(p)
+	return len(p), nil
+}
+
+// TestWritersCancelled cancels each writer once it starts writing an LTS too
+// large for a single buffer, and checks that it stops with the error of the
+// context well before the end.
+func TestWritersCancelled(t *testing.T) {
+	lts := chain(50000)
+	for name, write := range map[string]func(context.Context, io.Writer, pifra.Lts) error{
+		"dot":     WriteDOT,
+		"graphml": WriteGraphML,
+		"json":    WriteJSON,
+		"aut":     WriteAut,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var full bytes.Buffer
+			if err := write(context.Background(), &full, lts); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &cancellingWriter{cancel: cancel}
+			if err := write(ctx, w, lts); err != context.Canceled {
+				t.Fatalf("got %v, want %v", err, context.Canceled)
+			}
+			if w.written == 0 || w.written >= full.Len()/2 {
+				t.Errorf("wrote %d of %d bytes before stopping", w.written, full.Len())
+			}
+		})
+	}
+}
+
+func TestWritersDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if err := WriteDOT(ctx, &buf, chain(2)); err != context.Canceled || buf.Len() != 0 {
+		t.Errorf("got %v and %q, want %v and nothing written", err, buf.String(), context.Canceled)
+	}
+}
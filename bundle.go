@@ -0,0 +1,121 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// Entries of a bundle holding the LTSs to compare.
+const (
+	bundleLeft  = "left.gob"
+	bundleRight = "right.gob"
+)
+
+// loadBundle reads and preprocesses both sides of the comparison from the
+// entries leftName and rightName of a tar archive, by default left.gob and
+// right.gob.
+func loadBundle(name, leftName, rightName string) (left, right pifra.Lts, err error) {
+	if leftName == "" {
+		leftName = bundleLeft
+	}
+	if rightName == "" {
+		rightName = bundleRight
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var found [2]bool
+	tr := tar.NewReader(f)
+	for {
+		var hdr *tar.Header
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return left, right, fmt.Errorf("%s: %v", name, err)
+		}
+		entry := path.Clean(hdr.Name)
+		if entry != leftName && entry != rightName {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return left, right, fmt.Errorf("%s: %v", name, err)
+		}
+		for side, want := range []string{leftName, rightName} {
+			if entry != want {
+				continue
+			}
+			lts, err := bisim.LoadLTS(bytes.NewReader(data))
+			if err == nil {
+				err = prepareSide(&lts, Side(side) == RightSide)
+			}
+			if err != nil {
+				return left, right, fmt.Errorf("%s LTS %s:%s: %v", Side(side), name, hdr.Name, err)
+			}
+			if Side(side) == LeftSide {
+				left = lts
+			} else {
+				right = lts
+			}
+			found[side] = true
+		}
+	}
+	for side, entry := range []string{leftName, rightName} {
+		if !found[side] {
+			return left, right, fmt.Errorf("%s: no %s entry", name, entry)
+		}
+	}
+	return left, right, nil
+}
+
+func checkCommand(args []string) {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(),
+			"usage: pisim check [flags] bundle.tar|pair.pls [prefix]\n\n"+
+				"Compares two entries of a tar archive, by default left.gob and right.gob,\n"+
+				"or of a pack written by pisim pack, by default its first two, taking the\n"+
+				"same flags as a comparison of two files.")
+		flag.PrintDefaults()
+	}
+	args = parseArgs(flag.CommandLine, args)
+	check(loadConfig(flag.CommandLine))
+	if *weak {
+		*equivalence = "weak"
+	}
+	if len(args) < 1 || len(args) > 2 {
+		log.Fatalln("Wrong number of arguments")
+	}
+	check(validateSides())
+	if needsPrefix() && len(args) < 2 {
+		log.Fatalln(errNoOutput)
+	}
+	if *saveBisim != "" {
+		log.Fatalln("-save-bisim needs the LTSs in separate files")
+	}
+	inputFiles = args[:1]
+	load := loadBundle
+	if isPack(args[0]) {
+		load = loadPack
+	}
+	left, right, err := load(args[0], *leftEntry, *rightEntry)
+	check(err)
+	var prefix string
+	if len(args) > 1 {
+		prefix = args[1]
+	}
+	compare(left, right, []string{args[0], args[0]}, prefix)
+}
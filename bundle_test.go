@@ -0,0 +1,67 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTar writes a tar archive holding the given files to name in dir,
+// each under its entry name.
+func writeTar(t *testing.T, dir, name string, entries map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for entry, file := range entries {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: entry, Mode: 0o644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeTestFile(t, dir, name, buf.String())
+}
+
+// TestCheckBundle compares the fixtures of testdata/sides bundled in a tar
+// archive, and checks the verdicts and explanations against those of the
+// separate files.
+func TestCheckBundle(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right, permuted := filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob"),
+		filepath.Join(sides, "permuted.gob")
+	dir := t.TempDir()
+	bundle := writeTar(t, dir, "bundle.tar", map[string]string{
+		"left.gob": left, "./right.gob": right, "models/permuted.gob": permuted,
+	})
+	for _, test := range []struct {
+		args        []string
+		left, right string
+	}{
+		{nil, left, right},
+		{[]string{"-right-name", "models/permuted.gob"}, left, permuted},
+		{[]string{"-left-name", "right.gob", "-right-name", "right.gob"}, right, right},
+	} {
+		want, _, wantCode := runPisim(t, dir, "-quiet", "-explain", test.left, test.right)
+		args := append(append([]string{"check", "-quiet", "-explain"}, test.args...), bundle)
+		got, stderr, code := runPisim(t, dir, args...)
+		if code != wantCode || got != want {
+			t.Errorf("%v: status %d and %q, want %d and %q: %s", test.args, code, got, wantCode, want, stderr)
+		}
+	}
+	_, stderr, code := runPisim(t, dir, "check", "-quiet", "-left-name", "missing.gob", bundle)
+	if code != 1 || !strings.Contains(stderr, "no missing.gob entry") {
+		t.Errorf("missing entry: status %d and %q, want an error naming it", code, stderr)
+	}
+}
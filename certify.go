@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var certify = flag.Bool("certify", false,
+	"append to each dot file a certificate of the stability of its classes, checked by pisim check-cert")
+
+// certificateHeader starts the comments holding a certificate.
+const certificateHeader = "// pisim certificate 1:"
+
+// A StabilityCertificate records, for the states of one LTS, their classes
+// and the classes each label leads to from every member of a class. It holds
+// if every member of a class moves by each label into exactly the recorded
+// classes, which makes the classes a stable partition of the LTS.
+type StabilityCertificate struct {
+	Equivalence string
+	Equivariant bool
+	// Members lists the original IDs of the states of each class.
+	Members map[int][]int
+	// Moves maps each class and the printed form of a label to the sorted
+	// classes its members reach by that label.
+	Moves map[int]map[string][]int
+}
+
+// validateCertify refuses the options under which a certificate could not be
+// checked against the original LTS alone.
+func validateCertify() error {
+	if !*certify {
+		return nil
+	}
+	if _, ok := refinements[*equivalence]; !ok || *equivalence == "eta" || *sim {
+		return errors.New("-certify needs strong, weak or delay bisimilarity")
+	}
+	if len(observation.Classes) > 0 || *undirected || *tauPattern != "" || *dropSelfLoops != "" || *project != "" ||
+		*labelEquivFile != "" {
+		return errors.New("-certify cannot be combined with -observe, -hide, -observe-only, -strip-annotations, " +
+			"-undirected, -tau, -drop-self-loops, -project or -label-equiv")
+	}
+	// check-cert checks a forward bisimulation, which a backward one is not.
+	if *backward || *forwardBackward {
+		return errors.New("-certify cannot be combined with -backward or -forward-backward")
+	}
+	return nil
+}
+
+// stateMoves returns the classes state reaches by each label, sorted.
+func stateMoves(succs map[int][]pifra.Transition, classOf func(int) (int, bool), state int) (map[string][]int, error) {
+	sets := make(map[string]map[int]bool)
+	for _, trans := range succs[state] {
+		class, ok := classOf(trans.Destination)
+		if !ok {
+			return nil, errors.New("a transition leads to a state without a class")
+		}
+		text := trans.Label.PrettyPrintGraph()
+		if sets[text] == nil {
+			sets[text] = make(map[int]bool)
+		}
+		sets[text][class] = true
+	}
+	moves := make(map[string][]int, len(sets))
+	for text, set := range sets {
+		for class := range set {
+			moves[text] = append(moves[text], class)
+		}
+		sort.Ints(moves[text])
+	}
+	return moves, nil
+}
+
+func equalMoves(a, b map[string][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for text, classes := range a {
+		if !reflect.DeepEqual(classes, b[text]) {
+			return false
+		}
+	}
+	return true
+}
+
+// newCertificate computes the certificate of the states of lts, the LTS
+// refined for one side, under the classes of bisim; sides tells the side of
+// every state. It fails if two members of a class disagree, that is if the
+// classes are not stable.
+func newCertificate(bisim Bisimulation, sides Sides, lts pifra.Lts) (StabilityCertificate, error) {
+	cert := StabilityCertificate{
+		Equivalence: *equivalence,
+		Equivariant: *equivariant,
+		Members:     make(map[int][]int),
+		Moves:       make(map[int]map[string][]int),
+	}
+	succs := successors(lts)
+	classOf := func(state int) (int, bool) {
+		class, ok := bisim[state]
+		return class, ok
+	}
+	states := make([]int, 0, len(lts.States))
+	for state := range lts.States {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	for _, state := range states {
+		class := bisim[state]
+		moves, err := stateMoves(succs, classOf, state)
+		if err != nil {
+			return cert, err
+		}
+		if first, ok := cert.Moves[class]; !ok {
+			cert.Moves[class] = moves
+		} else if !equalMoves(first, moves) {
+			return cert, fmt.Errorf("states %s and %s of class %d move differently",
+				decodedName(sides[state].Side, cert.Members[class][0]), stateName(sides, state), class)
+		}
+		cert.Members[class] = append(cert.Members[class], sides[state].ID)
+	}
+	return cert, nil
+}
+
+// verify checks the certificate against lts, decoded from the original file.
+func (cert StabilityCertificate) verify(lts pifra.Lts) error {
+	classes := make(map[int]int)
+	for class, members := range cert.Members {
+		for _, state := range members {
+			if _, ok := lts.States[state]; !ok {
+				return fmt.Errorf("class %d: no state %d", class, state)
+			}
+			if other, ok := classes[state]; ok {
+				return fmt.Errorf("state %d is in classes %d and %d", state, other, class)
+			}
+			classes[state] = class
+		}
+	}
+	for state := range lts.States {
+		if _, ok := classes[state]; !ok {
+			return fmt.Errorf("state %d has no class", state)
+		}
+	}
+	if cert.Equivariant {
+		canonicaliseLTS(&lts)
+	}
+	saturated, ok := refinements[cert.Equivalence]
+	if !ok || cert.Equivalence == "eta" {
+		return fmt.Errorf("cannot check a certificate for %q", cert.Equivalence)
+	}
+	if saturated {
+		lts = bisim.Saturate(lts, cert.Equivalence == "weak", IsTau)
+	}
+	succs := successors(lts)
+	classOf := func(state int) (int, bool) {
+		class, ok := classes[state]
+		return class, ok
+	}
+	for state, class := range classes {
+		moves, err := stateMoves(succs, classOf, state)
+		if err != nil {
+			return err
+		}
+		if !equalMoves(moves, cert.Moves[class]) {
+			return fmt.Errorf("state %d does not move as recorded for class %d", state, class)
+		}
+	}
+	return nil
+}
+
+// comments prints the certificate as dot comments.
+func (cert StabilityCertificate) comments() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s equivalence %s, equivariant %v\n", certificateHeader, cert.Equivalence, cert.Equivariant)
+	classes := make([]int, 0, len(cert.Members))
+	for class := range cert.Members {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+	for _, class := range classes {
+		fmt.Fprintf(&buf, "// class %d: %s\n", class, joinInts(cert.Members[class]))
+		texts := make([]string, 0, len(cert.Moves[class]))
+		for text := range cert.Moves[class] {
+			texts = append(texts, text)
+		}
+		sort.Strings(texts)
+		for _, text := range texts {
+			fmt.Fprintf(&buf, "// class %d on %s: %s\n", class, text, joinInts(cert.Moves[class][text]))
+		}
+	}
+	return buf.Bytes()
+}
+
+func joinInts(ints []int) string {
+	texts := make([]string, len(ints))
+	for i, n := range ints {
+		texts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(texts, " ")
+}
+
+func splitInts(text string) ([]int, error) {
+	var ints []int
+	for _, field := range strings.Fields(text) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// readCertificate parses the certificate comments of a dot file.
+func readCertificate(name string) (cert StabilityCertificate, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	cert.Members = make(map[int][]int)
+	cert.Moves = make(map[int]map[string][]int)
+	found := false
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if strings.HasPrefix(text, certificateHeader) {
+			_, err = fmt.Sscanf(strings.TrimPrefix(text, certificateHeader), " equivalence %s equivariant %t",
+				&cert.Equivalence, &cert.Equivariant)
+			cert.Equivalence = strings.TrimSuffix(cert.Equivalence, ",")
+			if err != nil {
+				return cert, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			found = true
+			continue
+		}
+		if !found || !strings.HasPrefix(text, "// class ") {
+			continue
+		}
+		colon := strings.LastIndex(text, ": ")
+		if colon < 0 {
+			return cert, fmt.Errorf("%s:%d: malformed certificate line", name, line)
+		}
+		head := strings.TrimPrefix(text[:colon], "// class ")
+		ints, err := splitInts(text[colon+2:])
+		if err != nil {
+			return cert, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		var class int
+		if i := strings.Index(head, " on "); i >= 0 {
+			if class, err = strconv.Atoi(head[:i]); err != nil {
+				return cert, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			if cert.Moves[class] == nil {
+				cert.Moves[class] = make(map[string][]int)
+			}
+			cert.Moves[class][head[i+4:]] = ints
+		} else {
+			if class, err = strconv.Atoi(head); err != nil {
+				return cert, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			cert.Members[class] = ints
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if !found {
+		return cert, fmt.Errorf("%s: no certificate", name)
+	}
+	return cert, nil
+}
+
+// withCertificate inserts the comments of cert before the closing brace of
+// the dot graph data.
+func withCertificate(data []byte, cert StabilityCertificate) []byte {
+	body := bytes.TrimSuffix(data, []byte("}\n"))
+	out := make([]byte, 0, len(data)+1024)
+	out = append(out, body...)
+	out = append(out, '\n')
+	out = append(out, cert.comments()...)
+	return append(out, "}\n"...)
+}
+
+func checkCertCommand(args []string) {
+	fs := flag.NewFlagSet("check-cert", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim check-cert file.dot original.gob\n\n"+
+			"Checks the certificate written by -certify into a dot file against the\n"+
+			"LTS the dot file was drawn from.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cert, err := readCertificate(args[0])
+	check(err)
+	lts, err := decodeLTS(args[1])
+	check(err)
+	if err := cert.verify(lts); err != nil {
+		fmt.Printf("Certificate does not hold: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Certificate holds: %d classes over %d states\n", len(cert.Members), len(lts.States))
+}
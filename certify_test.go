@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCertifyDirection(t *testing.T) {
+	for _, name := range []string{"backward", "forward-backward"} {
+		t.Run(name, func(t *testing.T) {
+			setFlag(t, "certify", "true")
+			setFlag(t, name, "true")
+			err := validateCertify()
+			if err == nil || !strings.Contains(err.Error(), "-"+name) {
+				t.Errorf("validateCertify() = %v, want an error naming -%s", err, name)
+			}
+		})
+	}
+	setFlag(t, "certify", "true")
+	if err := validateCertify(); err != nil {
+		t.Errorf("validateCertify() = %v for forward strong bisimilarity", err)
+	}
+}
+
+func TestCertifyBackwardRefused(t *testing.T) {
+	dir := t.TempDir()
+	lts := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-certify", "-backward", lts, lts)
+	if code != 1 || !strings.Contains(stderr, "-backward") {
+		t.Errorf("got status %d and %q, want 1 and -backward refused", code, stderr)
+	}
+}
+
+// TestCheckCert certifies the classes of two pairs, checks the certificates
+// against the LTSs they were drawn from, and checks that they fail against
+// another LTS or once tampered with.
+func TestCheckCert(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, permuted := filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")
+	aa := writeTestFile(t, dir, "aa.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n")
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	for _, test := range []struct {
+		name, left, right string
+		args              []string
+		want              string
+	}{
+		{"strong", left, permuted, nil, "8 classes over 8 states"},
+		{"weak", left, permuted, []string{"-equivalence", "weak"}, "8 classes over 8 states"},
+		{"merged", aa, a, nil, "2 classes over 3 states"},
+	} {
+		out := filepath.Join(dir, test.name)
+		args := append(append([]string{"-certify"}, test.args...), test.left, test.right, out)
+		if _, stderr, code := runPisim(t, dir, args...); code != 0 {
+			t.Fatalf("%s: status %d, want 0: %s", test.name, code, stderr)
+		}
+		stdout, stderr, code := runPisim(t, dir, "check-cert", out+"-left.dot", test.left)
+		if code != 0 || stdout != "Certificate holds: "+test.want+"\n" {
+			t.Errorf("%s: status %d and %q, want the certificate to hold with %s: %s",
+				test.name, code, stdout, test.want, stderr)
+		}
+		if _, stderr, code := runPisim(t, dir, "check-cert", out+"-right.dot", test.right); code != 0 {
+			t.Errorf("%s: right certificate: status %d, want 0: %s", test.name, code, stderr)
+		}
+	}
+
+	stdout, _, code := runPisim(t, dir, "check-cert", filepath.Join(dir, "strong-left.dot"), permuted)
+	if code != 1 || !strings.HasPrefix(stdout, "Certificate does not hold: ") {
+		t.Errorf("another LTS: status %d and %q, want the certificate not to hold", code, stdout)
+	}
+	dot, err := os.ReadFile(filepath.Join(dir, "merged-left.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(dot), "// class 0 on 1 1: 1\n", "// class 0 on 1 1: 0 1\n", 1)
+	if tampered == string(dot) {
+		t.Fatalf("no move of class 0 to tamper with:\n%s", dot)
+	}
+	stdout, _, code = runPisim(t, dir, "check-cert", writeTestFile(t, dir, "tampered.dot", tampered), aa)
+	if code != 1 || stdout != "Certificate does not hold: state 0 does not move as recorded for class 0\n" {
+		t.Errorf("tampered: status %d and %q, want the certificate not to hold", code, stdout)
+	}
+	_, stderr, code := runPisim(t, dir, "check-cert", writeTestFile(t, dir, "plain.dot", "digraph {\n}\n"), aa)
+	if code != 1 || !strings.Contains(stderr, "plain.dot: no certificate") {
+		t.Errorf("no certificate: status %d and %q, want it refused", code, stderr)
+	}
+}
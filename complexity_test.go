@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// nondeterministicChain returns a chain of n states by one action, with a
+// second move of the initial state that keeps it off the Hopcroft fast path.
+// Refinement splits the chain one state at a time, from its end.
+func nondeterministicChain(n int) pifra.Lts {
+	lts := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, n),
+		RegSizeReached: make(map[int]bool),
+	}
+	a := pifra.Label{
+		Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: 1},
+		Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 1},
+	}
+	for s := 0; s < n; s++ {
+		lts.States[s] = pifra.Configuration{}
+		if s+1 < n {
+			lts.Transitions = append(lts.Transitions, pifra.Transition{
+				Source: s, Destination: s + 1, Label: a,
+			})
+		}
+	}
+	lts.Transitions = append(lts.Transitions, pifra.Transition{Source: 0, Destination: 2, Label: a})
+	return lts
+}
+
+// prepared returns copies of left and right prepared for refinement as by
+// loadSides.
+func prepared(t testing.TB, left, right pifra.Lts) (pifra.Lts, pifra.Lts) {
+	left, right = cloneLTS(left), cloneLTS(right)
+	if err := prepareSide(&left, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSide(&right, true); err != nil {
+		t.Fatal(err)
+	}
+	return left, right
+}
+
+// TestPartKSComplexity guards the number of split attempts of partKS against
+// growing quadratically, as it did when the refinement restarted its scan of
+// the partition after every split. It refines chains of doubling length,
+// each needing a split per state, and bounds the growth of the attempts
+// counted for -stats at each doubling. Linear growth doubles them, the
+// O(m·log n) bound of the worklist a little more, and quadratic growth
+// quadruples them: the tolerance of 3 allows for the logarithmic factor and
+// for the order of the worklist, which follows map iteration, while failing
+// on a quadratic regression. An attempt still scans the whole block it tries,
+// so the time of the test grows faster than the attempts.
+func TestPartKSComplexity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measures refinements of up to 4096 states")
+	}
+	const tolerance = 3.0
+	prev := 0
+	for n := 256; n <= 2048; n *= 2 {
+		left, right := prepared(t, nondeterministicChain(n), nondeterministicChain(n))
+		counters.attempts, counters.splits = 0, 0
+		partKS(left, right)
+		t.Logf("n=%d: %d splits, %d attempts", n, counters.splits, counters.attempts)
+		if counters.splits < n-1 {
+			t.Fatalf("n=%d: %d splits, want the chain split state by state", n, counters.splits)
+		}
+		if prev > 0 && float64(counters.attempts) > tolerance*float64(prev) {
+			t.Errorf("n=%d: %d attempts, over %g times the %d of half the size",
+				n, counters.attempts, tolerance, prev)
+		}
+		prev = counters.attempts
+	}
+}
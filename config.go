@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const defaultConfig = "pisim.json"
+
+var configFile = flag.String("config", "",
+	"read default flag values from `file` (default "+defaultConfig+" if present)")
+
+// configValue renders a JSON value in the textual form expected by flag.Set.
+// Lists become comma-separated values.
+func configValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, len(list))
+		for i, v := range list {
+			values[i] = configValue(v)
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// loadConfig sets every flag of fs that was not given on the command line from
+// the JSON object in the config file, keyed by flag name.
+func loadConfig(fs *flag.FlagSet) error {
+	name := *configFile
+	if name == "" {
+		if _, err := os.Stat(defaultConfig); err != nil {
+			return nil
+		}
+		name = defaultConfig
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for flagName, value := range values {
+		if fs.Lookup(flagName) == nil {
+			return fmt.Errorf("%s: unknown flag %q", name, flagName)
+		}
+		if set[flagName] {
+			continue
+		}
+		if err := fs.Set(flagName, configValue(value)); err != nil {
+			return fmt.Errorf("%s: flag %q: %v", name, flagName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	setFlag(t, "config", writeTestFile(t, dir, "team.json",
+		`{"equivalence": "weak", "hide": ["1 *", "2 *"], "limit": 3, "quiet": true}`))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	equivalence := fs.String("equivalence", "strong", "")
+	hide := fs.String("hide", "", "")
+	limit := fs.Int("limit", 0, "")
+	quiet := fs.Bool("quiet", false, "")
+	if err := fs.Parse([]string{"-limit", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *equivalence != "weak" || *hide != "1 *,2 *" || !*quiet {
+		t.Errorf("config set -equivalence %q, -hide %q and -quiet %v, want weak, \"1 *,2 *\" and true",
+			*equivalence, *hide, *quiet)
+	}
+	if *limit != 5 {
+		t.Errorf("-limit %d, want the 5 given on the command line", *limit)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct{ config, fails string }{
+		{`{"colour": "red"}`, `unknown flag "colour"`},
+		{`{"limit": "many"}`, `flag "limit"`},
+		{`{"limit": `, "team.json"},
+	} {
+		setFlag(t, "config", writeTestFile(t, dir, "team.json", test.config))
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("limit", 0, "")
+		if err := loadConfig(fs); err == nil || !strings.Contains(err.Error(), test.fails) {
+			t.Errorf("config %s: loadConfig() = %v, want an error mentioning %s", test.config, err, test.fails)
+		}
+	}
+}
+
+// TestDefaultConfig checks that pisim reads pisim.json from the working
+// directory: τ.a and a are weakly but not strongly bisimilar.
+func TestDefaultConfig(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, i, 1)\n(1, \"1 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	if _, _, code := runPisim(t, dir, "-quiet", left, right); code != 1 {
+		t.Fatalf("status %d without a config, want 1", code)
+	}
+	writeTestFile(t, dir, defaultConfig, `{"equivalence": "weak"}`)
+	if _, stderr, code := runPisim(t, dir, "-quiet", left, right); code != 0 {
+		t.Errorf("status %d with %s, want 0: %s", code, defaultConfig, stderr)
+	}
+}
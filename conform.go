@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yungene/pifra"
+)
+
+// ConformReport is the outcome of pisim conform.
+type ConformReport struct {
+	Spec        string   `json:"spec"`
+	Impl        string   `json:"impl"`
+	Equivalence string   `json:"equivalence"`
+	Hidden      []string `json:"hidden,omitempty"`
+	// PrunedSpec and PrunedImpl count the unreachable states dropped.
+	PrunedSpec int  `json:"pruned_spec"`
+	PrunedImpl int  `json:"pruned_impl"`
+	Conforms   bool `json:"conforms"`
+	// Reason explains a failure, and Action is an action distinguishing the
+	// initial states, if the equivalence is decided by refinement.
+	Reason   string   `json:"reason,omitempty"`
+	Action   string   `json:"action,omitempty"`
+	OnlySpec []string `json:"only_spec,omitempty"`
+	OnlyImpl []string `json:"only_impl,omitempty"`
+	Witness  string   `json:"witness,omitempty"`
+}
+
+// pruneUnreachable drops the states of lts that initial cannot reach, and
+// their transitions, and returns how many states were dropped.
+func pruneUnreachable(lts *pifra.Lts, initial int) int {
+	tree := newAccessTree(*lts, initial)
+	pruned := *lts
+	pruned.States = make(map[int]pifra.Configuration, len(tree.dist))
+	pruned.RegSizeReached = make(map[int]bool)
+	for state, conf := range lts.States {
+		if _, ok := tree.dist[state]; ok {
+			pruned.States[state] = conf
+			if lts.RegSizeReached[state] {
+				pruned.RegSizeReached[state] = true
+			}
+		}
+	}
+	pruned.Transitions = nil
+	for _, trans := range lts.Transitions {
+		if _, ok := pruned.States[trans.Source]; ok {
+			pruned.Transitions = append(pruned.Transitions, trans)
+		}
+	}
+	n := len(lts.States) - len(pruned.States)
+	*lts = pruned
+	return n
+}
+
+// visibleTexts prints the visible actions among actions.
+func visibleTexts(actions []pifra.Label) []string {
+	var texts []string
+	for _, action := range actions {
+		if !IsTau(action) {
+			texts = append(texts, actionText(action))
+		}
+	}
+	return texts
+}
+
+// conform checks spec against impl after hiding in impl and pruning both
+// down to what initial, their initial states, reach.
+func conform(spec, impl pifra.Lts, initial [2]int, report *ConformReport) error {
+	if err := checkLabels(&spec, &impl); err != nil {
+		return err
+	}
+	hiding := Observation{}
+	if len(report.Hidden) > 0 {
+		hiding.Classes = []ObservationClass{{Name: "hidden", Patterns: report.Hidden, Silent: true}}
+	}
+	if err := hiding.validate(); err != nil {
+		return err
+	}
+	impl = hiding.observe(impl)
+	report.PrunedSpec = pruneUnreachable(&spec, initial[LeftSide])
+	report.PrunedImpl = pruneUnreachable(&impl, initial[RightSide])
+	onlySpec, onlyImpl := alphabetDifference(spec, impl)
+	report.OnlySpec, report.OnlyImpl = visibleTexts(onlySpec), visibleTexts(onlyImpl)
+
+	if _, ok := refinements[*equivalence]; !ok {
+		var err error
+		checkInternal(safely(func() {
+			report.Conforms, report.Reason, err = checkEquivalence(*equivalence, spec, impl)
+		}))
+		return err
+	}
+	part, refSpec, refImpl := refineSides(spec, impl)
+	bisim := part.bisimilar()
+	report.Conforms = bisim != nil
+	if !report.Conforms {
+		report.Reason = "the initial states are not " + *equivalence + " bisimilar"
+		if action, ok := initialDistinction(part); ok {
+			report.Action = actionText(action)
+		}
+		return part.err()
+	}
+	if *witness != "" {
+		report.Witness = *witness
+		return writeWitness(bisim, part.sides, part.initial, refSpec, refImpl)
+	}
+	return nil
+}
+
+func conformCommand(args []string) {
+	fs := flag.NewFlagSet("conform", flag.ExitOnError)
+	hidden := fs.String("hide", "",
+		"hide implementation labels matching the comma-separated glob `patterns`")
+	fs.StringVar(equivalence, "equiv", "weak",
+		"equivalence to check: strong, weak, delay, eta, nested-sim-2, possible-futures or completed-trace")
+	reportFile := fs.String("report", "", "write the JSON report to `file` instead of stdout")
+	fs.StringVar(witness, "witness", "",
+		"on success, write a bisimulation relating the initial states to `file`")
+	fs.StringVar(tauPattern, "tau", "",
+		"also treat labels whose printed form matches the glob `pattern` as silent")
+	fs.BoolVar(force, "force", false, "overwrite existing output files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim conform spec.gob impl.gob [-hide patterns] [-equiv weak]")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	inputFiles = args
+	report := ConformReport{
+		Spec:        args[0],
+		Impl:        args[1],
+		Equivalence: *equivalence,
+		Hidden:      splitPatterns(*hidden),
+	}
+	spec, impl, err := loadSides(args[0], args[1])
+	check(err)
+	check(validateTau(spec, impl))
+	check(conform(spec, impl, initialStates, &report))
+	data, err := json.MarshalIndent(report, "", "  ")
+	check(err)
+	data = append(data, '\n')
+	if *reportFile != "" {
+		check(writeFile(*reportFile, data))
+	} else {
+		os.Stdout.Write(data)
+	}
+	if !report.Conforms {
+		os.Exit(1)
+	}
+}
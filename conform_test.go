@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestConform checks the reports of pisim conform on a spec a.b and an
+// implementation a.c.b with an unreachable state, which conforms once the
+// internal action c is hidden.
+func TestConform(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeTestFile(t, dir, "spec.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n")
+	impl := writeTestFile(t, dir, "impl.aut", "des (0, 4, 5)\n"+
+		"(0, \"1 1\", 1)\n(1, \"3 3\", 2)\n(2, \"2 2\", 3)\n(4, \"1 1\", 0)\n")
+	witness := filepath.Join(dir, "witness.txt")
+	report := filepath.Join(dir, "report.json")
+	for _, test := range []struct {
+		args []string
+		code int
+		want ConformReport
+	}{
+		{
+			[]string{"-hide", "3 *", "-witness", witness}, 0,
+			ConformReport{Equivalence: "weak", Hidden: []string{"3 *"}, PrunedImpl: 1,
+				Conforms: true, Witness: witness},
+		},
+		{
+			nil, 1,
+			ConformReport{Equivalence: "weak", PrunedImpl: 1,
+				Reason: "the initial states are not weak bisimilar", Action: "1 1", OnlyImpl: []string{"3 3"}},
+		},
+		{
+			[]string{"-equiv", "completed-trace"}, 1,
+			ConformReport{Equivalence: "completed-trace", PrunedImpl: 1,
+				Reason: "trace <1 1, 2 2>: only left can perform it", OnlyImpl: []string{"3 3"}},
+		},
+		{
+			[]string{"-equiv", "delay", "-hide", "3 *", "-report", report}, 0,
+			ConformReport{Equivalence: "delay", Hidden: []string{"3 *"}, PrunedImpl: 1, Conforms: true},
+		},
+	} {
+		os.Remove(report)
+		args := append(append([]string{"conform"}, test.args...), spec, impl)
+		stdout, stderr, code := runPisim(t, dir, args...)
+		if code != test.code {
+			t.Errorf("%v: status %d, want %d: %s", test.args, code, test.code, stderr)
+		}
+		data := []byte(stdout)
+		if _, err := os.Stat(report); err == nil {
+			if stdout != "" {
+				t.Errorf("%v: printed %q besides the report file", test.args, stdout)
+			}
+			if data, err = os.ReadFile(report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var got ConformReport
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%v: %v in %q", test.args, err, data)
+		}
+		test.want.Spec, test.want.Impl = spec, impl
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.args, got, test.want)
+		}
+	}
+	data, err := os.ReadFile(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# left right bisimulation\n0 0\n1 1\n1 2\n2 3\n"; string(data) != want {
+		t.Errorf("witness:\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// denseIDs maps, for each side, the dense IDs 0..n-1 given to the states of
+// its LTS on load back to their original IDs, and denseIndex maps them the
+// other way. Both are nil for a side whose IDs were already dense.
+var (
+	denseIDs   [2][]int
+	denseIndex [2]map[int]int
+)
+
+// inputIDs maps, for each side, the uniquified states of its LTS to their IDs
+// in its input, and uniqueIDs maps them the other way. uniquifyLTS records
+// both, so that the IDs of states are looked up rather than decoded.
+var (
+	inputIDs  [2]map[int]int
+	uniqueIDs [2]map[int]int
+)
+
+// renumber gives the states of lts, and the endpoints of its transitions, the
+// IDs 0..n-1 in the order of their original IDs, except that the initial
+// state 0 keeps ID 0. It records the mapping for side, and leaves lts as it is
+// if its IDs are already 0..n-1.
+func renumber(lts *pifra.Lts, side Side) {
+	denseIDs[side], denseIndex[side] = nil, nil
+	seen := make(map[int]bool, len(lts.States))
+	for id := range lts.States {
+		seen[id] = true
+	}
+	for _, trans := range lts.Transitions {
+		seen[trans.Source] = true
+		seen[trans.Destination] = true
+	}
+	ids := make([]int, 0, len(seen))
+	dense := true
+	for id := range seen {
+		ids = append(ids, id)
+		if id < 0 || id >= len(seen) {
+			dense = false
+		}
+	}
+	if dense {
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if (ids[i] == 0) != (ids[j] == 0) {
+			return ids[i] == 0
+		}
+		return ids[i] < ids[j]
+	})
+	index := make(map[int]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	states := make(map[int]pifra.Configuration, len(lts.States))
+	for id, conf := range lts.States {
+		states[index[id]] = conf
+	}
+	lts.States = states
+	reached := make(map[int]bool, len(lts.RegSizeReached))
+	for id, ok := range lts.RegSizeReached {
+		if i, found := index[id]; found {
+			reached[i] = ok
+		}
+	}
+	lts.RegSizeReached = reached
+	for i, trans := range lts.Transitions {
+		lts.Transitions[i].Source = index[trans.Source]
+		lts.Transitions[i].Destination = index[trans.Destination]
+	}
+	denseIDs[side], denseIndex[side] = ids, index
+}
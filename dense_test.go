@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestRenumber(t *testing.T) {
+	ids, index, initial := denseIDs, denseIndex, initialStates
+	t.Cleanup(func() { denseIDs, denseIndex, initialStates = ids, index, initial })
+
+	lts := pifra.Lts{
+		States:         map[int]pifra.Configuration{0: {}, 7: {}, 1000000: {}, 1 << 40: {}, -3: {}},
+		RegSizeReached: map[int]bool{1 << 40: true},
+		Transitions: []pifra.Transition{
+			{Source: 0, Destination: 7, Label: inputLabel(1, 1)},
+			{Source: 7, Destination: -3, Label: inputLabel(2, 2)},
+			{Source: 1 << 40, Destination: 0, Label: tauLabel},
+		},
+	}
+	dense := cloneLTS(lts)
+	renumber(&dense, RightSide)
+	if want := []int{0, -3, 7, 1000000, 1 << 40}; !reflect.DeepEqual(denseIDs[RightSide], want) {
+		t.Fatalf("dense IDs %v, want %v", denseIDs[RightSide], want)
+	}
+	for i, id := range denseIDs[RightSide] {
+		if denseIndex[RightSide][id] != i {
+			t.Errorf("state %d is dense %d, indexed as %d", id, i, denseIndex[RightSide][id])
+		}
+		if !reflect.DeepEqual(dense.States[i], lts.States[id]) || dense.RegSizeReached[i] != lts.RegSizeReached[id] {
+			t.Errorf("dense state %d does not hold state %d", i, id)
+		}
+	}
+	for i, trans := range dense.Transitions {
+		orig := lts.Transitions[i]
+		if denseIDs[RightSide][trans.Source] != orig.Source || denseIDs[RightSide][trans.Destination] != orig.Destination ||
+			trans.Label != orig.Label {
+			t.Errorf("transition %d is %v, want %v renumbered", i, trans, orig)
+		}
+	}
+
+	renumber(&dense, LeftSide)
+	if denseIDs[LeftSide] != nil || denseIndex[LeftSide] != nil {
+		t.Errorf("dense LTS renumbered by %v", denseIDs[LeftSide])
+	}
+}
+
+// TestSparseClasses compares an LTS of sparse IDs with a dense copy of it,
+// and checks that the classes saved as JSON name the original IDs.
+func TestSparseClasses(t *testing.T) {
+	dir := t.TempDir()
+	const big = 1 << 40
+	left := writeTestLTS(t, dir, "left.gob", pifra.Lts{
+		States: map[int]pifra.Configuration{0: {}, 7: {}, 1000000: {}, big: {}},
+		Transitions: []pifra.Transition{
+			{Source: 0, Destination: 7, Label: inputLabel(1, 1)},
+			{Source: 7, Destination: 1000000, Label: inputLabel(2, 2)},
+			{Source: 0, Destination: big, Label: inputLabel(1, 1)},
+		},
+	})
+	right := writeTestFile(t, dir, "right.aut",
+		"des (0, 3, 4)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(0, \"1 1\", 3)\n")
+	saved := filepath.Join(dir, "classes.json")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-save-bisim", saved, left, right); code != 0 {
+		t.Fatalf("status %d, want 0: %s", code, stderr)
+	}
+	data, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bisim SavedBisimulation
+	if err := json.Unmarshal(data, &bisim); err != nil {
+		t.Fatal(err)
+	}
+	var states []int
+	for state := range bisim.LeftClasses {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	if want := []int{0, 7, 1000000, big}; !reflect.DeepEqual(states, want) {
+		t.Fatalf("left classes of states %v, want %v", states, want)
+	}
+	for left, right := range map[int]int{0: 0, 7: 1, 1000000: 2, big: 3} {
+		if bisim.LeftClasses[left] != bisim.RightClasses[right] {
+			t.Errorf("left state %d in class %d, right state %d in class %d",
+				left, bisim.LeftClasses[left], right, bisim.RightClasses[right])
+		}
+	}
+	if bisim.LeftClasses[7] == bisim.LeftClasses[big] {
+		t.Errorf("left states 7 and %d in the same class %d", big, bisim.LeftClasses[7])
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// BenchmarkDestinations measures the lookup of the blocks a state reaches by
+// the silent action of an LTS where nine transitions in ten are silent,
+// against a linear scan of the transitions.
+func BenchmarkDestinations(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	lts := reference.Random(r, 20000, 200000, 2, 0.9)
+	part := partKS(lts, pifra.Lts{})
+	counts := make(map[pifra.Label]int)
+	for _, trans := range lts.Transitions {
+		counts[trans.Label]++
+	}
+	tau := part.actions[0]
+	for _, label := range part.actions {
+		if counts[label] > counts[tau] {
+			tau = label
+		}
+	}
+	var sources []int
+	for s := range lts.States {
+		sources = append(sources, s)
+	}
+	b.Run("binary search", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			part.destinations(sources[i%len(sources)], tau)
+		}
+	})
+	b.Run("linear scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			source := sources[i%len(sources)]
+			var dests []int
+			for _, trans := range lts.Transitions {
+				if trans.Label == tau && trans.Source == source {
+					dests = append(dests, part.states[trans.Destination].id)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+
+	"github.com/yungene/pifra"
+)
+
+var diffDot = flag.String("diff", "",
+	"when the LTSs are not equivalent, write to `file` their joint quotient graph with the classes of only one side highlighted")
+
+// diffColours colour the classes holding states of only the given side, and
+// the transitions into them.
+var diffColours = [2]string{LeftSide: "red", RightSide: "blue"}
+
+// dimColour draws the classes shared by both sides, and the transitions
+// between them.
+const dimColour = "gray"
+
+// unmatchedClasses maps the classes of bisim, the classes of part, that hold
+// states of only one side to that side. They are the orphan blocks of part.
+func unmatchedClasses(part Partition, bisim Bisimulation) map[int]Side {
+	unmatched := make(map[int]Side)
+	for label, count := range classCounts(part, bisim) {
+		switch {
+		case count[RightSide] == 0:
+			unmatched[label] = LeftSide
+		case count[LeftSide] == 0:
+			unmatched[label] = RightSide
+		}
+	}
+	return unmatched
+}
+
+// diffGraphViz renders the quotient of left and right under part, dimming the
+// classes both sides share so that the unmatched ones, and the transitions
+// that lead into them, stand out.
+func diffGraphViz(part Partition, left, right pifra.Lts) []byte {
+	var buf bytes.Buffer
+	bisim := part.classes()
+	names := classNames(bisim, left, right)
+	counts := classCounts(part, bisim)
+	unmatched := unmatchedClasses(part, bisim)
+
+	buf.WriteString("digraph {\n")
+	for label, count := range counts {
+		attrs := fmt.Sprintf("color=%s,fontcolor=%s,", dimColour, dimColour)
+		if side, ok := unmatched[label]; ok {
+			attrs = fmt.Sprintf("style=filled,fillcolor=%s,fontcolor=white,", diffColours[side])
+		}
+		if label == bisim[part.initial[LeftSide]] || label == bisim[part.initial[RightSide]] {
+			attrs += "peripheries=2,"
+		}
+		fmt.Fprintf(&buf, "    %d [%slabel=\"%s\\n%d left, %d right\"]\n",
+			label, attrs, dotEscape(names[label]), count[LeftSide], count[RightSide])
+	}
+	buf.WriteRune('\n')
+	for _, edge := range quotientEdges(bisim, left, right) {
+		attrs := fmt.Sprintf("color=%s,fontcolor=%s,", dimColour, dimColour)
+		if side, ok := unmatched[edge.dst]; ok {
+			attrs = fmt.Sprintf("color=%s,penwidth=2,", diffColours[side])
+		}
+		fmt.Fprintf(&buf, "    %d -> %d [%s%slabel=\"%s\"]\n", edge.src, edge.dst,
+			attrs, edgeAttrs(edge.label), dotEscape(edge.label.PrettyPrintGraph()))
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
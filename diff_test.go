@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	diffNode = regexp.MustCompile(`(?m)^    (\d+) \[(?:style=filled,fillcolor=(\w+))?`)
+	diffEdge = regexp.MustCompile(`(?m)^    \d+ -> (\d+) \[color=(\w+),`)
+)
+
+// TestDiffHighlights checks on random inequivalent pairs that -diff fills
+// exactly the blocks holding states of one side, in the colour of that side,
+// and draws the transitions into them in the same colour.
+func TestDiffHighlights(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	highlighted := 0
+	for i := 0; i < 200; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		part := partKS(left, right)
+		if part.bisimilar() != nil {
+			continue
+		}
+		bisim := part.classes()
+		want := make(map[int]string)
+		for _, block := range part.Blocks() {
+			var count [2]int
+			for _, state := range block.States() {
+				count[part.sides[state].Side]++
+			}
+			if count[RightSide] == 0 {
+				want[bisim[block.States()[0]]] = diffColours[LeftSide]
+			} else if count[LeftSide] == 0 {
+				want[bisim[block.States()[0]]] = diffColours[RightSide]
+			}
+		}
+		dot := string(diffGraphViz(part, left, right))
+		got := make(map[int]string)
+		for _, m := range diffNode.FindAllStringSubmatch(dot, -1) {
+			if m[2] != "" {
+				id, _ := strconv.Atoi(m[1])
+				got[id] = m[2]
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("pair %d: highlighted %v, want %v\n%s", i, got, want, dot)
+		}
+		for _, m := range diffEdge.FindAllStringSubmatch(dot, -1) {
+			dst, _ := strconv.Atoi(m[1])
+			colour, ok := want[dst]
+			if !ok {
+				colour = dimColour
+			}
+			if m[2] != colour {
+				t.Fatalf("pair %d: transition into %d drawn %s, want %s\n%s", i, dst, m[2], colour, dot)
+			}
+		}
+		highlighted += len(want)
+	}
+	if highlighted == 0 {
+		t.Error("no block highlighted")
+	}
+}
+
+func TestDiffFlag(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := filepath.Join(sides, "left.gob")
+	out := filepath.Join(dir, "diff.dot")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-diff", out, left, filepath.Join(sides, "right.gob")); code != 1 {
+		t.Fatalf("status %d, want 1: %s", code, stderr)
+	}
+	dot, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dot), "fillcolor="+diffColours[LeftSide]) {
+		t.Errorf("no class of the left side only highlighted:\n%s", dot)
+	}
+	os.Remove(out)
+	_, stderr, code := runPisim(t, dir, "-quiet", "-diff", out, left, filepath.Join(sides, "permuted.gob"))
+	if code != 0 || !strings.Contains(stderr, "-diff: the LTSs are equivalent") {
+		t.Errorf("equivalent pair: status %d and %q, want 0 and a note", code, stderr)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("-diff written for an equivalent pair")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/yungene/pifra"
+)
+
+// dumpTransitions returns the transitions of lts sorted by source, label text
+// and destination.
+func dumpTransitions(lts pifra.Lts) []pifra.Transition {
+	trans := append([]pifra.Transition(nil), lts.Transitions...)
+	sort.SliceStable(trans, func(i, j int) bool {
+		a, b := trans[i], trans[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if la, lb := a.Label.PrettyPrintGraph(), b.Label.PrettyPrintGraph(); la != lb {
+			return la < lb
+		}
+		return a.Destination < b.Destination
+	})
+	return trans
+}
+
+// writeDump writes lts as text, one state or transition per line: first the
+// states in order, with their configurations as pifra prints them, then the
+// transitions as dumpTransitions orders them, with their labels quoted.
+func writeDump(w io.Writer, lts pifra.Lts) error {
+	bw := bufio.NewWriter(w)
+	ids := make([]int, 0, len(lts.States))
+	for id := range lts.States {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		bound := ""
+		if lts.RegSizeReached[id] {
+			bound = " (register bound reached)"
+		}
+		fmt.Fprintf(bw, "state %d%s: %s\n", id, bound, prettyConfiguration(lts.States[id]))
+	}
+	for _, trans := range dumpTransitions(lts) {
+		fmt.Fprintf(bw, "transition %d %s %d\n",
+			trans.Source, strconv.Quote(trans.Label.PrettyPrintGraph()), trans.Destination)
+	}
+	return bw.Flush()
+}
+
+// canonicalIDs renumbers the states of lts in breadth-first order from the
+// initial state, so that LTSs differing only in the numbering of their states
+// get the same IDs. The moves of each state are visited by label text, then
+// by the fingerprint of their destination, then by its configuration. The
+// states the initial state cannot reach follow, by breadth-first search from
+// each in the same order. Only bisimilar states with the same configuration
+// are left in the order of their old IDs.
+func canonicalIDs(lts pifra.Lts) pifra.Lts {
+	succs := successors(lts)
+	colours, _ := colour(lts, -1)
+	less := func(s, t int) bool {
+		if colours[s] != colours[t] {
+			return colours[s] < colours[t]
+		}
+		if cs, ct := prettyConfiguration(lts.States[s]), prettyConfiguration(lts.States[t]); cs != ct {
+			return cs < ct
+		}
+		return s < t
+	}
+	ids := make(map[int]int, len(lts.States))
+	visit := func(root int) {
+		ids[root] = len(ids)
+		queue := []int{root}
+		for i := 0; i < len(queue); i++ {
+			moves := succs[queue[i]]
+			sort.SliceStable(moves, func(i, j int) bool {
+				a, b := moves[i], moves[j]
+				if la, lb := a.Label.PrettyPrintGraph(), b.Label.PrettyPrintGraph(); la != lb {
+					return la < lb
+				}
+				return less(a.Destination, b.Destination)
+			})
+			for _, trans := range moves {
+				if _, ok := ids[trans.Destination]; !ok {
+					ids[trans.Destination] = len(ids)
+					queue = append(queue, trans.Destination)
+				}
+			}
+		}
+	}
+	visit(0)
+	var unreached []int
+	for id := range lts.States {
+		if _, ok := ids[id]; !ok {
+			unreached = append(unreached, id)
+		}
+	}
+	sort.Slice(unreached, func(i, j int) bool {
+		return less(unreached[i], unreached[j])
+	})
+	for _, id := range unreached {
+		if _, ok := ids[id]; !ok {
+			visit(id)
+		}
+	}
+	return relabelStates(lts, ids)
+}
+
+// relabelStates returns lts with its states renamed by ids.
+func relabelStates(lts pifra.Lts, ids map[int]int) pifra.Lts {
+	out := lts
+	out.States = make(map[int]pifra.Configuration, len(lts.States))
+	out.RegSizeReached = make(map[int]bool)
+	for id, conf := range lts.States {
+		out.States[ids[id]] = conf
+		if lts.RegSizeReached[id] {
+			out.RegSizeReached[ids[id]] = true
+		}
+	}
+	out.Transitions = make([]pifra.Transition, len(lts.Transitions))
+	for i, trans := range lts.Transitions {
+		trans.Source, trans.Destination = ids[trans.Source], ids[trans.Destination]
+		out.Transitions[i] = trans
+	}
+	return out
+}
+
+func dumpCommand(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	canonical := fs.Bool("canonical-ids", false,
+		"renumber the states in breadth-first order from the initial state before dumping")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim dump [-canonical-ids] in.gob\n\n"+
+			"Prints an LTS as text for diff: its states in order, then its transitions\n"+
+			"sorted by source, label and destination, one per line.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	lts, err := decodeLTS(args[0])
+	check(err)
+	if *canonical {
+		if _, ok := lts.States[0]; !ok {
+			check(fmt.Errorf("%s: no initial state 0", args[0]))
+		}
+		lts = canonicalIDs(lts)
+	}
+	check(writeDump(os.Stdout, lts))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func dumpText(t *testing.T, lts pifra.Lts) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeDump(&buf, lts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDump(t *testing.T) {
+	lts := shard([]int{0, 1, 2}, [3]int{1, 2, 0}, [3]int{0, 2, 2}, [3]int{0, 1, 2}, [3]int{0, 1, 1})
+	lts.RegSizeReached = map[int]bool{2: true}
+	want := "state 0: \nstate 1: \nstate 2 (register bound reached): \n" +
+		"transition 0 \"1 1\" 1\ntransition 0 \"1 1\" 2\ntransition 0 \"2 2\" 2\ntransition 1 \"2 2\" 0\n"
+	if got := dumpText(t, lts); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// The dump does not depend on the order of the transitions.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		r.Shuffle(len(lts.Transitions), func(i, j int) {
+			lts.Transitions[i], lts.Transitions[j] = lts.Transitions[j], lts.Transitions[i]
+		})
+		if got := dumpText(t, lts); got != want {
+			t.Fatalf("shuffled transitions %v: got\n%s", lts.Transitions, got)
+		}
+	}
+}
+
+// TestCanonicalIDs checks that random deterministic LTSs, cut to the states
+// their initial state reaches, dump the same under -canonical-ids as their
+// renamings do, and the same as testdata/sides/left.gob and its renaming do
+// through the dump command.
+func TestCanonicalIDs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		lts, err := sliceLTS(randomDeterministic(r, 1+r.Intn(8), 3), 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lts.RegSizeReached = make(map[int]bool)
+		want := dumpText(t, canonicalIDs(lts))
+		if got := dumpText(t, canonicalIDs(permuted(r, lts))); got != want {
+			t.Fatalf("LTS %d: renaming dumps\n%s\nwant\n%s", i, got, want)
+		}
+		if got := dumpText(t, canonicalIDs(canonicalIDs(lts))); got != want {
+			t.Fatalf("LTS %d: canonical IDs are not stable:\n%s\nwant\n%s", i, got, want)
+		}
+	}
+
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var dumps [2][2]string
+	for i, input := range []string{"left.gob", "permuted.gob"} {
+		for j, args := range [][]string{{"dump"}, {"dump", "-canonical-ids"}} {
+			stdout, stderr, code := runPisim(t, dir, append(args, filepath.Join(sides, input))...)
+			if code != 0 {
+				t.Fatalf("%v %s: status %d: %s", args, input, code, stderr)
+			}
+			dumps[i][j] = stdout
+		}
+	}
+	if dumps[0][0] == dumps[1][0] {
+		t.Error("left.gob and its renaming dump the same without -canonical-ids")
+	}
+	if dumps[0][1] != dumps[1][1] {
+		t.Errorf("left.gob and its renaming dump differently under -canonical-ids:\n%s\n%s", dumps[0][1], dumps[1][1])
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// registerRenaming maps each register index of regs to its position when the
+// registers are ordered by the names they hold. Configurations that only differ
+// by a permutation of their register contents share the same renamed form.
+func registerRenaming(regs pifra.Registers) map[int]int {
+	indices := make([]int, 0, len(regs.Registers))
+	for i := range regs.Registers {
+		indices = append(indices, i)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		ni, nj := regs.Registers[indices[i]], regs.Registers[indices[j]]
+		if ni != nj {
+			return ni < nj
+		}
+		return indices[i] < indices[j]
+	})
+	renaming := make(map[int]int, len(indices))
+	for pos, i := range indices {
+		renaming[i] = pos + 1
+	}
+	return renaming
+}
+
+func renameSymbol(sym pifra.Symbol, renaming map[int]int) pifra.Symbol {
+	if sym.Type == pifra.SymbolTypTau {
+		return sym
+	}
+	// Fresh names are stored past the end of the registers, outside the
+	// renaming's domain, and so keep their index.
+	if i, ok := renaming[sym.Value]; ok {
+		sym.Value = i
+	}
+	return sym
+}
+
+func renameLabel(label pifra.Label, renaming map[int]int) pifra.Label {
+	return pifra.Label{
+		Symbol:  renameSymbol(label.Symbol, renaming),
+		Symbol2: renameSymbol(label.Symbol2, renaming),
+	}
+}
+
+// canonicaliseLTS rewrites the register assignment of every configuration into
+// its canonical order, and the labels of outgoing transitions consistently
+// with the renaming of their source configuration.
+func canonicaliseLTS(lts *pifra.Lts) {
+	renamings := make(map[int]map[int]int, len(lts.States))
+	for id, conf := range lts.States {
+		renaming := registerRenaming(conf.Registers)
+		regs := make(map[int]string, len(conf.Registers.Registers))
+		for i, name := range conf.Registers.Registers {
+			regs[renaming[i]] = name
+		}
+		conf.Registers = pifra.Registers{Size: conf.Registers.Size, Registers: regs}
+		lts.States[id] = conf
+		renamings[id] = renaming
+	}
+	for i, trans := range lts.Transitions {
+		if renaming, ok := renamings[trans.Source]; ok {
+			lts.Transitions[i].Label = renameLabel(trans.Label, renaming)
+		}
+	}
+}
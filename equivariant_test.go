@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestRegisterRenaming(t *testing.T) {
+	for _, test := range []struct {
+		regs map[int]string
+		want map[int]int
+	}{
+		{map[int]string{}, map[int]int{}},
+		{map[int]string{1: "a", 2: "b"}, map[int]int{1: 1, 2: 2}},
+		{map[int]string{1: "c", 2: "a", 3: "b"}, map[int]int{1: 3, 2: 1, 3: 2}},
+		// Registers holding the same name keep their order.
+		{map[int]string{1: "b", 2: "a", 3: "b"}, map[int]int{1: 2, 2: 1, 3: 3}},
+	} {
+		got := registerRenaming(pifra.Registers{Size: len(test.regs), Registers: test.regs})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.regs, got, test.want)
+		}
+	}
+}
+
+// registerConf returns a configuration of the inert process with the given
+// register contents.
+func registerConf(names ...string) pifra.Configuration {
+	regs := make(map[int]string, len(names))
+	for i, name := range names {
+		regs[i+1] = name
+	}
+	return pifra.Configuration{
+		Process:   &pifra.ElemNil{},
+		Registers: pifra.Registers{Size: len(names), Registers: regs},
+	}
+}
+
+// TestCanonicaliseLTS builds two configurations holding x and y in either
+// order, each inputting y on x, and a fresh name on x, as labels naming the
+// registers of x and y, and checks that canonicalisation gives both the same registers and label.
+// Silent labels and fresh names, past the registers, are left alone.
+func TestCanonicaliseLTS(t *testing.T) {
+	// fresh inputs a fresh name on the register x is in.
+	fresh := func(x int) pifra.Label {
+		return pifra.Label{
+			Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: x},
+			Symbol2: pifra.Symbol{Type: pifra.SymbolTypFreshInput, Value: 3},
+		}
+	}
+	lts := func(conf pifra.Configuration, x, y int) pifra.Lts {
+		return pifra.Lts{
+			States: map[int]pifra.Configuration{0: conf, 1: registerConf()},
+			Transitions: []pifra.Transition{
+				{Source: 0, Destination: 1, Label: inputLabel(x, y)},
+				{Source: 0, Destination: 1, Label: tauLabel},
+				{Source: 0, Destination: 1, Label: fresh(x)},
+			},
+		}
+	}
+	xy := lts(registerConf("x", "y"), 1, 2)
+	yx := lts(registerConf("y", "x"), 2, 1)
+	canonicaliseLTS(&xy)
+	canonicaliseLTS(&yx)
+	if !reflect.DeepEqual(xy, yx) {
+		t.Errorf("canonical forms differ:\n%+v\n%+v", xy, yx)
+	}
+	if want := registerConf("x", "y").Registers; !reflect.DeepEqual(yx.States[0].Registers, want) {
+		t.Errorf("registers %v, want %v", yx.States[0].Registers, want)
+	}
+	var labels []pifra.Label
+	for _, trans := range yx.Transitions {
+		labels = append(labels, trans.Label)
+	}
+	if want := []pifra.Label{inputLabel(1, 2), tauLabel, fresh(1)}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels %v, want %v", labels, want)
+	}
+}
+
+// TestEquivariant compares configurations holding x and y in either order,
+// each inputting y on x and then moving on, which only -equivariant relates.
+func TestEquivariant(t *testing.T) {
+	lts := func(conf pifra.Configuration, first, second pifra.Label) pifra.Lts {
+		return pifra.Lts{
+			States: map[int]pifra.Configuration{0: conf, 1: registerConf(), 2: registerConf()},
+			Transitions: []pifra.Transition{
+				{Source: 0, Destination: 1, Label: first},
+				{Source: 1, Destination: 2, Label: second},
+			},
+		}
+	}
+	dir := t.TempDir()
+	left := writeTestLTS(t, dir, "left.gob", lts(registerConf("x", "y"), inputLabel(1, 2), inputLabel(1, 1)))
+	right := writeTestLTS(t, dir, "right.gob", lts(registerConf("y", "x"), inputLabel(2, 1), inputLabel(1, 1)))
+	for args, want := range map[string]int{"": 1, "-equivariant": 0} {
+		flags := []string{"-quiet"}
+		if args != "" {
+			flags = append(flags, args)
+		}
+		if stdout, stderr, code := runPisim(t, dir, append(flags, left, right)...); code != want {
+			t.Errorf("%q: status %d, want %d: %s%s", args, code, want, stdout, stderr)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package events defines the partition refinement events written by pisim's
+// -trace-events flag as newline-delimited JSON.
+package events
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Kind is the type of an Event.
+type Kind string
+
+const (
+	// Init carries the initial partition.
+	Init Kind = "init"
+	// Round marks the start of a refinement round.
+	Round Kind = "round"
+	// Split replaces the Parent block by the two Blocks it was split into,
+	// using Action as the splitter. A level-wise or η refinement splits a
+	// block into any number of Blocks by the signatures of their states, for
+	// Reason "signature".
+	Split Kind = "split"
+	// Stop marks an early termination of the refinement, for Reason.
+	Stop Kind = "stop"
+	// Done carries the final partition.
+	Done Kind = "done"
+)
+
+// Block is a block of the partition and its member states.
+type Block struct {
+	ID     int   `json:"id"`
+	States []int `json:"states"`
+}
+
+// Event is a single step of the refinement.
+type Event struct {
+	Kind   Kind    `json:"kind"`
+	Round  int     `json:"round"`
+	Parent int     `json:"parent"`
+	Action string  `json:"action,omitempty"`
+	Blocks []Block `json:"blocks,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Writer writes events as newline-delimited JSON. Each event is written to the
+// underlying writer as soon as it is recorded, so that a consumer can follow
+// the stream during a long refinement.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// Write records a single event.
+func (w *Writer) Write(e Event) error {
+	return w.enc.Encode(e)
+}
+
+// Replay reads an event stream and reconstructs the partition it describes,
+// as a map from block IDs to member states.
+func Replay(r io.Reader) (map[int][]int, error) {
+	blocks := make(map[int][]int)
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		switch e.Kind {
+		case Init:
+			blocks = make(map[int][]int)
+		case Split:
+			delete(blocks, e.Parent)
+		default:
+			continue
+		}
+		for _, b := range e.Blocks {
+			blocks[b.ID] = b.States
+		}
+	}
+	return blocks, nil
+}
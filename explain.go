@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var explain = flag.Bool("explain", false, "explain the verdict")
+
+func alphabet(lts pifra.Lts) map[pifra.Label]bool {
+	actions := make(map[pifra.Label]bool)
+	for _, trans := range lts.Transitions {
+		actions[trans.Label] = true
+	}
+	return actions
+}
+
+func without(a, b map[pifra.Label]bool) []pifra.Label {
+	var diff []pifra.Label
+	for action := range a {
+		if !b[action] {
+			diff = append(diff, action)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return bisim.LabelLess(diff[i], diff[j])
+	})
+	return diff
+}
+
+// alphabetDifference returns the actions occurring only in left and only in
+// right.
+func alphabetDifference(left, right pifra.Lts) (onlyLeft, onlyRight []pifra.Label) {
+	l, r := alphabet(left), alphabet(right)
+	return without(l, r), without(r, l)
+}
+
+func formatActions(actions []pifra.Label) string {
+	texts := make([]string, len(actions))
+	for i, action := range actions {
+		texts[i] = actionText(action)
+	}
+	return "{" + strings.Join(texts, ", ") + "}"
+}
+
+// printAlphabetDifference prints the actions occurring on one side only. Unless
+// always is set, nothing is printed when both sides share their alphabet.
+func printAlphabetDifference(w io.Writer, left, right pifra.Lts, always bool) {
+	onlyLeft, onlyRight := alphabetDifference(left, right)
+	if !always && len(onlyLeft) == 0 && len(onlyRight) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "only in left: %s, only in right: %s\n",
+		formatActions(onlyLeft), formatActions(onlyRight))
+}
+
+// classOverlap counts the classes of part holding states of both sides, and
+// those holding states of one side only, by side.
+func classOverlap(part Partition) (shared int, exclusive [2]int) {
+	for _, block := range part.blocks {
+		if part.mixed(block) {
+			shared++
+			continue
+		}
+		for state := range block.states {
+			exclusive[part.sides[state].Side]++
+			break
+		}
+	}
+	return shared, exclusive
+}
+
+// printOverlap prints how many classes of part the sides share, for a
+// negative verdict: none means the LTSs have no behaviour in common.
+func printOverlap(w io.Writer, part Partition) {
+	shared, exclusive := classOverlap(part)
+	fmt.Fprintf(w, "classes: %d shared by both sides, %d left only, %d right only\n",
+		shared, exclusive[LeftSide], exclusive[RightSide])
+	if shared == 0 {
+		fmt.Fprintln(w, "no state of either side is bisimilar to a state of the other: the LTSs share no behaviour")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestAlphabetDifference(t *testing.T) {
+	lts := func(labels ...pifra.Label) pifra.Lts {
+		out := pifra.Lts{States: map[int]pifra.Configuration{0: {}, 1: {}}}
+		for _, label := range labels {
+			out.Transitions = append(out.Transitions, pifra.Transition{Source: 0, Destination: 1, Label: label})
+		}
+		return out
+	}
+	left := lts(inputLabel(1, 1), inputLabel(2, 1), inputLabel(1, 2), tauLabel, inputLabel(1, 1))
+	right := lts(inputLabel(3, 1), inputLabel(1, 1), tauLabel)
+	onlyLeft, onlyRight := alphabetDifference(left, right)
+	if want := []pifra.Label{inputLabel(1, 2), inputLabel(2, 1)}; !reflect.DeepEqual(onlyLeft, want) {
+		t.Errorf("only in left: %v, want %v", onlyLeft, want)
+	}
+	if want := []pifra.Label{inputLabel(3, 1)}; !reflect.DeepEqual(onlyRight, want) {
+		t.Errorf("only in right: %v, want %v", onlyRight, want)
+	}
+
+	var buf bytes.Buffer
+	printAlphabetDifference(&buf, left, right, false)
+	if got, want := buf.String(), "only in left: {1 2, 2 1}, only in right: {3 1}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	buf.Reset()
+	printAlphabetDifference(&buf, left, left, false)
+	if buf.Len() != 0 {
+		t.Errorf("printed %q for a shared alphabet", buf.String())
+	}
+	printAlphabetDifference(&buf, left, left, true)
+	if got, want := buf.String(), "only in left: {}, only in right: {}\n"; got != want {
+		t.Errorf("always: got %q, want %q", got, want)
+	}
+}
+
+// TestClassOverlap compares a.b + c with a.b + d, which share the classes of
+// b and of the deadlock, and a loop by a with one by b, which share none.
+func TestClassOverlap(t *testing.T) {
+	for _, test := range []struct {
+		left, right pifra.Lts
+		want        string
+	}{
+		{
+			shard([]int{0, 1, 2, 3}, [3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{0, 3, 3}),
+			shard([]int{0, 1, 2, 3}, [3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{0, 4, 3}),
+			"classes: 2 shared by both sides, 1 left only, 1 right only\n",
+		},
+		{
+			shard([]int{0}, [3]int{0, 1, 0}),
+			shard([]int{0}, [3]int{0, 2, 0}),
+			"classes: 0 shared by both sides, 1 left only, 1 right only\n" +
+				"no state of either side is bisimilar to a state of the other: the LTSs share no behaviour\n",
+		},
+	} {
+		left, right := prepared(t, test.left, test.right)
+		var buf bytes.Buffer
+		printOverlap(&buf, partKS(left, right))
+		if got := buf.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+
+	dir := t.TempDir()
+	left := writeTestLTS(t, dir, "left.gob", shard([]int{0, 1, 2, 3}, [3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{0, 3, 3}))
+	right := writeTestLTS(t, dir, "right.gob", shard([]int{0, 1, 2, 3}, [3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{0, 4, 3}))
+	stdout, stderr, code := runPisim(t, dir, "-quiet", "-explain", left, right)
+	if code != 1 || !strings.Contains(stdout, "classes: 2 shared by both sides, 1 left only, 1 right only\n") {
+		t.Errorf("-explain: status %d and\n%s%s", code, stdout, stderr)
+	}
+	if stdout, _, _ := runPisim(t, dir, "-quiet", left, right); strings.Contains(stdout, "classes:") {
+		t.Errorf("printed the overlap without -explain:\n%s", stdout)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+var leftFingerprint = flag.String("left-fingerprint", "",
+	"seed the partition with the fingerprints of the left LTS precomputed by pisim fingerprint in `file`")
+
+// fingerprintMagic starts every fingerprint file.
+const fingerprintMagic = "\x00pisim-fingerprint 1\n"
+
+// Fingerprints colour the states of an LTS by iterated signature hashing.
+// The colour of a state after a round hashes its previous colour with the set
+// of its moves, as pairs of label and colour of the destination. Colours are
+// canonical: they depend only on behaviour, not on state IDs, so bisimilar
+// states of any LTSs get equal colours after the same number of rounds.
+type Fingerprints struct {
+	// Rounds is the number of rounds after which the colouring of the LTS
+	// stopped separating states.
+	Rounds int
+	// Colours maps states to their colour after Rounds rounds.
+	Colours map[int]uint64
+	// Equivariant records whether the LTS was canonicalised by -equivariant.
+	Equivariant bool
+	// SHA256 identifies the file the LTS was read from.
+	SHA256 [32]byte
+}
+
+func labelHash(label pifra.Label) uint64 {
+	h := fnv.New64a()
+	var buf [32]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(label.Symbol.Type))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(label.Symbol.Value))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(label.Symbol2.Type))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(label.Symbol2.Value))
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// colourRound computes the colours of the next round.
+func colourRound(succs map[int][]pifra.Transition, colours map[int]uint64) map[int]uint64 {
+	next := make(map[int]uint64, len(colours))
+	var moves [][2]uint64
+	var buf [16]byte
+	for state, colour := range colours {
+		moves = moves[:0]
+		for _, trans := range succs[state] {
+			moves = append(moves, [2]uint64{labelHash(trans.Label), colours[trans.Destination]})
+		}
+		sort.Slice(moves, func(i, j int) bool {
+			if moves[i][0] != moves[j][0] {
+				return moves[i][0] < moves[j][0]
+			}
+			return moves[i][1] < moves[j][1]
+		})
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint64(buf[:8], colour)
+		h.Write(buf[:8])
+		for i, move := range moves {
+			if i > 0 && move == moves[i-1] {
+				continue
+			}
+			binary.LittleEndian.PutUint64(buf[:8], move[0])
+			binary.LittleEndian.PutUint64(buf[8:], move[1])
+			h.Write(buf[:])
+		}
+		next[state] = h.Sum64()
+	}
+	return next
+}
+
+func countColours(colours map[int]uint64) int {
+	distinct := make(map[uint64]bool)
+	for _, c := range colours {
+		distinct[c] = true
+	}
+	return len(distinct)
+}
+
+// colour runs rounds rounds of signature hashing over lts, or rounds until
+// the colouring stops separating states if rounds is negative. It returns the
+// colours and the number of rounds run.
+func colour(lts pifra.Lts, rounds int) (map[int]uint64, int) {
+	succs := successors(lts)
+	colours := make(map[int]uint64, len(lts.States))
+	for state := range lts.States {
+		colours[state] = 0
+	}
+	n := 1
+	for round := 0; round != rounds; round++ {
+		next := colourRound(succs, colours)
+		if rounds < 0 {
+			m := countColours(next)
+			if m == n {
+				return colours, round
+			}
+			n = m
+		}
+		colours = next
+	}
+	return colours, rounds
+}
+
+// Fingerprint colours the states of lts until the colouring is stable.
+func Fingerprint(lts pifra.Lts) Fingerprints {
+	colours, rounds := colour(lts, -1)
+	return Fingerprints{Rounds: rounds, Colours: colours}
+}
+
+func (fp Fingerprints) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(fingerprintMagic)
+	bw.Write(fp.SHA256[:])
+	var flags byte
+	if fp.Equivariant {
+		flags = 1
+	}
+	bw.WriteByte(flags)
+	var buf [binary.MaxVarintLen64]byte
+	putUvarint := func(x uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf[:], x)])
+	}
+	putUvarint(uint64(fp.Rounds))
+	putUvarint(uint64(len(fp.Colours)))
+	states := make([]int, 0, len(fp.Colours))
+	for state := range fp.Colours {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	for _, state := range states {
+		putUvarint(uint64(state))
+		binary.LittleEndian.PutUint64(buf[:8], fp.Colours[state])
+		bw.Write(buf[:8])
+	}
+	return bw.Flush()
+}
+
+func decodeFingerprints(r io.Reader) (fp Fingerprints, err error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(fingerprintMagic))
+	if _, err = io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, []byte(fingerprintMagic)) {
+		return fp, errors.New("not a pisim fingerprint file")
+	}
+	if _, err = io.ReadFull(br, fp.SHA256[:]); err != nil {
+		return
+	}
+	flags, err := br.ReadByte()
+	if err != nil {
+		return
+	}
+	fp.Equivariant = flags&1 != 0
+	rounds, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+	fp.Rounds = int(rounds)
+	fp.Colours = make(map[int]uint64)
+	var buf [8]byte
+	for i := uint64(0); i < n; i++ {
+		state, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fp, err
+		}
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return fp, err
+		}
+		fp.Colours[int(state)] = binary.LittleEndian.Uint64(buf[:])
+	}
+	return fp, nil
+}
+
+func readFingerprints(name string) (Fingerprints, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return Fingerprints{}, err
+	}
+	defer f.Close()
+	fp, err := decodeFingerprints(f)
+	if err != nil {
+		return fp, fmt.Errorf("%s: %v", name, err)
+	}
+	return fp, nil
+}
+
+// initialColours, if set, seeds the partition built by partKS and partEta:
+// states start in the same block only if they have the same colour.
+var initialColours map[int]uint64
+
+// loadSeed sets initialColours from the fingerprints of the left LTS in the
+// file -left-fingerprint, colouring the right LTS for as many rounds. Since
+// bisimilar states get equal colours, the seed never separates bisimilar
+// states, and refinement from it reaches the same partition. The fingerprints
+// are of the left LTS as read, so loadSeed refuses every flag that rewrites
+// the labels or transitions before refinement.
+func loadSeed(leftName string, right pifra.Lts) error {
+	if *equivalence != "strong" || len(observation.Classes) > 0 || *mergeLabels || *stripAnnotations != "" ||
+		*tauPattern != "" || *dropSelfLoops != "" || *project != "" || *labelEquivFile != "" ||
+		*undirected || *backward || *forwardBackward {
+		return errors.New("-left-fingerprint needs plain strong bisimilarity of the LTSs as read, " +
+			"without -observe, -hide, -observe-only, -strip-annotations, -merge-labels-by-text, -tau, " +
+			"-drop-self-loops, -project, -label-equiv, -undirected, -backward or -forward-backward")
+	}
+	fp, err := readFingerprints(*leftFingerprint)
+	if err != nil {
+		return err
+	}
+	if fp.Equivariant != *equivariant {
+		return fmt.Errorf("%s was computed with -equivariant=%v", *leftFingerprint, fp.Equivariant)
+	}
+	sum, err := fileSHA256(leftName)
+	if err != nil {
+		return err
+	}
+	if fmt.Sprintf("%x", fp.SHA256) != sum && !*force {
+		return fmt.Errorf("%s was computed for a different file than %s (use -force to use it anyway)",
+			*leftFingerprint, leftName)
+	}
+	rightColours, _ := colour(right, fp.Rounds)
+	initialColours = rightColours
+	for state, c := range fp.Colours {
+		initialColours[uniquified(state, LeftSide)] = c
+	}
+	return nil
+}
+
+// uniquified returns the uniquified state of the state with the input ID
+// state on side, or -1 if the LTS of side does not have it.
+func uniquified(state int, side Side) int {
+	id, ok := uniqueIDs[side][state]
+	if !ok {
+		return -1
+	}
+	return id
+}
+
+func fingerprintCommand(args []string) {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	out := fs.String("out", "", "write the fingerprints to `file` (default in.gob.fp)")
+	fs.BoolVar(equivariant, "equivariant", false,
+		"fingerprint modulo permutations of register contents, for use with -equivariant")
+	fs.BoolVar(force, "force", false, "overwrite an existing output file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim fingerprint in.gob [-out file]")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = args[0] + ".fp"
+	}
+	inputFiles = args
+	lts, err := decodeLTS(args[0])
+	check(err)
+	if *equivariant {
+		canonicaliseLTS(&lts)
+	}
+	fp := Fingerprint(lts)
+	fp.Equivariant = *equivariant
+	data, err := ioutil.ReadFile(args[0])
+	check(err)
+	fp.SHA256 = sha256.Sum256(data)
+	var buf bytes.Buffer
+	check(fp.encode(&buf))
+	check(writeFile(*out, buf.Bytes()))
+	fmt.Printf("%d states, %d colours after %d rounds\n",
+		len(fp.Colours), countColours(fp.Colours), fp.Rounds)
+}
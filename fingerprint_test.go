@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+func TestLoadSeedRefusesRewrites(t *testing.T) {
+	for _, f := range []struct{ name, value string }{
+		{"equivalence", "weak"},
+		{"merge-labels-by-text", "true"},
+		{"strip-annotations", "@.*"},
+		{"tau", "1 1"},
+		{"drop-self-loops", "tau"},
+		{"project", "1 1"},
+		{"label-equiv", "labels.txt"},
+		{"undirected", "true"},
+		{"backward", "true"},
+		{"forward-backward", "true"},
+	} {
+		t.Run(f.name, func(t *testing.T) {
+			setFlag(t, "left-fingerprint", "left.gob.fp")
+			setFlag(t, f.name, f.value)
+			err := loadSeed("left.gob", pifra.Lts{})
+			if err == nil || !strings.Contains(err.Error(), "-left-fingerprint") {
+				t.Errorf("loadSeed() = %v with -%s=%s, want it refused", err, f.name, f.value)
+			}
+		})
+	}
+}
+
+// TestSeedKeepsVerdict checks that seeding the partition with fingerprints
+// gives the verdict of an unseeded comparison, and of the reference.
+func TestSeedKeepsVerdict(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		left := reference.Random(r, 5, 8, 2, 0)
+		right := reference.Random(r, 5, 8, 2, 0)
+		if i%2 == 0 {
+			right = permuted(r, left)
+		}
+		dir := t.TempDir()
+		leftName := writeTestLTS(t, dir, "left.gob", left)
+		rightName := writeTestLTS(t, dir, "right.gob", right)
+		if _, stderr, code := runPisim(t, dir, "fingerprint", leftName); code != 0 {
+			t.Fatalf("fingerprint: status %d: %s", code, stderr)
+		}
+		plain, _, plainCode := runPisim(t, dir, "-quiet", leftName, rightName)
+		seeded, _, seededCode := runPisim(t, dir, "-quiet", "-left-fingerprint", filepath.Join(dir, "left.gob.fp"),
+			leftName, rightName)
+		if seeded != plain || seededCode != plainCode {
+			t.Errorf("pair %d: seeded %q (status %d), unseeded %q (status %d)",
+				i, seeded, seededCode, plain, plainCode)
+		}
+		if want := reference.Strong(left, right); (plainCode == 0) != want {
+			t.Errorf("pair %d: status %d, reference says bisimilar=%v", i, plainCode, want)
+		}
+	}
+}
@@ -2,6 +2,9 @@ module github.com/yungene/pisim
 
 go 1.17
 
-require github.com/yungene/pifra v0.0.5
+require (
+	github.com/yungene/pifra v0.0.5
+	golang.org/x/sync v0.1.0
+)
 
 require github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
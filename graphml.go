@@ -0,0 +1,17 @@
+package main
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// ltsGraphML renders a single LTS as GraphML, with configurations on nodes and
+// labels on edges.
+func ltsGraphML(ctx context.Context, lts pifra.Lts) ([]byte, error) {
+	var buf bytes.Buffer
+	err := bisim.WriteGraphML(ctx, &buf, lts)
+	return buf.Bytes(), err
+}
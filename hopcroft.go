@@ -0,0 +1,14 @@
+package main
+
+import "flag"
+
+var noFastPath = flag.Bool("no-fastpath", false,
+	"refine deterministic LTSs like any others, rather than by Hopcroft's algorithm")
+
+// fastPath tells whether partKS may refine by Hopcroft's algorithm, which
+// bisim.Refiner uses for deterministic LTSs. It needs none of the features
+// that follow the general refinement step by step.
+func fastPath() bool {
+	return !*noFastPath && *anytime == 0 && tracer == nil && !stopOnceDistinguished &&
+		!*explainRelation && !levelRefinement()
+}
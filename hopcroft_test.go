@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// randomDeterministic returns a random LTS of n states with at most one move
+// per state and action, out of labels input actions.
+func randomDeterministic(r *rand.Rand, n, labels int) pifra.Lts {
+	lts := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, n),
+		RegSizeReached: make(map[int]bool),
+	}
+	for s := 0; s < n; s++ {
+		lts.States[s] = pifra.Configuration{}
+		for a := 1; a <= labels; a++ {
+			if r.Intn(3) > 0 {
+				lts.Transitions = append(lts.Transitions, pifra.Transition{
+					Source: s, Destination: r.Intn(n), Label: inputLabel(a, 1),
+				})
+			}
+		}
+	}
+	return lts
+}
+
+func TestHopcroftReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var verdicts [2]int
+	for i := 0; i < 300; i++ {
+		n := 1 + r.Intn(5)
+		left := randomDeterministic(r, n, 2)
+		right := randomDeterministic(r, 1+r.Intn(5), 2)
+		if i%3 == 0 {
+			right = permuted(r, left)
+		}
+		want := reference.Strong(left, right)
+		counters.hopcroft = false
+		got := refinedBisimilar(t, left, right, "strong")
+		if !counters.hopcroft {
+			t.Fatalf("pair %d: deterministic LTSs did not take the fast path", i)
+		}
+		if got != want {
+			t.Fatalf("pair %d: bisimilar = %v, reference says %v\nleft: %v\nright: %v",
+				i, got, want, left.Transitions, right.Transitions)
+		}
+		if want {
+			verdicts[1]++
+		} else {
+			verdicts[0]++
+		}
+	}
+	if verdicts[0] == 0 || verdicts[1] == 0 {
+		t.Errorf("%d negative and %d positive verdicts, want both", verdicts[0], verdicts[1])
+	}
+}
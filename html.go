@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"html"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var (
+	htmlOut = flag.String("html", "",
+		"write a page for exploring the blocks of the final partition in a browser to `file`, "+
+			"searchable by block, state, configuration and side")
+	htmlMaxSize = flag.Int("html-max-size", 8<<20,
+		"truncate the configurations on the -html page to keep it under `n` bytes (0 for unlimited)")
+)
+
+// htmlPage is the data embedded in the -html page as JSON.
+type htmlPage struct {
+	Left   string      `json:"left"`
+	Right  string      `json:"right"`
+	Blocks []htmlBlock `json:"blocks"`
+	// Truncated is set if the configurations were cut to Limit characters.
+	Truncated bool `json:"truncated,omitempty"`
+	Limit     int  `json:"limit,omitempty"`
+}
+
+// htmlBlock is a block of the final partition. Kind is "left" or "right" for
+// a block with states of one side only, and "mixed" otherwise.
+type htmlBlock struct {
+	ID      int         `json:"id"`
+	Class   int         `json:"class"`
+	Kind    string      `json:"kind"`
+	Initial bool        `json:"initial,omitempty"`
+	States  []htmlState `json:"states"`
+	Moves   []htmlMove  `json:"moves"`
+}
+
+// htmlState is a member of a block, by its original ID.
+type htmlState struct {
+	Side          string `json:"side"`
+	State         int    `json:"state"`
+	Name          string `json:"name,omitempty"`
+	Configuration string `json:"configuration"`
+}
+
+// htmlMove is an entry of the signature of a block: the blocks its first
+// state reaches by Label.
+type htmlMove struct {
+	Label  string `json:"label"`
+	Blocks []int  `json:"blocks"`
+}
+
+// newHTMLPage describes the blocks of part, refined over the LTSs read from
+// inputs, whose states left and right hold.
+func newHTMLPage(part Partition, left, right pifra.Lts, inputs []string) htmlPage {
+	bisim := part.classes()
+	page := htmlPage{Left: inputs[0], Right: inputs[1]}
+	for _, block := range part.Blocks() {
+		states := block.States()
+		b := htmlBlock{ID: block.id, Class: bisim[states[0]], Kind: "mixed"}
+		if !part.mixed(block) {
+			b.Kind = part.sides[states[0]].Side.String()
+		}
+		for _, state := range states {
+			origin := part.sides[state]
+			lts := left
+			if origin.Side == RightSide {
+				lts = right
+			}
+			if state == part.initial[origin.Side] {
+				b.Initial = true
+			}
+			b.States = append(b.States, htmlState{
+				Side:          origin.Side.String(),
+				State:         origin.ID,
+				Name:          stateNames[origin.Side][origin.ID],
+				Configuration: prettyConfiguration(lts.States[state]),
+			})
+		}
+		b.Moves = []htmlMove{}
+		for _, label := range part.actions {
+			if dests := part.destinations(states[0], label); len(dests) > 0 {
+				b.Moves = append(b.Moves, htmlMove{Label: actionText(label), Blocks: dests})
+			}
+		}
+		sort.SliceStable(b.Moves, func(i, j int) bool {
+			return b.Moves[i].Label < b.Moves[j].Label
+		})
+		page.Blocks = append(page.Blocks, b)
+	}
+	return page
+}
+
+// truncated returns page with its configurations cut to n characters.
+func (page htmlPage) truncated(n int) htmlPage {
+	out := page
+	out.Truncated, out.Limit = true, n
+	out.Blocks = make([]htmlBlock, len(page.Blocks))
+	for i, b := range page.Blocks {
+		b.States = append([]htmlState(nil), b.States...)
+		for j, s := range b.States {
+			if r := []rune(s.Configuration); len(r) > n {
+				b.States[j].Configuration = string(r[:n]) + "…"
+			}
+		}
+		out.Blocks[i] = b
+	}
+	return out
+}
+
+// renderHTML renders page as a self-contained HTML document.
+func renderHTML(page htmlPage) ([]byte, error) {
+	// json.Marshal escapes <, > and &, so the data cannot close its script
+	// element.
+	data, err := json.Marshal(page)
+	if err != nil {
+		return nil, err
+	}
+	title := html.EscapeString(page.Left + " vs " + page.Right)
+	var buf bytes.Buffer
+	buf.WriteString(strings.Replace(htmlHead, "{{title}}", title, -1))
+	buf.Write(data)
+	buf.WriteString(htmlTail)
+	return buf.Bytes(), nil
+}
+
+// htmlDocument renders page, cutting its configurations to the most
+// characters that keep it within -html-max-size.
+func htmlDocument(page htmlPage) ([]byte, error) {
+	doc, err := renderHTML(page)
+	if err != nil || *htmlMaxSize <= 0 || len(doc) <= *htmlMaxSize {
+		return doc, err
+	}
+	longest := 0
+	for _, b := range page.Blocks {
+		for _, s := range b.States {
+			if n := len([]rune(s.Configuration)); n > longest {
+				longest = n
+			}
+		}
+	}
+	// Search for the longest cut that fits; lo always fits, or is 0.
+	lo, hi := 0, longest
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		doc, err = renderHTML(page.truncated(mid))
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) <= *htmlMaxSize {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	doc, err = renderHTML(page.truncated(lo))
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) > *htmlMaxSize {
+		log.Printf("-html: the page takes %d bytes even without configurations, over -html-max-size %d",
+			len(doc), *htmlMaxSize)
+	} else {
+		log.Printf("-html: configurations cut to %d characters to keep the page under %d bytes",
+			lo, *htmlMaxSize)
+	}
+	return doc, nil
+}
+
+// writeHTML writes the -html page describing the blocks of part.
+func writeHTML(name string, part Partition, left, right pifra.Lts, inputs []string) error {
+	doc, err := htmlDocument(newHTMLPage(part, left, right, inputs))
+	if err != nil {
+		return err
+	}
+	return writeFile(name, doc)
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pisim: {{title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+#filters input, #filters select { margin-right: 1em; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+tbody tr { cursor: pointer; }
+tbody tr:hover { background: #eef; }
+tr.selected { background: #ddf; }
+td.config { font-family: monospace; max-width: 40em; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+#details pre { white-space: pre-wrap; margin: 0; }
+a.block { cursor: pointer; color: #00c; text-decoration: underline; margin-right: 0.5em; }
+.note { color: #666; }
+</style>
+</head>
+<body>
+<h1>pisim: {{title}}</h1>
+<div id="filters">
+<label>Block <input id="block" size="6"></label>
+<label>State <input id="state" size="6"></label>
+<label>Configuration <input id="config" size="30"></label>
+<label>Side <select id="kind">
+<option value="">all</option>
+<option value="left">only left</option>
+<option value="right">only right</option>
+<option value="mixed">mixed</option>
+</select></label>
+</div>
+<p id="count" class="note"></p>
+<table>
+<thead><tr><th>Block</th><th>Class</th><th>Side</th><th>Left states</th><th>Right states</th><th>Configuration</th></tr></thead>
+<tbody id="rows"></tbody>
+</table>
+<div id="details"></div>
+<script id="data" type="application/json">`
+
+const htmlTail = `</script>
+<script>
+"use strict";
+var page = JSON.parse(document.getElementById("data").textContent);
+var byID = {};
+page.blocks.forEach(function (b) { byID[b.id] = b; });
+var maxRows = 500;
+var selected = null;
+
+function el(tag, text) {
+	var e = document.createElement(tag);
+	if (text !== undefined) e.textContent = text;
+	return e;
+}
+
+function stateName(s) {
+	return s.name ? s.name : String(s.state);
+}
+
+function members(b, side) {
+	return b.states.filter(function (s) { return s.side === side; }).map(stateName).join(" ");
+}
+
+function matches(b, f) {
+	if (f.block !== "" && String(b.id) !== f.block) return false;
+	if (f.kind !== "" && b.kind !== f.kind) return false;
+	if (f.state !== "" && !b.states.some(function (s) {
+		return String(s.state) === f.state || s.name === f.state;
+	})) return false;
+	if (f.config !== "" && !b.states.some(function (s) {
+		return s.configuration.indexOf(f.config) >= 0;
+	})) return false;
+	return true;
+}
+
+function render() {
+	var f = {
+		block: document.getElementById("block").value.trim(),
+		state: document.getElementById("state").value.trim(),
+		config: document.getElementById("config").value,
+		kind: document.getElementById("kind").value
+	};
+	var found = page.blocks.filter(function (b) { return matches(b, f); });
+	var rows = document.getElementById("rows");
+	rows.textContent = "";
+	found.slice(0, maxRows).forEach(function (b) {
+		var tr = el("tr");
+		if (b === selected) tr.className = "selected";
+		tr.appendChild(el("td", b.id + (b.initial ? " (initial)" : "")));
+		tr.appendChild(el("td", b.class));
+		tr.appendChild(el("td", b.kind));
+		tr.appendChild(el("td", members(b, "left")));
+		tr.appendChild(el("td", members(b, "right")));
+		var conf = el("td", b.states[0].configuration);
+		conf.className = "config";
+		tr.appendChild(conf);
+		tr.onclick = function () { show(b); };
+		rows.appendChild(tr);
+	});
+	var count = found.length + " of " + page.blocks.length + " blocks";
+	if (found.length > maxRows) count += ", showing the first " + maxRows;
+	if (page.truncated) count += "; configurations cut to " + (page.limit || 0) + " characters";
+	document.getElementById("count").textContent = count;
+}
+
+function show(b) {
+	selected = b;
+	var d = document.getElementById("details");
+	d.textContent = "";
+	d.appendChild(el("h2", "Block " + b.id + " (class " + b.class + ", " + b.kind + ")"));
+	d.appendChild(el("h3", "Signature"));
+	if (b.moves.length === 0) d.appendChild(el("p", "no moves"));
+	var moves = el("table");
+	b.moves.forEach(function (m) {
+		var tr = el("tr");
+		tr.appendChild(el("td", m.label));
+		var td = el("td");
+		m.blocks.forEach(function (id) {
+			var a = el("a", String(id));
+			a.className = "block";
+			a.onclick = function () { show(byID[id]); };
+			td.appendChild(a);
+		});
+		tr.appendChild(td);
+		moves.appendChild(tr);
+	});
+	d.appendChild(moves);
+	d.appendChild(el("h3", "Members"));
+	var states = el("table");
+	b.states.forEach(function (s) {
+		var tr = el("tr");
+		tr.appendChild(el("td", s.side));
+		tr.appendChild(el("td", stateName(s)));
+		var td = el("td");
+		td.appendChild(el("pre", s.configuration));
+		tr.appendChild(td);
+		states.appendChild(tr);
+	});
+	d.appendChild(states);
+	render();
+	d.scrollIntoView();
+}
+
+["block", "state", "config"].forEach(function (id) {
+	document.getElementById(id).oninput = render;
+});
+document.getElementById("kind").onchange = render;
+render();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/yungene/pifra"
+)
+
+// embeddedPage decodes the data embedded in an -html document.
+func embeddedPage(t *testing.T, doc []byte) htmlPage {
+	t.Helper()
+	const open = `<script id="data" type="application/json">`
+	text := string(doc)
+	start := strings.Index(text, open)
+	if start < 0 {
+		t.Fatalf("no data in\n%s", doc)
+	}
+	text = text[start+len(open):]
+	var page htmlPage
+	if err := json.Unmarshal([]byte(text[:strings.Index(text, "</script>")]), &page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// TestHTMLPage checks the data embedded in the -html page of random pairs
+// against their final partitions: the blocks with their states, classes,
+// kinds and initial states, and the blocks each state reaches by every
+// action.
+func TestHTMLPage(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		part := partKS(left, right)
+		doc, err := renderHTML(newHTMLPage(part, left, right, []string{"l.gob", "r.gob"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := embeddedPage(t, doc)
+		if page.Left != "l.gob" || page.Right != "r.gob" || page.Truncated {
+			t.Fatalf("pair %d: page of %q and %q, truncated %v", i, page.Left, page.Right, page.Truncated)
+		}
+		if len(page.Blocks) != len(part.blocks) {
+			t.Fatalf("pair %d: %d blocks, want %d", i, len(page.Blocks), len(part.blocks))
+		}
+		bisim := part.classes()
+		next := movesOf(left, right)
+		seen := make(map[int]bool)
+		for _, b := range page.Blocks {
+			block, ok := part.blocks[b.ID]
+			if !ok {
+				t.Fatalf("pair %d: no block %d", i, b.ID)
+			}
+			var states []int
+			var sides [2]bool
+			initial := false
+			for _, s := range b.States {
+				side := LeftSide
+				if s.Side == "right" {
+					side = RightSide
+				}
+				state := uniquified(s.State, side)
+				states = append(states, state)
+				sides[side] = true
+				initial = initial || state == part.initial[side]
+				seen[state] = true
+				if bisim[state] != b.Class {
+					t.Fatalf("pair %d: state %d of block %d in class %d, not %d", i, state, b.ID, bisim[state], b.Class)
+				}
+			}
+			sort.Ints(states)
+			if !reflect.DeepEqual(states, block.States()) {
+				t.Fatalf("pair %d: block %d holds %v, want %v", i, b.ID, states, block.States())
+			}
+			kind := "mixed"
+			if !sides[RightSide] {
+				kind = "left"
+			} else if !sides[LeftSide] {
+				kind = "right"
+			}
+			if b.Kind != kind || b.Initial != initial {
+				t.Fatalf("pair %d: block %d of kind %q, initial %v, want %q and %v",
+					i, b.ID, b.Kind, b.Initial, kind, initial)
+			}
+			for _, state := range states {
+				moves := []htmlMove{}
+				for _, label := range part.actions {
+					ids := make(map[int]bool)
+					for _, dst := range next[state][label] {
+						ids[part.states[dst].id] = true
+					}
+					if len(ids) == 0 {
+						continue
+					}
+					move := htmlMove{Label: label.PrettyPrintGraph()}
+					for id := range ids {
+						move.Blocks = append(move.Blocks, id)
+					}
+					sort.Ints(move.Blocks)
+					moves = append(moves, move)
+				}
+				sort.Slice(moves, func(i, j int) bool { return moves[i].Label < moves[j].Label })
+				if !reflect.DeepEqual(moves, b.Moves) {
+					t.Fatalf("pair %d: block %d moves %v, but state %d %v", i, b.ID, b.Moves, state, moves)
+				}
+			}
+		}
+		if len(seen) != len(part.states) {
+			t.Fatalf("pair %d: %d states on the page, want %d", i, len(seen), len(part.states))
+		}
+	}
+}
+
+// TestHTMLMaxSize gives the states of a pair configurations of a thousand
+// characters, and checks that -html-max-size cuts them to keep the page
+// within it, down to nothing for a page of no more than the bare page, and
+// leaves them whole when they fit.
+func TestHTMLMaxSize(t *testing.T) {
+	lts := shard([]int{0, 1, 2}, [3]int{0, 1, 1}, [3]int{1, 2, 2})
+	for state := range lts.States {
+		lts.States[state] = pifra.Configuration{
+			Process:   &pifra.ElemNil{},
+			Registers: pifra.Registers{Size: 1, Registers: map[int]string{1: strings.Repeat("ü", 1000)}},
+		}
+	}
+	left, right := prepared(t, lts, lts)
+	page := newHTMLPage(partKS(left, right), left, right, []string{"l.gob", "r.gob"})
+	full, err := renderHTML(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bare, err := renderHTML(page.truncated(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, max := range []int{len(full), (len(full) + len(bare)) / 2, len(bare)} {
+		setFlag(t, "html-max-size", strconv.Itoa(max))
+		doc, err := htmlDocument(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(doc) > max {
+			t.Errorf("-html-max-size %d: page of %d bytes", max, len(doc))
+		}
+		got := embeddedPage(t, doc)
+		if got.Truncated != (max < len(full)) {
+			t.Errorf("-html-max-size %d: truncated %v", max, got.Truncated)
+		}
+		for _, b := range got.Blocks {
+			for _, s := range b.States {
+				n := utf8.RuneCountInString(s.Configuration)
+				if got.Truncated && (n > got.Limit+1 || !strings.HasSuffix(s.Configuration, "…")) {
+					t.Errorf("-html-max-size %d: configuration of %d characters beyond the limit %d",
+						max, n, got.Limit)
+				}
+				if !got.Truncated && n < 1000 {
+					t.Errorf("-html-max-size %d: configuration cut to %d characters", max, n)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package ltsfile recognises the formats of LTS files and reads and writes
+// the header of the gobs pisim writes. It is shared by the pisim command and
+// the bisim package.
+package ltsfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// Version is the pisim version recorded in the gob files it writes.
+const Version = "0.1.0"
+
+// GobMagic starts every gob written by pisim. A gob stream never starts with a
+// zero byte, so the header is unambiguous.
+const GobMagic = "\x00pisim\n"
+
+const pifraPath = "github.com/yungene/pifra"
+
+// PifraVersion returns the version of pifra pisim was built with.
+func PifraVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == pifraPath {
+				return dep.Version
+			}
+		}
+	}
+	return "(unknown)"
+}
+
+// WriteHeader writes the header of a pisim gob, which ReadHeader checks.
+func WriteHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s%s\n", GobMagic, Version)
+	return err
+}
+
+// ReadHeader consumes the header of a gob written by pisim, if there is one,
+// and reports whether it was found. It fails when the header was written by a
+// different version of pisim.
+func ReadHeader(r *bufio.Reader) (bool, error) {
+	prefix, err := r.Peek(len(GobMagic))
+	if err != nil || !bytes.Equal(prefix, []byte(GobMagic)) {
+		return false, nil
+	}
+	r.Discard(len(GobMagic))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return true, fmt.Errorf("truncated pisim header: %v", err)
+	}
+	if v := line[:len(line)-1]; v != Version {
+		return true, fmt.Errorf(
+			"this file was written by pisim v%s, you're running v%s", v, Version)
+	}
+	return true, nil
+}
+
+// ExplainDecodeError wraps a failure to decode a gob LTS with its likely cause.
+func ExplainDecodeError(err error, header bool) error {
+	if header {
+		return fmt.Errorf("decoding pisim gob: %v", err)
+	}
+	return fmt.Errorf("decoding gob: %v (the file is not a pifra LTS gob, or "+
+		"was written by a pifra version incompatible with %s %s)",
+		err, pifraPath, PifraVersion())
+}
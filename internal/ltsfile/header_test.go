@@ -0,0 +1,51 @@
+package ltsfile
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	var written bytes.Buffer
+	if err := WriteHeader(&written); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		data   string
+		header bool
+		fails  string
+	}{
+		{"current", written.String() + "gob", true, ""},
+		{"plain gob", "\x1e\xff\x81gob", false, ""},
+		{"mismatch", GobMagic + "0.0.1\ngob", true, "written by pisim v0.0.1, you're running v" + Version},
+		{"truncated", GobMagic + Version, true, "truncated pisim header"},
+	} {
+		r := bufio.NewReader(strings.NewReader(test.data))
+		header, err := ReadHeader(r)
+		if header != test.header {
+			t.Errorf("%s: header found %v, want %v", test.name, header, test.header)
+		}
+		if test.fails == "" && err != nil || test.fails != "" && (err == nil || !strings.Contains(err.Error(), test.fails)) {
+			t.Errorf("%s: ReadHeader() = %v, want %q", test.name, err, test.fails)
+			continue
+		}
+		if test.fails == "" {
+			if rest, _ := r.ReadString(0); !strings.HasSuffix(rest, "gob") || header && rest != "gob" {
+				t.Errorf("%s: %q left after the header", test.name, rest)
+			}
+		}
+	}
+}
+
+func TestExplainDecodeError(t *testing.T) {
+	err := ExplainDecodeError(bytes.ErrTooLarge, false)
+	if !strings.Contains(err.Error(), "incompatible with "+pifraPath) {
+		t.Errorf("pifra gob: %v, want the pifra version explained", err)
+	}
+	if err := ExplainDecodeError(bytes.ErrTooLarge, true); err.Error() != "decoding pisim gob: "+bytes.ErrTooLarge.Error() {
+		t.Errorf("pisim gob: %v", err)
+	}
+}
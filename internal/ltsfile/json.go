@@ -0,0 +1,26 @@
+package ltsfile
+
+// JSONLTS is the JSON encoding of an LTS, as pisim reads it and writes it
+// under -output-encoding json. State 0 is the initial state, as in pifra's
+// gobs. Configurations are written as pifra prints them, for people to read,
+// and ignored on reading.
+type JSONLTS struct {
+	States      []JSONState      `json:"states"`
+	Transitions []JSONTransition `json:"transitions"`
+}
+
+// JSONState is a state of a JSONLTS.
+type JSONState struct {
+	ID            int    `json:"id"`
+	Configuration string `json:"configuration,omitempty"`
+	// RegSizeReached marks the states where pifra hit its register bound.
+	RegSizeReached bool `json:"reg_size_reached,omitempty"`
+}
+
+// JSONTransition is a transition of a JSONLTS.
+type JSONTransition struct {
+	Source      int `json:"source"`
+	Destination int `json:"destination"`
+	// Label is written as in pifra's graphs, such as "1 2" or "1' 2", or τ.
+	Label string `json:"label"`
+}
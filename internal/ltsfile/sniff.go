@@ -0,0 +1,123 @@
+package ltsfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// SniffSize is the length of the prefix inspected to recognise a file format.
+const SniffSize = 64
+
+// Format is a file format recognised by Sniff.
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatEmpty
+	FormatPisim
+	FormatGob
+	FormatGzip
+	FormatDot
+	FormatJSON
+	FormatAut
+	FormatPretty
+)
+
+// foreignFormats describes the formats pisim recognises but cannot read.
+var foreignFormats = map[Format]string{
+	FormatDot:    "a GraphViz dot file",
+	FormatJSON:   "a JSON file",
+	FormatAut:    "an Aldebaran .aut file",
+	FormatPretty: "pifra's pretty-printed output (--output-pretty)",
+}
+
+// Sniff recognises the format of a file from its first bytes.
+func Sniff(prefix []byte) Format {
+	if len(prefix) == 0 {
+		return FormatEmpty
+	}
+	if bytes.HasPrefix(prefix, []byte(GobMagic)) {
+		return FormatPisim
+	}
+	if bytes.HasPrefix(prefix, []byte{0x1f, 0x8b}) {
+		return FormatGzip
+	}
+	if isGobTypeDefinition(prefix) {
+		return FormatGob
+	}
+	text := bytes.TrimLeft(bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(text, []byte("digraph")), bytes.HasPrefix(text, []byte("strict digraph")):
+		return FormatDot
+	case bytes.HasPrefix(text, []byte("{")), bytes.HasPrefix(text, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(text, []byte("des (")), bytes.HasPrefix(text, []byte("des(")):
+		return FormatAut
+	case bytes.HasPrefix(text, []byte("s0 ")), bytes.HasPrefix(text, []byte("s0+ ")):
+		return FormatPretty
+	}
+	return FormatUnknown
+}
+
+// gobUint decodes an unsigned integer in gob's encoding, returning its value
+// and length.
+func gobUint(b []byte) (uint64, int, bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	if b[0] < 0x80 {
+		return uint64(b[0]), 1, true
+	}
+	n := -int(int8(b[0]))
+	if n > 8 || len(b) < n+1 {
+		return 0, 0, false
+	}
+	var x uint64
+	for _, c := range b[1 : n+1] {
+		x = x<<8 | uint64(c)
+	}
+	return x, n + 1, true
+}
+
+// firstGobTypeID is the smallest ID gob assigns to a user-defined type.
+const firstGobTypeID = 64
+
+// isGobTypeDefinition reports whether prefix starts like a gob stream: a
+// message length followed by the negated ID of a user-defined type, which
+// introduces the definition of the type of the first value.
+func isGobTypeDefinition(prefix []byte) bool {
+	length, n, ok := gobUint(prefix)
+	if !ok || length == 0 {
+		return false
+	}
+	id, _, ok := gobUint(prefix[n:])
+	// Signed integers carry their sign in the lowest bit, and the negation
+	// of the ID in the other bits.
+	return ok && id&1 == 1 && id>>1+1 >= firstGobTypeID
+}
+
+// SniffLTS inspects the start of r without consuming it, and returns the
+// reader to decode the LTS from or an error naming the format found.
+func SniffLTS(r *bufio.Reader) (*bufio.Reader, error) {
+	prefix, _ := r.Peek(SniffSize)
+	f := Sniff(prefix)
+	switch f {
+	case FormatPisim, FormatGob:
+		return r, nil
+	case FormatGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip: %v", err)
+		}
+		return SniffLTS(bufio.NewReader(zr))
+	case FormatEmpty:
+		return nil, fmt.Errorf("the file is empty")
+	case FormatUnknown:
+		return nil, fmt.Errorf("unrecognised file format: expected an LTS gob " +
+			"written by pifra --output-gob (-g) or by pisim")
+	}
+	return nil, fmt.Errorf("the file looks like %s, but pisim reads LTS gobs: "+
+		"run pifra with --output-gob (-g)", foreignFormats[f])
+}
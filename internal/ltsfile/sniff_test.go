@@ -0,0 +1,93 @@
+package ltsfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	type lts struct {
+		States      map[int]string
+		Transitions []int
+	}
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(lts{States: map[int]string{0: "s"}}); err != nil {
+		t.Fatal(err)
+	}
+	var pisim bytes.Buffer
+	WriteHeader(&pisim)
+	pisim.Write(plain.Bytes())
+	for _, test := range []struct {
+		name string
+		data []byte
+		want Format
+	}{
+		{"empty", nil, FormatEmpty},
+		{"pisim", pisim.Bytes(), FormatPisim},
+		{"gob", plain.Bytes(), FormatGob},
+		{"gzip", gzipped(t, plain.Bytes()), FormatGzip},
+		{"dot", []byte("digraph {\n    0 -> 1\n}\n"), FormatDot},
+		{"strict dot", []byte("\n strict digraph {}"), FormatDot},
+		{"json", []byte("\xef\xbb\xbf{\"states\": []}"), FormatJSON},
+		{"aut", []byte("des (0, 1, 2)\n(0, \"a\", 1)\n"), FormatAut},
+		{"pretty", []byte("s0 = ...\n"), FormatPretty},
+		{"garbage", []byte("hello, world"), FormatUnknown},
+		{"gob of a predeclared type", []byte{0x03, 0x04, 0x00, 0x02}, FormatUnknown},
+	} {
+		if got := Sniff(test.data); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSniffLTS(t *testing.T) {
+	var plain bytes.Buffer
+	gob.NewEncoder(&plain).Encode(struct{ States map[int]string }{map[int]string{0: "s"}})
+	for _, test := range []struct {
+		name  string
+		data  []byte
+		fails string
+	}{
+		{"gob", plain.Bytes(), ""},
+		{"gzip", gzipped(t, plain.Bytes()), ""},
+		{"empty", nil, "the file is empty"},
+		{"dot", []byte("digraph {}"), "looks like a GraphViz dot file"},
+		{"pretty", []byte("s0 = a"), "pifra's pretty-printed output"},
+		{"garbage", []byte("hello"), "unrecognised file format"},
+		{"gzipped garbage", gzipped(t, []byte("hello")), "unrecognised file format"},
+	} {
+		r, err := SniffLTS(bufio.NewReader(bytes.NewReader(test.data)))
+		if test.fails != "" {
+			if err == nil || !strings.Contains(err.Error(), test.fails) {
+				t.Errorf("%s: got %v, want %q", test.name, err, test.fails)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		// The prefix inspected is read again from the reader returned.
+		data, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(data, plain.Bytes()) {
+			t.Errorf("%s: read %q and %v, want the gob", test.name, data, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package reference decides bisimilarity of small LTSs by the textbook
+// greatest fixpoint, to cross-check the optimised algorithms of pisim. It
+// starts from the full relation between the states of two LTSs and removes
+// pairs that violate the transfer property until none do, which takes
+// O(n²·m) time: it is only meant for LTSs of a few dozen states.
+package reference
+
+import (
+	"math/rand"
+
+	"github.com/yungene/pifra"
+)
+
+type pair struct {
+	s, t int
+}
+
+type move struct {
+	label pifra.Label
+	dst   int
+}
+
+func isTau(label pifra.Label) bool {
+	return label.Symbol.Type == pifra.SymbolTypTau
+}
+
+func moves(lts pifra.Lts) map[int][]move {
+	m := make(map[int][]move)
+	for _, trans := range lts.Transitions {
+		m[trans.Source] = append(m[trans.Source], move{trans.Label, trans.Destination})
+	}
+	return m
+}
+
+// matched reports whether every move of s is matched by a move of t with the
+// same label into a state related to the destination of the move of s.
+func matched(s, t int, sm, tm map[int][]move, rel map[pair]bool, swap bool) bool {
+	for _, ms := range sm[s] {
+		ok := false
+		for _, mt := range tm[t] {
+			p := pair{ms.dst, mt.dst}
+			if swap {
+				p = pair{mt.dst, ms.dst}
+			}
+			if ms.label == mt.label && rel[p] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// greatest returns the greatest bisimulation between the states of left and
+// right, given the moves of each.
+func greatest(left, right pifra.Lts, lm, rm map[int][]move) map[pair]bool {
+	rel := make(map[pair]bool)
+	for s := range left.States {
+		for t := range right.States {
+			rel[pair{s, t}] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for p := range rel {
+			if !matched(p.s, p.t, lm, rm, rel, false) || !matched(p.t, p.s, rm, lm, rel, true) {
+				delete(rel, p)
+				changed = true
+			}
+		}
+	}
+	return rel
+}
+
+// Strong reports whether the initial states of left and right, both state 0,
+// are strongly bisimilar.
+func Strong(left, right pifra.Lts) bool {
+	return greatest(left, right, moves(left), moves(right))[pair{0, 0}]
+}
+
+// closure maps every state of lts to the states it reaches by zero or more
+// tau moves.
+func closure(lts pifra.Lts, m map[int][]move) map[int]map[int]bool {
+	reach := make(map[int]map[int]bool)
+	for s := range lts.States {
+		seen := map[int]bool{s: true}
+		stack := []int{s}
+		for len(stack) > 0 {
+			u := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, mv := range m[u] {
+				if isTau(mv.label) && !seen[mv.dst] {
+					seen[mv.dst] = true
+					stack = append(stack, mv.dst)
+				}
+			}
+		}
+		reach[s] = seen
+	}
+	return reach
+}
+
+// weakMoves returns the weak moves of lts: s =tau=> t if t is reachable by
+// tau moves, and s =a=> t for a visible a if s tau* a tau* t, or only if
+// s tau* a t without trailing.
+func weakMoves(lts pifra.Lts, trailing bool) map[int][]move {
+	m := moves(lts)
+	reach := closure(lts, m)
+	weak := make(map[int][]move)
+	tau := pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}
+	for s := range lts.States {
+		seen := make(map[move]bool)
+		add := func(mv move) {
+			if !seen[mv] {
+				seen[mv] = true
+				weak[s] = append(weak[s], mv)
+			}
+		}
+		for u := range reach[s] {
+			add(move{tau, u})
+			for _, mv := range m[u] {
+				if isTau(mv.label) {
+					continue
+				}
+				if !trailing {
+					add(mv)
+					continue
+				}
+				for v := range reach[mv.dst] {
+					add(move{mv.label, v})
+				}
+			}
+		}
+	}
+	return weak
+}
+
+// Weak reports whether the initial states of left and right are weakly
+// bisimilar, where every tau label, whatever its second symbol, is the same
+// silent action.
+func Weak(left, right pifra.Lts) bool {
+	return greatest(left, right, weakMoves(left, true), weakMoves(right, true))[pair{0, 0}]
+}
+
+// Delay reports whether the initial states of left and right are delay
+// bisimilar: as Weak, but a visible move is not followed by tau moves.
+func Delay(left, right pifra.Lts) bool {
+	return greatest(left, right, weakMoves(left, false), weakMoves(right, false))[pair{0, 0}]
+}
+
+// Random returns an LTS with states 0 to n-1 and m transitions between
+// random states, labelled by tau with probability tau and otherwise by one of
+// labels input actions.
+func Random(r *rand.Rand, n, m, labels int, tau float64) pifra.Lts {
+	lts := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, n),
+		RegSizeReached: make(map[int]bool),
+	}
+	for s := 0; s < n; s++ {
+		lts.States[s] = pifra.Configuration{}
+	}
+	for i := 0; i < m; i++ {
+		label := pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}
+		if r.Float64() >= tau {
+			label = pifra.Label{
+				Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: 1 + r.Intn(labels)},
+				Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 1},
+			}
+		}
+		lts.Transitions = append(lts.Transitions, pifra.Transition{
+			Source:      r.Intn(n),
+			Destination: r.Intn(n),
+			Label:       label,
+		})
+	}
+	return lts
+}
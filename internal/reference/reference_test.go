@@ -0,0 +1,77 @@
+package reference
+
+import (
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func action(a int) pifra.Label {
+	return pifra.Label{
+		Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: a},
+		Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 1},
+	}
+}
+
+var tau = pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}
+
+// lts builds an LTS of n states from transitions given as source, label and
+// destination.
+func lts(n int, moves ...interface{}) pifra.Lts {
+	l := pifra.Lts{States: make(map[int]pifra.Configuration)}
+	for s := 0; s < n; s++ {
+		l.States[s] = pifra.Configuration{}
+	}
+	for i := 0; i < len(moves); i += 3 {
+		l.Transitions = append(l.Transitions, pifra.Transition{
+			Source:      moves[i].(int),
+			Label:       moves[i+1].(pifra.Label),
+			Destination: moves[i+2].(int),
+		})
+	}
+	return l
+}
+
+func TestReference(t *testing.T) {
+	a, b, c := action(1), action(2), action(3)
+	for _, test := range []struct {
+		name                string
+		left, right         pifra.Lts
+		strong, weak, delay bool
+	}{
+		{"a.(b+c) vs a.b+a.c",
+			lts(4, 0, a, 1, 1, b, 2, 1, c, 3),
+			lts(5, 0, a, 1, 0, a, 2, 1, b, 3, 2, c, 4),
+			false, false, false},
+		{"a.0 vs a.0+a.0",
+			lts(2, 0, a, 1),
+			lts(3, 0, a, 1, 0, a, 2),
+			true, true, true},
+		{"tau.a vs a",
+			lts(3, 0, tau, 1, 1, a, 2),
+			lts(2, 0, a, 1),
+			false, true, true},
+		{"a.tau.b vs a.b",
+			lts(4, 0, a, 1, 1, tau, 2, 2, b, 3),
+			lts(3, 0, a, 1, 1, b, 2),
+			false, true, true},
+		{"a.(c+tau.b)+a.b vs a.(c+tau.b)",
+			lts(5, 0, a, 1, 1, c, 2, 1, tau, 3, 3, b, 4, 0, a, 3),
+			lts(5, 0, a, 1, 1, c, 2, 1, tau, 3, 3, b, 4),
+			false, true, false},
+		{"a+tau.b vs a+b",
+			lts(4, 0, a, 1, 0, tau, 2, 2, b, 3),
+			lts(3, 0, a, 1, 0, b, 2),
+			false, false, false},
+	} {
+		if got := Strong(test.left, test.right); got != test.strong {
+			t.Errorf("%s: Strong = %v", test.name, got)
+		}
+		if got := Weak(test.left, test.right); got != test.weak {
+			t.Errorf("%s: Weak = %v", test.name, got)
+		}
+		if got := Delay(test.left, test.right); got != test.delay {
+			t.Errorf("%s: Delay = %v", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var checkIso = flag.Bool("check-iso", false,
+	"on a positive verdict, check that the reachable quotients of both sides are isomorphic and print the bijection of classes")
+
+// classGraph is the reachable part of the quotient of one side, over class
+// labels.
+type classGraph struct {
+	root  int
+	nodes []int
+	succs map[int][]quotientEdge
+	edges map[quotientEdge]bool
+	in    map[int]int
+}
+
+func newClassGraph(bisim Bisimulation, lts pifra.Lts, initial int) classGraph {
+	g := classGraph{
+		root:  bisim[initial],
+		succs: make(map[int][]quotientEdge),
+		edges: make(map[quotientEdge]bool),
+		in:    make(map[int]int),
+	}
+	all := make(map[int][]quotientEdge)
+	for _, edge := range quotientEdges(bisim, lts) {
+		all[edge.src] = append(all[edge.src], edge)
+	}
+	seen := map[int]bool{g.root: true}
+	g.nodes = []int{g.root}
+	for i := 0; i < len(g.nodes); i++ {
+		for _, edge := range all[g.nodes[i]] {
+			g.succs[edge.src] = append(g.succs[edge.src], edge)
+			g.edges[edge] = true
+			g.in[edge.dst]++
+			if !seen[edge.dst] {
+				seen[edge.dst] = true
+				g.nodes = append(g.nodes, edge.dst)
+			}
+		}
+	}
+	return g
+}
+
+// shape summarises the edges around a node, which an isomorphism must
+// preserve.
+func (g classGraph) shape(node int) string {
+	labels := make([]pifra.Label, 0, len(g.succs[node]))
+	for _, edge := range g.succs[node] {
+		labels = append(labels, edge.label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return bisim.LabelLess(labels[i], labels[j])
+	})
+	return fmt.Sprint(g.in[node], labels)
+}
+
+// isomorphism searches for a bijection between the nodes of a and b that maps
+// root to root and preserves labelled edges. Nodes are assigned in the order
+// a discovers them, each to a successor of the image of its discoverer.
+func isomorphism(a, b classGraph) (map[int]int, bool) {
+	if len(a.nodes) != len(b.nodes) || len(a.edges) != len(b.edges) {
+		return nil, false
+	}
+	// Class labels are shared by both sides, so a node and its image may
+	// carry the same label: keep everything about the two graphs apart.
+	shapes := [2]map[int]string{make(map[int]string), make(map[int]string)}
+	for i, g := range []classGraph{a, b} {
+		for _, node := range g.nodes {
+			shapes[i][node] = g.shape(node)
+		}
+	}
+	// parent records the edge by which a first discovered each node.
+	parent := make(map[int]quotientEdge, len(a.nodes))
+	for _, node := range a.nodes {
+		for _, edge := range a.succs[node] {
+			if _, ok := parent[edge.dst]; !ok && edge.dst != a.root {
+				parent[edge.dst] = edge
+			}
+		}
+	}
+	image := make(map[int]int, len(a.nodes))
+	used := make(map[int]bool, len(b.nodes))
+	consistent := func(node, target int) bool {
+		if shapes[0][node] != shapes[1][target] {
+			return false
+		}
+		for _, edge := range a.succs[node] {
+			if dst, ok := image[edge.dst]; ok {
+				if !b.edges[quotientEdge{target, dst, edge.label}] {
+					return false
+				}
+			}
+		}
+		for src, img := range image {
+			for _, edge := range a.succs[src] {
+				if edge.dst == node && !b.edges[quotientEdge{img, target, edge.label}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(a.nodes) {
+			return true
+		}
+		node := a.nodes[i]
+		var candidates []int
+		if node == a.root {
+			candidates = []int{b.root}
+		} else {
+			edge := parent[node]
+			for _, e := range b.succs[image[edge.src]] {
+				if e.label == edge.label {
+					candidates = append(candidates, e.dst)
+				}
+			}
+		}
+		for _, target := range candidates {
+			if used[target] || !consistent(node, target) {
+				continue
+			}
+			image[node], used[target] = target, true
+			if assign(i + 1) {
+				return true
+			}
+			delete(image, node)
+			delete(used, target)
+		}
+		return false
+	}
+	if !assign(0) {
+		return nil, false
+	}
+	return image, true
+}
+
+// printIsomorphism checks that the reachable quotients of left and right are
+// isomorphic, as they must be for bisimilar initial states, and prints the
+// bijection of class labels.
+func printIsomorphism(w io.Writer, bisim Bisimulation, left, right pifra.Lts) error {
+	a := newClassGraph(bisim, left, 0)
+	image, ok := isomorphism(a, newClassGraph(bisim, right, 1))
+	if !ok {
+		return fmt.Errorf("internal error: the quotients of bisimilar LTSs are not isomorphic")
+	}
+	fmt.Fprintln(w, "quotients are isomorphic:")
+	nodes := append([]int(nil), a.nodes...)
+	sort.Ints(nodes)
+	for _, node := range nodes {
+		fmt.Fprintf(w, "  %d -> %d\n", node, image[node])
+	}
+	return nil
+}
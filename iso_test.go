@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestIsomorphism(t *testing.T) {
+	a, b := inputLabel(1, 1), inputLabel(2, 2)
+	lts := func(trans ...pifra.Transition) pifra.Lts {
+		out := pifra.Lts{States: make(map[int]pifra.Configuration)}
+		for _, tr := range trans {
+			out.States[tr.Source], out.States[tr.Destination] = pifra.Configuration{}, pifra.Configuration{}
+		}
+		out.Transitions = trans
+		return out
+	}
+	// Left states are even and right states odd, as uniquified.
+	left := lts(pifra.Transition{Source: 0, Destination: 2, Label: a}, pifra.Transition{Source: 2, Destination: 4, Label: b})
+	right := lts(pifra.Transition{Source: 1, Destination: 3, Label: a}, pifra.Transition{Source: 3, Destination: 5, Label: b})
+	bisim := Bisimulation{0: 10, 2: 20, 4: 30, 1: 100, 3: 200, 5: 300}
+	image, ok := isomorphism(newClassGraph(bisim, left, 0), newClassGraph(bisim, right, 1))
+	if want := map[int]int{10: 100, 20: 200, 30: 300}; !ok || !reflect.DeepEqual(image, want) {
+		t.Errorf("got %v, %v, want %v", image, ok, want)
+	}
+	swapped := lts(pifra.Transition{Source: 1, Destination: 3, Label: b}, pifra.Transition{Source: 3, Destination: 5, Label: a})
+	if image, ok := isomorphism(newClassGraph(bisim, left, 0), newClassGraph(bisim, swapped, 1)); ok {
+		t.Errorf("b.a found isomorphic to a.b by %v", image)
+	}
+}
+
+// TestCheckIso checks -check-iso on an LTS and a renaming of its states,
+// whose classes are named alike on both sides.
+func TestCheckIso(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runPisim(t, dir, "-quiet", "-check-iso",
+		filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob"))
+	want := "quotients are isomorphic:\n  0 -> 0\n  2 -> 2\n  3 -> 3\n  5 -> 5\n  6 -> 6\n  7 -> 7\n"
+	if code != 0 || stdout != want {
+		t.Errorf("status %d and %q, want 0 and %q: %s", code, stdout, want, stderr)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/ltsfile"
+)
+
+// encodeLTSJSON encodes lts as JSON, with its states in increasing order.
+func encodeLTSJSON(ctx context.Context, lts pifra.Lts) ([]byte, error) {
+	var buf bytes.Buffer
+	err := bisim.WriteJSON(ctx, &buf, lts)
+	return buf.Bytes(), err
+}
+
+// readJSON reads an LTS encoded as by encodeLTSJSON. Its states are named by
+// their IDs, since their configurations are lost.
+func readJSON(r io.Reader) (pifra.Lts, map[int]string, error) {
+	var dec ltsfile.JSONLTS
+	if err := json.NewDecoder(r).Decode(&dec); err != nil {
+		return pifra.Lts{}, nil, err
+	}
+	lts := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(dec.States)),
+		RegSizeReached: make(map[int]bool),
+	}
+	names := make(map[int]string, len(dec.States))
+	for _, state := range dec.States {
+		if _, ok := lts.States[state.ID]; ok {
+			return pifra.Lts{}, nil, fmt.Errorf("state %d is listed twice", state.ID)
+		}
+		lts.States[state.ID] = pifra.Configuration{}
+		names[state.ID] = strconv.Itoa(state.ID)
+		if state.RegSizeReached {
+			lts.RegSizeReached[state.ID] = true
+		}
+	}
+	if _, ok := lts.States[0]; !ok {
+		return pifra.Lts{}, nil, fmt.Errorf("no initial state 0")
+	}
+	for i, trans := range dec.Transitions {
+		for _, id := range []int{trans.Source, trans.Destination} {
+			if _, ok := lts.States[id]; !ok {
+				return pifra.Lts{}, nil, fmt.Errorf("transition %d: unknown state %d", i, id)
+			}
+		}
+		label, err := parseLabel(trans.Label)
+		if err != nil {
+			return pifra.Lts{}, nil, fmt.Errorf("transition %d: %v", i, err)
+		}
+		lts.Transitions = append(lts.Transitions, pifra.Transition{
+			Source:      trans.Source,
+			Destination: trans.Destination,
+			Label:       label,
+		})
+	}
+	lts.StatesExplored = len(lts.States)
+	lts.StatesGenerated = len(lts.States)
+	return lts, names, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// A label equivalence table is a JSON object mapping the printed form of a
+// label to the printed form of its canonical representative, such as
+// {"b 1": "a 1", "c 1": "a 1"}. Refinement compares every label the table
+// names as the action of its canonical form, so that b 1, c 1 and a 1 are one
+// and the same action. Unlike a renaming the table need not be injective, and
+// it is applied once: a form it maps to is not looked up again, so collapsing
+// a, b and c means mapping both b and c to a. Labels the table does not name
+// are still compared as they are, even if some print alike, such as the
+// classes of -observe. The representative of a class of labels is the least
+// label printed in the canonical form that the table does not map elsewhere,
+// if there is one, or else the least label of the class, and outputs name the
+// class by it. Silent and visible labels cannot be collapsed together, since
+// saturation must already know which moves are silent.
+var labelEquivFile = flag.String("label-equiv", "",
+	"compare labels modulo the JSON `table` mapping the printed form of labels to that of their representative")
+
+// labelEquiv is the table given by -label-equiv, if any.
+var labelEquiv map[string]string
+
+// labelActions maps the labels of the latest partition to their
+// representatives under labelEquiv.
+var labelActions map[pifra.Label]pifra.Label
+
+// actionOf returns the action refinement compared label by.
+func actionOf(label pifra.Label) pifra.Label {
+	if action, ok := labelActions[label]; ok {
+		return action
+	}
+	return label
+}
+
+// actionLTS returns lts with the label of every transition replaced by its
+// action, as refinement compares it.
+func actionLTS(lts pifra.Lts) pifra.Lts {
+	if labelEquiv == nil {
+		return lts
+	}
+	out := lts
+	out.Transitions = make([]pifra.Transition, len(lts.Transitions))
+	for i, trans := range lts.Transitions {
+		trans.Label = actionOf(trans.Label)
+		out.Transitions[i] = trans
+	}
+	return out
+}
+
+func loadLabelEquiv() error {
+	if *labelEquivFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(*labelEquivFile)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &labelEquiv); err != nil {
+		return fmt.Errorf("%s: %v", *labelEquivFile, err)
+	}
+	return nil
+}
+
+// canonicalLabels maps each of labels to the representative of its class
+// under labelEquiv, and every label the table does not name to itself.
+func canonicalLabels(labels []pifra.Label) map[pifra.Label]pifra.Label {
+	forms := make(map[string]bool, len(labelEquiv))
+	for _, form := range labelEquiv {
+		forms[form] = true
+	}
+	// printed holds, for each canonical form, the least label printed in it
+	// that stays in its class, and named the least label mapped to it.
+	printed := make(map[string]pifra.Label)
+	named := make(map[string]pifra.Label)
+	least := func(reps map[string]pifra.Label, form string, label pifra.Label) {
+		if rep, ok := reps[form]; !ok || bisim.LabelLess(label, rep) {
+			reps[form] = label
+		}
+	}
+	for _, label := range labels {
+		text := label.PrettyPrintGraph()
+		form, ok := labelEquiv[text]
+		if ok {
+			least(named, form, label)
+		}
+		if forms[text] && (!ok || form == text) {
+			least(printed, text, label)
+		}
+	}
+	canon := make(map[pifra.Label]pifra.Label, len(labels))
+	for _, label := range labels {
+		canon[label] = label
+		if form, ok := labelEquiv[label.PrettyPrintGraph()]; ok {
+			if rep, ok := printed[form]; ok {
+				canon[label] = rep
+			} else {
+				canon[label] = named[form]
+			}
+		}
+	}
+	return canon
+}
+
+// checkLabelEquiv refuses a table collapsing silent and visible labels of the
+// LTSs together.
+func checkLabelEquiv(ltss ...pifra.Lts) error {
+	if labelEquiv == nil {
+		return nil
+	}
+	var labels []pifra.Label
+	seen := make(map[pifra.Label]bool)
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if !seen[trans.Label] {
+				seen[trans.Label] = true
+				labels = append(labels, trans.Label)
+			}
+		}
+	}
+	// silent and visible map each representative to the least silent and
+	// visible label of its class.
+	silent := make(map[pifra.Label]pifra.Label)
+	visible := make(map[pifra.Label]pifra.Label)
+	for label, rep := range canonicalLabels(labels) {
+		side := visible
+		if IsTau(label) {
+			side = silent
+		}
+		if old, ok := side[rep]; !ok || bisim.LabelLess(label, old) {
+			side[rep] = label
+		}
+	}
+	var mixed []pifra.Label
+	for rep := range silent {
+		if _, ok := visible[rep]; ok {
+			mixed = append(mixed, rep)
+		}
+	}
+	if len(mixed) == 0 {
+		return nil
+	}
+	sort.Slice(mixed, func(i, j int) bool { return bisim.LabelLess(mixed[i], mixed[j]) })
+	rep := mixed[0]
+	return fmt.Errorf("%s: silent label %q and visible label %q both map to %q", *labelEquivFile,
+		silent[rep].PrettyPrintGraph(), visible[rep].PrettyPrintGraph(), rep.PrettyPrintGraph())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestCanonicalLabels(t *testing.T) {
+	old := labelEquiv
+	t.Cleanup(func() { labelEquiv = old })
+	labelEquiv = map[string]string{"2 1": "3 1", "4 1": "3 1", "5 1": "6 1", "7 1": "6 1"}
+	b, c, d, e, f, g := inputLabel(2, 1), inputLabel(3, 1), inputLabel(4, 1), inputLabel(5, 1),
+		inputLabel(6, 1), inputLabel(7, 1)
+	got := canonicalLabels([]pifra.Label{b, c, d, g, e, tauLabel})
+	want := map[pifra.Label]pifra.Label{
+		// The label printed as the canonical form represents its class.
+		b: c, c: c, d: c,
+		// Without one, the least label of the class does.
+		e: e, g: e,
+		tauLabel: tauLabel,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("canonicalLabels() = %v, want %v", got, want)
+	}
+	if got := canonicalLabels([]pifra.Label{f, g})[g]; got != f {
+		t.Errorf("7 1 represented by %v, want %v", got, f)
+	}
+}
+
+// TestLabelEquiv compares a.b with a.c, which are bisimilar only once c is an
+// alias of b.
+func TestLabelEquiv(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"3 1\", 2)\n")
+	for _, test := range []struct {
+		name, table string
+		code        int
+	}{
+		{"none", "", 1},
+		{"alias", `{"3 1": "2 1"}`, 0},
+		{"both to a third", `{"3 1": "9 9", "2 1": "9 9"}`, 0},
+		// The table is applied once: 3 1 becomes 2 1, but 2 1 becomes 1 1.
+		{"not transitive", `{"3 1": "2 1", "2 1": "1 1"}`, 1},
+	} {
+		args := []string{"-quiet"}
+		if test.table != "" {
+			args = append(args, "-label-equiv", writeTestFile(t, dir, "table.json", test.table))
+		}
+		stdout, stderr, code := runPisim(t, dir, append(args, left, right)...)
+		if code != test.code {
+			t.Errorf("%s: status %d, want %d: %s%s", test.name, code, test.code, stdout, stderr)
+		}
+	}
+
+	silent := writeTestFile(t, dir, "silent.aut", "des (0, 1, 2)\n(0, i, 1)\n")
+	for _, test := range []struct {
+		table, want string
+	}{
+		{`{"2 1": "τ"}`, `silent label "τ" and visible label "2 1" both map to "τ"`},
+		{`{"2 1": 3}`, "table.json: json: cannot unmarshal number"},
+	} {
+		table := writeTestFile(t, dir, "table.json", test.table)
+		_, stderr, code := runPisim(t, dir, "-quiet", "-label-equiv", table, left, silent)
+		if code == 0 || !strings.Contains(stderr, test.want) {
+			t.Errorf("%s: status %d and %q, want an error holding %q", test.table, code, stderr, test.want)
+		}
+	}
+}
+
+// TestLabelEquivObserve compares inputs on channels 1 and 3 observed in two
+// classes, which print alike but stay distinct actions under a table naming
+// neither.
+func TestLabelEquivObserve(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"3 1\", 1)\n")
+	spec := writeTestFile(t, dir, "observe.json",
+		`{"classes": [{"name": "on 1", "patterns": ["1 *"]}, {"name": "on 3", "patterns": ["3 *"]}]}`)
+	for _, test := range []struct {
+		name, table string
+		code        int
+	}{
+		{"no table", "", 1},
+		{"unrelated table", `{"9 9": "9 9"}`, 1},
+	} {
+		args := []string{"-quiet", "-observe", spec}
+		if test.table != "" {
+			args = append(args, "-label-equiv", writeTestFile(t, dir, "table.json", test.table))
+		}
+		stdout, stderr, code := runPisim(t, dir, append(args, left, right)...)
+		if code != test.code {
+			t.Errorf("%s: status %d, want %d: %s%s", test.name, code, test.code, stdout, stderr)
+		}
+	}
+}
+
+// TestCanonicalLabelsByValue checks that labels the table does not name keep
+// their own action even when they print alike, and that of several labels
+// printed in a canonical form only the least joins its class.
+func TestCanonicalLabelsByValue(t *testing.T) {
+	old := labelEquiv
+	t.Cleanup(func() { labelEquiv = old })
+	x := pifra.Label{Symbol: pifra.Symbol{Type: observedClass, Value: 0}}
+	y := pifra.Label{Symbol: pifra.Symbol{Type: observedClass, Value: 1}}
+	labelEquiv = map[string]string{"2 1": x.PrettyPrintGraph()}
+	b := inputLabel(2, 1)
+	got := canonicalLabels([]pifra.Label{y, x, b})
+	want := map[pifra.Label]pifra.Label{x: x, y: y, b: x}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("canonicalLabels() = %v, want %v", got, want)
+	}
+}
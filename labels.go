@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var (
+	strictLabels = flag.Bool("strict-labels", false,
+		"fail when distinct labels share the same printed form")
+	mergeLabels = flag.Bool("merge-labels-by-text", false,
+		"treat labels with the same printed form as the same action")
+)
+
+// labelConflicts groups the distinct labels of the LTSs by their printed form
+// and returns the forms shared by more than one label, each with its labels in
+// order.
+func labelConflicts(ltss ...pifra.Lts) map[string][]pifra.Label {
+	texts := make(map[string]map[pifra.Label]struct{})
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			text := trans.Label.PrettyPrintGraph()
+			if texts[text] == nil {
+				texts[text] = make(map[pifra.Label]struct{})
+			}
+			texts[text][trans.Label] = exists
+		}
+	}
+	conflicts := make(map[string][]pifra.Label)
+	for text, labels := range texts {
+		if len(labels) < 2 {
+			continue
+		}
+		for label := range labels {
+			conflicts[text] = append(conflicts[text], label)
+		}
+		sort.Slice(conflicts[text], func(i, j int) bool {
+			return bisim.LabelLess(conflicts[text][i], conflicts[text][j])
+		})
+	}
+	return conflicts
+}
+
+func conflictError(conflicts map[string][]pifra.Label) error {
+	texts := make([]string, 0, len(conflicts))
+	for text := range conflicts {
+		texts = append(texts, text)
+	}
+	sort.Strings(texts)
+	var b strings.Builder
+	b.WriteString("distinct labels share a printed form:")
+	for _, text := range texts {
+		fmt.Fprintf(&b, "\n  %q:", text)
+		for _, label := range conflicts[text] {
+			fmt.Fprintf(&b, " %+v", label)
+		}
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+// mergeConflicts rewrites every conflicting label to the first label sharing
+// its printed form.
+func mergeConflicts(conflicts map[string][]pifra.Label, ltss ...*pifra.Lts) {
+	for _, lts := range ltss {
+		for i, trans := range lts.Transitions {
+			if labels, ok := conflicts[trans.Label.PrettyPrintGraph()]; ok {
+				lts.Transitions[i].Label = labels[0]
+			}
+		}
+	}
+}
+
+// checkLabels applies -strict-labels and -merge-labels-by-text.
+func checkLabels(left, right *pifra.Lts) error {
+	if !*strictLabels && !*mergeLabels {
+		return nil
+	}
+	conflicts := labelConflicts(*left, *right)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if *mergeLabels {
+		mergeConflicts(conflicts, left, right)
+		return nil
+	}
+	return conflictError(conflicts)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// otherTau is a silent label that pifra prints as τ, like tauLabel, but that
+// compares unequal to it.
+var otherTau = pifra.Label{
+	Symbol:  pifra.Symbol{Type: pifra.SymbolTypTau},
+	Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 3},
+}
+
+// silentStep returns the LTS of a single move by label.
+func silentStep(label pifra.Label) pifra.Lts {
+	return pifra.Lts{
+		States:         map[int]pifra.Configuration{0: {}, 1: {}},
+		RegSizeReached: make(map[int]bool),
+		Transitions:    []pifra.Transition{{Source: 0, Destination: 1, Label: label}},
+	}
+}
+
+func TestLabelConflicts(t *testing.T) {
+	conflicts := labelConflicts(silentStep(otherTau), silentStep(tauLabel), silentStep(inputLabel(1, 1)))
+	want := map[string][]pifra.Label{"τ": {tauLabel, otherTau}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("labelConflicts() = %v, want %v", conflicts, want)
+	}
+}
+
+// TestLabelsSharingText compares two LTSs whose only labels print alike but
+// differ, which plain comparison tells apart.
+func TestLabelsSharingText(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestLTS(t, dir, "left.gob", silentStep(tauLabel))
+	right := writeTestLTS(t, dir, "right.gob", silentStep(otherTau))
+	if _, _, code := runPisim(t, dir, "-quiet", left, right); code != 1 {
+		t.Errorf("plain: status %d, want 1", code)
+	}
+	_, stderr, code := runPisim(t, dir, "-quiet", "-strict-labels", left, right)
+	if code != 1 || !strings.Contains(stderr, "distinct labels share a printed form:\n  \"τ\":") {
+		t.Errorf("-strict-labels: status %d and %q, want 1 and the conflict on τ", code, stderr)
+	}
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-merge-labels-by-text", left, right); code != 0 {
+		t.Errorf("-merge-labels-by-text: status %d, want 0: %s", code, stderr)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	levelwise = flag.Bool("levelwise", false,
+		"refine level by level, splitting every block against the partition of the previous level, "+
+			"and report the number of blocks at each level in -stats and -result-json")
+	bounded = flag.Int("bounded", 0,
+		"decide `k`-step bisimilarity: refine at most k levels, as -levelwise does; "+
+			"the verdict is unknown if the partition is not yet stable")
+)
+
+// levelBlocks holds the number of blocks of a level-wise refinement at each
+// depth: the initial partition first, then the partition after every level
+// that split a block.
+var levelBlocks []int
+
+// levelRefinement tells whether partKS refines level by level.
+func levelRefinement() bool {
+	return *levelwise || *bounded > 0
+}
+
+func validateLevelwise() error {
+	if *bounded < 0 {
+		return errors.New("-bounded must be positive")
+	}
+	if !levelRefinement() {
+		return nil
+	}
+	if _, ok := refinements[*equivalence]; !ok || *equivalence == "eta" || *sim || *compareStats {
+		return errors.New("-levelwise and -bounded need -equivalence strong, weak or delay, " +
+			"without -sim or -compare-stats")
+	}
+	if *prereduce {
+		return errors.New("-levelwise and -bounded cannot be combined with -prereduce, " +
+			"which refines more than once")
+	}
+	return nil
+}
+
+// printLevels prints the number of blocks at each depth of a level-wise
+// refinement.
+func printLevels(w io.Writer) {
+	if levelBlocks == nil {
+		return
+	}
+	counts := make([]string, len(levelBlocks))
+	for depth, n := range levelBlocks {
+		counts[depth] = fmt.Sprintf("%d:%d", depth, n)
+	}
+	fmt.Fprintf(w, "blocks by depth: %s\n", strings.Join(counts, " "))
+}
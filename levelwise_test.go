@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// movesOf maps every state of ltss, uniquified, to the states it reaches by
+// each label.
+func movesOf(ltss ...pifra.Lts) map[int]map[pifra.Label][]int {
+	moves := make(map[int]map[pifra.Label][]int)
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if moves[trans.Source] == nil {
+				moves[trans.Source] = make(map[pifra.Label][]int)
+			}
+			moves[trans.Source][trans.Label] = append(moves[trans.Source][trans.Label], trans.Destination)
+		}
+	}
+	return moves
+}
+
+// stepBlocks returns the blocks of k-step strong bisimilarity over the states
+// of part and the transitions of left and right, computed naively from a
+// single block: each step
+// splits the states by their blocks and the set of labels and blocks of their
+// moves.
+func stepBlocks(part Partition, left, right pifra.Lts, k int) [][]int {
+	next := movesOf(left, right)
+	class := make(map[int]int, len(part.states))
+	for state := range part.states {
+		class[state] = 0
+	}
+	for step := 0; step < k; step++ {
+		sigs := make(map[int]string, len(class))
+		for state := range class {
+			seen := make(map[string]bool)
+			var moves []string
+			for _, label := range part.actions {
+				for _, dst := range next[state][label] {
+					move := fmt.Sprintf("%s>%d", label.PrettyPrintGraph(), class[dst])
+					if !seen[move] {
+						seen[move] = true
+						moves = append(moves, move)
+					}
+				}
+			}
+			sort.Strings(moves)
+			sigs[state] = strconv.Itoa(class[state]) + "|" + strings.Join(moves, ",")
+		}
+		ids := make(map[string]int)
+		for state, sig := range sigs {
+			if _, ok := ids[sig]; !ok {
+				ids[sig] = len(ids)
+			}
+			class[state] = ids[sig]
+		}
+	}
+	members := make(map[int][]int)
+	for state, c := range class {
+		members[c] = append(members[c], state)
+	}
+	var blocks [][]int
+	for _, states := range members {
+		sort.Ints(states)
+		blocks = append(blocks, states)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i][0] < blocks[j][0] })
+	return blocks
+}
+
+// TestBounded refines random pairs under -bounded k for k from 1 to 4, and
+// checks the blocks against k-step bisimilarity computed naively, and the
+// blocks by depth of -levelwise against their number at each step.
+func TestBounded(t *testing.T) {
+	setFlag(t, "no-fastpath", "true")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		for k := 1; k <= 4; k++ {
+			setFlag(t, "bounded", strconv.Itoa(k))
+			part := partKS(left, right)
+			var got [][]int
+			for _, block := range part.blocks {
+				got = append(got, block.States())
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+			if want := stepBlocks(part, left, right, k); !reflect.DeepEqual(got, want) {
+				t.Fatalf("pair %d, -bounded %d: blocks %v, want %v", i, k, got, want)
+			}
+		}
+
+		setFlag(t, "bounded", "0")
+		setFlag(t, "levelwise", "true")
+		part := partKS(left, right)
+		for depth, n := range levelBlocks {
+			if want := len(stepBlocks(part, left, right, depth)); n != want {
+				t.Fatalf("pair %d: blocks by depth %v, want %d at depth %d", i, levelBlocks, want, depth)
+			}
+		}
+		if last := levelBlocks[len(levelBlocks)-1]; last != len(part.blocks) {
+			t.Fatalf("pair %d: blocks by depth %v end short of the %d blocks", i, levelBlocks, len(part.blocks))
+		}
+		setFlag(t, "levelwise", "false")
+	}
+}
+
+// TestLevelsOutput compares chains of 3 and 4 moves by a, which 4-step
+// bisimilarity tells apart but 3-step bisimilarity does not.
+func TestLevelsOutput(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 3, 4)\n(0, \"1 1\", 1)\n(1, \"1 1\", 2)\n(2, \"1 1\", 3)\n")
+	right := writeTestFile(t, dir, "right.aut",
+		"des (0, 4, 5)\n(0, \"1 1\", 1)\n(1, \"1 1\", 2)\n(2, \"1 1\", 3)\n(3, \"1 1\", 4)\n")
+	for _, test := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"-levelwise", "-stats"}, 1, "blocks by depth: 0:1 1:2 2:3 3:4 4:5\n"},
+		{[]string{"-levelwise", "-result-json"}, 1, "\"levels\": [\n    1,\n    2,\n    3,\n    4,\n    5\n  ]"},
+		{[]string{"-bounded", "3"}, 4, "Unknown (bisimilar up to the splits performed)\n"},
+		{[]string{"-bounded", "4"}, 1, "Not bisimilar\n"},
+		{[]string{"-levelwise", "-equivalence", "eta"}, 1, "-levelwise and -bounded need -equivalence strong"},
+	} {
+		args := append(append([]string{"-quiet"}, test.args...), left, right)
+		stdout, stderr, code := runPisim(t, dir, args...)
+		if code != test.code || !strings.Contains(stdout+stderr, test.want) {
+			t.Errorf("%v: status %d and\n%s%s\nwant %d and %q", args, code, stdout, stderr, test.code, test.want)
+		}
+	}
+}
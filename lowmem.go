@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/yungene/pisim/bisim"
+)
+
+// lowMem trades speed for a lower peak of memory: the inputs are loaded one
+// at a time, their duplicate transitions are found by sorting rather than
+// hashing, and the refiner keeps the moves of the states and the members of
+// the blocks in temporary files, as bisim.Options.Spill does.
+var (
+	lowMem = flag.Bool("low-mem", false,
+		"lower peak memory at the cost of speed, keeping the transitions and blocks of the refinement in temporary files")
+	lowMemChunk = flag.Int("low-mem-chunk", 1<<16,
+		"number of values read back at once with -low-mem")
+)
+
+func validateLowMem() error {
+	if *lowMem && *lowMemChunk < 1 {
+		return errors.New("-low-mem-chunk must be positive")
+	}
+	return nil
+}
+
+// spilled holds the refiners whose temporary files -low-mem keeps, until
+// their partition is closed or removeSpilled runs on the way out.
+var spilled []*bisim.Refiner
+
+// removeSpilled removes the temporary files of every refiner under -low-mem.
+// It reports its errors without exiting, as it runs on the way out.
+func removeSpilled() {
+	for _, r := range spilled {
+		if err := r.Close(); err != nil {
+			log.Printf("-low-mem: %v", err)
+		}
+	}
+	spilled = nil
+}
+
+// close removes the temporary files of the refiner of p under -low-mem, for
+// partitions dropped before the comparison ends. p cannot be queried for
+// moves afterwards.
+func (p Partition) close() {
+	for i, r := range spilled {
+		if r == p.refiner {
+			spilled = append(spilled[:i], spilled[i+1:]...)
+			if err := r.Close(); err != nil {
+				log.Printf("-low-mem: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// err returns the first error reading the temporary files of the refiner of
+// p under -low-mem, so that an answer read from them is not trusted.
+func (p Partition) err() error {
+	if p.refiner == nil {
+		return nil
+	}
+	return p.refiner.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// classes returns the blocks of part as sorted lists of states, sorted by
+// their first state, so that partitions compare whatever their block IDs.
+func classes(part Partition) [][]int {
+	var out [][]int
+	for _, b := range part.Blocks() {
+		out = append(out, b.States())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+// TestLowMem checks that partKS finds the same partitions with the refinement
+// spilled to disk in chunks of a few values as in memory, and removes the
+// files once the partitions are closed.
+// quotientMoves returns the transitions of the quotient of part, printed.
+func quotientMoves(part Partition) []string {
+	var moves []string
+	part.QuotientTransitions(func(src, dst int, label pifra.Label) {
+		moves = append(moves, fmt.Sprintf("%d -%s-> %d", src, label.PrettyPrintGraph(), dst))
+	})
+	return moves
+}
+
+func TestLowMem(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		left, right := prepared(t, reference.Random(r, 1+r.Intn(20), r.Intn(60), 3, 0),
+			reference.Random(r, 1+r.Intn(20), r.Intn(60), 3, 0))
+		part := partKS(left, right)
+		want, wantMoves := classes(part), quotientMoves(part)
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			setFlag(t, "low-mem", "true")
+			setFlag(t, "low-mem-chunk", "3")
+			part := partKS(left, right)
+			if got := classes(part); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if got := quotientMoves(part); !reflect.DeepEqual(got, wantMoves) {
+				t.Errorf("got quotient moves %v, want %v", got, wantMoves)
+			}
+			part.close()
+			if len(spilled) != 0 {
+				t.Errorf("%d refiners still spilled", len(spilled))
+			}
+		})
+	}
+}
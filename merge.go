@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yungene/pifra"
+)
+
+// unionLTS returns the union of left and right, uniquified so that their
+// states are disjoint. It has the initial states of both.
+func unionLTS(left, right pifra.Lts) pifra.Lts {
+	union := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(left.States)+len(right.States)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for _, lts := range []pifra.Lts{left, right} {
+		for state, conf := range lts.States {
+			union.States[state] = conf
+			if lts.RegSizeReached[state] {
+				union.RegSizeReached[state] = true
+			}
+		}
+		union.Transitions = append(union.Transitions, lts.Transitions...)
+	}
+	union.StatesExplored = len(union.States)
+	union.StatesGenerated = len(union.States)
+	return union
+}
+
+func mergeCommand(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("out", "", "write the merged LTS to `file`")
+	fs.StringVar(equivalence, "equivalence", "strong",
+		"minimize modulo `equivalence`: strong, weak, delay or eta")
+	fs.StringVar(outputEncoding, "output-encoding", "gob",
+		"write the merged LTS as `encoding`: gob, or json, which pisim also reads")
+	fs.BoolVar(force, "force", false, "overwrite an existing output file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim merge a.gob b.gob -out union.gob\n\n"+
+			"Minimizes the union of two LTSs, keeping the states of both apart. The\n"+
+			"initial state of a is the initial state of the result. The initial state\n"+
+			"of b is merged into it if they are equivalent, and reported otherwise.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 2 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, ok := refinements[*equivalence]; !ok {
+		check(fmt.Errorf("merge needs an equivalence decided by partition refinement, not %q", *equivalence))
+	}
+	check(validateOutputEncoding())
+	inputFiles = args
+	left, right, err := loadSides(args[0], args[1])
+	check(err)
+	part, _, _ := refineSides(left, right)
+	bisim := part.classes()
+	union := unionLTS(left, right)
+	initial := part.initial[LeftSide]
+	ctx, stop := outputContext()
+	defer stop()
+	check(writeEncodedLTS(ctx, *out, projectQuotient(bisim, union, initial)))
+	if second := part.initial[RightSide]; bisim[second] != bisim[initial] {
+		ids, _ := quotientIDs(bisim, union, initial)
+		log.Printf("the initial states are not %s bisimilar: the initial state of %s is state %d of %s",
+			*equivalence, args[1], ids[bisim[second]], *out)
+	}
+}
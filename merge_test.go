@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMerge merges testdata/sides/left.gob, which is minimal, with itself and
+// with its renaming, which must give it back up to the numbering of its
+// states, and a.0 with b.0, which share only their deadlocked state.
+func TestMerge(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := filepath.Join(sides, "left.gob")
+	dir := t.TempDir()
+	for _, other := range []string{left, filepath.Join(sides, "permuted.gob")} {
+		out := filepath.Join(dir, "union.gob")
+		if _, stderr, code := runPisim(t, dir, "merge", "-force", left, other, "-out", out); code != 0 {
+			t.Fatalf("%s: status %d: %s", other, code, stderr)
+		}
+		orig, err := decodeLTS(left)
+		if err != nil {
+			t.Fatal(err)
+		}
+		union, err := decodeLTS(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transitions := len(dedupTransitions(orig.Transitions))
+		if len(union.States) != len(orig.States) || len(union.Transitions) != transitions {
+			t.Errorf("%s: merged LTS of %d states and %d transitions, want %d and %d", other,
+				len(union.States), len(union.Transitions), len(orig.States), transitions)
+		}
+		if stdout, stderr, code := runPisim(t, dir, "-quiet", left, out); code != 0 {
+			t.Errorf("%s: merged LTS not bisimilar to the original: %s%s", other, stdout, stderr)
+		}
+	}
+
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	b := writeTestFile(t, dir, "b.aut", "des (0, 1, 2)\n(0, \"2 1\", 1)\n")
+	out := filepath.Join(dir, "ab.gob")
+	_, stderr, code := runPisim(t, dir, "merge", a, b, "-out", out)
+	if want := "the initial states are not strong bisimilar: the initial state of " + b + " is state 1 of " + out; code != 0 ||
+		!strings.Contains(stderr, want) {
+		t.Errorf("status %d and %q, want %q", code, stderr, want)
+	}
+	union, err := decodeLTS(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(union.States) != 3 || len(union.Transitions) != 2 {
+		t.Errorf("merged LTS of %d states and %d transitions, want 3 and 2", len(union.States), len(union.Transitions))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// Duplicate transitions never change a verdict, except under -graded, which
+// counts moves and so keeps them.
+var normalize = flag.Bool("normalize", true,
+	"drop duplicate transitions of the inputs (not with -graded, which counts them)")
+
+// dropDuplicates removes the repeated transitions of lts, keeping the first
+// of each, and returns how many were removed.
+func dropDuplicates(lts *pifra.Lts) int {
+	transitions := lts.Transitions
+	var repeated []bool
+	if *lowMem {
+		repeated = sortedRepeats(transitions)
+	} else {
+		repeated = hashedRepeats(transitions)
+	}
+	n := 0
+	for _, r := range repeated {
+		if r {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	unique := make([]pifra.Transition, 0, len(transitions)-n)
+	for i, trans := range transitions {
+		if !repeated[i] {
+			unique = append(unique, trans)
+		}
+	}
+	lts.Transitions = unique
+	return n
+}
+
+// hashedRepeats tells which transitions repeat an earlier one.
+func hashedRepeats(transitions []pifra.Transition) []bool {
+	seen := make(map[pifra.Transition]bool, len(transitions))
+	repeated := make([]bool, len(transitions))
+	for i, trans := range transitions {
+		repeated[i] = seen[trans]
+		seen[trans] = true
+	}
+	return repeated
+}
+
+// sortedRepeats tells which transitions repeat an earlier one, as
+// hashedRepeats does, but sorts the positions of the transitions rather than
+// hashing them: slower, but a word per transition instead of a map entry,
+// for -low-mem.
+func sortedRepeats(transitions []pifra.Transition) []bool {
+	order := make([]int, len(transitions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := transitions[order[i]], transitions[order[j]]
+		switch {
+		case a.Source != b.Source:
+			return a.Source < b.Source
+		case a.Destination != b.Destination:
+			return a.Destination < b.Destination
+		case a.Label != b.Label:
+			return bisim.LabelLess(a.Label, b.Label)
+		}
+		return order[i] < order[j]
+	})
+	repeated := make([]bool, len(transitions))
+	for i := 1; i < len(order); i++ {
+		repeated[order[i]] = transitions[order[i]] == transitions[order[i-1]]
+	}
+	return repeated
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// withDuplicates returns lts with a copy of some of its transitions appended,
+// and the number of copies.
+func withDuplicates(r *rand.Rand, lts pifra.Lts) (pifra.Lts, int) {
+	dup := cloneLTS(lts)
+	n := 0
+	for _, trans := range lts.Transitions {
+		if r.Intn(2) == 0 {
+			dup.Transitions = append(dup.Transitions, trans)
+			n++
+		}
+	}
+	return dup, n
+}
+
+// TestDropDuplicates checks on random pairs with duplicated transitions that
+// dropping them leaves each transition once, finding the same repeats with
+// -low-mem as without, and that the verdict is the same whether they are
+// dropped or not.
+func TestDropDuplicates(t *testing.T) {
+	type pair struct {
+		left, right pifra.Lts
+		bisimilar   bool
+	}
+	var pairs []pair
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(r)
+		dupLeft, n := withDuplicates(r, left)
+		dupRight, _ := withDuplicates(r, right)
+		pairs = append(pairs, pair{dupLeft, dupRight, refinedBisimilar(t, left, right, "strong")})
+
+		dropped := cloneLTS(dupLeft)
+		unique := len(dedupTransitions(left.Transitions))
+		if got := dropDuplicates(&dropped); got != len(dupLeft.Transitions)-unique ||
+			len(dropped.Transitions) != unique {
+			t.Fatalf("pair %d: dropped %d of %d transitions (%d added), leaving %d, want %d left",
+				i, got, len(dupLeft.Transitions), n, len(dropped.Transitions), unique)
+		}
+		sorted, hashed := sortedRepeats(dupLeft.Transitions), hashedRepeats(dupLeft.Transitions)
+		if !reflect.DeepEqual(sorted, hashed) {
+			t.Fatalf("pair %d: repeats %v by sorting, %v by hashing", i, sorted, hashed)
+		}
+		if got := refinedBisimilar(t, dupLeft, dupRight, "strong"); got != pairs[i].bisimilar {
+			t.Fatalf("pair %d: bisimilar %v with duplicates dropped, want %v", i, got, pairs[i].bisimilar)
+		}
+	}
+	setFlag(t, "normalize", "false")
+	for i, p := range pairs {
+		if got := refinedBisimilar(t, p.left, p.right, "strong"); got != p.bisimilar {
+			t.Fatalf("pair %d: bisimilar %v with duplicates kept, want %v", i, got, p.bisimilar)
+		}
+	}
+}
+
+// dedupTransitions returns transitions without repeats.
+func dedupTransitions(transitions []pifra.Transition) []pifra.Transition {
+	seen := make(map[pifra.Transition]bool)
+	var unique []pifra.Transition
+	for _, trans := range transitions {
+		if !seen[trans] {
+			seen[trans] = true
+			unique = append(unique, trans)
+		}
+	}
+	return unique
+}
+
+func TestNormalizeFlag(t *testing.T) {
+	dir := t.TempDir()
+	dup := writeTestFile(t, dir, "dup.aut", "des (0, 4, 2)\n(0, \"1 1\", 1)\n(0, \"1 1\", 1)\n(1, i, 0)\n(0, \"1 1\", 1)\n")
+	a := writeTestFile(t, dir, "a.aut", "des (0, 2, 2)\n(0, \"1 1\", 1)\n(1, i, 0)\n")
+	for _, test := range []struct {
+		normalize string
+		edges     int
+	}{
+		{"true", 2},
+		{"false", 4},
+	} {
+		out := filepath.Join(dir, test.normalize)
+		_, stderr, code := runPisim(t, dir, "-normalize="+test.normalize, dup, a, out)
+		if code != 0 {
+			t.Fatalf("-normalize=%s: status %d, want 0: %s", test.normalize, code, stderr)
+		}
+		if reported := strings.Contains(stderr, "left LTS: dropped 2 duplicate transitions"); reported !=
+			(test.normalize == "true") {
+			t.Errorf("-normalize=%s: reported %v: %s", test.normalize, reported, stderr)
+		}
+		dot, err := os.ReadFile(out + "-left.dot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edges := strings.Count(string(dot), "->"); edges != test.edges {
+			t.Errorf("-normalize=%s: %d edges, want %d:\n%s", test.normalize, edges, test.edges, dot)
+		}
+	}
+}
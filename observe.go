@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var (
+	observeSpec = flag.String("observe", "",
+		"compare modulo the label classes defined in the JSON `file`")
+	hide = flag.String("hide", "",
+		"treat labels matching the comma-separated glob `patterns` as silent")
+	observeOnly = flag.String("observe-only", "",
+		"treat labels matching none of the comma-separated glob `patterns` as silent")
+)
+
+// observedClass is the symbol type of the actions standing for observation
+// classes. It lies outside the symbol types used by pifra.
+const observedClass pifra.SymbolType = -1
+
+// ObservationClass is a named set of labels, given by glob patterns over their
+// printed form, that count as the same observation. A silent class is observed
+// as tau. A complement class holds the visible labels matching none of its
+// patterns instead.
+type ObservationClass struct {
+	Name       string   `json:"name"`
+	Patterns   []string `json:"patterns"`
+	Silent     bool     `json:"silent"`
+	Complement bool     `json:"complement,omitempty"`
+}
+
+// matches reports whether label belongs to c.
+func (c ObservationClass) matches(label pifra.Label) bool {
+	if c.Complement && IsTau(label) {
+		return false
+	}
+	for _, pattern := range c.Patterns {
+		if labelMatches(pattern, label) {
+			return !c.Complement
+		}
+	}
+	return c.Complement
+}
+
+// Observation maps labels to the actions refinement compares them by: each
+// label is observed as the first class it matches, or as itself if none.
+// Hiding and label observation files both compile into an Observation.
+type Observation struct {
+	Classes []ObservationClass `json:"classes"`
+}
+
+func (o Observation) validate() error {
+	for _, class := range o.Classes {
+		for _, pattern := range class.Patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("class %q: bad pattern %q", class.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func (o Observation) action(label pifra.Label) pifra.Label {
+	for i, class := range o.Classes {
+		if class.matches(label) {
+			if class.Silent {
+				return tauLabel
+			}
+			return pifra.Label{Symbol: pifra.Symbol{Type: observedClass, Value: i}}
+		}
+	}
+	return label
+}
+
+// observe returns lts with every label replaced by its observed action.
+func (o Observation) observe(lts pifra.Lts) pifra.Lts {
+	if len(o.Classes) == 0 {
+		return lts
+	}
+	observed := lts
+	observed.Transitions = make([]pifra.Transition, len(lts.Transitions))
+	for i, trans := range lts.Transitions {
+		trans.Label = o.action(trans.Label)
+		observed.Transitions[i] = trans
+	}
+	return observed
+}
+
+// observation is the Observation given by -observe, -hide and -observe-only.
+var observation Observation
+
+func splitPatterns(patterns string) []string {
+	var list []string
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func loadObservation() error {
+	if *observeSpec != "" {
+		data, err := ioutil.ReadFile(*observeSpec)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &observation); err != nil {
+			return fmt.Errorf("%s: %v", *observeSpec, err)
+		}
+	}
+	if *hide != "" && *observeOnly != "" {
+		return errors.New("-hide and -observe-only cannot be combined")
+	}
+	if *hide != "" {
+		observation.Classes = append([]ObservationClass{{
+			Name:     "hidden",
+			Patterns: splitPatterns(*hide),
+			Silent:   true,
+		}}, observation.Classes...)
+	}
+	if *observeOnly != "" {
+		observation.Classes = append([]ObservationClass{{
+			Name:       "hidden",
+			Patterns:   splitPatterns(*observeOnly),
+			Silent:     true,
+			Complement: true,
+		}}, observation.Classes...)
+	}
+	return observation.validate()
+}
+
+// actionText prints an action of the refinement, which is either a label or
+// an observation class, possibly reversed by -undirected.
+func actionText(action pifra.Label) string {
+	if isReversed(action) {
+		return actionText(reverseLabel(action)) + " (reversed)"
+	}
+	if action.Symbol.Type == observedClass {
+		return observation.Classes[action.Symbol.Value].Name
+	}
+	return action.PrettyPrintGraph()
+}
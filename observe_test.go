@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestObservePayload compares an input of 1 on channel 1 with one of 2,
+// which differ unless an observation class ignores the payload. The coloured
+// LTSs still show the original labels.
+func TestObservePayload(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 2\", 1)\n")
+	spec := writeTestFile(t, dir, "observe.json",
+		`{"classes": [{"name": "on 1", "patterns": ["1 *"]}]}`)
+	if stdout, _, code := runPisim(t, dir, "-quiet", left, right); code != 1 {
+		t.Fatalf("without -observe: status %d and %q, want 1", code, stdout)
+	}
+	stdout, _, code := runPisim(t, dir, "-observe", spec, left, right, filepath.Join(dir, "out"))
+	if code != 0 {
+		t.Fatalf("-observe: status %d and %q, want 0", code, stdout)
+	}
+	for side, label := range map[string]string{"left": "1 1", "right": "1 2"} {
+		data, err := os.ReadFile(filepath.Join(dir, "out-"+side+".dot"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `label="`+label+`"`) {
+			t.Errorf("coloured %s LTS lacks its label %q:\n%s", side, label, data)
+		}
+	}
+	if stdout, _, code := runPisim(t, dir, "-quiet", "-hide", "1 *", left, right); code != 0 {
+		t.Errorf("-hide: status %d and %q, want 0", code, stdout)
+	}
+}
+
+func TestObserveErrors(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	bad := writeTestFile(t, dir, "bad.json", `{"classes": [{"name": "bad", "patterns": ["["]}]}`)
+	for _, args := range [][]string{
+		{"-observe", bad},
+		{"-hide", "1 *", "-observe-only", "2 *"},
+	} {
+		args = append(append([]string{"-quiet"}, args...), left, left)
+		if _, stderr, code := runPisim(t, dir, args...); code == 0 || stderr == "" {
+			t.Errorf("%v: status %d and %q, want an error", args, code, stderr)
+		}
+	}
+}
+
+// TestObserveOnly compares a.b with b.a, which are weakly bisimilar when only
+// a is observed, as τ.a and a.τ, but not when b is observed too, nor under
+// strong bisimilarity, where the silent moves count.
+func TestObserveOnly(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"2 1\", 1)\n(1, \"1 1\", 2)\n")
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-weak"}, 1},
+		{[]string{"-weak", "-observe-only", "1 *"}, 0},
+		{[]string{"-weak", "-observe-only", "1 *, 2 *"}, 1},
+		{[]string{"-observe-only", "1 *"}, 1},
+	} {
+		args := append(append([]string{"-quiet"}, test.args...), left, right)
+		if stdout, stderr, code := runPisim(t, dir, args...); code != test.code {
+			t.Errorf("%v: status %d, want %d: %s%s", args, code, test.code, stdout, stderr)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var (
+	listOrphans = flag.Bool("orphans", false,
+		"list blocks whose states have no bisimilar partner on the other side")
+	orphansMax = flag.Int("orphans-max", 20,
+		"maximum number of orphan blocks to list")
+	orphansJSON = flag.String("orphans-json", "",
+		"also write the orphan blocks as JSON to `file`")
+)
+
+// Orphan is a block containing states of only one side, described by its
+// representative: the member with the shortest access trace.
+type Orphan struct {
+	Side          string   `json:"side"`
+	State         int      `json:"state"`
+	Name          string   `json:"name,omitempty"`
+	Size          int      `json:"size"`
+	Configuration string   `json:"configuration"`
+	Reachable     bool     `json:"reachable"`
+	Trace         []string `json:"trace"`
+}
+
+// accessTree holds the shortest paths from the initial state of an LTS, as
+// found by a breadth-first search.
+type accessTree struct {
+	dist   map[int]int
+	parent map[int]pifra.Transition
+}
+
+func newAccessTree(lts pifra.Lts, root int) accessTree {
+	succs := make(map[int][]pifra.Transition)
+	for _, trans := range lts.Transitions {
+		succs[trans.Source] = append(succs[trans.Source], trans)
+	}
+	tree := accessTree{
+		dist:   map[int]int{root: 0},
+		parent: make(map[int]pifra.Transition),
+	}
+	queue := []int{root}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, trans := range succs[state] {
+			if _, ok := tree.dist[trans.Destination]; ok {
+				continue
+			}
+			tree.dist[trans.Destination] = tree.dist[state] + 1
+			tree.parent[trans.Destination] = trans
+			queue = append(queue, trans.Destination)
+		}
+	}
+	return tree
+}
+
+func (t accessTree) trace(state int) []pifra.Label {
+	labels := make([]pifra.Label, t.dist[state])
+	for i := len(labels) - 1; i >= 0; i-- {
+		trans := t.parent[state]
+		labels[i] = trans.Label
+		state = trans.Source
+	}
+	return labels
+}
+
+func prettyConfiguration(conf pifra.Configuration) string {
+	if conf.Process == nil {
+		return ""
+	}
+	return pifra.PrettyPrintConfiguration(conf)
+}
+
+func prettyTrace(labels []pifra.Label) []string {
+	trace := make([]string, len(labels))
+	for i, label := range labels {
+		trace[i] = actionText(label)
+	}
+	return trace
+}
+
+func findOrphans(part Partition, left, right pifra.Lts) []Orphan {
+	trees := [2]accessTree{
+		newAccessTree(left, part.initial[LeftSide]),
+		newAccessTree(right, part.initial[RightSide]),
+	}
+	var orphans []Orphan
+	for _, block := range part.blocks {
+		if part.mixed(block) {
+			continue
+		}
+		rep, best := -1, -1
+		for state := range block.states {
+			d, ok := trees[part.sides[state].Side].dist[state]
+			if !ok {
+				d = -1
+			}
+			if rep == -1 || (d != -1 && (best == -1 || d < best)) ||
+				(d == best && state < rep) {
+				rep, best = state, d
+			}
+		}
+		origin := part.sides[rep]
+		lts := left
+		if origin.Side == RightSide {
+			lts = right
+		}
+		orphan := Orphan{
+			Side:          origin.Side.String(),
+			State:         origin.ID,
+			Name:          stateNames[origin.Side][origin.ID],
+			Size:          len(block.states),
+			Configuration: prettyConfiguration(lts.States[rep]),
+			Reachable:     best != -1,
+		}
+		if orphan.Reachable {
+			orphan.Trace = prettyTrace(trees[part.sides[rep].Side].trace(rep))
+		}
+		orphans = append(orphans, orphan)
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if a.Reachable != b.Reachable {
+			return a.Reachable
+		}
+		if len(a.Trace) != len(b.Trace) {
+			return len(a.Trace) < len(b.Trace)
+		}
+		if ta, tb := strings.Join(a.Trace, "\x00"), strings.Join(b.Trace, "\x00"); ta != tb {
+			return ta < tb
+		}
+		if a.Side != b.Side {
+			return a.Side < b.Side
+		}
+		return a.State < b.State
+	})
+	if *orphansMax >= 0 && len(orphans) > *orphansMax {
+		orphans = orphans[:*orphansMax]
+	}
+	return orphans
+}
+
+func printOrphans(w io.Writer, orphans []Orphan) {
+	fmt.Fprintf(w, "%d orphan block(s)\n", len(orphans))
+	for _, o := range orphans {
+		trace := "unreachable"
+		if o.Reachable {
+			trace = "<" + strings.Join(o.Trace, ", ") + ">"
+		}
+		name := o.Name
+		if name == "" {
+			name = strconv.Itoa(o.State)
+		}
+		fmt.Fprintf(w, "  %s state %s (%d state(s)) via %s\n",
+			o.Side, name, o.Size, trace)
+		if o.Configuration != "" {
+			fmt.Fprintf(w, "    %s\n", o.Configuration)
+		}
+	}
+}
+
+func reportOrphans(part Partition, left, right pifra.Lts) error {
+	orphans := findOrphans(part, left, right)
+	if *listOrphans {
+		printOrphans(os.Stdout, orphans)
+	}
+	if *orphansJSON == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(orphans, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(*orphansJSON, append(data, '\n'))
+}
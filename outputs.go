@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+var (
+	outLeft = flag.String("out-left", "",
+		"write the coloured left LTS to `file` rather than prefix-left.dot")
+	outRight = flag.String("out-right", "",
+		"write the coloured right LTS to `file` rather than prefix-right.dot")
+	outSides = flag.String("sides", "both",
+		"write the coloured LTS of `side` left or right only, or of both")
+	quiet = flag.Bool("quiet", false,
+		"only print the verdict, without writing the coloured LTSs")
+)
+
+// errNoOutput is the error of a comparison that would write coloured LTSs
+// but was given nowhere to write them.
+var errNoOutput = errors.New("no output for the coloured LTSs: " +
+	"give an output prefix, -out-left or -out-right, or -quiet")
+
+func validateSides() error {
+	switch *outSides {
+	case "left", "right", "both":
+	default:
+		return fmt.Errorf("unknown -sides %q: expected left, right or both", *outSides)
+	}
+	if *quiet && (*outLeft != "" || *outRight != "") {
+		return errors.New("-quiet writes no coloured LTSs, so it cannot be combined with -out-left or -out-right")
+	}
+	if *outSides == "left" && *outRight != "" || *outSides == "right" && *outLeft != "" {
+		return fmt.Errorf("-sides %s excludes the output of the other side", *outSides)
+	}
+	return nil
+}
+
+// wantSide reports whether the coloured LTS of side is to be written.
+func wantSide(side Side) bool {
+	return *outSides == "both" || *outSides == side.String()
+}
+
+// colouredFile returns the file the coloured LTS of side goes to: the file
+// given for it, or else the one named after prefix, or "" if there is neither.
+func colouredFile(prefix string, side Side) string {
+	if out := [2]*string{outLeft, outRight}[side]; *out != "" {
+		return *out
+	}
+	if prefix == "" {
+		return ""
+	}
+	ext := "dot"
+	if *outputFormat == "tikz" {
+		ext = "tex"
+	}
+	return fmt.Sprintf("%s-%s.%s", prefix, side, ext)
+}
+
+// checkEvery is the number of states or transitions bisimGraphViz renders
+// between two checks of its context.
+const checkEvery = 1024
+
+// outputContext returns the context a command writes its outputs under, done
+// once the user interrupts the command, so that a long render stops with an
+// error rather than leaving the user waiting. A command calls it once its
+// refinement is over, so that an interrupt before then still ends the
+// process at once. A second interrupt does so too.
+func outputContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestOutputs compares testdata/sides/left.gob with its renaming under each
+// combination of an output prefix, -out-left, -out-right and -sides, and
+// checks which files are written and that each holds the coloured LTS of its
+// side. The combinations that leave a side without a file, or contradict
+// each other, are refused before the inputs are read.
+func TestOutputs(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []string{filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")}
+
+	ref := t.TempDir()
+	if _, stderr, code := runPisim(t, ref, append(inputs, "p")...); code != 0 {
+		t.Fatalf("status %d: %s", code, stderr)
+	}
+	want := make(map[string]string)
+	for _, side := range []string{"left", "right"} {
+		data, err := ioutil.ReadFile(filepath.Join(ref, "p-"+side+".dot"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[side] = string(data)
+	}
+
+	for _, test := range []struct {
+		flags  []string
+		prefix bool
+		files  map[string]string // from file name to side
+		err    string
+	}{
+		{prefix: true, files: map[string]string{"p-left.dot": "left", "p-right.dot": "right"}},
+		{flags: []string{"-sides", "left"}, prefix: true, files: map[string]string{"p-left.dot": "left"}},
+		{flags: []string{"-sides", "right"}, prefix: true, files: map[string]string{"p-right.dot": "right"}},
+		{flags: []string{"-out-left", "l.dot"}, prefix: true,
+			files: map[string]string{"l.dot": "left", "p-right.dot": "right"}},
+		{flags: []string{"-out-left", "l.dot", "-out-right", "r.dot"},
+			files: map[string]string{"l.dot": "left", "r.dot": "right"}},
+		{flags: []string{"-out-left", "l.dot", "-out-right", "r.dot"}, prefix: true,
+			files: map[string]string{"l.dot": "left", "r.dot": "right"}},
+		{flags: []string{"-sides", "left", "-out-left", "l.dot"}, files: map[string]string{"l.dot": "left"}},
+		{flags: []string{"-sides", "right", "-out-right", "r.dot"}, files: map[string]string{"r.dot": "right"}},
+		{flags: []string{"-quiet"}},
+		{flags: []string{"-quiet"}, prefix: true},
+
+		{err: errNoOutput.Error()},
+		{flags: []string{"-out-left", "l.dot"}, err: errNoOutput.Error()},
+		{flags: []string{"-sides", "left"}, err: errNoOutput.Error()},
+		{flags: []string{"-sides", "right", "-out-left", "l.dot"},
+			err: "-sides right excludes the output of the other side"},
+		{flags: []string{"-quiet", "-out-left", "l.dot"}, err: "cannot be combined with -out-left or -out-right"},
+		{flags: []string{"-sides", "left", "-out-right", "r.dot"}, prefix: true,
+			err: "-sides left excludes the output of the other side"},
+		{flags: []string{"-sides", "middle"}, prefix: true, err: `unknown -sides "middle"`},
+	} {
+		dir := t.TempDir()
+		args := append(append([]string(nil), test.flags...), inputs...)
+		if test.prefix {
+			args = append(args, "p")
+		}
+		_, stderr, code := runPisim(t, dir, args...)
+		if test.err != "" {
+			if code == 0 || !strings.Contains(stderr, test.err) {
+				t.Errorf("%v: status %d and %q, want an error %q", args, code, stderr, test.err)
+			}
+		} else if code != 0 {
+			t.Errorf("%v: status %d: %s", args, code, stderr)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, wantFiles []string
+		for _, entry := range entries {
+			got = append(got, entry.Name())
+		}
+		for name, side := range test.files {
+			wantFiles = append(wantFiles, name)
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			if string(data) != want[side] {
+				t.Errorf("%v: %s holds\n%s\nwant the %s LTS\n%s", args, name, data, side, want[side])
+			}
+		}
+		sort.Strings(wantFiles)
+		if !reflect.DeepEqual(got, wantFiles) {
+			t.Errorf("%v: wrote %q, want %q", args, got, wantFiles)
+		}
+	}
+}
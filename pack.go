@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var (
+	leftEntry = flag.String("left-name", "",
+		"with pisim check, compare the archive entry `name` as the left LTS")
+	rightEntry = flag.String("right-name", "",
+		"with pisim check, compare the archive entry `name` as the right LTS")
+)
+
+// packVersion is the version of the pack format written by pisim pack.
+const packVersion = 1
+
+// PackManifest starts a pack: a gob stream of the manifest followed by one
+// PackEntry per name, in order.
+type PackManifest struct {
+	Version int
+	Names   []string
+}
+
+// PackEntry is an LTS of a pack. Data holds the file it was packed from, so
+// that skipping an entry costs no LTS decoding.
+type PackEntry struct {
+	Name string
+	Data []byte
+}
+
+func isPack(name string) bool {
+	return filepath.Ext(name) == ".pls"
+}
+
+// loadPack reads and preprocesses the entries leftName and rightName of a
+// pack, by default its first two entries. It stops reading once both are
+// found.
+func loadPack(name, leftName, rightName string) (left, right pifra.Lts, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var manifest PackManifest
+	if err = dec.Decode(&manifest); err != nil {
+		return left, right, fmt.Errorf("%s: not a pack: %v", name, err)
+	}
+	if manifest.Version != packVersion {
+		return left, right, fmt.Errorf("%s: unsupported pack version %d", name, manifest.Version)
+	}
+	if leftName == "" && len(manifest.Names) > 0 {
+		leftName = manifest.Names[0]
+	}
+	if rightName == "" && len(manifest.Names) > 1 {
+		rightName = manifest.Names[1]
+	}
+	wanted := [2]string{leftName, rightName}
+	for side, entry := range wanted {
+		if !containsString(manifest.Names, entry) {
+			return left, right, fmt.Errorf("%s: no entry %q for the %s LTS (entries: %s)",
+				name, entry, Side(side), strings.Join(manifest.Names, ", "))
+		}
+	}
+	var found [2]bool
+	for !found[LeftSide] || !found[RightSide] {
+		var entry PackEntry
+		if err = dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return left, right, fmt.Errorf("%s: %v", name, err)
+		}
+		for side, want := range wanted {
+			if entry.Name != want || found[side] {
+				continue
+			}
+			lts, err := bisim.LoadLTS(bytes.NewReader(entry.Data))
+			if err == nil {
+				err = prepareSide(&lts, Side(side) == RightSide)
+			}
+			if err != nil {
+				return left, right, fmt.Errorf("%s LTS %s:%s: %v", Side(side), name, entry.Name, err)
+			}
+			if Side(side) == LeftSide {
+				left = lts
+			} else {
+				right = lts
+			}
+			found[side] = true
+		}
+	}
+	return left, right, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+func packCommand(args []string) {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	out := fs.String("out", "", "write the pack to `file`, conventionally ending in .pls")
+	fs.BoolVar(force, "force", false, "overwrite an existing output file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim pack a.gob b.gob... -out pair.pls\n\n"+
+			"Packs LTSs into one file for pisim check, naming each entry by the base\n"+
+			"name of its file.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) == 0 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	inputFiles = args
+	manifest := PackManifest{Version: packVersion}
+	for _, arg := range args {
+		name := filepath.Base(arg)
+		if containsString(manifest.Names, name) {
+			check(fmt.Errorf("two inputs named %s", name))
+		}
+		manifest.Names = append(manifest.Names, name)
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	check(enc.Encode(manifest))
+	for i, arg := range args {
+		data, err := ioutil.ReadFile(arg)
+		check(err)
+		if _, err := bisim.LoadLTS(bytes.NewReader(data)); err != nil {
+			check(fmt.Errorf("%s: %v", arg, err))
+		}
+		check(enc.Encode(PackEntry{Name: manifest.Names[i], Data: data}))
+	}
+	check(writeFile(*out, buf.Bytes()))
+}
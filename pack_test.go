@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPack packs the fixtures of testdata/sides, reads the pack back, and
+// checks the verdicts and explanations of pisim check against those of the
+// separate files.
+func TestPack(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob"),
+		filepath.Join(sides, "permuted.gob")}
+	dir := t.TempDir()
+	pack := filepath.Join(dir, "pair.pls")
+	if _, stderr, code := runPisim(t, dir, append([]string{"pack", "-out", pack}, files...)...); code != 0 {
+		t.Fatalf("pack: status %d, want 0: %s", code, stderr)
+	}
+
+	f, err := os.Open(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+	var manifest PackManifest
+	if err := dec.Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	want := PackManifest{Version: packVersion, Names: []string{"left.gob", "right.gob", "permuted.gob"}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("manifest %+v, want %+v", manifest, want)
+	}
+	for i, file := range files {
+		var entry PackEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Name != want.Names[i] || !bytes.Equal(entry.Data, data) {
+			t.Errorf("entry %d is %s of %d bytes, want %s of %d", i, entry.Name, len(entry.Data),
+				want.Names[i], len(data))
+		}
+	}
+
+	for _, test := range []struct {
+		args        []string
+		left, right string
+	}{
+		{nil, files[0], files[1]},
+		{[]string{"-right-name", "permuted.gob"}, files[0], files[2]},
+		{[]string{"-left-name", "permuted.gob", "-right-name", "permuted.gob"}, files[2], files[2]},
+	} {
+		want, _, wantCode := runPisim(t, dir, "-quiet", "-explain", test.left, test.right)
+		args := append(append([]string{"check", "-quiet", "-explain"}, test.args...), pack)
+		got, stderr, code := runPisim(t, dir, args...)
+		if code != wantCode || got != want {
+			t.Errorf("%v: status %d and %q, want %d and %q: %s", test.args, code, got, wantCode, want, stderr)
+		}
+	}
+	_, stderr, code := runPisim(t, dir, "check", "-quiet", "-right-name", "missing.gob", pack)
+	if code != 1 || !strings.Contains(stderr,
+		`no entry "missing.gob" for the right LTS (entries: left.gob, right.gob, permuted.gob)`) {
+		t.Errorf("missing entry: status %d and %q, want an error naming it", code, stderr)
+	}
+	_, stderr, code = runPisim(t, dir, "pack", "-out", filepath.Join(dir, "twice.pls"), files[0], files[0])
+	if code != 1 || !strings.Contains(stderr, "two inputs named left.gob") {
+		t.Errorf("same name twice: status %d and %q, want it refused", code, stderr)
+	}
+}
+
+// TestPackSkipsEntries checks that pisim check decodes only the entries it
+// compares, and stops reading once it has them.
+func TestPackSkipsEntries(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := os.ReadFile(filepath.Join(sides, "left.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, v := range []interface{}{
+		PackManifest{Version: packVersion, Names: []string{"a.gob", "broken.gob", "b.gob"}},
+		PackEntry{Name: "a.gob", Data: left},
+		PackEntry{Name: "broken.gob", Data: []byte("not an LTS")},
+		PackEntry{Name: "b.gob", Data: left},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	full := buf.Bytes()
+	short := writeTestFile(t, dir, "short.pls", string(full[:len(full)-len(left)/2]))
+	pack := writeTestFile(t, dir, "pair.pls", string(full))
+	for _, test := range []struct {
+		pack, right string
+		code        int
+		err         string
+	}{
+		// Both entries are found before the broken one and the cut.
+		{short, "a.gob", 0, ""},
+		{pack, "b.gob", 0, ""},
+		{pack, "broken.gob", 1, "right LTS " + pack + ":broken.gob: "},
+		{short, "b.gob", 1, short + ": unexpected EOF"},
+	} {
+		_, stderr, code := runPisim(t, dir, "check", "-quiet", "-left-name", "a.gob", "-right-name", test.right, test.pack)
+		if code != test.code || !strings.Contains(stderr, test.err) {
+			t.Errorf("%s of %s: status %d and %q, want %d and %q", test.right, filepath.Base(test.pack), code, stderr,
+				test.code, test.err)
+		}
+	}
+}
@@ -1,33 +1,72 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/gob"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
-	"text/template"
+	"strings"
+	"time"
 
 	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/events"
+	"github.com/yungene/pisim/internal/ltsfile"
+	"golang.org/x/sync/errgroup"
 )
 
 var exists = struct{}{}
 
+var (
+	equivariant = flag.Bool("equivariant", false,
+		"compare modulo permutations of register contents")
+	equivalence = flag.String("equivalence", "strong",
+		"equivalence to check: strong, weak, delay, eta, nested-sim-2, possible-futures, failures, completed-trace or trace")
+	ignoreInitial = flag.Bool("ignore-initial", false,
+		"require every state to have a bisimilar partner, regardless of the initial states")
+	graded = flag.Bool("graded", false,
+		"count transitions: require matching numbers of moves into each class")
+	noFingerprints = flag.Bool("no-fingerprints", false,
+		"always compare destination sets in full when splitting, without the fingerprint fast path")
+	force = flag.Bool("force", false,
+		"overwrite existing output files, even if they are inputs")
+	maxInputStates = flag.Int("max-states", 0,
+		"refuse LTSs with more than `n` states (0 for unlimited)")
+	anytime = flag.Duration("anytime", 0,
+		"stop refining after `duration` and report the partition reached so far")
+	maxGraphNodes = flag.Int("max-graph-nodes", 5000,
+		"write the quotient graph instead of the coloured LTSs when they have more than `n` states (0 for unlimited)")
+)
+
+// inputFiles are the files read by the current command, protected from being
+// overwritten by its outputs.
+var inputFiles []string
+
 // States is a set of states.
 type States map[int]struct{}
 
-// Actions maps labels to their list of transitions.
-type Actions map[pifra.Label][]pifra.Transition
-
-var blockIDCounter int
+// maxStates bounds the number of states of an input LTS. Their IDs are
+// renumbered densely on load, so that uniquifyLTS cannot overflow.
+const maxStates = math.MaxInt32
 
 // Block is a set of states, identified by a unique integer.
 type Block struct {
 	id     int
 	states States
+	// version tells apart the blocks that reuse an ID: refinement replaces
+	// blocks rather than changing their states, so the same version means
+	// the same states.
+	version int
 }
 
 // Blocks is a set of Blocks keyed by their IDs.
@@ -39,14 +78,129 @@ type StateBlocks map[int]Block
 // Partition is primarily a set of Blocks, but also carries some auxiliary data
 // to simplify and optimise the implementation.
 type Partition struct {
-	blocks  Blocks
-	states  StateBlocks
-	actions Actions
+	blocks Blocks
+	states StateBlocks
+	// actions holds the labels of the transitions, one per group under
+	// -label-equiv, ordered by bisim.LabelLess. The refiner holds the moves.
+	actions []pifra.Label
+	// sides records which LTS every state comes from.
+	sides Sides
+	// initial holds the initial states of the sides, by Side.
+	initial [2]int
+	// stopped is set when the refinement ended before the partition was
+	// stable, so that it over-approximates bisimilarity.
+	stopped bool
+	// snapshots holds the latest snapshot, from which Snapshot derives the
+	// next one.
+	snapshots *snapshotBase
+	// refiner refined the partition, whose blocks it shares by ID. It is nil
+	// for partitions rebuilt from saved classes.
+	refiner *bisim.Refiner
+}
+
+// ID returns the identifier of b, unique among the blocks of a partition.
+func (b Block) ID() int {
+	return b.id
+}
+
+// States returns the states of b in increasing order.
+func (b Block) States() []int {
+	states := make([]int, 0, len(b.states))
+	for s := range b.states {
+		states = append(states, s)
+	}
+	sort.Ints(states)
+	return states
+}
+
+// Blocks returns the blocks of p in increasing order of ID.
+func (p Partition) Blocks() []Block {
+	blocks := make([]Block, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].id < blocks[j].id
+	})
+	return blocks
+}
+
+// initialsSplit reports whether the initial states of the sides are in
+// different blocks.
+func (p Partition) initialsSplit() bool {
+	return p.states[p.initial[LeftSide]].id != p.states[p.initial[RightSide]].id
+}
+
+// Side identifies the LTS a state comes from. It is the Side of package
+// bisim, which refines the partitions.
+type Side = bisim.Side
+
+const (
+	LeftSide  = bisim.Left
+	RightSide = bisim.Right
+)
+
+// Origin is where a state comes from: the side of its LTS, and its ID in the
+// input of that side.
+type Origin struct {
+	Side Side
+	ID   int
+}
+
+// Sides maps states to their origins.
+type Sides map[int]Origin
+
+// newSides returns the origins of the states of left and right, as uniquifyLTS
+// recorded them.
+func newSides(left, right pifra.Lts) Sides {
+	sides := make(Sides, len(left.States)+len(right.States))
+	for state := range left.States {
+		sides[state] = Origin{LeftSide, inputIDs[LeftSide][state]}
+	}
+	for state := range right.States {
+		sides[state] = Origin{RightSide, inputIDs[RightSide][state]}
+	}
+	return sides
 }
 
 func check(err error) {
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		exit(1)
+	}
+}
+
+// exitInternal is the exit status for internal errors such as panics, as
+// opposed to 1 for a negative verdict.
+const exitInternal = 3
+
+// exitUnknown is the exit status when -anytime stopped the refinement before
+// the initial states were separated.
+const exitUnknown = 4
+
+// maxStack bounds the stack trace reported for a panic.
+const maxStack = 4096
+
+// safely runs f, converting a panic into an error carrying the panic value and
+// the start of the stack trace.
+func safely(f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			stack := debug.Stack()
+			if len(stack) > maxStack {
+				stack = stack[:maxStack]
+			}
+			err = fmt.Errorf("internal error: %v\n%s", v, stack)
+		}
+	}()
+	f()
+	return
+}
+
+func checkInternal(err error) {
+	if err != nil {
+		log.Print(err)
+		exit(exitInternal)
 	}
 }
 
@@ -55,17 +209,50 @@ func closeFile(f *os.File) {
 }
 
 func decodeLTS(name string) (lts pifra.Lts, err error) {
+	lts, _, err = decodeNamedLTS(name)
+	return
+}
+
+// decodeNamedLTS decodes the LTS in the file name, and the names of its
+// states if it was written by hand, as an Aldebaran .aut file or as CSV, or
+// as JSON, as -output-encoding json writes quotients.
+func decodeNamedLTS(name string) (lts pifra.Lts, names map[int]string, err error) {
+	if isDir(name) {
+		lts, err = loadShards(name)
+		return
+	}
 	file, err := os.Open(name)
 	if err != nil {
 		return
 	}
 	defer closeFile(file)
-	dec := gob.NewDecoder(file)
-	err = dec.Decode(&lts)
+	br := bufio.NewReader(file)
+	prefix, _ := br.Peek(ltsfile.SniffSize)
+	switch {
+	case filepath.Ext(name) == ".csv":
+		return readCSV(br)
+	case ltsfile.Sniff(prefix) == ltsfile.FormatAut:
+		return readAut(br)
+	case ltsfile.Sniff(prefix) == ltsfile.FormatJSON:
+		return readJSON(br)
+	}
+	lts, err = bisim.LoadLTS(br)
 	return
 }
 
-func uniquifyLTS(lts *pifra.Lts, right bool) {
+// initialStates holds, per side, the uniquified ID of the initial state of
+// the LTS last uniquified for that side.
+var initialStates = [2]int{0, 1}
+
+func uniquifyLTS(lts *pifra.Lts, right bool) error {
+	if len(lts.States) > maxStates {
+		return fmt.Errorf("LTS exceeds supported size (2^31-1 states)")
+	}
+	side := LeftSide
+	if right {
+		side = RightSide
+	}
+	renumber(lts, side)
 	var offset int
 	if right {
 		offset = 1
@@ -73,38 +260,61 @@ func uniquifyLTS(lts *pifra.Lts, right bool) {
 	uniquify := func(id int) int {
 		return (id * 2) + offset
 	}
+	// pifra numbers the initial state 0, and renumber keeps it first, or
+	// makes the smallest state the first without a state 0.
+	initialStates[side] = uniquify(0)
+	inputIDs[side] = make(map[int]int, len(lts.States))
+	uniqueIDs[side] = make(map[int]int, len(lts.States))
 	states := make(map[int]pifra.Configuration, len(lts.States))
 	for id, conf := range lts.States {
+		input := id
+		if ids := denseIDs[side]; ids != nil {
+			input = ids[id]
+		}
+		inputIDs[side][uniquify(id)] = input
+		uniqueIDs[side][input] = uniquify(id)
 		states[uniquify(id)] = conf
 	}
 	lts.States = states
+	reached := make(map[int]bool, len(lts.RegSizeReached))
+	for id, ok := range lts.RegSizeReached {
+		reached[uniquify(id)] = ok
+	}
+	lts.RegSizeReached = reached
 	for i, trans := range lts.Transitions {
 		lts.Transitions[i].Source = uniquify(trans.Source)
 		lts.Transitions[i].Destination = uniquify(trans.Destination)
 	}
+	return nil
 }
 
-func newBlock() Block {
-	b := Block{id: blockIDCounter, states: make(States)}
-	blockIDCounter++
-	return b
-}
-
-func collectStates(part Partition, block Block, lts pifra.Lts) {
-	for state := range lts.States {
-		block.states[state] = exists
-		part.states[state] = block
+// collectActions returns the labels of the transitions of ltss, or one label
+// per group under -label-equiv, ordered by bisim.LabelLess.
+func collectActions(ltss ...pifra.Lts) []pifra.Label {
+	seen := make(map[pifra.Label]bool)
+	var labels []pifra.Label
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if !seen[trans.Label] {
+				seen[trans.Label] = true
+				labels = append(labels, trans.Label)
+			}
+		}
 	}
-}
-
-func collectActions(part Partition, lts pifra.Lts) {
-	for _, trans := range lts.Transitions {
-		if slice, ok := part.actions[trans.Label]; ok {
-			part.actions[trans.Label] = append(slice, trans)
-		} else {
-			part.actions[trans.Label] = []pifra.Transition{trans}
+	if labelEquiv != nil {
+		canon := canonicalLabels(labels)
+		labelActions = canon
+		labels = labels[:0]
+		for label, rep := range canon {
+			if label == rep {
+				labels = append(labels, rep)
+			}
 		}
 	}
+	sort.Slice(labels, func(i, j int) bool {
+		return bisim.LabelLess(labels[i], labels[j])
+	})
+	return labels
 }
 
 func (bs Blocks) add(b Block) {
@@ -115,118 +325,269 @@ func (bs Blocks) remove(b Block) {
 	delete(bs, b.id)
 }
 
+// newPartition returns a partition of the states of left and right with no
+// blocks yet.
 func newPartition(left, right pifra.Lts) Partition {
-	part := Partition{
-		blocks:  make(Blocks),
-		states:  make(StateBlocks),
-		actions: make(Actions),
-	}
-	block := newBlock()
-	part.blocks.add(block)
-	collectStates(part, block, left)
-	collectStates(part, block, right)
-	collectActions(part, left)
-	collectActions(part, right)
-	return part
+	return Partition{
+		blocks:    make(Blocks),
+		states:    make(StateBlocks),
+		actions:   collectActions(left, right),
+		sides:     newSides(left, right),
+		initial:   initialStates,
+		snapshots: new(snapshotBase),
+	}
 }
 
-func destinations(source int, action pifra.Label, part Partition) []int {
-	dests := make(Blocks)
-	for _, trans := range part.actions[action] {
-		if trans.Label == action && trans.Source == source {
-			dests.add(part.states[trans.Destination])
+// addBlock adds the block b of the refiner of p to p, and returns it. The
+// refiner gives an LTS without states its initial state, which p leaves out,
+// so the block is not added if it holds only that state.
+func (p Partition) addBlock(b bisim.Block) Block {
+	block := Block{id: b.ID(), states: make(States), version: nextBlockVersion()}
+	for _, s := range b.States() {
+		if origin, ok := p.sides[s.ID]; ok && origin.Side == s.Side {
+			block.states[s.ID] = exists
+			p.states[s.ID] = block
 		}
 	}
-	ids := make([]int, len(dests))
-	for id := range dests {
-		ids = append(ids, id)
+	if len(block.states) > 0 {
+		p.blocks.add(block)
 	}
-	sort.Ints(ids)
-	return ids
+	return block
 }
 
-func equalInts(a, b []int) bool {
-	if len(a) != len(b) {
-		return false
+// applySplit splits a block of p as the refiner of p did, and reports the
+// split, made for reason, to -trace and -explain-relation. The block keeps
+// its ID and the states that did not move to the new blocks.
+func (p Partition) applySplit(split bisim.Split, reason string) {
+	parent, ok := p.blocks[split.Block]
+	if !ok {
+		return
 	}
-	for i, x := range a {
-		if b[i] != x {
-			return false
+	p.blocks.remove(parent)
+	var children []Block
+	for _, b := range split.Parts {
+		if child := p.addBlock(b); len(child.states) > 0 {
+			children = append(children, child)
 		}
 	}
-	return true
-}
-
-func splitKS(block Block, action pifra.Label, part Partition) (Block, Block) {
-	var s int
-	for state := range block.states {
-		s = state
-		break
-	}
-	b1 := newBlock()
-	b2 := newBlock()
-	sdests := destinations(s, action, part)
-	for t := range block.states {
-		tdests := destinations(t, action, part)
-		if equalInts(sdests, tdests) {
-			b1.states[t] = exists
-		} else {
-			b2.states[t] = exists
+	kept := Block{id: parent.id, states: make(States), version: nextBlockVersion()}
+	for state := range parent.states {
+		if p.states[state].id == parent.id {
+			kept.states[state] = exists
+			p.states[state] = kept
 		}
 	}
-	if len(b2.states) == 0 {
-		return block, b2
+	if len(kept.states) > 0 {
+		p.blocks.add(kept)
+		children = append([]Block{kept}, children...)
 	}
-	return b1, b2
-}
-
-func refine(part Partition, b, b1, b2 Block) {
-	part.blocks.remove(b)
-	part.blocks.add(b1)
-	part.blocks.add(b2)
-	for state := range b.states {
-		if _, ok := b1.states[state]; ok {
-			part.states[state] = b1
-		} else {
-			part.states[state] = b2
-		}
+	// Only the initial state the refiner gave an LTS without states moved.
+	if len(children) == 1 && children[0].id == parent.id {
+		return
 	}
+	recordSplit(parent.id, reason, children...)
+	traceSplit(parent, split, children)
 }
 
+// anytimeDeadline is when refinePartition stops under -anytime.
+var anytimeDeadline time.Time
+
+// partKS refines the partition of the states of left and right, uniquified
+// as by prepareSide, until the states of a block are strongly bisimilar, or,
+// with -levelwise and -bounded, level by level.
 func partKS(left, right pifra.Lts) Partition {
+	return refinePartition(left, right, "strong", initialColours)
+}
+
+// partEta refines the partition of the states of left and right until the
+// states of a block have equal η-signatures.
+func partEta(left, right pifra.Lts) Partition {
+	return refinePartition(left, right, "eta", initialColours)
+}
+
+// refinePartition refines the partition of the states of left and right by a
+// bisim.Refiner deciding equivalence, strong or eta, over the LTSs as given,
+// starting from a block per colour if colours is set. The partition follows
+// the refiner split by split for -trace, -explain-relation and -watch, and is
+// otherwise read from it once refinement stops: at the end, at the -anytime
+// deadline, or once the initial states are apart if stopOnceDistinguished is
+// set.
+func refinePartition(left, right pifra.Lts, equivalence string, colours map[int]uint64) Partition {
 	part := newPartition(left, right)
-	changed := true
-	for changed {
-		changed = false
-	out:
-		for id, block := range part.blocks {
-			for action := range part.actions {
-				b1, b2 := splitKS(block, action, part)
-				if b1.id == id {
-					continue
-				}
-				refine(part, block, b1, b2)
-				changed = true
-				break out
+	opts := bisim.Options{
+		Equivalence:    equivalence,
+		Silent:         IsTau,
+		Graded:         *graded,
+		NoFingerprints: *noFingerprints,
+		UpTo:           *upTo,
+		Progress:       onProgress,
+	}
+	switch {
+	case levelRefinement():
+		opts.Algorithm, opts.Bound = "levelwise", *bounded
+	case fastPath():
+		opts.Algorithm = "hopcroft"
+	}
+	if colours != nil {
+		opts.Colour = func(s bisim.State) uint64 { return colours[s.ID] }
+	}
+	if *lowMem {
+		opts.Spill = *lowMemChunk
+	}
+	follow := tracer != nil || *explainRelation || onSnapshot != nil || stopOnceDistinguished
+	if follow {
+		opts.Split = func(split bisim.Split) {
+			reason := actionText(split.Action)
+			switch {
+			case equivalence == "eta":
+				reason = "η-signature"
+			case split.Signature:
+				reason = fmt.Sprintf("level %d", split.Step)
 			}
+			part.applySplit(split, reason)
 		}
 	}
-	return part
-}
+	r, err := bisim.NewRefiner(actionLTS(left), actionLTS(right), opts)
+	checkInternal(err)
+	part.refiner = r
+	if *lowMem {
+		spilled = append(spilled, r)
+	}
+	if follow {
+		for _, b := range r.Partition().Blocks() {
+			part.addBlock(b)
+		}
+	}
+	startSplitTree(part)
+	if tracer != nil {
+		trace(events.Event{Kind: events.Init, Blocks: tracePartition(part)})
+	}
 
-func isLeft(state int) bool {
-	return state%2 == 0
+	anytimeDeadline = time.Time{}
+	if *anytime > 0 {
+		anytimeDeadline = time.Now().Add(*anytime)
+	}
+	var round int
+	var stop string
+	ended := false
+	for !ended {
+		if !anytimeDeadline.IsZero() && time.Now().After(anytimeDeadline) {
+			stop = "deadline"
+			break
+		}
+		round++
+		trace(events.Event{Kind: events.Round, Round: round})
+		ended = !r.Step()
+		reportSnapshot(part, round)
+		if ended && !r.Stable() {
+			stop = "bound"
+		}
+		if stopOnceDistinguished && part.initialsSplit() {
+			break
+		}
+	}
+	part.stopped = stop != ""
+	checkInternal(r.Err())
+	if !follow {
+		for _, b := range r.Partition().Blocks() {
+			part.addBlock(b)
+		}
+	}
+
+	work := r.Work()
+	counters.rounds += work.Steps
+	if r.Stable() {
+		counters.rounds++
+	}
+	counters.splits += work.Splits
+	counters.attempts += work.Attempts
+	if work.Hopcroft {
+		counters.hopcroft = true
+	}
+	if levelRefinement() {
+		levelBlocks = r.Levels()
+	}
+	// The refiner reports its end itself, unless the loop left it early.
+	if !ended && onProgress != nil {
+		onProgress(bisim.Progress{
+			Steps:  work.Steps,
+			Blocks: len(part.blocks),
+			States: len(part.states),
+			Splits: work.Splits,
+			Done:   true,
+		})
+	}
+	if tracer != nil && part.stopped {
+		trace(events.Event{
+			Kind:   events.Stop,
+			Round:  round,
+			Blocks: tracePartition(part),
+			Reason: stop,
+		})
+	} else if tracer != nil {
+		trace(events.Event{
+			Kind:   events.Done,
+			Round:  round,
+			Blocks: tracePartition(part),
+		})
+	}
+	return part
 }
 
-func (ss States) bisimilar() bool {
-	var left, right bool
-	for s := range ss {
-		if isLeft(s) {
-			left = true
+// refineSides decides the partition refinement equivalence selected by
+// -equivalence. It saturates left and right first if the equivalence abstracts
+// from silent moves, and returns the LTSs the partition was refined over.
+func refineSides(left, right pifra.Lts) (part Partition, refLeft, refRight pifra.Lts) {
+	if *prereduce && refinements[*equivalence] {
+		checkInternal(safely(func() {
+			part, refLeft, refRight = refinePrereduced(left, right)
+		}))
+		return
+	}
+	refLeft, refRight = left, right
+	if refinements[*equivalence] {
+		refLeft, refRight = saturateSides(left, right, *equivalence == "weak")
+	}
+	refLeft, refRight = directed(refLeft), directed(refRight)
+	checkInternal(safely(func() {
+		if *equivalence == "eta" {
+			part = partEta(refLeft, refRight)
 		} else {
-			right = true
+			part = partKS(refLeft, refRight)
 		}
-		if left && right {
+	}))
+	return
+}
+
+// state returns the state s of p as its refiner names it.
+func (p Partition) state(s int) bisim.State {
+	return bisim.State{Side: p.sides[s].Side, ID: s}
+}
+
+// initialDistinction returns an action distinguishing the initial states in
+// part, refined by refineSides.
+func initialDistinction(part Partition) (pifra.Label, bool) {
+	return part.refiner.Distinction(part.state(part.initial[LeftSide]), part.state(part.initial[RightSide]))
+}
+
+// distinguishingTrace follows distinguishing actions from the initial states
+// of part, refined by refineSides, as bisim.Refiner.Trace does.
+func distinguishingTrace(part Partition) []pifra.Label {
+	return part.refiner.Trace(part.state(part.initial[LeftSide]), part.state(part.initial[RightSide]))
+}
+
+// destinations returns the sorted IDs of the blocks of p that source reaches
+// by action, as its refiner compared them.
+func (p Partition) destinations(source int, action pifra.Label) []int {
+	return p.refiner.Destinations(p.state(source), action)
+}
+
+// mixed reports whether block contains states of both sides.
+func (p Partition) mixed(block Block) bool {
+	var seen [2]bool
+	for s := range block.states {
+		seen[p.sides[s].Side] = true
+		if seen[LeftSide] && seen[RightSide] {
 			return true
 		}
 	}
@@ -235,95 +596,591 @@ func (ss States) bisimilar() bool {
 
 type Bisimulation map[int]int
 
+// bisimilar returns the classes of the partition if the initial states of both
+// sides share a block, or, with -ignore-initial, if every block contains states
+// of both sides. Under -backward it needs both. Otherwise it returns nil.
 func (p Partition) bisimilar() Bisimulation {
-	var label int
-	bisim := make(Bisimulation)
-	for _, block := range p.blocks {
-		if !block.states.bisimilar() {
-			return nil
+	if *ignoreInitial || *backward {
+		for _, block := range p.blocks {
+			if !p.mixed(block) {
+				return nil
+			}
 		}
+	}
+	if !*ignoreInitial && p.initialsSplit() {
+		return nil
+	}
+	return p.classes()
+}
+
+// classes labels every block of the partition, numbering them in the order of
+// their smallest state so that the labelling is deterministic.
+func (p Partition) classes() Bisimulation {
+	blocks, _ := p.quotientBlocks()
+	bisim := make(Bisimulation)
+	for label, block := range blocks {
 		for state := range block.states {
 			bisim[state] = label
 		}
-		label++
 	}
 	return bisim
 }
 
-func bisimGraphViz(bisim Bisimulation, lts pifra.Lts) []byte {
+// bisimGraphViz renders lts with its states labelled by the names of their
+// classes under bisim, marking its initial state initial. It stops with the
+// error of ctx once ctx is done.
+func bisimGraphViz(ctx context.Context, bisim Bisimulation, names map[int]string, lts pifra.Lts,
+	initial int) ([]byte, error) {
 	var buf bytes.Buffer
-	type StateTmpl struct {
-		Label int
-		Attrs string
-	}
-	type TransTmpl struct {
-		Src   int
-		Dest  int
-		Label string
+	written := 0
+	// done counts a state or transition about to be written, checking ctx
+	// every checkEvery of them.
+	done := func() bool {
+		check := written%checkEvery == 0
+		written++
+		return check && ctx.Err() != nil
 	}
-	const stmpl = "    {{.Label}} [{{.Attrs}}label=\"{{.Label}}\"]\n"
-	const ttmpl = "    {{.Src}} -> {{.Dest}} [label=\"{{ .Label}}\"]\n"
-	stateTmpl := template.Must(template.New("state").Parse(stmpl))
-	transTmpl := template.Must(template.New("trans").Parse(ttmpl))
-
-	states := make([]int, len(lts.States))
+	states := make([]int, 0, len(lts.States))
 	for state := range lts.States {
 		states = append(states, state)
 	}
 	sort.Ints(states)
+	shown := func(int) bool { return true }
+	if *renderDepth >= 0 {
+		dist := classDistances(bisim, lts, initial, *renderDepth)
+		shown = func(class int) bool {
+			_, ok := dist[class]
+			return ok
+		}
+	}
+
+	var ready map[int]string
+	if *readySets {
+		ready = readySetTexts(lts)
+	}
 
 	buf.WriteString("digraph {\n")
 	for _, state := range states {
+		if done() {
+			return nil, ctx.Err()
+		}
 		label := bisim[state]
+		if !shown(label) {
+			continue
+		}
 		var attrs string
 		if lts.RegSizeReached[state] {
 			attrs += "peripheries=3,"
-		} else if state == 0 || state == 1 {
+		} else if state == initial {
 			attrs += "peripheries=2,"
 		}
-		node := StateTmpl{Label: label, Attrs: attrs}
-		stateTmpl.Execute(&buf, node)
+		fmt.Fprintf(&buf, "    %d [%slabel=\"%s%s%s\"]\n", label, attrs, dotEscape(names[label]), propsText(state),
+			readySetText(ready, state))
 	}
 	buf.WriteRune('\n')
+	frontier := make(map[pifra.Transition]bool)
 	for _, trans := range lts.Transitions {
-		transTmpl.Execute(&buf, TransTmpl{
-			Src:   bisim[trans.Source],
-			Dest:  bisim[trans.Destination],
-			Label: trans.Label.PrettyPrintGraph(),
-		})
+		if done() {
+			return nil, ctx.Err()
+		}
+		src, dst := bisim[trans.Source], bisim[trans.Destination]
+		if !shown(src) {
+			continue
+		}
+		attrs, label := edgeAttrs(trans.Label), dotEscape(trans.Label.PrettyPrintGraph())
+		if !shown(dst) {
+			key := pifra.Transition{Source: src, Label: trans.Label}
+			if !frontier[key] {
+				frontier[key] = true
+				fmt.Fprintf(&buf, "    %d -> %s [%slabel=\"%s\"]\n", src, frontierNode, attrs, label)
+			}
+			continue
+		}
+		fmt.Fprintf(&buf, "    %d -> %d [%slabel=\"%s\"]\n", src, dst, attrs, label)
+	}
+	if len(frontier) > 0 {
+		fmt.Fprintf(&buf, "    %s [shape=plaintext,label=\"...\"]\n", frontierNode)
 	}
 	buf.WriteString("}\n")
-	return buf.Bytes()
+	return buf.Bytes(), nil
+}
+
+// writeColoured writes the LTSs coloured by bisim, those of the sides picked
+// by -sides, to the files colouredFile names: prefix-left.dot and
+// prefix-right.dot by default, or their .tex counterparts under -format tikz.
+// The graphs carry the certificates of the sides if given. If a side to be
+// written has more than -max-graph-nodes states, the quotient graph of part
+// goes to prefix-quotient.dot instead, or without a prefix to the first file.
+func writeColoured(ctx context.Context, prefix string, part Partition, bisim Bisimulation,
+	names map[int]string, left, right pifra.Lts, certs []StabilityCertificate) error {
+	ltss := []pifra.Lts{left, right}
+	var sides []Side
+	for _, side := range []Side{LeftSide, RightSide} {
+		if wantSide(side) {
+			if colouredFile(prefix, side) == "" {
+				return errNoOutput
+			}
+			sides = append(sides, side)
+		}
+	}
+	n := 0
+	for _, side := range sides {
+		if len(ltss[side].States) > n {
+			n = len(ltss[side].States)
+		}
+	}
+	if *maxGraphNodes > 0 && n > *maxGraphNodes {
+		out := colouredFile(prefix, sides[0])
+		if prefix != "" {
+			out = prefix + "-quotient.dot"
+		}
+		log.Printf("note: an LTS has %d states, more than -max-graph-nodes %d: "+
+			"writing the quotient graph to %s instead", n, *maxGraphNodes, out)
+		return writeFile(out, quotientGraphViz(part, left, right))
+	}
+	for _, side := range sides {
+		var data []byte
+		if *outputFormat == "tikz" {
+			data = bisimTikZ(bisim, names, ltss[side], part.initial[side])
+		} else {
+			var err error
+			data, err = bisimGraphViz(ctx, bisim, names, ltss[side], part.initial[side])
+			if err != nil {
+				return err
+			}
+			if certs != nil {
+				data = withCertificate(data, certs[side])
+			}
+		}
+		data = withProjection(data, side, *outputFormat == "tikz")
+		if err := writeFile(colouredFile(prefix, side), data); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+func encodeLTS(lts pifra.Lts) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ltsfile.WriteHeader(&buf); err != nil {
+		return nil, err
+	}
+	err := gob.NewEncoder(&buf).Encode(lts)
+	return buf.Bytes(), err
+}
+
+func writeLTS(name string, lts pifra.Lts) error {
+	data, err := encodeLTS(lts)
+	if err != nil {
+		return err
+	}
+	return writeFile(name, data)
+}
+
+// checkOutput refuses to write name over one of the input files, or over any
+// existing file, unless -force is given.
+func checkOutput(name string) error {
+	if *force {
+		return nil
+	}
+	out, err := os.Stat(name)
+	if err != nil {
+		return nil
+	}
+	abs, _ := filepath.Abs(name)
+	for _, input := range inputFiles {
+		in, err := os.Stat(input)
+		inAbs, _ := filepath.Abs(input)
+		if inAbs == abs || (err == nil && os.SameFile(in, out)) {
+			return fmt.Errorf("refusing to overwrite input file %s", name)
+		}
+	}
+	return fmt.Errorf("%s already exists (use -force to overwrite)", name)
+}
+
+// writeFile atomically replaces name with data, by writing to a temporary file
+// in the same directory and renaming it.
 func writeFile(name string, data []byte) error {
+	if err := checkOutput(name); err != nil {
+		return err
+	}
+	return replaceFile(name, data)
+}
+
+// replaceFile is writeFile without checkOutput, for files pisim rewrites.
+func replaceFile(name string, data []byte) (err error) {
 	dir := filepath.Dir(name)
 	os.MkdirAll(dir, os.ModePerm)
-	return ioutil.WriteFile(name, data, 0644)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// warnSameFile warns when both inputs are the same file, which trivially
+// compares a system with itself.
+func warnSameFile(leftName, rightName string) {
+	l, err := os.Stat(leftName)
+	if err != nil {
+		return
+	}
+	r, err := os.Stat(rightName)
+	if err != nil {
+		return
+	}
+	if os.SameFile(l, r) {
+		log.Printf("warning: %s and %s are the same file", leftName, rightName)
+	}
+}
+
+// warnNoTransitions warns about inputs without transitions. They are valid,
+// all their states being deadlocked and so equivalent, but usually come from
+// a failed or truncated generation.
+func warnNoTransitions(left, right pifra.Lts, inputs []string) {
+	for side, lts := range []pifra.Lts{left, right} {
+		if len(lts.Transitions) == 0 {
+			log.Printf("warning: %s LTS %s has %d state(s) but no transitions",
+				Side(side), inputs[side], len(lts.States))
+		}
+	}
+}
+
+// loadSide decodes and preprocesses the LTS of one side of the comparison,
+// independently of the other side.
+func loadSide(name string, right bool) (lts pifra.Lts, err error) {
+	side := "left"
+	if right {
+		side = "right"
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%s LTS %s: %v", side, name, err)
+		}
+	}()
+	lts, names, err := decodeNamedLTS(name)
+	if err != nil {
+		return
+	}
+	if names != nil && *equivariant {
+		return lts, errors.New("-equivariant needs the configurations of an LTS generated by pifra")
+	}
+	index := 0
+	if right {
+		index = 1
+	}
+	stateNames[index] = names
+	err = prepareSide(&lts, right)
+	return
+}
+
+// prepareSide applies the preprocessing of one side of the comparison to a
+// decoded LTS.
+func prepareSide(lts *pifra.Lts, right bool) error {
+	if n := len(lts.States); *maxInputStates > 0 && n > *maxInputStates {
+		return fmt.Errorf("LTS has %d states, exceeds limit %d", n, *maxInputStates)
+	}
+	if *equivariant {
+		canonicaliseLTS(lts)
+	}
+	if *normalize && !*graded {
+		if n := dropDuplicates(lts); n > 0 {
+			side := LeftSide
+			if right {
+				side = RightSide
+			}
+			log.Printf("%s LTS: dropped %d duplicate transitions", side, n)
+		}
+	}
+	return uniquifyLTS(lts, right)
+}
+
+// loadSides preprocesses both sides of the comparison concurrently, or one
+// after the other under -low-mem, so that only one is decoded at a time.
+func loadSides(leftName, rightName string) (left, right pifra.Lts, err error) {
+	if *lowMem {
+		if left, err = loadSide(leftName, false); err != nil {
+			return
+		}
+		right, err = loadSide(rightName, true)
+		return
+	}
+	var g errgroup.Group
+	g.Go(func() (err error) {
+		left, err = loadSide(leftName, false)
+		return
+	})
+	g.Go(func() (err error) {
+		right, err = loadSide(rightName, true)
+		return
+	})
+	err = g.Wait()
+	return
 }
 
 func init() {
 	pifra.RegisterGobs()
 }
 
+// commands are the subcommands of pisim. Without one, pisim compares two LTSs.
+var commands = map[string]func(args []string){
+	"slice":        sliceCommand,
+	"apply-bisim":  applyBisimCommand,
+	"check":        checkCommand,
+	"conform":      conformCommand,
+	"fingerprint":  fingerprintCommand,
+	"pack":         packCommand,
+	"check-cert":   checkCertCommand,
+	"shrink":       shrinkCommand,
+	"render-block": renderBlockCommand,
+	"batch-dir":    batchDirCommand,
+	"merge":        mergeCommand,
+	"dump":         dumpCommand,
+}
+
 func main() {
-	if len(os.Args) < 4 {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	flag.Parse()
+	check(loadConfig(flag.CommandLine))
+	args := flag.Args()
+	if *weak {
+		*equivalence = "weak"
+	}
+	if len(args) < 2 {
 		log.Fatalln("Wrong number of arguments")
 	}
-	left, err := decodeLTS(os.Args[1])
+	check(validateSides())
+	if needsPrefix() && len(args) < 3 {
+		log.Fatalln(errNoOutput)
+	}
+	inputFiles = args[:2]
+	warnSameFile(args[0], args[1])
+	left, right, err := loadSides(args[0], args[1])
 	check(err)
-	right, err := decodeLTS(os.Args[2])
+	var prefix string
+	if len(args) > 2 {
+		prefix = args[2]
+	}
+	compare(left, right, args[:2], prefix)
+}
+
+// needsPrefix reports whether the comparison writes the coloured LTS of a
+// side without a file given for it, and so needs an output prefix.
+func needsPrefix() bool {
+	_, refinement := refinements[*equivalence]
+	if !refinement || *sim || *compareStats || *quiet {
+		return false
+	}
+	return wantSide(LeftSide) && *outLeft == "" || wantSide(RightSide) && *outRight == ""
+}
+
+// compare compares the preprocessed LTSs read from inputs, prints the verdict
+// and writes the requested outputs, exiting with status 1 on a negative
+// verdict.
+func compare(left, right pifra.Lts, inputs []string, prefix string) {
+	_, refinement := refinements[*equivalence]
+	var err error
+	check(startProfiles())
+	defer stopProfiles()
+	defer removeSpilled()
+	if *showProgress {
+		onProgress = printProgress
+	}
+	check(validateTau(left, right))
+	check(checkTruncation(left, right, inputs))
+	warnNoTransitions(left, right, inputs)
+	check(checkLabels(&left, &right))
+	check(applyDropSelfLoops(&left, &right))
+	check(applyProjection(&left, &right))
+	check(loadObservation())
+	annotated, err := annotationClasses(left, right)
 	check(err)
-	uniquifyLTS(&left, false)
-	uniquifyLTS(&right, true)
-	part := partKS(left, right)
+	observation.Classes = append(observation.Classes, annotated...)
+	check(validateSeed())
+	check(validateCertify())
+	check(validateUpTo())
+	check(validateLowMem())
+	check(validateFormat())
+	check(validatePrereduce())
+	check(validateOutputEncoding())
+	if *propFile != "" && (*sim || !refinement) {
+		check(errors.New("-prop needs an equivalence decided by partition refinement, without -sim"))
+	}
+	check(loadLabelEquiv())
+	if labelEquiv != nil && (*sim || !refinement) {
+		check(errors.New("-label-equiv needs an equivalence decided by partition refinement, without -sim"))
+	}
+	check(validateCompareStats())
+	check(validateDirection())
+	check(validateExplainRelation())
+	check(validateLevelwise())
+	check(validateWatch())
+	refLeft, refRight := observation.observe(left), observation.observe(right)
+	if *undirected {
+		refLeft, refRight = addReverse(refLeft), addReverse(refRight)
+	}
+	check(checkLabelEquiv(refLeft, refRight))
+	if *explain {
+		printAlphabetDifference(os.Stdout, refLeft, refRight, true)
+	}
+	if *compareStats {
+		checkInternal(safely(func() {
+			err = printSpectrum(os.Stdout, refLeft, refRight)
+		}))
+		check(err)
+		return
+	}
+	if *sim {
+		var ok bool
+		var buf bytes.Buffer
+		checkInternal(safely(func() {
+			ok, err = checkSimulation(&buf, refLeft, refRight)
+		}))
+		check(err)
+		res := newResult(inputs, left, right, ok, true)
+		res.Directions = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		printResult(res)
+		if !ok {
+			exit(1)
+		}
+		return
+	}
+	if !refinement {
+		var ok bool
+		var reason string
+		checkInternal(safely(func() {
+			ok, reason, err = checkEquivalence(*equivalence, refLeft, refRight)
+		}))
+		check(err)
+		res := newResult(inputs, left, right, ok, true)
+		if !ok {
+			res.Witness = &bisim.Witness{Reason: reason}
+			// -explain printed the alphabets first.
+			if !*explain {
+				onlyLeft, onlyRight := alphabetDifference(refLeft, refRight)
+				res.Witness.OnlyLeft, res.Witness.OnlyRight = prettyTrace(onlyLeft), prettyTrace(onlyRight)
+			}
+		}
+		printResult(res)
+		if !ok {
+			exit(1)
+		}
+		return
+	}
+	if *leftFingerprint != "" {
+		check(loadSeed(inputs[0], refRight))
+	}
+	check(loadPropositions(refLeft, refRight))
+	seedInitialStates(refLeft, refRight)
+	traceFile, err := openTrace()
+	check(err)
+	stopWatch := startWatch(newSides(refLeft, refRight))
+	part, refLeft, refRight := refineSides(refLeft, refRight)
+	stopWatch()
+	if traceFile != nil {
+		closeFile(traceFile)
+	}
+	ctx, stop := outputContext()
+	defer stop()
+	if *listOrphans || *orphansJSON != "" {
+		check(reportOrphans(part, left, right))
+	}
+	if *quotientDot != "" {
+		check(writeFile(*quotientDot, quotientGraphViz(part, left, right)))
+	}
+	if *summaryDot != "" {
+		check(writeFile(*summaryDot, summaryGraphViz(part, left, right)))
+	}
+	if *htmlOut != "" {
+		check(writeHTML(*htmlOut, part, left, right, inputs))
+	}
+	check(writeQuotients(ctx, part, left, right))
+	if *showStats {
+		printStats(os.Stderr, part, left, right)
+	}
 	bisim := part.bisimilar()
+	if *diffDot != "" {
+		if bisim == nil {
+			check(writeFile(*diffDot, diffGraphViz(part, left, right)))
+		} else {
+			log.Println("-diff: the LTSs are equivalent, so there is no difference to draw")
+		}
+	}
+	if *saveBisim != "" {
+		check(writeBisim(*saveBisim, part, bisim != nil && !part.stopped, inputs[0], inputs[1]))
+	}
+	res := newResult(inputs, left, right, bisim != nil, !part.stopped)
+	res.Stats.Classes = len(part.blocks)
+	res.Stats.Levels = levelBlocks
 	if bisim == nil {
-		fmt.Println("Not bisimilar")
-		os.Exit(1)
+		res.Witness = newWitness(part, refLeft, refRight)
+		// -explain printed the alphabets first.
+		if *explain {
+			res.Witness.OnlyLeft, res.Witness.OnlyRight = nil, nil
+		}
+	}
+	checkInternal(part.err())
+	printSplitTree(os.Stdout, part, part.classes())
+	printResult(res)
+	if bisim == nil {
+		if *explain && !*resultJSON {
+			printOverlap(os.Stdout, part)
+		}
+		exit(1)
+	}
+	if *checkIso && !part.stopped {
+		if *equivalence == "eta" {
+			log.Println("-check-iso does not apply to -equivalence eta")
+		} else if bisim[part.initial[LeftSide]] != bisim[part.initial[RightSide]] {
+			log.Println("-check-iso: the initial states are not bisimilar")
+		} else {
+			checkInternal(printIsomorphism(os.Stdout, bisim, refLeft, refRight))
+		}
+	}
+	names := classNames(bisim, left, right)
+	if !part.stopped {
+		if *summary {
+			printSummary(os.Stdout, bisim, part.sides, part.initial, names, refLeft, refRight)
+		}
+		if *witness != "" {
+			check(writeWitness(bisim, part.sides, part.initial, refLeft, refRight))
+		}
+	}
+	var certs []StabilityCertificate
+	if *certify && part.stopped {
+		log.Println("-certify: the refinement stopped early, so its classes cannot be certified")
+	} else if *certify {
+		for _, lts := range []pifra.Lts{refLeft, refRight} {
+			cert, err := newCertificate(bisim, part.sides, lts)
+			checkInternal(err)
+			certs = append(certs, cert)
+		}
+	}
+	if !*quiet {
+		check(writeColoured(ctx, prefix, part, bisim, names, left, right, certs))
+	}
+	if part.stopped {
+		exit(exitUnknown)
 	}
-	data := bisimGraphViz(bisim, left)
-	check(writeFile(os.Args[3]+"-left.dot", data))
-	data = bisimGraphViz(bisim, right)
-	check(writeFile(os.Args[3]+"-right.dot", data))
 }
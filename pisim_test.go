@@ -0,0 +1,884 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/ltsfile"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// TestMain runs pisim itself instead of the tests when the test binary is
+// started by runPisim, so that the tests can check the exit status and output
+// of whole invocations.
+func TestMain(m *testing.M) {
+	if os.Getenv("PISIM_TEST_MAIN") == "1" {
+		os.Args = append([]string{"pisim"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runPisim runs pisim with args in dir, and returns what it printed and its
+// exit status.
+func runPisim(t *testing.T, dir string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PISIM_TEST_MAIN=1")
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// writeTestFile writes text to the file name in dir and returns its path.
+func writeTestFile(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeTestLTS writes lts as a gob to the file name in dir and returns its
+// path.
+func writeTestLTS(t testing.TB, dir, name string, lts pifra.Lts) string {
+	t.Helper()
+	data, err := encodeLTS(lts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// permuted returns lts with its states renamed by a random permutation that
+// keeps the initial state 0, so that it is isomorphic to lts.
+func permuted(r *rand.Rand, lts pifra.Lts) pifra.Lts {
+	perm := r.Perm(len(lts.States) - 1)
+	name := func(state int) int {
+		if state == 0 {
+			return 0
+		}
+		return perm[state-1] + 1
+	}
+	out := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(lts.States)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for state, conf := range lts.States {
+		out.States[name(state)] = conf
+	}
+	for _, trans := range lts.Transitions {
+		trans.Source, trans.Destination = name(trans.Source), name(trans.Destination)
+		out.Transitions = append(out.Transitions, trans)
+	}
+	return out
+}
+
+// setFlag sets the command-line flag name to value until the end of the test.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	f := flag.Lookup(name)
+	if f == nil {
+		t.Fatalf("no flag -%s", name)
+	}
+	old := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.Value.Set(old); err != nil {
+			t.Errorf("restoring -%s: %v", name, err)
+		}
+	})
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 0, 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", left)
+	if code != 1 || !bytes.Contains([]byte(stderr), []byte("Wrong number of arguments")) {
+		t.Errorf("got status %d and %q, want 1 and the wrong number of arguments", code, stderr)
+	}
+}
+
+// randomPair returns two small random LTSs, isomorphic one time in three, so
+// that both verdicts are common.
+func randomPair(r *rand.Rand) (pifra.Lts, pifra.Lts) {
+	left := reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0)
+	if r.Intn(3) == 0 {
+		return left, permuted(r, left)
+	}
+	return left, reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0)
+}
+
+// refinedBisimilar decides equivalence, strong, weak or delay, of left and
+// right by partKS, as compare does, and reports whether it relates their
+// initial states.
+func refinedBisimilar(t testing.TB, left, right pifra.Lts, equivalence string) bool {
+	left, right = prepared(t, left, right)
+	if refinements[equivalence] {
+		left, right = bisim.Saturate(left, equivalence == "weak", IsTau), bisim.Saturate(right, equivalence == "weak", IsTau)
+	}
+	part := partKS(left, right)
+	defer part.close()
+	return !part.initialsSplit()
+}
+
+// checkReference compares refinedBisimilar with the reference decision on
+// random pairs of LTSs drawn by pair.
+func checkReference(t *testing.T, equivalence string, pair func(*rand.Rand) (pifra.Lts, pifra.Lts),
+	decide func(left, right pifra.Lts) bool) {
+	t.Helper()
+	r := rand.New(rand.NewSource(1))
+	var verdicts [2]int
+	for i := 0; i < 300; i++ {
+		left, right := pair(r)
+		want := decide(left, right)
+		if got := refinedBisimilar(t, left, right, equivalence); got != want {
+			t.Fatalf("pair %d: %s bisimilar = %v, reference says %v\nleft: %v\nright: %v",
+				i, equivalence, got, want, left.Transitions, right.Transitions)
+		}
+		if want {
+			verdicts[1]++
+		} else {
+			verdicts[0]++
+		}
+	}
+	if verdicts[0] == 0 || verdicts[1] == 0 {
+		t.Errorf("%d negative and %d positive verdicts, want both", verdicts[0], verdicts[1])
+	}
+}
+
+func TestPartKSReference(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		flags []string
+	}{
+		{"plain", nil},
+		{"no-fingerprints", []string{"no-fingerprints"}},
+		{"up-to", []string{"up-to"}},
+		{"low-mem", []string{"low-mem"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "no-fastpath", "true")
+			for _, name := range test.flags {
+				setFlag(t, name, "true")
+			}
+			checkReference(t, "strong", randomPair, reference.Strong)
+		})
+	}
+}
+
+// TestBisimAgrees checks that package bisim, which the CLI does not use for
+// its comparisons, decides each equivalence as the CLI's refinement does.
+func TestBisimAgrees(t *testing.T) {
+	for _, equivalence := range []string{"strong", "weak", "delay", "eta"} {
+		t.Run(equivalence, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			var verdicts [2]int
+			for i := 0; i < 300; i++ {
+				left, right := randomSilentPair(r)
+				var want bool
+				if equivalence == "eta" {
+					l, r := prepared(t, left, right)
+					want = !partEta(l, r).initialsSplit()
+				} else {
+					want = refinedBisimilar(t, left, right, equivalence)
+				}
+				got, err := bisim.CheckBisimilar(context.Background(), left, right,
+					bisim.Options{Equivalence: equivalence})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("pair %d: bisim says %v, pisim %v\nleft: %v\nright: %v",
+						i, got, want, left.Transitions, right.Transitions)
+				}
+				if want {
+					verdicts[1]++
+				} else {
+					verdicts[0]++
+				}
+			}
+			if verdicts[0] == 0 || verdicts[1] == 0 {
+				t.Errorf("%d negative and %d positive verdicts, want both", verdicts[0], verdicts[1])
+			}
+		})
+	}
+}
+
+func TestLoadSidesNamesTheSide(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0 \"1 1\" 1)\n")
+	_, _, err := loadSides(left, right)
+	if err == nil || !strings.HasPrefix(err.Error(), "right LTS "+right+": line 2") {
+		t.Errorf("loadSides() = %v, want an error on line 2 of the right LTS", err)
+	}
+}
+
+// BenchmarkLoadSides measures decoding and preparing two LTSs of 200000
+// transitions concurrently, as pisim does, against one after the other.
+func BenchmarkLoadSides(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	dir := b.TempDir()
+	left := writeTestLTS(b, dir, "left.gob", reference.Random(r, 50000, 200000, 4, 0.2))
+	right := writeTestLTS(b, dir, "right.gob", reference.Random(r, 50000, 200000, 4, 0.2))
+	b.Run("concurrent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := loadSides(left, right); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := loadSide(left, false); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := loadSide(right, true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestHeaderMismatch(t *testing.T) {
+	dir := t.TempDir()
+	data, err := encodeLTS(nondeterministicChain(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := bytes.Replace(data, []byte(ltsfile.GobMagic+ltsfile.Version+"\n"), []byte(ltsfile.GobMagic+"0.0.1\n"), 1)
+	if bytes.Equal(old, data) {
+		t.Fatal("encodeLTS wrote no header")
+	}
+	left := writeTestFile(t, dir, "left.gob", string(old))
+	right := writeTestFile(t, dir, "right.gob", string(data))
+	_, stderr, code := runPisim(t, dir, "-quiet", left, right)
+	if code != 1 || !strings.Contains(stderr, "written by pisim v0.0.1, you're running v"+ltsfile.Version) {
+		t.Errorf("status %d and %q, want 1 and the version mismatch", code, stderr)
+	}
+}
+
+func TestWarnSameFile(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	link := filepath.Join(dir, "link.aut")
+	if err := os.Symlink(left, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name        string
+		left, right string
+		warns       bool
+	}{
+		{"same path", left, left, true},
+		{"relative path", left, "./left.aut", true},
+		{"symbolic link", left, link, true},
+		{"different files", left, right, false},
+	} {
+		_, stderr, code := runPisim(t, dir, "-quiet", test.left, test.right)
+		if code != 0 {
+			t.Errorf("%s: status %d, want 0: %s", test.name, code, stderr)
+		}
+		if warned := strings.Contains(stderr, "are the same file"); warned != test.warns {
+			t.Errorf("%s: warned %v, want %v: %s", test.name, warned, test.warns, stderr)
+		}
+	}
+}
+
+func TestOutputCollision(t *testing.T) {
+	dir := t.TempDir()
+	const text = "des (0, 1, 2)\n(0, \"1 1\", 1)\n"
+	left := writeTestFile(t, dir, "left.aut", text)
+	right := writeTestFile(t, dir, "right.aut", text)
+	existing := writeTestFile(t, dir, "existing.dot", "old")
+	for _, test := range []struct {
+		name  string
+		args  []string
+		fails string
+		file  string
+		text  string
+	}{
+		{"input", []string{"-quotient-dot", "./left.aut"}, "refusing to overwrite input file", left, text},
+		{"existing", []string{"-quotient-dot", existing}, "already exists (use -force to overwrite)", existing, "old"},
+		{"forced", []string{"-force", "-quotient-dot", existing}, "", existing, "digraph {\n"},
+	} {
+		args := append(append([]string{"-quiet"}, test.args...), left, right)
+		_, stderr, code := runPisim(t, dir, args...)
+		if test.fails == "" && code != 0 || test.fails != "" && (code != 1 || !strings.Contains(stderr, test.fails)) {
+			t.Errorf("%s: status %d and %q, want %q", test.name, code, stderr, test.fails)
+		}
+		data, err := os.ReadFile(test.file)
+		if err != nil || !strings.HasPrefix(string(data), test.text) {
+			t.Errorf("%s: %s holds %q, %v, want %q", test.name, test.file, data, err, test.text)
+		}
+	}
+}
+
+// TestReplaceFileAtomic checks that replaceFile leaves no temporary file
+// behind, whether it succeeds or fails to rename it over its target.
+func TestReplaceFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.dot")
+	if err := replaceFile(name, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != "new" {
+		t.Errorf("out.dot holds %q, %v, want new", data, err)
+	}
+	// A directory with contents cannot be replaced by a file.
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.Mkdir(blocked, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, blocked, "keep", "")
+	if err := replaceFile(blocked, []byte("new")); err == nil {
+		t.Error("replaceFile replaced a directory")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "out.dot" && e.Name() != "blocked" {
+			t.Errorf("%s left behind", e.Name())
+		}
+	}
+}
+
+// TestIgnoreInitial compares a.b* with b* + a.b*, rooted at b*. The roots
+// differ, but each state of either side is bisimilar to one of the other.
+func TestIgnoreInitial(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 2)\n(0, \"1 1\", 1)\n(1, \"2 2\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 2)\n(0, \"2 2\", 0)\n(1, \"1 1\", 0)\n")
+	extra := writeTestFile(t, dir, "extra.aut", "des (0, 3, 3)\n(0, \"2 2\", 0)\n(1, \"1 1\", 0)\n(2, \"1 1\", 1)\n")
+	for _, test := range []struct {
+		name        string
+		args        []string
+		left, right string
+		code        int
+	}{
+		{"roots", nil, left, right, 1},
+		{"whole state spaces", []string{"-ignore-initial"}, left, right, 0},
+		{"state without partner", []string{"-ignore-initial"}, left, extra, 1},
+	} {
+		args := append(append([]string{"-quiet"}, test.args...), test.left, test.right)
+		if _, stderr, code := runPisim(t, dir, args...); code != test.code {
+			t.Errorf("%s: status %d, want %d: %s", test.name, code, test.code, stderr)
+		}
+	}
+}
+
+// TestGraded compares a.0, a.0 + a.0 with two deadlocked states, and the
+// same with a duplicated transition to one: all strongly bisimilar, but
+// graded bisimilarity counts the transitions, duplicates included.
+func TestGraded(t *testing.T) {
+	dir := t.TempDir()
+	one := writeTestFile(t, dir, "one.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	two := writeTestFile(t, dir, "two.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n")
+	dup := writeTestFile(t, dir, "dup.aut", "des (0, 2, 2)\n(0, \"1 1\", 1)\n(0, \"1 1\", 1)\n")
+	for _, test := range []struct {
+		left, right   string
+		strong, grade int
+	}{
+		{one, two, 0, 1},
+		{dup, two, 0, 0},
+		{one, dup, 0, 1},
+	} {
+		if _, _, code := runPisim(t, dir, "-quiet", test.left, test.right); code != test.strong {
+			t.Errorf("%s and %s: status %d, want %d", test.left, test.right, code, test.strong)
+		}
+		if _, _, code := runPisim(t, dir, "-quiet", "-graded", test.left, test.right); code != test.grade {
+			t.Errorf("%s and %s under -graded: status %d, want %d", test.left, test.right, code, test.grade)
+		}
+	}
+}
+
+// TestUniquifyExtremeIDs loads states numbered at both ends of int, which
+// uniquifyLTS would overflow without renumbering them densely first.
+func TestUniquifyExtremeIDs(t *testing.T) {
+	ids, index, initial := denseIDs, denseIndex, initialStates
+	inputs, uniques := inputIDs, uniqueIDs
+	t.Cleanup(func() {
+		denseIDs, denseIndex, initialStates = ids, index, initial
+		inputIDs, uniqueIDs = inputs, uniques
+	})
+	lts := pifra.Lts{
+		States: map[int]pifra.Configuration{0: {}, math.MaxInt: {}, math.MinInt: {}},
+		Transitions: []pifra.Transition{
+			{Source: 0, Destination: math.MaxInt, Label: inputLabel(1, 1)},
+			{Source: math.MaxInt, Destination: math.MinInt, Label: inputLabel(1, 1)},
+		},
+		RegSizeReached: map[int]bool{math.MinInt: true},
+	}
+	if err := uniquifyLTS(&lts, true); err != nil {
+		t.Fatal(err)
+	}
+	var states []int
+	for state := range lts.States {
+		id, ok := inputIDs[RightSide][state]
+		if !ok {
+			t.Errorf("state %d is not a right state", state)
+		}
+		states = append(states, id)
+	}
+	sort.Ints(states)
+	if want := []int{math.MinInt, 0, math.MaxInt}; !reflect.DeepEqual(states, want) {
+		t.Errorf("original states %v, want %v", states, want)
+	}
+	for _, trans := range lts.Transitions {
+		if _, ok := lts.States[trans.Source]; !ok {
+			t.Errorf("transition from unknown state %d", trans.Source)
+		}
+		if _, ok := lts.States[trans.Destination]; !ok {
+			t.Errorf("transition to unknown state %d", trans.Destination)
+		}
+	}
+	if initialStates[RightSide] != uniquified(0, RightSide) || inputIDs[RightSide][initialStates[RightSide]] != 0 {
+		t.Errorf("initial state %d, want the uniquified state 0", initialStates[RightSide])
+	}
+	for state := range lts.RegSizeReached {
+		if id := inputIDs[RightSide][state]; id != math.MinInt {
+			t.Errorf("state %d marked truncated", id)
+		}
+	}
+}
+
+func TestSafely(t *testing.T) {
+	if err := safely(func() {}); err != nil {
+		t.Errorf("got %v without a panic", err)
+	}
+	var deep func(int)
+	deep = func(n int) {
+		if n == 0 {
+			panic("boom")
+		}
+		deep(n - 1)
+	}
+	err := safely(func() { deep(200) })
+	if err == nil || !strings.HasPrefix(err.Error(), "internal error: boom\n") {
+		t.Fatalf("got %v, want the panic value", err)
+	}
+	if !strings.Contains(err.Error(), "runtime/debug.Stack") {
+		t.Errorf("no stack trace in %q", err)
+	}
+	if n := len(err.Error()) - len("internal error: boom\n"); n > maxStack {
+		t.Errorf("stack trace of %d bytes, want at most %d", n, maxStack)
+	}
+}
+
+// TestAnytimeSeparatesEarly stops a refinement after its first split, which
+// already separates the initial states of a.c.c.c and b.c.c.c, so the
+// partial partition gives the negative verdict.
+func TestAnytimeSeparatesEarly(t *testing.T) {
+	line := func(first pifra.Label) pifra.Lts {
+		lts := pifra.Lts{States: map[int]pifra.Configuration{0: {}}}
+		for i := 0; i < 4; i++ {
+			label := inputLabel(3, 3)
+			if i == 0 {
+				label = first
+			}
+			lts.States[i+1] = pifra.Configuration{}
+			lts.Transitions = append(lts.Transitions, pifra.Transition{Source: i, Destination: i + 1, Label: label})
+		}
+		return lts
+	}
+	left, right := prepared(t, line(inputLabel(1, 1)), line(inputLabel(2, 2)))
+	setFlag(t, "anytime", "1h")
+	oldSnapshot := onSnapshot
+	t.Cleanup(func() { onSnapshot = oldSnapshot })
+	steps := 0
+	onSnapshot = func(round int, s *PartitionSnapshot) {
+		steps = round
+		anytimeDeadline = time.Now().Add(-time.Second)
+	}
+	part := partKS(left, right)
+	if steps != 1 {
+		t.Errorf("%d steps, want the deadline to stop the refinement after the first", steps)
+	}
+	if !part.stopped || !part.initialsSplit() {
+		t.Errorf("stopped %v, initial states split %v, want both", part.stopped, part.initialsSplit())
+	}
+}
+
+func TestAnytimeUnknown(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestLTS(t, dir, "left.gob", nondeterministicChain(20))
+	right := writeTestLTS(t, dir, "right.gob", nondeterministicChain(20))
+	stdout, stderr, code := runPisim(t, dir, "-anytime", "1ns", "-stats", left, right, filepath.Join(dir, "out"))
+	if code != exitUnknown || stdout != "Unknown (bisimilar up to the splits performed)\n" {
+		t.Errorf("status %d and %q, want %d and an unknown verdict", code, stdout, exitUnknown)
+	}
+	if !strings.Contains(stderr, "refinement stopped early: classes may be merged\n") {
+		t.Errorf("-stats does not note the early stop:\n%s", stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out-left.dot")); err != nil {
+		t.Errorf("no coloured LTS from the partial partition: %v", err)
+	}
+}
+
+// TestSidesGolden checks the outputs that tell the sides of states apart
+// against golden files in testdata/sides, written by pisim before the
+// Partition recorded the side of every state, when it was read off the
+// uniquified IDs. left.gob and right.gob are not bisimilar, and permuted.gob
+// is left.gob with its states renamed.
+func TestSidesGolden(t *testing.T) {
+	const out = "OUT"
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		golden string
+		args   []string
+		right  string
+	}{
+		{"orphans.golden", []string{"-orphans"}, "right.gob"},
+		{"orphans-json.golden", []string{"-orphans-json", out}, "right.gob"},
+		{"quotient.golden", []string{"-quotient-dot", out}, "right.gob"},
+		{"quotient-bisimilar.golden", []string{"-quotient-dot", out}, "permuted.gob"},
+		{"witness.golden", []string{"-witness", out}, "permuted.gob"},
+		{"sim.golden", []string{"-sim"}, "right.gob"},
+		{"sim-bisimilar.golden", []string{"-sim"}, "permuted.gob"},
+	} {
+		dir := t.TempDir()
+		file := filepath.Join(dir, test.golden)
+		var args []string
+		for _, arg := range test.args {
+			if arg == out {
+				arg = file
+			}
+			args = append(args, arg)
+		}
+		args = append(args, filepath.Join(sides, "left.gob"), filepath.Join(sides, test.right),
+			filepath.Join(dir, "out"))
+		if test.args[0] == "-sim" {
+			args = args[:len(args)-1]
+		}
+		stdout, stderr, code := runPisim(t, dir, args...)
+		if code == exitInternal || code == 2 {
+			t.Fatalf("%s: status %d: %s", test.golden, code, stderr)
+		}
+		got := []byte(stdout)
+		if len(test.args) > 1 {
+			var err error
+			if got, err = os.ReadFile(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(filepath.Join(sides, test.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.golden, got, want)
+		}
+	}
+}
+
+// TestMaxStates checks that -max-states refuses the side over the limit
+// before comparing: testdata/sides/left.gob has 8 states and right.gob 6.
+func TestMaxStates(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-max-states", "7", left, right)
+	if code != 1 || !strings.Contains(stderr, "left LTS "+left+": LTS has 8 states, exceeds limit 7") {
+		t.Errorf("-max-states 7: status %d and %q, want the left LTS refused", code, stderr)
+	}
+	stdout, stderr, code := runPisim(t, dir, "-quiet", "-max-states", "8", left, right)
+	if code != 1 || !strings.HasPrefix(stdout, "Not bisimilar") {
+		t.Errorf("-max-states 8: status %d and %q, want a verdict: %s", code, stdout, stderr)
+	}
+}
+
+// TestPartitionAccessors iterates the blocks of refined partitions, and
+// checks them against the block of every state and the sides of the LTSs.
+func TestPartitionAccessors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		part := partKS(left, right)
+		seen := make(map[int]bool)
+		prev := -1
+		for _, b := range part.Blocks() {
+			if b.ID() <= prev {
+				t.Fatalf("pair %d: block %d after block %d", i, b.ID(), prev)
+			}
+			prev = b.ID()
+			states := b.States()
+			if len(states) == 0 || !sort.IntsAreSorted(states) {
+				t.Fatalf("pair %d: block %d has states %v", i, b.ID(), states)
+			}
+			for _, s := range states {
+				if seen[s] {
+					t.Fatalf("pair %d: state %d in two blocks", i, s)
+				}
+				seen[s] = true
+				if of := part.states[s]; of.ID() != b.ID() {
+					t.Errorf("pair %d: state %d maps to block %d, want %d", i, s, of.ID(), b.ID())
+				}
+				_, inLeft := left.States[s]
+				if want := map[bool]Side{true: LeftSide, false: RightSide}[inLeft]; part.sides[s].Side != want {
+					t.Errorf("pair %d: state %d on side %v, want %v", i, s, part.sides[s].Side, want)
+				}
+			}
+		}
+		if len(seen) != len(left.States)+len(right.States) {
+			t.Errorf("pair %d: blocks hold %d states, want %d", i, len(seen), len(left.States)+len(right.States))
+		}
+	}
+}
+
+// graphViz renders lts by bisimGraphViz under a context that is never done.
+func graphViz(t testing.TB, bisim Bisimulation, names map[int]string, lts pifra.Lts, initial int) string {
+	t.Helper()
+	data, err := bisimGraphViz(context.Background(), bisim, names, lts, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// countdownContext is a context that is done once its Err has been called
+// left times.
+type countdownContext struct {
+	context.Context
+	left int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.left == 0 {
+		return context.Canceled
+	}
+	ctx.left--
+	return nil
+}
+
+// TestGraphVizCancelled cancels bisimGraphViz after it checks its context a
+// few times, and checks that it stops with the error of the context.
+func TestGraphVizCancelled(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bisim, lts := ownClasses(reference.Random(r, 10*checkEvery, 10*checkEvery, 3, 0.2))
+	names := classNames(bisim, lts)
+	for _, checks := range []int{0, 3, 12} {
+		ctx := &countdownContext{context.Background(), checks}
+		if _, err := bisimGraphViz(ctx, bisim, names, lts, initialStates[LeftSide]); err != context.Canceled {
+			t.Errorf("cancelled after %d checks: got %v, want %v", checks, err, context.Canceled)
+		}
+	}
+	ctx := &countdownContext{context.Background(), 100}
+	if _, err := bisimGraphViz(ctx, bisim, names, lts, initialStates[LeftSide]); err != nil {
+		t.Errorf("got %v after %d checks", err, 100-ctx.left)
+	}
+}
+
+// BenchmarkGraphViz measures bisimGraphViz on an LTS of 100000 states, each
+// in a class of its own, against executing a template per state and
+// transition, as it did before.
+func BenchmarkGraphViz(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	bisim, lts := ownClasses(reference.Random(r, 100000, 300000, 4, 0.2))
+	names := classNames(bisim, lts)
+	initial := initialStates[LeftSide]
+	b.Run("fprintf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bisimGraphViz(context.Background(), bisim, names, lts, initial)
+		}
+	})
+	b.Run("template", func(b *testing.B) {
+		type stateTmpl struct {
+			Label int
+			Attrs string
+			Name  string
+		}
+		type transTmpl struct {
+			Src   int
+			Dest  int
+			Attrs string
+			Label string
+		}
+		states := template.Must(template.New("state").Parse("    {{.Label}} [{{.Attrs}}label=\"{{.Name}}\"]\n"))
+		transitions := template.Must(template.New("trans").Parse(
+			"    {{.Src}} -> {{.Dest}} [{{.Attrs}}label=\"{{ .Label}}\"]\n"))
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			buf.WriteString("digraph {\n")
+			for _, state := range sortedStates(lts) {
+				var attrs string
+				if state == initial {
+					attrs = "peripheries=2,"
+				}
+				states.Execute(&buf, stateTmpl{Label: bisim[state], Attrs: attrs, Name: names[bisim[state]]})
+			}
+			buf.WriteRune('\n')
+			for _, trans := range lts.Transitions {
+				transitions.Execute(&buf, transTmpl{
+					Src:   bisim[trans.Source],
+					Dest:  bisim[trans.Destination],
+					Attrs: edgeAttrs(trans.Label),
+					Label: trans.Label.PrettyPrintGraph(),
+				})
+			}
+			buf.WriteString("}\n")
+		}
+	})
+}
+
+// TestMaxGraphNodes checks that the coloured LTSs of left and its renamed
+// copy, of 8 states, give way to their quotient graph above -max-graph-nodes.
+func TestMaxGraphNodes(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")
+	for _, test := range []struct {
+		max      string
+		quotient bool
+	}{
+		{"7", true},
+		{"8", false},
+		{"0", false},
+	} {
+		dir := t.TempDir()
+		out := filepath.Join(dir, "out")
+		_, stderr, code := runPisim(t, dir, "-max-graph-nodes", test.max, left, right, out)
+		if code != 0 {
+			t.Fatalf("-max-graph-nodes %s: status %d, want 0: %s", test.max, code, stderr)
+		}
+		noted := strings.Contains(stderr, "note: an LTS has 8 states, more than -max-graph-nodes "+test.max+
+			": writing the quotient graph to "+out+"-quotient.dot instead")
+		if noted != test.quotient {
+			t.Errorf("-max-graph-nodes %s: noted %v, want %v: %s", test.max, noted, test.quotient, stderr)
+		}
+		for file, want := range map[string]bool{"-quotient.dot": test.quotient, "-left.dot": !test.quotient,
+			"-right.dot": !test.quotient} {
+			if _, err := os.Stat(out + file); (err == nil) != want {
+				t.Errorf("-max-graph-nodes %s: %s written %v, want %v", test.max, file, err == nil, want)
+			}
+		}
+		if !test.quotient {
+			continue
+		}
+		quotient := filepath.Join(dir, "quotient.dot")
+		if _, stderr, code := runPisim(t, dir, "-quiet", "-quotient-dot", quotient, left, right); code != 0 {
+			t.Fatalf("-quotient-dot: status %d, want 0: %s", code, stderr)
+		}
+		compareFiles(t, out+"-quotient.dot", quotient)
+	}
+}
+
+// TestNoTransitions compares LTSs without transitions, whose states are all
+// deadlocked, with each other and with LTSs that move.
+func TestNoTransitions(t *testing.T) {
+	dir := t.TempDir()
+	dead := writeTestFile(t, dir, "dead.aut", "des (0, 0, 1)\n")
+	other := writeTestFile(t, dir, "other.aut", "des (0, 0, 1)\n")
+	tau := writeTestFile(t, dir, "tau.aut", "des (0, 1, 2)\n(0, i, 1)\n")
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	for _, test := range []struct {
+		name  string
+		right string
+		codes map[string]int
+	}{
+		{"deadlocks", other, map[string]int{"strong": 0, "weak": 0, "delay": 0, "eta": 0}},
+		{"silent move", tau, map[string]int{"strong": 1, "weak": 0, "delay": 0, "eta": 0}},
+		{"visible move", a, map[string]int{"strong": 1, "weak": 1, "delay": 1, "eta": 1}},
+	} {
+		for equivalence, want := range test.codes {
+			_, stderr, code := runPisim(t, dir, "-quiet", "-equivalence", equivalence, dead, test.right)
+			if code != want {
+				t.Errorf("%s, %s: status %d, want %d: %s", test.name, equivalence, code, want, stderr)
+			}
+			if !strings.Contains(stderr, "warning: left LTS "+dead+" has 1 state(s) but no transitions") {
+				t.Errorf("%s, %s: no warning in %q", test.name, equivalence, stderr)
+			}
+			if warned := strings.Contains(stderr, "right LTS "+test.right+" has"); warned != (test.right == other) {
+				t.Errorf("%s, %s: warned %v about the right LTS: %s", test.name, equivalence, warned, stderr)
+			}
+		}
+	}
+}
+
+// TestNonZeroInitial compares a.b numbered from 3, so that it has no state 0,
+// with a.b numbered from 2 and with b + a.0 rooted at 0, where the state after
+// a of the left side is bisimilar to the right initial state. The initial
+// states recorded must be the first states of the sides, and the coloured
+// LTSs must mark those alone.
+func TestNonZeroInitial(t *testing.T) {
+	left := shard([]int{3, 4, 5}, [3]int{3, 1, 4}, [3]int{4, 2, 5})
+	for _, test := range []struct {
+		right     pifra.Lts
+		initial   int
+		bisimilar bool
+	}{
+		{shard([]int{2, 6, 8}, [3]int{2, 1, 6}, [3]int{6, 2, 8}), 2, true},
+		{shard([]int{0, 1, 2}, [3]int{0, 2, 1}, [3]int{2, 1, 0}), 0, false},
+	} {
+		l, r := prepared(t, left, test.right)
+		part := partKS(l, r)
+		if got := part.sides[part.initial[LeftSide]].ID; got != 3 {
+			t.Errorf("left initial state %d, want 3", got)
+		}
+		if got := part.sides[part.initial[RightSide]].ID; got != test.initial {
+			t.Errorf("right initial state %d, want %d", got, test.initial)
+		}
+		if part.initialsSplit() == test.bisimilar {
+			t.Errorf("right rooted at %d: bisimilar %v, want %v", test.initial, !test.bisimilar, test.bisimilar)
+		}
+		bisim := part.classes()
+		names := classNames(bisim, l, r)
+		for side, lts := range []pifra.Lts{l, r} {
+			dot := graphViz(t, bisim, names, lts, part.initial[side])
+			marked := regexp.MustCompile(`(?m)^    (\d+) \[peripheries=2,`).FindAllStringSubmatch(dot, -1)
+			if len(marked) != 1 || marked[0][1] != strconv.Itoa(bisim[part.initial[side]]) {
+				t.Errorf("right rooted at %d: %s LTS marks %v, want class %d\n%s",
+					test.initial, Side(side), marked, bisim[part.initial[side]], dot)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+var prereduce = flag.Bool("prereduce", false,
+	"under weak or delay bisimilarity, minimise each side modulo strong bisimilarity before saturating")
+
+// prereduced records the sizes of the sides after each stage of -prereduce,
+// for -stats.
+var prereduced struct {
+	done        bool
+	states      [2]int // states of the strong quotients
+	transitions [2]int // transitions of the strong quotients
+	saturated   [2]int // transitions of the saturated quotients
+}
+
+func validatePrereduce() error {
+	if !*prereduce {
+		return nil
+	}
+	if !refinements[*equivalence] {
+		return errors.New("-prereduce needs -equivalence weak or delay")
+	}
+	if *traceEvents != "" {
+		return errors.New("-prereduce cannot be combined with -trace, which follows a single refinement")
+	}
+	return nil
+}
+
+// strongQuotient minimises one side modulo strong bisimilarity. Every state
+// of the quotient is named by a member of its class, the initial state for
+// the class of initial, so that the quotients of both sides keep their states
+// apart and their initial states. classOf maps every state of lts to its class
+// in the quotient. stopped is set if the refinement stopped early, in which
+// case the quotient may merge states that are not bisimilar.
+func strongQuotient(lts pifra.Lts, initial int) (quot pifra.Lts, classOf map[int]int, stopped bool) {
+	part := partKS(lts, pifra.Lts{})
+	defer part.close()
+	bisim := part.classes()
+	ids, reps := quotientIDs(bisim, lts, initial)
+	members := make(map[int]int, len(ids))
+	for label, id := range ids {
+		members[id] = reps[label]
+	}
+	members[ids[bisim[initial]]] = initial
+	plain := projectQuotient(bisim, lts, initial)
+	quot = pifra.Lts{
+		States:          make(map[int]pifra.Configuration, len(plain.States)),
+		RegSizeReached:  make(map[int]bool, len(plain.RegSizeReached)),
+		StatesExplored:  plain.StatesExplored,
+		StatesGenerated: plain.StatesGenerated,
+	}
+	for id, conf := range plain.States {
+		quot.States[members[id]] = conf
+	}
+	for id, ok := range plain.RegSizeReached {
+		quot.RegSizeReached[members[id]] = ok
+	}
+	for _, trans := range plain.Transitions {
+		trans.Source, trans.Destination = members[trans.Source], members[trans.Destination]
+		quot.Transitions = append(quot.Transitions, trans)
+	}
+	classOf = make(map[int]int, len(lts.States))
+	for state := range lts.States {
+		classOf[state] = members[ids[bisim[state]]]
+	}
+	return quot, classOf, part.stopped
+}
+
+// expandSaturated lifts the saturated quotient sat back to the states of lts:
+// every state takes the moves of its class, each to the smallest member of
+// the destination class. The result is far smaller than the saturation of lts
+// but has the same bisimulation classes, so the diagnostics that follow the
+// refinement can work on it.
+func expandSaturated(lts, sat pifra.Lts, classOf map[int]int) pifra.Lts {
+	reps := make(map[int]int)
+	states := make([]int, 0, len(lts.States))
+	for state := range lts.States {
+		states = append(states, state)
+		if rep, ok := reps[classOf[state]]; !ok || state < rep {
+			reps[classOf[state]] = state
+		}
+	}
+	sort.Ints(states)
+	succs := successors(sat)
+	exp := lts
+	exp.Transitions = nil
+	for _, state := range states {
+		for _, trans := range succs[classOf[state]] {
+			exp.Transitions = append(exp.Transitions, pifra.Transition{
+				Source:      state,
+				Destination: reps[trans.Destination],
+				Label:       trans.Label,
+			})
+		}
+	}
+	return exp
+}
+
+// refinePrereduced decides weak or delay bisimilarity as refineSides does, but
+// saturates and refines the strong quotients of left and right rather than
+// the sides themselves. Strong bisimilarity implies both, so the classes of the
+// quotients, mapped back through the quotient maps, are those of the sides.
+func refinePrereduced(left, right pifra.Lts) (part Partition, refLeft, refRight pifra.Lts) {
+	quotLeft, leftClass, leftStopped := strongQuotient(left, initialStates[LeftSide])
+	quotRight, rightClass, rightStopped := strongQuotient(right, initialStates[RightSide])
+	satLeft, satRight := saturateSides(quotLeft, quotRight, *equivalence == "weak")
+	quotPart := partKS(satLeft, satRight)
+	defer quotPart.close()
+
+	prereduced.done = true
+	prereduced.states = [2]int{len(quotLeft.States), len(quotRight.States)}
+	prereduced.transitions = [2]int{len(quotLeft.Transitions), len(quotRight.Transitions)}
+	prereduced.saturated = [2]int{len(satLeft.Transitions), len(satRight.Transitions)}
+
+	refLeft = expandSaturated(left, satLeft, leftClass)
+	refRight = expandSaturated(right, satRight, rightClass)
+	colours := make(map[int]uint64, len(left.States)+len(right.States))
+	for _, side := range []struct {
+		lts     pifra.Lts
+		classOf map[int]int
+	}{{left, leftClass}, {right, rightClass}} {
+		for state := range side.lts.States {
+			colours[state] = uint64(quotPart.states[side.classOf[state]].id)
+		}
+	}
+	// The classes of the quotients are stable over the expanded sides, so
+	// refining from them only confirms them.
+	part = refinePartition(refLeft, refRight, "strong", colours)
+	part.stopped = part.stopped || quotPart.stopped || leftStopped || rightStopped
+	return part, refLeft, refRight
+}
+
+func printPrereduced(w io.Writer) {
+	if !prereduced.done {
+		return
+	}
+	fmt.Fprintf(w, "strong quotients: left %d states, %d transitions; right %d states, %d transitions\n",
+		prereduced.states[LeftSide], prereduced.transitions[LeftSide],
+		prereduced.states[RightSide], prereduced.transitions[RightSide])
+	fmt.Fprintf(w, "saturated quotients: left %d transitions; right %d transitions\n",
+		prereduced.saturated[LeftSide], prereduced.saturated[RightSide])
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// TestPrereduce checks on random pairs with silent moves that refining the
+// saturated strong quotients gives the verdict of the reference, and the
+// classes of refining the saturated sides themselves.
+func TestPrereduce(t *testing.T) {
+	for _, test := range []struct {
+		equivalence string
+		decide      func(left, right pifra.Lts) bool
+	}{
+		{"weak", reference.Weak},
+		{"delay", reference.Delay},
+	} {
+		t.Run(test.equivalence, func(t *testing.T) {
+			setFlag(t, "equivalence", test.equivalence)
+			r := rand.New(rand.NewSource(1))
+			var verdicts [2]int
+			for i := 0; i < 200; i++ {
+				left, right := randomSilentPair(r)
+				want := test.decide(left, right)
+				left, right = prepared(t, left, right)
+				direct, _, _ := refineSides(left, right)
+
+				setFlag(t, "prereduce", "true")
+				part, _, _ := refineSides(left, right)
+				setFlag(t, "prereduce", "false")
+				if got := !part.initialsSplit(); got != want {
+					t.Fatalf("pair %d: bisimilar = %v, reference says %v\nleft: %v\nright: %v",
+						i, got, want, left.Transitions, right.Transitions)
+				}
+				if got, want := classes(part), classes(direct); !reflect.DeepEqual(got, want) {
+					t.Fatalf("pair %d: classes %v, want %v", i, got, want)
+				}
+				if want {
+					verdicts[1]++
+				} else {
+					verdicts[0]++
+				}
+			}
+			if verdicts[0] == 0 || verdicts[1] == 0 {
+				t.Errorf("%d negative and %d positive verdicts, want both", verdicts[0], verdicts[1])
+			}
+		})
+	}
+}
+
+// TestStrongQuotientNames checks that the states of a strong quotient are
+// members of their classes, the initial state for its own class, so that the
+// quotients of both sides share no state.
+func TestStrongQuotientNames(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		left, right := randomSilentPair(r)
+		left, right = prepared(t, left, right)
+		seen := make(map[int]Side)
+		for side, lts := range []pifra.Lts{left, right} {
+			initial := initialStates[side]
+			quot, classOf, _ := strongQuotient(lts, initial)
+			if _, ok := quot.States[initial]; !ok || classOf[initial] != initial {
+				t.Fatalf("pair %d: %s quotient does not keep the initial state %d", i, Side(side), initial)
+			}
+			for state := range quot.States {
+				if _, ok := lts.States[state]; !ok || classOf[state] != state {
+					t.Fatalf("pair %d: %s quotient state %d is not a member of its class", i, Side(side), state)
+				}
+				if other, ok := seen[state]; ok {
+					t.Fatalf("pair %d: state %d in the quotients of %s and %s", i, state, other, Side(side))
+				}
+				seen[state] = Side(side)
+			}
+		}
+	}
+}
+
+func TestPrereduceFlag(t *testing.T) {
+	dir := t.TempDir()
+	// τ.a + τ.a, whose strong quotient has three states rather than five.
+	left := writeTestFile(t, dir, "left.aut",
+		"des (0, 4, 5)\n(0, i, 1)\n(1, \"1 1\", 2)\n(0, i, 3)\n(3, \"1 1\", 4)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-stats", "-equivalence", "weak", "-prereduce", left, right)
+	if code != 0 || !strings.Contains(stderr,
+		"strong quotients: left 3 states, 2 transitions; right 2 states, 1 transitions\n") {
+		t.Errorf("status %d and %q, want 0 and the sizes of the quotients", code, stderr)
+	}
+	_, stderr, code = runPisim(t, dir, "-quiet", "-prereduce", left, right)
+	if code == 0 || !strings.Contains(stderr, "-prereduce needs -equivalence weak or delay") {
+		t.Errorf("strong: status %d and %q, want -prereduce refused", code, stderr)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	runtimetrace "runtime/trace"
+)
+
+var (
+	cpuProfile = flag.String("cpuprofile", "",
+		"write a CPU profile of the comparison, after decoding, to `file`")
+	memProfile = flag.String("memprofile", "",
+		"write a heap profile at the end of the comparison to `file`")
+	execTrace = flag.String("trace", "",
+		"write an execution trace of the comparison, for go tool trace, to `file`")
+)
+
+// profiles holds the files of the running profiles, which stopProfiles
+// completes.
+var profiles struct {
+	cpu, trace *os.File
+	mem        string
+}
+
+// startProfiles starts the profiles requested by -cpuprofile, -memprofile and
+// -trace. The heap profile is only written by stopProfiles.
+func startProfiles() error {
+	for _, file := range []string{*cpuProfile, *memProfile, *execTrace} {
+		if file != "" {
+			if err := checkOutput(file); err != nil {
+				return err
+			}
+		}
+	}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err = pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		profiles.cpu = f
+	}
+	if *execTrace != "" {
+		f, err := os.Create(*execTrace)
+		if err != nil {
+			return err
+		}
+		if err = runtimetrace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		profiles.trace = f
+	}
+	profiles.mem = *memProfile
+	return nil
+}
+
+// stopProfiles stops the running profiles and writes the heap profile. It
+// may be called more than once, and reports its errors without exiting, as it
+// runs on the way out.
+func stopProfiles() {
+	if profiles.cpu != nil {
+		pprof.StopCPUProfile()
+		if err := profiles.cpu.Close(); err != nil {
+			log.Printf("-cpuprofile: %v", err)
+		}
+		profiles.cpu = nil
+	}
+	if profiles.trace != nil {
+		runtimetrace.Stop()
+		if err := profiles.trace.Close(); err != nil {
+			log.Printf("-trace: %v", err)
+		}
+		profiles.trace = nil
+	}
+	if profiles.mem != "" {
+		if err := writeHeapProfile(profiles.mem); err != nil {
+			log.Printf("-memprofile: %v", err)
+		}
+		profiles.mem = ""
+	}
+}
+
+func writeHeapProfile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	// Collect garbage first, so the profile shows the live heap.
+	runtime.GC()
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exit stops the profiles, removes the -low-mem files and exits with code.
+// It stands for os.Exit in the comparison, as os.Exit skips deferred calls.
+func exit(code int) {
+	stopProfiles()
+	removeSpilled()
+	os.Exit(code)
+}
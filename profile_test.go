@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestProfiles compares testdata/sides/left.gob with its renaming, which
+// returns, and with right.gob, which exits early with status 1, under
+// -cpuprofile, -memprofile and -trace, and checks that each run leaves three
+// complete files: the profiles gzipped, as pprof writes them, and the trace
+// with the header of the runtime.
+func TestProfiles(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for right, code := range map[string]int{"permuted.gob": 0, "right.gob": 1} {
+		dir := t.TempDir()
+		if _, stderr, got := runPisim(t, dir, "-quiet", "-cpuprofile", "cpu.pprof", "-memprofile", "mem.pprof",
+			"-trace", "trace.out", filepath.Join(sides, "left.gob"), filepath.Join(sides, right)); got != code {
+			t.Fatalf("%s: status %d, want %d: %s", right, got, code, stderr)
+		}
+		for _, name := range []string{"cpu.pprof", "mem.pprof"} {
+			f, err := os.Open(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			r, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", right, name, err)
+			}
+			data, err := ioutil.ReadAll(r)
+			if err != nil || len(data) == 0 {
+				t.Errorf("%s: %s holds %d bytes: %v", right, name, len(data), err)
+			}
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, "trace.out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("go 1.")) || len(data) < 64 {
+			t.Errorf("%s: trace of %d bytes, not one of the runtime", right, len(data))
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yungene/pisim/bisim"
+)
+
+var showProgress = flag.Bool("progress", false,
+	"report the progress of the refinement on stderr")
+
+// onProgress, if set, is called by partKS and partEta with their progress,
+// at most every 200ms and once when they finish.
+var onProgress func(bisim.Progress)
+
+// printProgress draws p on a single line of stderr.
+func printProgress(p bisim.Progress) {
+	percent := 100
+	if p.States > 0 {
+		percent = 100 * p.Blocks / p.States
+	}
+	fmt.Fprintf(os.Stderr, "\rstep %d: %d blocks of at most %d (%d%%), %d splits",
+		p.Steps, p.Blocks, p.States, percent, p.Splits)
+	if p.Done {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// withProgress records the progress reports of the refinements run by the
+// test.
+func withProgress(t *testing.T) *[]bisim.Progress {
+	oldProgress := onProgress
+	t.Cleanup(func() { onProgress = oldProgress })
+	var reports []bisim.Progress
+	onProgress = func(p bisim.Progress) { reports = append(reports, p) }
+	return &reports
+}
+
+func TestProgress(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	left := reference.Random(r, 200, 600, 3, 0.2)
+	left, right := prepared(t, left, permuted(r, left))
+
+	reports := withProgress(t)
+	part := partKS(left, right)
+	if len(*reports) == 0 {
+		t.Fatal("no progress reported")
+	}
+	last := (*reports)[len(*reports)-1]
+	if !last.Done || last.Blocks != len(part.blocks) || last.States != len(part.states) {
+		t.Errorf("last report %+v, want done with %d blocks of %d states", last, len(part.blocks), len(part.states))
+	}
+	for i, p := range (*reports)[:len(*reports)-1] {
+		if p.Done || p.Blocks > last.Blocks || i > 0 && p.Blocks < (*reports)[i-1].Blocks {
+			t.Errorf("report %d %+v is out of order before %+v", i, p, last)
+		}
+	}
+}
+
+func TestProgressFlag(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-progress", a, a)
+	if code != 0 || !strings.Contains(stderr, "\rstep ") || !strings.HasSuffix(stderr, "splits\n") {
+		t.Errorf("status %d and %q, want 0 and a progress line", code, stderr)
+	}
+}
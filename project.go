@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+// Projection applies before hiding: -project drops the transitions whose
+// label is not kept, and -hide, -observe-only and -observe then see only
+// the labels that remain. A label projected away is no move at all, while a
+// hidden one is still a silent move, so under weak bisimilarity the two can
+// give different verdicts. Silent moves are never projected away.
+var project = flag.String("project", "",
+	"drop the visible transitions whose label matches none of the comma-separated glob `patterns` "+
+		"before comparing, and before -hide")
+
+// projected counts the transitions -project dropped from each side, once it
+// has been applied.
+var projected [2]int
+
+// projectLTS returns lts without the visible transitions whose label matches
+// none of patterns, and the number dropped.
+func projectLTS(lts pifra.Lts, patterns []string) (pifra.Lts, int) {
+	kept := lts
+	kept.Transitions = make([]pifra.Transition, 0, len(lts.Transitions))
+	for _, trans := range lts.Transitions {
+		if !IsTau(trans.Label) && !matchesAny(patterns, trans.Label) {
+			continue
+		}
+		kept.Transitions = append(kept.Transitions, trans)
+	}
+	return kept, len(lts.Transitions) - len(kept.Transitions)
+}
+
+// applyProjection projects both sides onto the labels of -project, reporting
+// how many transitions were dropped.
+func applyProjection(left, right *pifra.Lts) error {
+	patterns := splitPatterns(*project)
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("-project: bad pattern %q", pattern)
+		}
+	}
+	*left, projected[LeftSide] = projectLTS(*left, patterns)
+	*right, projected[RightSide] = projectLTS(*right, patterns)
+	log.Printf("-project dropped %d transitions from the left LTS and %d from the right",
+		projected[LeftSide], projected[RightSide])
+	return nil
+}
+
+// withProjection records -project in a comment at the start of data, the
+// coloured LTS of side written as dot, or as TikZ if tikz is set.
+func withProjection(data []byte, side Side, tikz bool) []byte {
+	if *project == "" {
+		return data
+	}
+	comment := "//"
+	if tikz {
+		comment = "%"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s projected onto %s: %d transitions dropped\n",
+		comment, strings.Join(splitPatterns(*project), ", "), projected[side])
+	buf.Write(data)
+	return buf.Bytes()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProject compares a + b with a under weak bisimilarity. Projecting onto
+// a drops b, which makes them bisimilar, while hiding b leaves a silent move
+// to a deadlock that a cannot match. With both, projection comes first, so
+// -hide only sees the labels kept.
+func TestProject(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"2 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-project", "1 *"}, 0},
+		{[]string{"-hide", "2 *"}, 1},
+		{[]string{"-project", "1 *", "-hide", "2 *"}, 0},
+		{[]string{"-project", "1 *, 2 *", "-hide", "2 *"}, 1},
+	} {
+		args := append(append([]string{"-quiet", "-weak"}, test.args...), left, right)
+		if stdout, stderr, code := runPisim(t, dir, args...); code != test.code {
+			t.Errorf("%v: status %d, want %d: %s%s", args, code, test.code, stdout, stderr)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	_, stderr, code := runPisim(t, dir, "-project", "1 *", left, right, out)
+	if code != 0 || !strings.Contains(stderr, "-project dropped 1 transitions from the left LTS and 0 from the right") {
+		t.Errorf("status %d and %q, want the transitions dropped", code, stderr)
+	}
+	for side, dropped := range map[string]string{"left": "1", "right": "0"} {
+		data, err := os.ReadFile(out + "-" + side + ".dot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "// projected onto 1 *: " + dropped + " transitions dropped\ndigraph {\n"; !strings.HasPrefix(string(data), want) {
+			t.Errorf("%s LTS starts\n%.60s\nwant\n%s", side, data, want)
+		}
+	}
+
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-project", "[", left, right); code == 0 ||
+		!strings.Contains(stderr, `-project: bad pattern "["`) {
+		t.Errorf("bad pattern: status %d and %q", code, stderr)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var propFile = flag.String("prop", "",
+	"label states with the atomic propositions in the JSON `file`, relating only states with equal propositions")
+
+// PropositionPattern gives propositions to the states whose printed
+// configuration matches a glob pattern.
+type PropositionPattern struct {
+	Pattern string   `json:"pattern"`
+	Props   []string `json:"props"`
+}
+
+// Propositions labels states with atomic propositions, by state ID per side
+// and by configuration pattern. A state holds the union of the propositions
+// given to it.
+type Propositions struct {
+	Left     map[int][]string     `json:"left"`
+	Right    map[int][]string     `json:"right"`
+	Patterns []PropositionPattern `json:"patterns"`
+}
+
+// stateProps holds the sorted propositions of every state given -prop, keyed
+// by uniquified state ID.
+var stateProps map[int][]string
+
+func (p Propositions) validate(left, right pifra.Lts) error {
+	for _, side := range []struct {
+		lts   pifra.Lts
+		props map[int][]string
+		side  Side
+	}{{left, p.Left, LeftSide}, {right, p.Right, RightSide}} {
+		for state, props := range side.props {
+			if _, ok := side.lts.States[uniquified(state, side.side)]; !ok {
+				return fmt.Errorf("no %s state %d", side.side, state)
+			}
+			if err := validateProps(props); err != nil {
+				return fmt.Errorf("%s state %d: %v", side.side, state, err)
+			}
+		}
+	}
+	for _, pattern := range p.Patterns {
+		if _, err := path.Match(pattern.Pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", pattern.Pattern)
+		}
+		if err := validateProps(pattern.Props); err != nil {
+			return fmt.Errorf("pattern %q: %v", pattern.Pattern, err)
+		}
+	}
+	return nil
+}
+
+func validateProps(props []string) error {
+	for _, prop := range props {
+		if prop == "" || strings.ContainsAny(prop, ",{}\"") {
+			return fmt.Errorf("bad proposition name %q", prop)
+		}
+	}
+	return nil
+}
+
+// label returns the sorted propositions of the states of left and right.
+func (p Propositions) label(left, right pifra.Lts) map[int][]string {
+	labels := make(map[int][]string)
+	for _, side := range []struct {
+		lts   pifra.Lts
+		props map[int][]string
+		side  Side
+	}{{left, p.Left, LeftSide}, {right, p.Right, RightSide}} {
+		for state, conf := range side.lts.States {
+			set := make(map[string]bool)
+			for _, prop := range side.props[inputIDs[side.side][state]] {
+				set[prop] = true
+			}
+			if len(p.Patterns) > 0 {
+				text := prettyConfiguration(conf)
+				for _, pattern := range p.Patterns {
+					if ok, _ := path.Match(pattern.Pattern, text); ok {
+						for _, prop := range pattern.Props {
+							set[prop] = true
+						}
+					}
+				}
+			}
+			if len(set) == 0 {
+				continue
+			}
+			props := make([]string, 0, len(set))
+			for prop := range set {
+				props = append(props, prop)
+			}
+			sort.Strings(props)
+			labels[state] = props
+		}
+	}
+	return labels
+}
+
+// loadPropositions sets stateProps from the file -prop, and seeds the
+// partition so that states with different propositions start, and so stay,
+// in different blocks. A seed from -left-fingerprint is kept: states must
+// agree on both.
+func loadPropositions(left, right pifra.Lts) error {
+	if *propFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(*propFile)
+	if err != nil {
+		return err
+	}
+	var p Propositions
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("%s: %v", *propFile, err)
+	}
+	if err := p.validate(left, right); err != nil {
+		return fmt.Errorf("%s: %v", *propFile, err)
+	}
+	stateProps = p.label(left, right)
+	colours := make(map[int]uint64, len(left.States)+len(right.States))
+	var buf [8]byte
+	for _, lts := range []pifra.Lts{left, right} {
+		for state := range lts.States {
+			h := fnv.New64a()
+			if initialColours != nil {
+				binary.LittleEndian.PutUint64(buf[:], initialColours[state])
+				h.Write(buf[:])
+			}
+			h.Write([]byte(strings.Join(stateProps[state], ",")))
+			colours[state] = h.Sum64()
+		}
+	}
+	initialColours = colours
+	return nil
+}
+
+// propsText prints the propositions of state for the dot output.
+func propsText(state int) string {
+	props, ok := stateProps[state]
+	if !ok {
+		return ""
+	}
+	return "\\n{" + dotEscape(strings.Join(props, ",")) + "}"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPropositions compares left with its renamed copy, which are bisimilar
+// until propositions tell corresponding states apart.
+func TestPropositions(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")
+	for _, test := range []struct {
+		name, props string
+		code        int
+		node        string
+	}{
+		{"matching", `{"left": {"3": ["p"]}, "right": {"5": ["p"]}}`, 0, `\n{p}"]`},
+		{"one side", `{"left": {"3": ["p"]}}`, 1, ""},
+		{"pattern", `{"patterns": [{"pattern": "*", "props": ["q"]}]}`, 0, `1 [label="1\n{q}"]`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			props := writeTestFile(t, dir, test.name+".json", test.props)
+			out := filepath.Join(dir, test.name)
+			stdout, stderr, code := runPisim(t, dir, "-prop", props, left, right, out)
+			if code != test.code {
+				t.Fatalf("status %d, want %d: %s%s", code, test.code, stdout, stderr)
+			}
+			if test.node == "" {
+				return
+			}
+			dot, err := os.ReadFile(out + "-left.dot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(dot), test.node) {
+				t.Errorf("coloured LTS does not hold %s:\n%s", test.node, dot)
+			}
+		})
+	}
+}
+
+func TestPropositionErrors(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")
+	for _, test := range []struct {
+		props, want string
+	}{
+		{`{"right": {"99": ["p"]}}`, "no right state 99"},
+		{`{"left": {"0": ["p,q"]}}`, `left state 0: bad proposition name "p,q"`},
+		{`{"patterns": [{"pattern": "[", "props": ["p"]}]}`, `bad pattern "["`},
+		{`{"left": `, "props.json: "},
+	} {
+		props := writeTestFile(t, dir, "props.json", test.props)
+		_, stderr, code := runPisim(t, dir, "-quiet", "-prop", props, left, right)
+		if code == 0 || !strings.Contains(stderr, test.want) {
+			t.Errorf("%s: status %d and %q, want an error holding %q", test.props, code, stderr, test.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var (
+	quotientDot = flag.String("quotient-dot", "",
+		"write the quotient graph of the final partition to `file`")
+	quotientOut = flag.String("quotient", "",
+		"write the quotients of both LTSs to `file`, with -left and -right inserted before the extension")
+	quotientLeft = flag.String("quotient-left", "",
+		"write the quotient of the left LTS to `file`, rather than as named by -quotient")
+	quotientRight = flag.String("quotient-right", "",
+		"write the quotient of the right LTS to `file`, rather than as named by -quotient")
+	outputEncoding = flag.String("output-encoding", "gob",
+		"write the quotients as `encoding`: gob, or json, which pisim also reads")
+	summaryDot = flag.String("summary-dot", "",
+		"write a compact graph of the classes to `file`, one edge per pair of classes")
+)
+
+func validateOutputEncoding() error {
+	switch *outputEncoding {
+	case "gob", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown -output-encoding %q: expected gob or json", *outputEncoding)
+}
+
+// quotientFile returns the file the quotient of side goes to: the file given
+// for it, or else the -quotient file with the side inserted before its
+// extension, or "" if there is neither.
+func quotientFile(side Side) string {
+	if out := [2]*string{quotientLeft, quotientRight}[side]; *out != "" {
+		return *out
+	}
+	if *quotientOut == "" {
+		return ""
+	}
+	ext := filepath.Ext(*quotientOut)
+	return strings.TrimSuffix(*quotientOut, ext) + "-" + side.String() + ext
+}
+
+// writeQuotients writes the quotient of each side of part with a file to go
+// to, in the encoding of -output-encoding, unless ctx is done first.
+func writeQuotients(ctx context.Context, part Partition, left, right pifra.Lts) error {
+	for side, lts := range []pifra.Lts{left, right} {
+		name := quotientFile(Side(side))
+		if name == "" {
+			continue
+		}
+		quot := projectQuotient(part.classes(), lts, part.initial[side])
+		if err := writeEncodedLTS(ctx, name, quot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEncodedLTS writes lts to name in the encoding of -output-encoding,
+// unless ctx is done first.
+func writeEncodedLTS(ctx context.Context, name string, lts pifra.Lts) error {
+	var data []byte
+	var err error
+	if *outputEncoding == "json" {
+		data, err = encodeLTSJSON(ctx, lts)
+	} else {
+		data, err = encodeLTS(lts)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(name, data)
+}
+
+type quotientEdge struct {
+	src   int
+	dst   int
+	label pifra.Label
+}
+
+// quotientEdges lifts the transitions of the given LTSs to the classes of
+// bisim, merging duplicates and ordering them by source, destination and label.
+func quotientEdges(bisim Bisimulation, ltss ...pifra.Lts) []quotientEdge {
+	seen := make(map[quotientEdge]bool)
+	var edges []quotientEdge
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			edge := quotientEdge{
+				src:   bisim[trans.Source],
+				dst:   bisim[trans.Destination],
+				label: trans.Label,
+			}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.src != b.src {
+			return a.src < b.src
+		}
+		if a.dst != b.dst {
+			return a.dst < b.dst
+		}
+		return a.label.PrettyPrintGraph() < b.label.PrettyPrintGraph()
+	})
+	return edges
+}
+
+func quotientGraphViz(part Partition, left, right pifra.Lts) []byte {
+	var buf bytes.Buffer
+	bisim := part.classes()
+	names := classNames(bisim, left, right)
+	counts := classCounts(part, bisim)
+
+	buf.WriteString("digraph {\n")
+	for label, count := range counts {
+		var attrs string
+		if label == bisim[part.initial[LeftSide]] || label == bisim[part.initial[RightSide]] {
+			attrs += "peripheries=2,"
+		}
+		if count[LeftSide] == 0 || count[RightSide] == 0 {
+			attrs += "style=dashed,"
+		}
+		fmt.Fprintf(&buf, "    %d [%slabel=\"%s\\n%d left, %d right\"]\n",
+			label, attrs, dotEscape(names[label]), count[LeftSide], count[RightSide])
+	}
+	buf.WriteRune('\n')
+	for _, edge := range quotientEdges(bisim, left, right) {
+		fmt.Fprintf(&buf, "    %d -> %d [%slabel=\"%s\"]\n", edge.src, edge.dst,
+			edgeAttrs(edge.label), dotEscape(edge.label.PrettyPrintGraph()))
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// summaryGraphViz draws the classes of the final partition, as
+// quotientGraphViz does, but more compactly: nodes only carry the class name
+// and size, and each pair of classes gets a single edge listing its labels.
+func summaryGraphViz(part Partition, left, right pifra.Lts) []byte {
+	var buf bytes.Buffer
+	bisim := part.classes()
+	names := classNames(bisim, left, right)
+	counts := classCounts(part, bisim)
+
+	buf.WriteString("digraph {\n")
+	for label, count := range counts {
+		var attrs string
+		if label == bisim[part.initial[LeftSide]] || label == bisim[part.initial[RightSide]] {
+			attrs += "peripheries=2,"
+		}
+		fmt.Fprintf(&buf, "    %d [%slabel=\"%s (%d)\"]\n",
+			label, attrs, dotEscape(names[label]), count[LeftSide]+count[RightSide])
+	}
+	buf.WriteRune('\n')
+	edges := quotientEdges(bisim, left, right)
+	for i := 0; i < len(edges); {
+		j := i
+		var labels []string
+		for ; j < len(edges) && edges[j].src == edges[i].src && edges[j].dst == edges[i].dst; j++ {
+			labels = append(labels, dotEscape(edges[j].label.PrettyPrintGraph()))
+		}
+		fmt.Fprintf(&buf, "    %d -> %d [label=\"%s\"]\n",
+			edges[i].src, edges[i].dst, strings.Join(labels, ", "))
+		i = j
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// classCounts counts the states of each side in every class of bisim, the
+// classes of part.
+func classCounts(part Partition, bisim Bisimulation) [][2]int {
+	counts := make([][2]int, len(part.blocks))
+	for state, label := range bisim {
+		counts[label][part.sides[state].Side]++
+	}
+	return counts
+}
+
+// projectQuotient returns the quotient of one side of the comparison under
+// bisim. Its classes are numbered densely in label order, except that the class
+// of the initial state comes first, and each takes the configuration of its
+// smallest member.
+func projectQuotient(bisim Bisimulation, lts pifra.Lts, initial int) pifra.Lts {
+	ids, reps := quotientIDs(bisim, lts, initial)
+
+	quot := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(ids)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for label, rep := range reps {
+		quot.States[ids[label]] = lts.States[rep]
+	}
+	for state := range lts.States {
+		if lts.RegSizeReached[state] {
+			quot.RegSizeReached[ids[bisim[state]]] = true
+		}
+	}
+	for _, edge := range quotientEdges(bisim, lts) {
+		quot.Transitions = append(quot.Transitions, pifra.Transition{
+			Source:      ids[edge.src],
+			Destination: ids[edge.dst],
+			Label:       edge.label,
+		})
+	}
+	quot.StatesExplored = len(quot.States)
+	quot.StatesGenerated = len(quot.States)
+	return quot
+}
+
+// quotientIDs numbers the classes of bisim among the states of lts as
+// projectQuotient does, and returns their numbers and smallest members by
+// label.
+func quotientIDs(bisim Bisimulation, lts pifra.Lts, initial int) (ids, reps map[int]int) {
+	reps = make(map[int]int)
+	for state := range lts.States {
+		label := bisim[state]
+		if rep, ok := reps[label]; !ok || state < rep {
+			reps[label] = state
+		}
+	}
+	labels := make([]int, 0, len(reps))
+	for label := range reps {
+		if label != bisim[initial] {
+			labels = append(labels, label)
+		}
+	}
+	sort.Ints(labels)
+	labels = append([]int{bisim[initial]}, labels...)
+	ids = make(map[int]int, len(labels))
+	for id, label := range labels {
+		ids[label] = id
+	}
+	return ids, reps
+}
+
+// quotientBlocks returns the blocks of p numbered as classes numbers them, in
+// the order of their smallest states, and the number of every block by ID.
+func (p Partition) quotientBlocks() (blocks []Block, classOf map[int]int) {
+	firsts := make([]int, 0, len(p.blocks))
+	for _, block := range p.blocks {
+		first := -1
+		for state := range block.states {
+			if first == -1 || state < first {
+				first = state
+			}
+		}
+		firsts = append(firsts, first)
+	}
+	sort.Ints(firsts)
+	blocks = make([]Block, len(firsts))
+	classOf = make(map[int]int, len(firsts))
+	for class, first := range firsts {
+		blocks[class] = p.states[first]
+		classOf[blocks[class].id] = class
+	}
+	return blocks, classOf
+}
+
+// QuotientStates calls fn for every class of p, with the class numbers and
+// order of the -quotient-dot graph, and the members of the class in
+// increasing order. Only the members of one class are held at a time.
+func (p Partition) QuotientStates(fn func(classID int, members []int)) {
+	blocks, _ := p.quotientBlocks()
+	for class, block := range blocks {
+		fn(class, block.States())
+	}
+}
+
+// QuotientTransitions calls fn for every transition of the quotient of p,
+// once per source class, destination class and label, ordered by source,
+// destination and label as in the -quotient-dot graph. The transitions are
+// those p was refined over: under weak or delay bisimilarity, the saturated
+// ones, and under -label-equiv, one label per group, read from the refiner
+// of p. Only the transitions leaving one class are held at a time.
+func (p Partition) QuotientTransitions(fn func(src, dst int, label pifra.Label)) {
+	blocks, classOf := p.quotientBlocks()
+	texts := make([]string, len(p.actions))
+	for action, label := range p.actions {
+		texts[action] = label.PrettyPrintGraph()
+	}
+	type move struct {
+		dst, action int
+	}
+	var moves []move
+	seen := make(map[move]bool)
+	for src, block := range blocks {
+		moves = moves[:0]
+		for state := range block.states {
+			for action, label := range p.actions {
+				for _, dst := range p.destinations(state, label) {
+					m := move{classOf[dst], action}
+					if !seen[m] {
+						seen[m] = true
+						moves = append(moves, m)
+					}
+				}
+			}
+		}
+		sort.Slice(moves, func(i, j int) bool {
+			if moves[i].dst != moves[j].dst {
+				return moves[i].dst < moves[j].dst
+			}
+			return texts[moves[i].action] < texts[moves[j].action]
+		})
+		for _, m := range moves {
+			delete(seen, m)
+			fn(src, m.dst, p.actions[m.action])
+		}
+	}
+}
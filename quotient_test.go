@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// TestQuotientDotGolden checks -quotient-dot against golden files, on a pair
+// with classes of a single side, drawn dashed, and on a bisimilar pair.
+func TestQuotientDotGolden(t *testing.T) {
+	const (
+		abc = "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n"
+		aa  = "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n"
+		a   = "des (0, 1, 2)\n(0, \"1 1\", 1)\n"
+	)
+	for _, test := range []struct {
+		golden      string
+		left, right string
+		code        int
+	}{
+		{"quotient-not-bisimilar.dot", abc, aa, 1},
+		{"quotient-bisimilar.dot", aa, a, 0},
+	} {
+		dir := t.TempDir()
+		left := writeTestFile(t, dir, "left.aut", test.left)
+		right := writeTestFile(t, dir, "right.aut", test.right)
+		out := filepath.Join(dir, "quotient.dot")
+		if _, stderr, code := runPisim(t, dir, "-quiet", "-quotient-dot", out, left, right); code != test.code {
+			t.Fatalf("%s: status %d, want %d: %s", test.golden, code, test.code, stderr)
+		}
+		got, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(filepath.Join("testdata", test.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.golden, got, want)
+		}
+	}
+}
+
+// doubled returns lts, whose states are 0 to n-1, with a copy s+n of every
+// state s that moves as s does. Each transition of s and of its copy leads to
+// its destination or to the copy of it at random, so every state is strongly
+// bisimilar to its copy.
+func doubled(r *rand.Rand, lts pifra.Lts) pifra.Lts {
+	n := len(lts.States)
+	out := cloneLTS(lts)
+	out.Transitions = nil
+	for s := 0; s < n; s++ {
+		out.States[s+n] = lts.States[s]
+	}
+	for _, trans := range lts.Transitions {
+		for _, src := range []int{trans.Source, trans.Source + n} {
+			dst := trans.Destination + n*r.Intn(2)
+			out.Transitions = append(out.Transitions, pifra.Transition{Source: src, Destination: dst, Label: trans.Label})
+		}
+	}
+	return out
+}
+
+// TestProjectedQuotientsIsomorphic checks that the quotients of bisimilar
+// LTSs written by -quotient-left and -quotient-right are isomorphic: they
+// have as many states and transitions, and comparing them puts exactly one
+// state of each in every class.
+func TestProjectedQuotientsIsomorphic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left := reference.Random(r, 1+r.Intn(6), r.Intn(10), 2, 0)
+		left, right := prepared(t, left, permuted(r, doubled(r, left)))
+		part := partKS(left, right)
+		bisim := part.classes()
+		quotLeft := projectQuotient(bisim, left, part.initial[LeftSide])
+		quotRight := projectQuotient(bisim, right, part.initial[RightSide])
+		if len(quotLeft.States) != len(quotRight.States) || len(quotLeft.Transitions) != len(quotRight.Transitions) {
+			t.Fatalf("pair %d: quotients of %d states and %d transitions, and %d and %d",
+				i, len(quotLeft.States), len(quotLeft.Transitions), len(quotRight.States), len(quotRight.Transitions))
+		}
+		quotLeft, quotRight = prepared(t, quotLeft, quotRight)
+		qpart := partKS(quotLeft, quotRight)
+		for _, block := range qpart.blocks {
+			var count [2]int
+			for state := range block.states {
+				count[qpart.sides[state].Side]++
+			}
+			if count != [2]int{1, 1} {
+				t.Fatalf("pair %d: a class of the quotients holds %d left and %d right states\nleft: %v\nright: %v",
+					i, count[0], count[1], quotLeft.Transitions, quotRight.Transitions)
+			}
+		}
+	}
+}
+
+// TestSummaryDotGolden checks -summary-dot against golden files, on the
+// fixtures of testdata/sides.
+func TestSummaryDotGolden(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		golden string
+		right  string
+		code   int
+	}{
+		{"summary-not-bisimilar.dot", "right.gob", 1},
+		{"summary-bisimilar.dot", "permuted.gob", 0},
+	} {
+		dir := t.TempDir()
+		out := filepath.Join(dir, "summary.dot")
+		_, stderr, code := runPisim(t, dir, "-quiet", "-summary-dot", out,
+			filepath.Join(sides, "left.gob"), filepath.Join(sides, test.right))
+		if code != test.code {
+			t.Fatalf("%s: status %d, want %d: %s", test.golden, code, test.code, stderr)
+		}
+		got, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(filepath.Join("testdata", test.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.golden, got, want)
+		}
+	}
+}
+
+// TestQuotientCallbacks collects the classes and transitions streamed by
+// QuotientStates and QuotientTransitions on random pairs, under strong and
+// weak bisimilarity, and checks them, order included, against the classes
+// and edges of the materialized quotient drawn by -quotient-dot. A second
+// pass must stream the same again.
+func TestQuotientCallbacks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		weak := i%2 == 1
+		if weak {
+			left, right = bisim.Saturate(left, true, IsTau), bisim.Saturate(right, true, IsTau)
+		}
+		part := partKS(left, right)
+
+		stream := func() ([][]int, []quotientEdge) {
+			var classes [][]int
+			part.QuotientStates(func(class int, members []int) {
+				if class != len(classes) {
+					t.Fatalf("pair %d: class %d streamed after %d classes", i, class, len(classes))
+				}
+				classes = append(classes, append([]int(nil), members...))
+			})
+			var edges []quotientEdge
+			part.QuotientTransitions(func(src, dst int, label pifra.Label) {
+				edges = append(edges, quotientEdge{src, dst, label})
+			})
+			return classes, edges
+		}
+		classes, edges := stream()
+
+		bisim := part.classes()
+		want := make([][]int, len(classes))
+		for state, class := range bisim {
+			if class >= len(want) {
+				t.Fatalf("pair %d, weak %v: state %d in class %d of %d streamed", i, weak, state, class, len(classes))
+			}
+			want[class] = append(want[class], state)
+		}
+		for _, members := range want {
+			sort.Ints(members)
+		}
+		if !reflect.DeepEqual(classes, want) {
+			t.Fatalf("pair %d, weak %v: streamed classes %v, want %v", i, weak, classes, want)
+		}
+		if wantEdges := quotientEdges(bisim, left, right); !reflect.DeepEqual(edges, wantEdges) {
+			t.Fatalf("pair %d, weak %v: streamed transitions %v, want %v", i, weak, edges, wantEdges)
+		}
+
+		if again, againEdges := stream(); !reflect.DeepEqual(again, classes) || !reflect.DeepEqual(againEdges, edges) {
+			t.Fatalf("pair %d, weak %v: a second pass streamed %v and %v", i, weak, again, againEdges)
+		}
+	}
+}
+
+// TestQuotientJSON writes the quotients of a.0 + a.0 and a.0 as JSON, and as
+// gob, and compares them with each other, with themselves and with the
+// inputs they were drawn from.
+func TestQuotientJSON(t *testing.T) {
+	dir := t.TempDir()
+	aa := writeTestFile(t, dir, "aa.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n")
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	b := writeTestFile(t, dir, "b.aut", "des (0, 1, 2)\n(0, \"2 2\", 1)\n")
+	for _, encoding := range []string{"json", "gob"} {
+		if _, stderr, code := runPisim(t, dir, "-quiet", "-output-encoding", encoding,
+			"-quotient", "q."+encoding, aa, a); code != 0 {
+			t.Fatalf("%s: status %d: %s", encoding, code, stderr)
+		}
+	}
+	for _, side := range []string{"left", "right"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "q-"+side+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var quot struct {
+			States      []json.RawMessage `json:"states"`
+			Transitions []json.RawMessage `json:"transitions"`
+		}
+		if err := json.Unmarshal(data, &quot); err != nil {
+			t.Fatalf("%s quotient is not JSON: %v\n%s", side, err, data)
+		}
+		if len(quot.States) != 2 || len(quot.Transitions) != 1 {
+			t.Errorf("%s quotient of %d states and %d transitions, want 2 and 1",
+				side, len(quot.States), len(quot.Transitions))
+		}
+	}
+	for _, test := range []struct {
+		left, right string
+		code        int
+	}{
+		{"q-left.json", "q-left.json", 0},
+		{"q-left.json", "q-right.json", 0},
+		{"q-left.json", "q-left.gob", 0},
+		{"q-left.json", aa, 0},
+		{"q-right.json", b, 1},
+	} {
+		if _, stderr, code := runPisim(t, dir, "-quiet", test.left, test.right); code != test.code {
+			t.Errorf("%s against %s: status %d, want %d: %s", test.left, test.right, code, test.code, stderr)
+		}
+	}
+}
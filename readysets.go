@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var readySets = flag.Bool("ready-sets", false,
+	"add to every node of the dot files the set of actions its state can perform")
+
+// readySetTexts returns the ready set of every state of lts with moves, as a
+// suffix for its dot label: the printed labels of its moves, sorted and
+// without duplicates.
+func readySetTexts(lts pifra.Lts) map[int]string {
+	labels := make(map[int][]pifra.Label)
+	for _, trans := range lts.Transitions {
+		labels[trans.Source] = append(labels[trans.Source], trans.Label)
+	}
+	texts := make(map[int]string, len(labels))
+	for state, ready := range labels {
+		sort.Slice(ready, func(i, j int) bool {
+			return bisim.LabelLess(ready[i], ready[j])
+		})
+		var printed []string
+		for i, label := range ready {
+			if i == 0 || label != ready[i-1] {
+				printed = append(printed, dotEscape(label.PrettyPrintGraph()))
+			}
+		}
+		texts[state] = "\\n[" + strings.Join(printed, ", ") + "]"
+	}
+	return texts
+}
+
+// readySetText returns the ready set suffix of state under -ready-sets.
+func readySetText(texts map[int]string, state int) string {
+	if !*readySets {
+		return ""
+	}
+	if text, ok := texts[state]; ok {
+		return text
+	}
+	return "\\n[]"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pisim/internal/reference"
+)
+
+var (
+	dotNodeLabel = regexp.MustCompile(`(?m)^    (\d+) \[.*label="(.*)"\]$`)
+	dotEdgeLabel = regexp.MustCompile(`(?m)^    (\d+) -> \d+ \[.*label="(.*)"\]$`)
+)
+
+// TestReadySets draws random LTSs, each state in a class of its own, and
+// checks that the ready set on every node under -ready-sets holds exactly the
+// labels of the edges drawn out of it, once each, and that nodes carry no
+// ready set without the flag.
+func TestReadySets(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		lts := reference.Random(r, 1+r.Intn(10), r.Intn(25), 3, 0.2)
+		bisim, uniq := ownClasses(cloneLTS(lts))
+		names := classNames(bisim, uniq)
+
+		setFlag(t, "ready-sets", "false")
+		plain := graphViz(t, bisim, names, uniq, initialStates[LeftSide])
+		if strings.Contains(plain, `\n[`) {
+			t.Fatalf("LTS %d: ready sets drawn without -ready-sets\n%s", i, plain)
+		}
+
+		setFlag(t, "ready-sets", "true")
+		dot := graphViz(t, bisim, names, uniq, initialStates[LeftSide])
+		want := make(map[string]map[string]bool)
+		for _, m := range dotEdgeLabel.FindAllStringSubmatch(dot, -1) {
+			if want[m[1]] == nil {
+				want[m[1]] = make(map[string]bool)
+			}
+			want[m[1]][m[2]] = true
+		}
+		nodes := dotNodeLabel.FindAllStringSubmatch(dot, -1)
+		if len(nodes) != len(lts.States) {
+			t.Fatalf("LTS %d: %d nodes, want %d\n%s", i, len(nodes), len(lts.States), dot)
+		}
+		for _, m := range nodes {
+			at := strings.Index(m[2], `\n[`)
+			if at < 0 || !strings.HasSuffix(m[2], "]") {
+				t.Fatalf("LTS %d: node %s has no ready set\n%s", i, m[1], dot)
+			}
+			var got []string
+			if set := m[2][at+3 : len(m[2])-1]; set != "" {
+				got = strings.Split(set, ", ")
+			}
+			var labels []string
+			for label := range want[m[1]] {
+				labels = append(labels, label)
+			}
+			sorted := append([]string(nil), got...)
+			sort.Strings(sorted)
+			sort.Strings(labels)
+			if !reflect.DeepEqual(sorted, labels) || len(got) != len(labels) {
+				t.Fatalf("LTS %d: node %s ready set %q, want the labels %q of its edges\n%s",
+					i, m[1], got, labels, dot)
+			}
+		}
+	}
+}
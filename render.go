@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var renderDepth = flag.Int("render-depth", -1,
+	"draw only the classes within `k` moves of the initial class in the dot files (-1 for all)")
+
+// frontierNode stands in the dot files for the classes beyond -render-depth.
+const frontierNode = "more"
+
+// dotEscaper escapes text for a double-quoted dot string, where a quote would
+// end the string, a backslash would start an escape of its own and a raw line
+// break would be kept in the label as is.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// dotEscape escapes s for a double-quoted dot string. Every piece of text
+// that reaches a dot file goes through it; the line breaks pisim puts between
+// pieces are written as \n directly.
+func dotEscape(s string) string {
+	return dotEscaper.Replace(s)
+}
+
+// classDistances returns the distance in moves from the class of initial, the
+// initial state of lts, to each class of bisim within depth moves of it.
+func classDistances(bisim Bisimulation, lts pifra.Lts, initial, depth int) map[int]int {
+	succs := make(map[int][]int)
+	for _, trans := range lts.Transitions {
+		src := bisim[trans.Source]
+		succs[src] = append(succs[src], bisim[trans.Destination])
+	}
+	dist := make(map[int]int)
+	var queue []int
+	if _, ok := lts.States[initial]; ok {
+		dist[bisim[initial]] = 0
+		queue = append(queue, bisim[initial])
+	}
+	for i := 0; i < len(queue); i++ {
+		class := queue[i]
+		if dist[class] == depth {
+			continue
+		}
+		for _, next := range succs[class] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[class] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dist
+}
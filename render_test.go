@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+var (
+	dotNode = regexp.MustCompile(`(?m)^    (\d+) \[`)
+	dotEdge = regexp.MustCompile(`(?m)^    (\d+) -> (\w+) \[`)
+)
+
+// TestRenderDepth draws random LTSs, each state in a class of its own, with
+// -render-depth k, and checks that the nodes drawn are the states within k
+// moves of the initial one by breadth-first search, and that the frontier
+// node stands for exactly the moves out of them.
+func TestRenderDepth(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		lts := reference.Random(r, 1+r.Intn(12), r.Intn(20), 2, 0.2)
+		dist := map[int]int{0: 0}
+		for queue := []int{0}; len(queue) > 0; queue = queue[1:] {
+			for _, trans := range lts.Transitions {
+				if _, ok := dist[trans.Destination]; trans.Source == queue[0] && !ok {
+					dist[trans.Destination] = dist[queue[0]] + 1
+					queue = append(queue, trans.Destination)
+				}
+			}
+		}
+		bisim, uniq := ownClasses(cloneLTS(lts))
+		names := classNames(bisim, uniq)
+		for k := 0; k <= 4; k++ {
+			setFlag(t, "render-depth", strconv.Itoa(k))
+			dot := graphViz(t, bisim, names, uniq, initialStates[LeftSide])
+
+			var want []int
+			for state, d := range dist {
+				if d <= k {
+					want = append(want, state)
+				}
+			}
+			sort.Ints(want)
+			var got []int
+			for _, m := range dotNode.FindAllStringSubmatch(dot, -1) {
+				n, _ := strconv.Atoi(m[1])
+				got = append(got, n)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("LTS %d, depth %d: nodes %v, want %v\n%s", i, k, got, want, dot)
+			}
+
+			frontier := false
+			for _, trans := range lts.Transitions {
+				d, ok := dist[trans.Source]
+				if ok && d <= k && dist[trans.Destination] > k {
+					frontier = true
+				}
+			}
+			for _, m := range dotEdge.FindAllStringSubmatch(dot, -1) {
+				if m[2] == frontierNode {
+					continue
+				}
+				if n, _ := strconv.Atoi(m[2]); dist[n] > k {
+					t.Fatalf("LTS %d, depth %d: edge to hidden state %d\n%s", i, k, n, dot)
+				}
+			}
+			if drawn := regexp.MustCompile(`-> ` + frontierNode + ` `).MatchString(dot); drawn != frontier {
+				t.Fatalf("LTS %d, depth %d: frontier drawn %v, want %v\n%s", i, k, drawn, frontier, dot)
+			}
+		}
+	}
+}
+
+// dotStatement is a node, edge or attribute statement of a dot file: its
+// source, its destination if it is an edge, and its attributes.
+type dotStatement struct {
+	src, dst string
+	attrs    map[string]string
+}
+
+// dotStatements parses the subset of the dot language pisim writes: a digraph
+// of subgraphs and of node, edge and graph attribute statements, whose
+// attribute values are IDs or double-quoted strings. It returns the
+// statements with their quoted values unescaped, and fails on anything else,
+// such as a string left open or a line break within a string.
+func dotStatements(dot string) ([]dotStatement, error) {
+	var toks []string
+	for i := 0; i < len(dot); {
+		c := dot[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.IndexByte("{}[]=,;", c) >= 0:
+			toks = append(toks, dot[i:i+1])
+			i++
+		case strings.HasPrefix(dot[i:], "->"):
+			toks = append(toks, "->")
+			i += 2
+		case c == '"':
+			var s strings.Builder
+			s.WriteByte('"')
+			for i++; ; i++ {
+				if i == len(dot) || dot[i] == '\n' || dot[i] == '\r' {
+					return nil, fmt.Errorf("string %q left open", s.String())
+				}
+				if dot[i] == '"' {
+					break
+				}
+				if dot[i] == '\\' && i+1 < len(dot) {
+					i++
+					switch dot[i] {
+					case 'n':
+						s.WriteByte('\n')
+					case '"', '\\':
+						s.WriteByte(dot[i])
+					default:
+						s.WriteByte('\\')
+						s.WriteByte(dot[i])
+					}
+					continue
+				}
+				s.WriteByte(dot[i])
+			}
+			toks = append(toks, s.String())
+			i++
+		case c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(dot) && (dot[j] == '_' || dot[j] == '.' || dot[j] >= '0' && dot[j] <= '9' ||
+				dot[j] >= 'a' && dot[j] <= 'z' || dot[j] >= 'A' && dot[j] <= 'Z') {
+				j++
+			}
+			toks = append(toks, dot[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("stray %q at offset %d", c, i)
+		}
+	}
+
+	isID := func(tok string) bool { return tok != "" && strings.IndexByte("{}[]=,;->\"", tok[0]) < 0 }
+	pos := 0
+	next := func() string {
+		if pos == len(toks) {
+			return ""
+		}
+		pos++
+		return toks[pos-1]
+	}
+	if next() != "digraph" || next() != "{" {
+		return nil, errors.New("no digraph")
+	}
+	var stmts []dotStatement
+	for depth := 1; depth > 0; {
+		tok := next()
+		switch {
+		case tok == "}":
+			depth--
+			continue
+		case tok == ";":
+			continue
+		case tok == "subgraph":
+			if !isID(next()) || next() != "{" {
+				return nil, errors.New("bad subgraph")
+			}
+			depth++
+			continue
+		case !isID(tok):
+			return nil, fmt.Errorf("statement starts with %q", tok)
+		}
+		stmt := dotStatement{src: tok, attrs: make(map[string]string)}
+		if pos < len(toks) && toks[pos] == "->" {
+			pos++
+			if stmt.dst = next(); !isID(stmt.dst) {
+				return nil, fmt.Errorf("edge from %s to %q", stmt.src, stmt.dst)
+			}
+		}
+		if pos < len(toks) && toks[pos] == "[" {
+			pos++
+			for {
+				key := next()
+				if key == "]" {
+					break
+				}
+				if !isID(key) || next() != "=" {
+					return nil, fmt.Errorf("bad attribute %q of %s", key, stmt.src)
+				}
+				value := next()
+				if strings.HasPrefix(value, `"`) {
+					value = value[1:]
+				} else if !isID(value) {
+					return nil, fmt.Errorf("bad value %q of %s of %s", value, key, stmt.src)
+				}
+				stmt.attrs[key] = value
+				if pos < len(toks) && toks[pos] == "," {
+					pos++
+				}
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+	if pos != len(toks) {
+		return nil, fmt.Errorf("%d tokens after the digraph", len(toks)-pos)
+	}
+	return stmts, nil
+}
+
+// hostileNames are texts that break a dot file unless escaped.
+var hostileNames = []string{
+	`say "hi"`,
+	`back\slash`,
+	`\"`,
+	`x"]; 9 -> 9 [label="`,
+	"two\nlines",
+	"crlf\r\nline",
+	"τ→λ ünïcode",
+	`trailing\`,
+	"]",
+}
+
+// TestDotEscape draws coloured LTSs with hostile class names, and render-block
+// graphs with hostile configurations, and checks that the dot files parse and
+// carry the texts intact, but for line breaks, which all become \n.
+func TestDotEscape(t *testing.T) {
+	newlines := strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	lts := shard([]int{0, 1, 2}, [3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{2, 1, 0})
+	for _, name := range hostileNames {
+		bisim, uniq := ownClasses(cloneLTS(lts))
+		names := make(map[int]string)
+		for _, class := range bisim {
+			names[class] = name + strconv.Itoa(class)
+		}
+		stmts, err := dotStatements(graphViz(t, bisim, names, uniq, initialStates[LeftSide]))
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		nodes := 0
+		for _, stmt := range stmts {
+			if stmt.dst != "" {
+				continue
+			}
+			nodes++
+			if want := newlines.Replace(name) + stmt.src; stmt.attrs["label"] != want {
+				t.Errorf("%q: node %s labelled %q, want %q", name, stmt.src, stmt.attrs["label"], want)
+			}
+		}
+		if nodes != len(lts.States) {
+			t.Errorf("%q: %d nodes, want %d", name, nodes, len(lts.States))
+		}
+
+		conf := cloneLTS(lts)
+		for state := range conf.States {
+			conf.States[state] = pifra.Configuration{
+				Process:   &pifra.ElemNil{},
+				Registers: pifra.Registers{Size: 1, Registers: map[int]string{1: name}},
+			}
+		}
+		shown := map[int]bool{0: true, 1: true, 2: true}
+		stmts, err = dotStatements(string(blockGraphViz(Bisimulation{0: 0, 1: 1, 2: 2}, conf, shown, 0)))
+		if err != nil {
+			t.Fatalf("%q: render-block: %v", name, err)
+		}
+		for _, stmt := range stmts {
+			if tooltip, ok := stmt.attrs["tooltip"]; ok && !strings.Contains(tooltip, newlines.Replace(name)) {
+				t.Errorf("%q: render-block tooltip %q", name, tooltip)
+			}
+		}
+	}
+
+	for _, bad := range []string{
+		"digraph {\n    0 [label=\"open]\n}\n",
+		"digraph {\n    0 [label=\"two\nlines\"]\n}\n",
+		"digraph {\n    0 [label=\"x\"]\"]\n}\n",
+		"digraph {\n    0 [label=\"x\"]\n",
+	} {
+		if _, err := dotStatements(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
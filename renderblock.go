@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+// savedClasses returns the classes saved for the side of saved computed from
+// the file name: the given side, or else the side whose input has the same
+// contents as name.
+func savedClasses(saved SavedBisimulation, name, side string) (map[int]int, error) {
+	sum, err := fileSHA256(name)
+	if err != nil {
+		return nil, err
+	}
+	switch side {
+	case "left", "right":
+		in, classes := saved.Left, saved.LeftClasses
+		if side == "right" {
+			in, classes = saved.Right, saved.RightClasses
+		}
+		if sum != in.SHA256 && !*force {
+			return nil, fmt.Errorf("%s differs from %s used for the saved classes (use -force to apply them anyway)",
+				name, in.Name)
+		}
+		return classes, nil
+	case "":
+		if sum == saved.Left.SHA256 {
+			return saved.LeftClasses, nil
+		}
+		if sum == saved.Right.SHA256 {
+			return saved.RightClasses, nil
+		}
+		return nil, fmt.Errorf("%s is neither input of the saved classes (use -side to pick one)", name)
+	}
+	return nil, fmt.Errorf("unknown -side %q", side)
+}
+
+// blockNeighbourhood returns the classes of bisim within radius moves of
+// block in the quotient graph of lts, following moves in either direction.
+func blockNeighbourhood(bisim Bisimulation, lts pifra.Lts, block, radius int) map[int]bool {
+	adj := make(map[int][]int)
+	for _, trans := range lts.Transitions {
+		src, dst := bisim[trans.Source], bisim[trans.Destination]
+		adj[src] = append(adj[src], dst)
+		adj[dst] = append(adj[dst], src)
+	}
+	dist := map[int]int{block: 0}
+	queue := []int{block}
+	for i := 0; i < len(queue); i++ {
+		class := queue[i]
+		if dist[class] == radius {
+			continue
+		}
+		for _, next := range adj[class] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[class] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	shown := make(map[int]bool, len(dist))
+	for class := range dist {
+		shown[class] = true
+	}
+	return shown
+}
+
+// blockGraphViz draws the states of lts in the classes shown, one cluster per
+// class, with their configurations as tooltips. Moves to and from the states
+// not shown end at the frontier node.
+func blockGraphViz(bisim Bisimulation, lts pifra.Lts, shown map[int]bool, block int) []byte {
+	var buf bytes.Buffer
+	members := make(map[int][]int)
+	for state := range lts.States {
+		if class := bisim[state]; shown[class] {
+			members[class] = append(members[class], state)
+		}
+	}
+	classes := make([]int, 0, len(members))
+	for class := range members {
+		classes = append(classes, class)
+		sort.Ints(members[class])
+	}
+	sort.Ints(classes)
+
+	buf.WriteString("digraph {\n")
+	for _, class := range classes {
+		fmt.Fprintf(&buf, "    subgraph cluster_%d {\n", class)
+		style := ""
+		if class == block {
+			style = ",style=bold"
+		}
+		fmt.Fprintf(&buf, "        graph [label=\"block %d\"%s]\n", class, style)
+		for _, state := range members[class] {
+			var attrs string
+			if lts.RegSizeReached[state] {
+				attrs += "peripheries=3,"
+			} else if state == 0 {
+				attrs += "peripheries=2,"
+			}
+			fmt.Fprintf(&buf, "        %d [%slabel=\"%d\",tooltip=\"%s\"]\n",
+				state, attrs, state, dotEscape(prettyConfiguration(lts.States[state])))
+		}
+		buf.WriteString("    }\n")
+	}
+	buf.WriteRune('\n')
+	type boundary struct {
+		state int
+		label pifra.Label
+		out   bool
+	}
+	frontier := make(map[boundary]bool)
+	for _, trans := range lts.Transitions {
+		src, dst := shown[bisim[trans.Source]], shown[bisim[trans.Destination]]
+		attrs, label := edgeAttrs(trans.Label), dotEscape(trans.Label.PrettyPrintGraph())
+		switch {
+		case src && dst:
+			fmt.Fprintf(&buf, "    %d -> %d [%slabel=\"%s\"]\n",
+				trans.Source, trans.Destination, attrs, label)
+		case src:
+			key := boundary{trans.Source, trans.Label, true}
+			if !frontier[key] {
+				frontier[key] = true
+				fmt.Fprintf(&buf, "    %d -> %s [%sstyle=dashed,label=\"%s\"]\n",
+					trans.Source, frontierNode, attrs, label)
+			}
+		case dst:
+			key := boundary{trans.Destination, trans.Label, false}
+			if !frontier[key] {
+				frontier[key] = true
+				fmt.Fprintf(&buf, "    %s -> %d [%sstyle=dashed,label=\"%s\"]\n",
+					frontierNode, trans.Destination, attrs, label)
+			}
+		}
+	}
+	if len(frontier) > 0 {
+		fmt.Fprintf(&buf, "    %s [shape=plaintext,label=\"...\"]\n", frontierNode)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func renderBlockCommand(args []string) {
+	fs := flag.NewFlagSet("render-block", flag.ExitOnError)
+	bisimFile := fs.String("bisim", "", "read the classes saved by -save-bisim from `file`")
+	ltsFile := fs.String("lts", "", "draw the states of the LTS in `file`")
+	side := fs.String("side", "", "use the classes of the `side` left or right (default: the side whose input matches -lts)")
+	block := fs.Int("block", -1, "draw the class with label `n`")
+	radius := fs.Int("radius", 1, "also draw the classes within `k` moves of the block, in either direction")
+	out := fs.String("out", "", "write the graph to `file`")
+	fs.BoolVar(force, "force", false,
+		"overwrite an existing output file, and apply classes saved for a different input")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim render-block -bisim saved.bisim -lts left.gob -block n -out file.dot\n\n"+
+			"Draws the states of one class of a saved comparison, and of the classes\n"+
+			"around it in the quotient graph, with moves leaving the drawn classes\n"+
+			"ending at a \"...\" node.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 0 || *bisimFile == "" || *ltsFile == "" || *block < 0 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *radius < 0 {
+		check(errors.New("-radius must not be negative"))
+	}
+	inputFiles = []string{*ltsFile}
+	saved, err := readBisim(*bisimFile)
+	check(err)
+	classes, err := savedClasses(saved, *ltsFile, *side)
+	check(err)
+	lts, err := decodeLTS(*ltsFile)
+	check(err)
+	bisim := make(Bisimulation, len(lts.States))
+	found := false
+	for state := range lts.States {
+		class, ok := classes[state]
+		if !ok {
+			check(fmt.Errorf("no saved class for state %d of %s", state, *ltsFile))
+		}
+		bisim[state] = class
+		found = found || class == *block
+	}
+	if !found {
+		check(fmt.Errorf("no block %d among the states of %s", *block, *ltsFile))
+	}
+	shown := blockNeighbourhood(bisim, lts, *block, *radius)
+	check(writeFile(*out, blockGraphViz(bisim, lts, shown, *block)))
+}
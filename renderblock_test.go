@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRenderBlockGolden draws the class of state 3 of left.gob, compared with
+// right.gob, and its neighbourhood against golden files in testdata/sides.
+func TestRenderBlockGolden(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := filepath.Join(sides, "left.gob")
+	saved := filepath.Join(dir, "saved.bisim")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-save-bisim", saved, left,
+		filepath.Join(sides, "right.gob")); code != 1 {
+		t.Fatalf("status %d, want 1: %s", code, stderr)
+	}
+	for _, radius := range []string{"0", "1"} {
+		out := filepath.Join(dir, "block.dot")
+		_, stderr, code := runPisim(t, dir, "render-block", "-force", "-bisim", saved, "-lts", left,
+			"-block", "6", "-radius", radius, "-out", out)
+		if code != 0 {
+			t.Fatalf("radius %s: status %d, want 0: %s", radius, code, stderr)
+		}
+		compareFiles(t, out, filepath.Join(sides, "render-block-"+radius+".golden"))
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-block", "99"}, "no block 99 among the states of " + left},
+		{[]string{"-block", "6", "-radius", "-1"}, "-radius must not be negative"},
+	} {
+		args := append([]string{"render-block", "-bisim", saved, "-lts", left, "-out", filepath.Join(dir, "x.dot")},
+			test.args...)
+		_, stderr, code := runPisim(t, dir, args...)
+		if code != 1 || !strings.Contains(stderr, test.want) {
+			t.Errorf("%v: status %d and %q, want an error holding %q", test.args, code, stderr, test.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package result defines the JSON object printed by pisim's -result-json
+// flag, for programs that consume it.
+//
+// The object carries its schema version in the "schema" field. Within a
+// version, fields are only ever added: a consumer written against version n
+// can read any later object of version n, ignoring the fields it does not
+// know. Removing or renaming a field, or changing its meaning, bumps the
+// version.
+package result
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Schema is the version of the object described by Comparison.
+const Schema = 1
+
+// Verdicts of a comparison.
+const (
+	Equivalent    = "equivalent"
+	NotEquivalent = "not equivalent"
+	// Unknown is the verdict when -anytime or -bounded stopped the
+	// refinement before it could tell the sides apart.
+	Unknown = "unknown"
+)
+
+// Side describes an input LTS of a comparison.
+type Side struct {
+	// File is the name the input was read from.
+	File        string `json:"file"`
+	States      int    `json:"states"`
+	Transitions int    `json:"transitions"`
+}
+
+// Comparison is the outcome of a comparison.
+type Comparison struct {
+	// Schema is the version of the object, Schema for the objects this
+	// package writes.
+	Schema int `json:"schema"`
+	// Version is the version of pisim that wrote the object.
+	Version string `json:"version"`
+	// Equivalence is the equivalence checked, as named by -equivalence, or
+	// "simulation" under -sim.
+	Equivalence string `json:"equivalence"`
+	// Direction is "backward" or "forward-backward" under -backward or
+	// -forward-backward, and empty for the usual forward comparison.
+	Direction string `json:"direction,omitempty"`
+	// Verdict is Equivalent, NotEquivalent or Unknown.
+	Verdict string `json:"verdict"`
+	// Equivalent is true exactly when Verdict is Equivalent: an Unknown
+	// verdict is not a positive one.
+	Equivalent bool `json:"equivalent"`
+	// Exhaustive is false if the check stopped early, as with -anytime.
+	Exhaustive bool `json:"exhaustive"`
+	Left       Side `json:"left"`
+	Right      Side `json:"right"`
+	// Classes counts the classes of the final partition, for the
+	// equivalences decided by partition refinement.
+	Classes int `json:"classes,omitempty"`
+	// Levels holds the number of blocks at each depth of a level-wise
+	// refinement, as with -levelwise: the initial partition first, then the
+	// partition after every level that split a block.
+	Levels         []int   `json:"levels,omitempty"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	// DistinguishingTrace leads from the initial states to a move one side
+	// can make and the other cannot match, for a negative verdict by
+	// partition refinement. Reason explains a negative verdict otherwise.
+	DistinguishingTrace []string `json:"distinguishing_trace,omitempty"`
+	Reason              string   `json:"reason,omitempty"`
+}
+
+// Decode reads a Comparison from r. It refuses objects of a later schema
+// version, whose fields may have changed meaning.
+func Decode(r io.Reader) (Comparison, error) {
+	var c Comparison
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return Comparison{}, err
+	}
+	if c.Schema > Schema {
+		return Comparison{}, fmt.Errorf("result schema %d is newer than the supported %d", c.Schema, Schema)
+	}
+	return c, nil
+}
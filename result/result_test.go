@@ -0,0 +1,82 @@
+package result
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeGolden(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "comparison-v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Comparison{
+		Schema:              1,
+		Version:             "0.1.0",
+		Equivalence:         "strong",
+		Direction:           "forward-backward",
+		Verdict:             NotEquivalent,
+		Exhaustive:          true,
+		Left:                Side{File: "left.gob", States: 4, Transitions: 3},
+		Right:               Side{File: "right.gob", States: 5, Transitions: 4},
+		Classes:             6,
+		Levels:              []int{1, 4, 6},
+		ElapsedSeconds:      0.25,
+		DistinguishingTrace: []string{"1 1"},
+		Reason:              "the initial states are not related",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Decode() = %+v, want %+v", c, want)
+	}
+}
+
+// TestFieldsKept checks that every field of a schema 1 object survives
+// decoding and encoding, so that no field was removed or renamed.
+func TestFieldsKept(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "comparison-v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden, got map[string]interface{}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range golden {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("field %q: got %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func TestDecodeUnknownField(t *testing.T) {
+	c, err := Decode(strings.NewReader(`{"schema": 1, "verdict": "equivalent", "equivalent": true, "later": 3}`))
+	if err != nil || c.Verdict != Equivalent || !c.Equivalent {
+		t.Errorf("Decode() = %+v, %v, want an equivalent verdict", c, err)
+	}
+}
+
+func TestDecodeNewerSchema(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"schema": 2}`)); err == nil {
+		t.Error("Decode() accepted schema 2")
+	}
+}
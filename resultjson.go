@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/result"
+)
+
+var resultJSON = flag.Bool("result-json", false,
+	"print the outcome as a single JSON object on stdout instead of the verdict")
+
+// started is when pisim started, for the elapsed time of -result-json.
+var started = time.Now()
+
+// newResult returns the result of comparing left and right, read from
+// inputs, under the flags, for a verdict telling whether they are equivalent
+// and whether the check was exhaustive.
+func newResult(inputs []string, left, right pifra.Lts, equivalent, exhaustive bool) bisim.Result {
+	equivalence := *equivalence
+	if *sim {
+		equivalence = "simulation"
+	}
+	r := bisim.Result{
+		Equivalence: equivalence,
+		Direction:   strings.TrimSpace(direction()),
+		Stats: bisim.Stats{
+			Left:  result.Side{File: inputs[0], States: len(left.States), Transitions: len(left.Transitions)},
+			Right: result.Side{File: inputs[1], States: len(right.States), Transitions: len(right.Transitions)},
+		},
+	}
+	switch {
+	case equivalent && !exhaustive:
+		r.Verdict = bisim.Unknown
+	case equivalent:
+		r.Verdict = bisim.Equivalent
+	default:
+		r.Verdict = bisim.NotEquivalent
+	}
+	return r
+}
+
+// printResult prints r on stdout, as a JSON object under -result-json, with
+// the time elapsed since pisim started.
+func printResult(r bisim.Result) {
+	if !*resultJSON {
+		fmt.Print(r)
+		return
+	}
+	r.Stats.Elapsed = time.Since(started)
+	check(encodeResult(os.Stdout, r))
+}
+
+// encodeResult writes r to w as the indented -result-json object.
+func encodeResult(w io.Writer, r bisim.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
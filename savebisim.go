@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/internal/ltsfile"
+)
+
+var saveBisim = flag.String("save-bisim", "",
+	"save the final classes to `file`, as JSON if it ends in .json and as a gob otherwise")
+
+// SavedInput identifies an input file of a saved comparison.
+type SavedInput struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// SavedBisimulation is the outcome of a comparison as saved by -save-bisim:
+// the class of every state, keyed by original state ID per side.
+type SavedBisimulation struct {
+	Version      string      `json:"version"`
+	Equivalence  string      `json:"equivalence"`
+	Bisimilar    bool        `json:"bisimilar"`
+	Left         SavedInput  `json:"left"`
+	Right        SavedInput  `json:"right"`
+	LeftClasses  map[int]int `json:"left_classes"`
+	RightClasses map[int]int `json:"right_classes"`
+}
+
+func fileSHA256(name string) (string, error) {
+	if isDir(name) {
+		return shardsSHA256(name)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func savedInput(name string) (SavedInput, error) {
+	sum, err := fileSHA256(name)
+	return SavedInput{Name: name, SHA256: sum}, err
+}
+
+func isJSON(name string) bool {
+	return filepath.Ext(name) == ".json"
+}
+
+// writeBisim saves the classes of part, computed from the files leftName and
+// rightName.
+func writeBisim(name string, part Partition, bisimilar bool, leftName, rightName string) error {
+	saved := SavedBisimulation{
+		Version:      ltsfile.Version,
+		Equivalence:  *equivalence,
+		Bisimilar:    bisimilar,
+		LeftClasses:  make(map[int]int),
+		RightClasses: make(map[int]int),
+	}
+	var err error
+	if saved.Left, err = savedInput(leftName); err != nil {
+		return err
+	}
+	if saved.Right, err = savedInput(rightName); err != nil {
+		return err
+	}
+	for state, label := range part.classes() {
+		if origin := part.sides[state]; origin.Side == LeftSide {
+			saved.LeftClasses[origin.ID] = label
+		} else {
+			saved.RightClasses[origin.ID] = label
+		}
+	}
+	var buf bytes.Buffer
+	if isJSON(name) {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(saved)
+	} else {
+		err = gob.NewEncoder(&buf).Encode(saved)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(name, buf.Bytes())
+}
+
+func readBisim(name string) (saved SavedBisimulation, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return saved, err
+	}
+	defer f.Close()
+	if isJSON(name) {
+		err = json.NewDecoder(f).Decode(&saved)
+	} else {
+		err = gob.NewDecoder(f).Decode(&saved)
+	}
+	if err != nil {
+		return saved, fmt.Errorf("%s: %v", name, err)
+	}
+	return saved, nil
+}
+
+// checkInputs refuses files whose contents differ from those the saved
+// classes were computed from, unless -force is given.
+func (saved SavedBisimulation) checkInputs(leftName, rightName string) error {
+	for _, in := range []struct {
+		saved SavedInput
+		name  string
+	}{{saved.Left, leftName}, {saved.Right, rightName}} {
+		sum, err := fileSHA256(in.name)
+		if err != nil {
+			return err
+		}
+		if sum != in.saved.SHA256 && !*force {
+			return fmt.Errorf("%s differs from %s used for the saved classes (use -force to apply them anyway)",
+				in.name, in.saved.Name)
+		}
+	}
+	return nil
+}
+
+// partition rebuilds the final partition over left and right from the saved
+// classes, with a block per class, identified by its label.
+func (saved SavedBisimulation) partition(left, right pifra.Lts) (Partition, error) {
+	part := newPartition(left, right)
+	blocks := make(map[int]Block)
+	for _, side := range []struct {
+		lts     pifra.Lts
+		classes map[int]int
+		side    Side
+	}{{left, saved.LeftClasses, LeftSide}, {right, saved.RightClasses, RightSide}} {
+		for state := range side.lts.States {
+			id := part.sides[state].ID
+			label, ok := side.classes[id]
+			if !ok {
+				return Partition{}, fmt.Errorf("no saved class for %s state %d", side.side, id)
+			}
+			block, ok := blocks[label]
+			if !ok {
+				block = Block{id: label, states: make(States), version: nextBlockVersion()}
+				blocks[label] = block
+				part.blocks.add(block)
+			}
+			block.states[state] = exists
+			part.states[state] = block
+		}
+	}
+	return part, nil
+}
+
+func applyBisimCommand(args []string) {
+	fs := flag.NewFlagSet("apply-bisim", flag.ExitOnError)
+	out := fs.String("out", "", "write the coloured LTSs to `prefix`-left.dot and prefix-right.dot")
+	fs.StringVar(quotientDot, "quotient-dot", "",
+		"write the quotient graph to `file`")
+	fs.StringVar(quotientOut, "quotient", "",
+		"write the quotients of both LTSs to `file`, with -left and -right inserted before the extension")
+	fs.StringVar(quotientLeft, "quotient-left", "",
+		"write the quotient of the left LTS to `file`, rather than as named by -quotient")
+	fs.StringVar(quotientRight, "quotient-right", "",
+		"write the quotient of the right LTS to `file`, rather than as named by -quotient")
+	fs.StringVar(outputEncoding, "output-encoding", "gob",
+		"write the quotients as `encoding`: gob, or json, which pisim also reads")
+	fs.BoolVar(force, "force", false,
+		"overwrite existing output files, and apply classes saved for different inputs")
+	fs.IntVar(maxGraphNodes, "max-graph-nodes", 5000,
+		"with -out, write prefix-quotient.dot instead when an LTS has more than `n` states (0 for unlimited)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim apply-bisim saved.bisim left.gob right.gob [outputs]")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 3 || (*out == "" && *quotientDot == "" && *quotientOut == "" &&
+		*quotientLeft == "" && *quotientRight == "") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	check(validateOutputEncoding())
+	inputFiles = args
+	saved, err := readBisim(args[0])
+	check(err)
+	check(saved.checkInputs(args[1], args[2]))
+	left, right, err := loadSides(args[1], args[2])
+	check(err)
+	part, err := saved.partition(left, right)
+	check(err)
+	ctx, stop := outputContext()
+	defer stop()
+	if *quotientDot != "" {
+		check(writeFile(*quotientDot, quotientGraphViz(part, left, right)))
+	}
+	check(writeQuotients(ctx, part, left, right))
+	if *out != "" {
+		bisim := part.classes()
+		names := classNames(bisim, left, right)
+		check(writeColoured(ctx, *out, part, bisim, names, left, right, nil))
+	}
+}
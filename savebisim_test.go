@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestApplyBisim saves the classes of a comparison as a gob and as JSON, and
+// checks that apply-bisim renders the same quotient graph from them, but only
+// over the inputs they were saved for unless forced.
+func TestApplyBisim(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob")
+	for _, saved := range []string{"classes.bisim", "classes.json"} {
+		t.Run(saved, func(t *testing.T) {
+			dir := t.TempDir()
+			saved := filepath.Join(dir, saved)
+			want := filepath.Join(dir, "want.dot")
+			if _, stderr, code := runPisim(t, dir, "-quiet", "-save-bisim", saved, "-quotient-dot", want,
+				left, right); code != 1 {
+				t.Fatalf("status %d, want 1: %s", code, stderr)
+			}
+			got := filepath.Join(dir, "got.dot")
+			if _, stderr, code := runPisim(t, dir, "apply-bisim", "-quotient-dot", got, saved, left, right); code != 0 {
+				t.Fatalf("apply-bisim: status %d: %s", code, stderr)
+			}
+			compareFiles(t, got, want)
+
+			// The same LTS compressed is a different file.
+			data, err := os.ReadFile(right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(data)
+			zw.Close()
+			other := writeTestFile(t, dir, "right.gob.gz", buf.String())
+			forced := filepath.Join(dir, "forced.dot")
+			_, stderr, code := runPisim(t, dir, "apply-bisim", "-quotient-dot", forced, saved, left, other)
+			if code != 1 || !strings.Contains(stderr, "differs from") {
+				t.Errorf("other input: status %d and %q, want a refusal", code, stderr)
+			}
+			if _, stderr, code := runPisim(t, dir, "apply-bisim", "-force", "-quotient-dot", forced, saved, left, other); code != 0 {
+				t.Fatalf("-force: status %d: %s", code, stderr)
+			}
+			compareFiles(t, forced, want)
+		})
+	}
+}
+
+// TestSavedPartition rebuilds the partitions of random pairs from their
+// saved classes, and checks that every class becomes a block identified by
+// its label.
+func TestSavedPartition(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		left, right := randomPair(r)
+		left, right = prepared(t, left, right)
+		part, _, _ := refineSides(left, right)
+		bisim := part.classes()
+		saved := SavedBisimulation{LeftClasses: make(map[int]int), RightClasses: make(map[int]int)}
+		for state, label := range bisim {
+			if origin := part.sides[state]; origin.Side == LeftSide {
+				saved.LeftClasses[origin.ID] = label
+			} else {
+				saved.RightClasses[origin.ID] = label
+			}
+		}
+		rebuilt, err := saved.partition(left, right)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, block := range rebuilt.Blocks() {
+			for _, state := range block.States() {
+				if bisim[state] != block.ID() {
+					t.Fatalf("pair %d: state %d of class %d in block %d", i, state, bisim[state], block.ID())
+				}
+			}
+		}
+		if got, want := classes(rebuilt), classes(part); !reflect.DeepEqual(got, want) {
+			t.Errorf("pair %d: blocks %v, want %v", i, got, want)
+		}
+	}
+}
+
+func compareFiles(t *testing.T, got, want string) {
+	t.Helper()
+	g, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(g, w) {
+		t.Errorf("%s:\n%s\nwant\n%s", filepath.Base(got), g, w)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/yungene/pifra"
+)
+
+// seed only names classes: head constructors are not invariant under
+// bisimilarity (P|0 is bisimilar to P), so seeding the partition itself with
+// them could change verdicts.
+var seed = flag.String("seed", "",
+	"prefix class labels with a seed class: head (head constructor of the process)")
+
+var headNames = map[pifra.ElementType]string{
+	pifra.ElemTypNil:         "nil",
+	pifra.ElemTypOutput:      "out",
+	pifra.ElemTypInput:       "in",
+	pifra.ElemTypMatch:       "match",
+	pifra.ElemTypRestriction: "res",
+	pifra.ElemTypSum:         "sum",
+	pifra.ElemTypParallel:    "par",
+	pifra.ElemTypProcess:     "proc",
+}
+
+// headConstructor names the outermost constructor of the process of conf, or
+// "none" if the configuration carries no process.
+func headConstructor(conf pifra.Configuration) string {
+	elem := conf.Process
+	for elem != nil && elem.Type() == pifra.ElemTypRoot {
+		elem = elem.(*pifra.ElemRoot).Next
+	}
+	if elem == nil {
+		return "none"
+	}
+	return headNames[elem.Type()]
+}
+
+func validateSeed() error {
+	switch *seed {
+	case "", "head":
+		return nil
+	}
+	return fmt.Errorf("unknown seed %q", *seed)
+}
+
+// classNames names the classes of bisim for display. Under -seed=head, each
+// name is prefixed by the head constructor of the smallest state of its class.
+func classNames(bisim Bisimulation, ltss ...pifra.Lts) map[int]string {
+	names := make(map[int]string)
+	reps := make(map[int]int)
+	confs := make(map[int]pifra.Configuration)
+	for _, lts := range ltss {
+		for state, conf := range lts.States {
+			label, ok := bisim[state]
+			if !ok {
+				continue
+			}
+			if rep, ok := reps[label]; !ok || state < rep {
+				reps[label] = state
+				confs[label] = conf
+			}
+		}
+	}
+	for label := range reps {
+		names[label] = strconv.Itoa(label)
+		if *seed == "head" {
+			names[label] = headConstructor(confs[label]) + "-" + names[label]
+		}
+	}
+	return names
+}
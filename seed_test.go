@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestHeadConstructor(t *testing.T) {
+	for _, tt := range []struct {
+		conf pifra.Configuration
+		want string
+	}{
+		{pifra.Configuration{}, "none"},
+		{pifra.Configuration{Process: &pifra.ElemNil{}}, "nil"},
+		{pifra.Configuration{Process: &pifra.ElemParallel{}}, "par"},
+		{pifra.Configuration{Process: &pifra.ElemRoot{Next: &pifra.ElemSum{}}}, "sum"},
+		{pifra.Configuration{Process: &pifra.ElemRoot{}}, "none"},
+	} {
+		if got := headConstructor(tt.conf); got != tt.want {
+			t.Errorf("head of %#v is %q, want %q", tt.conf.Process, got, tt.want)
+		}
+	}
+}
+
+// withHeads gives every state of lts a process with a random head.
+func withHeads(r *rand.Rand, lts pifra.Lts) pifra.Lts {
+	heads := []func() pifra.Element{
+		func() pifra.Element { return &pifra.ElemNil{} },
+		func() pifra.Element { return &pifra.ElemParallel{} },
+		func() pifra.Element { return &pifra.ElemSum{} },
+	}
+	for state, conf := range lts.States {
+		conf.Process = heads[r.Intn(len(heads))]()
+		lts.States[state] = conf
+	}
+	return lts
+}
+
+// TestSeedKeepsVerdicts checks that -seed=head leaves the verdicts alone, and
+// only prefixes the class labels of the coloured LTSs, which are written for
+// bisimilar pairs.
+func TestSeedKeepsVerdicts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	label := regexp.MustCompile(`label="(\w+)-\d+`)
+	bisimilar := 0
+	for i := 0; i < 20; i++ {
+		dir := t.TempDir()
+		left, right := randomPair(r)
+		leftFile := writeTestLTS(t, dir, "left.gob", withHeads(r, left))
+		rightFile := writeTestLTS(t, dir, "right.gob", withHeads(r, right))
+		want, _, wantCode := runPisim(t, dir, leftFile, rightFile, filepath.Join(dir, "plain"))
+		got, _, code := runPisim(t, dir, "-seed", "head", leftFile, rightFile, filepath.Join(dir, "seeded"))
+		if code != wantCode || got != want {
+			t.Errorf("pair %d: -seed head gives status %d and %q, want %d and %q", i, code, got, wantCode, want)
+		}
+		if code != 0 {
+			continue
+		}
+		bisimilar++
+		data, err := os.ReadFile(filepath.Join(dir, "seeded-left.dot"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range label.FindAllStringSubmatch(string(data), -1) {
+			if m[1] != "nil" && m[1] != "par" && m[1] != "sum" {
+				t.Errorf("pair %d: class label prefixed by %q", i, m[1])
+			}
+		}
+		if m := label.FindString(string(data)); m == "" {
+			t.Errorf("pair %d: no prefixed class label in\n%s", i, data)
+		}
+	}
+	if bisimilar == 0 {
+		t.Error("no bisimilar pair, so no coloured LTS was checked")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/yungene/pifra"
+)
+
+// Under weak, delay and η-bisimilarity a silent self-loop is inert, so
+// dropping it never changes the verdict. Under strong bisimilarity every
+// self-loop is a move like any other: dropping one can make states
+// bisimilar that were not.
+var dropSelfLoops = flag.String("drop-self-loops", "",
+	"drop self-loops whose label matches one of the comma-separated glob `patterns` (* for all) "+
+		"before comparing; this changes strong verdicts")
+
+// withoutSelfLoops returns lts without the self-loops whose label matches one
+// of patterns, and the number dropped.
+func withoutSelfLoops(lts pifra.Lts, patterns []string) (pifra.Lts, int) {
+	dropped := lts
+	dropped.Transitions = make([]pifra.Transition, 0, len(lts.Transitions))
+	for _, trans := range lts.Transitions {
+		if trans.Source == trans.Destination && matchesAny(patterns, trans.Label) {
+			continue
+		}
+		dropped.Transitions = append(dropped.Transitions, trans)
+	}
+	return dropped, len(lts.Transitions) - len(dropped.Transitions)
+}
+
+func matchesAny(patterns []string, label pifra.Label) bool {
+	for _, pattern := range patterns {
+		if labelMatches(pattern, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDropSelfLoops drops the self-loops selected by -drop-self-loops from
+// both sides, reporting how many were dropped.
+func applyDropSelfLoops(left, right *pifra.Lts) error {
+	patterns := splitPatterns(*dropSelfLoops)
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("-drop-self-loops: bad pattern %q", pattern)
+		}
+	}
+	var nl, nr int
+	*left, nl = withoutSelfLoops(*left, patterns)
+	*right, nr = withoutSelfLoops(*right, patterns)
+	log.Printf("dropped %d self-loops from the left LTS and %d from the right", nl, nr)
+	return nil
+}
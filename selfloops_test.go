@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestDropSelfLoops compares a with a whose initial state also loops, by a
+// silent move or by b, under strong and weak bisimilarity.
+func TestDropSelfLoops(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	tau := writeTestFile(t, dir, "tau.aut", "des (0, 2, 2)\n(0, \"1 1\", 1)\n(0, i, 0)\n")
+	b := writeTestFile(t, dir, "b.aut", "des (0, 2, 2)\n(0, \"1 1\", 1)\n(0, \"2 2\", 0)\n")
+	for _, test := range []struct {
+		name   string
+		left   string
+		args   []string
+		code   int
+		nl, nr int
+	}{
+		{"strong silent loop", tau, nil, 1, -1, -1},
+		{"strong silent loop dropped", tau, []string{"-drop-self-loops", "*"}, 0, 1, 0},
+		{"weak silent loop", tau, []string{"-equivalence", "weak"}, 0, -1, -1},
+		{"weak silent loop dropped", tau, []string{"-equivalence", "weak", "-drop-self-loops", "*"}, 0, 1, 0},
+		{"b loop", b, nil, 1, -1, -1},
+		{"b loop dropped", b, []string{"-drop-self-loops", "2 *"}, 0, 1, 0},
+		{"b loop kept by the pattern", b, []string{"-drop-self-loops", "1 *,3 3"}, 1, 0, 0},
+	} {
+		args := append(append([]string{"-quiet"}, test.args...), test.left, a)
+		stdout, stderr, code := runPisim(t, dir, args...)
+		if code != test.code {
+			t.Errorf("%s: status %d, want %d: %s%s", test.name, code, test.code, stdout, stderr)
+		}
+		if test.nl < 0 {
+			if strings.Contains(stderr, "self-loops") {
+				t.Errorf("%s: reported dropped self-loops: %s", test.name, stderr)
+			}
+			continue
+		}
+		want := fmt.Sprintf("dropped %d self-loops from the left LTS and %d from the right", test.nl, test.nr)
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%s: %q does not report %q", test.name, stderr, want)
+		}
+	}
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-drop-self-loops", "[", tau, a); code == 0 ||
+		!strings.Contains(stderr, `-drop-self-loops: bad pattern "["`) {
+		t.Errorf("bad pattern: status %d and %q, want it refused", code, stderr)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/yungene/pifra"
+)
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// shardFiles lists the gob files of the directory dir, in lexical order.
+func shardFiles(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.gob"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: no .gob shards", dir)
+	}
+	return names, nil
+}
+
+// loadShards decodes the gob files of dir, written by a sharded pifra run, and
+// merges them into one LTS. The shards must have disjoint state IDs, and the
+// transitions of every shard must lead to states of some shard.
+func loadShards(dir string) (lts pifra.Lts, err error) {
+	names, err := shardFiles(dir)
+	if err != nil {
+		return
+	}
+	lts = pifra.Lts{
+		States:         make(map[int]pifra.Configuration),
+		RegSizeReached: make(map[int]bool),
+	}
+	owner := make(map[int]string)
+	// shardOf names the shard of every transition.
+	var shardOf []string
+	transitions := make(map[string]int)
+	for _, name := range names {
+		shard, err := decodeLTS(name)
+		if err != nil {
+			return lts, err
+		}
+		base := filepath.Base(name)
+		for id, conf := range shard.States {
+			if other, ok := owner[id]; ok {
+				return lts, fmt.Errorf("state %d is in shards %s and %s", id, other, base)
+			}
+			owner[id] = base
+			lts.States[id] = conf
+		}
+		for id, ok := range shard.RegSizeReached {
+			if ok {
+				lts.RegSizeReached[id] = true
+			}
+		}
+		for range shard.Transitions {
+			shardOf = append(shardOf, base)
+		}
+		transitions[base] = len(shard.Transitions)
+		lts.Transitions = append(lts.Transitions, shard.Transitions...)
+		lts.StatesExplored += shard.StatesExplored
+		lts.StatesGenerated += shard.StatesGenerated
+	}
+	crossing := make(map[string]int)
+	for i, trans := range lts.Transitions {
+		for _, id := range []int{trans.Source, trans.Destination} {
+			if _, ok := owner[id]; !ok {
+				return lts, fmt.Errorf("shard %s: transition %d -> %d leads to no state of any shard",
+					shardOf[i], trans.Source, trans.Destination)
+			}
+		}
+		if owner[trans.Destination] != shardOf[i] {
+			crossing[shardOf[i]]++
+		}
+	}
+	states := make(map[string]int)
+	for _, base := range owner {
+		states[base]++
+	}
+	for _, name := range names {
+		base := filepath.Base(name)
+		log.Printf("%s: shard %s: %d states, %d transitions, %d to other shards",
+			dir, base, states[base], transitions[base], crossing[base])
+	}
+	return lts, nil
+}
+
+// shardsSHA256 hashes the shards of dir, in the order they are merged.
+func shardsSHA256(dir string) (string, error) {
+	names, err := shardFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
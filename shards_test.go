@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// shard returns an LTS of the given states and transitions, each transition
+// a source, an input channel and a destination.
+func shard(states []int, transitions ...[3]int) pifra.Lts {
+	lts := pifra.Lts{States: make(map[int]pifra.Configuration)}
+	for _, state := range states {
+		lts.States[state] = pifra.Configuration{}
+	}
+	for _, trans := range transitions {
+		lts.Transitions = append(lts.Transitions, pifra.Transition{
+			Source:      trans[0],
+			Destination: trans[2],
+			Label:       inputLabel(trans[1], trans[1]),
+		})
+	}
+	return lts
+}
+
+func TestShards(t *testing.T) {
+	dir := t.TempDir()
+	shards := filepath.Join(dir, "shards")
+	if err := os.Mkdir(shards, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestLTS(t, shards, "a.gob", shard([]int{0, 1}, [3]int{0, 1, 1}, [3]int{1, 2, 2}))
+	writeTestLTS(t, shards, "b.gob", shard([]int{2}, [3]int{2, 1, 4}))
+	writeTestLTS(t, shards, "c.gob", shard([]int{3, 4}, [3]int{4, 3, 0}, [3]int{3, 1, 4}))
+	writeTestFile(t, shards, "notes.txt", "not a shard")
+	whole := writeTestLTS(t, dir, "whole.gob", shard([]int{0, 1, 2, 3, 4},
+		[3]int{0, 1, 1}, [3]int{1, 2, 2}, [3]int{2, 1, 4}, [3]int{4, 3, 0}, [3]int{3, 1, 4}))
+
+	lts, err := loadShards(shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lts.States) != 5 || len(lts.Transitions) != 5 {
+		t.Errorf("merged %d states and %d transitions, want 5 and 5", len(lts.States), len(lts.Transitions))
+	}
+	_, stderr, code := runPisim(t, dir, "-quiet", shards, whole)
+	if code != 0 {
+		t.Fatalf("status %d, want 0: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"shard a.gob: 2 states, 2 transitions, 1 to other shards",
+		"shard b.gob: 1 states, 1 transitions, 1 to other shards",
+		"shard c.gob: 2 states, 2 transitions, 1 to other shards",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q does not report %q", stderr, want)
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		shard pifra.Lts
+		want  string
+	}{
+		{"collision", shard([]int{1, 5}), "state 1 is in shards a.gob and d.gob"},
+		{"dangling", shard([]int{5}, [3]int{5, 1, 9}), "shard d.gob: transition 5 -> 9 leads to no state of any shard"},
+	} {
+		bad := filepath.Join(dir, test.name)
+		if err := os.Mkdir(bad, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"a.gob", "b.gob", "c.gob"} {
+			data, err := os.ReadFile(filepath.Join(shards, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, bad, name, string(data))
+		}
+		writeTestLTS(t, bad, "d.gob", test.shard)
+		if _, err := loadShards(bad); err == nil || err.Error() != test.want {
+			t.Errorf("%s: got %v, want %s", test.name, err, test.want)
+		}
+	}
+	if _, err := loadShards(t.TempDir()); err == nil || !strings.HasSuffix(err.Error(), ": no .gob shards") {
+		t.Errorf("empty directory: got %v, want no shards", err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/yungene/pifra"
+)
+
+// stopOnceDistinguished makes partKS return as soon as the initial states are
+// split apart, which settles the verdict without finishing the refinement.
+// The partition it returns is only good for that verdict.
+var stopOnceDistinguished bool
+
+// cloneLTS copies the parts of lts that prepareSide rewrites.
+func cloneLTS(lts pifra.Lts) pifra.Lts {
+	clone := lts
+	clone.States = make(map[int]pifra.Configuration, len(lts.States))
+	for id, conf := range lts.States {
+		clone.States[id] = conf
+	}
+	clone.RegSizeReached = make(map[int]bool, len(lts.RegSizeReached))
+	for id, ok := range lts.RegSizeReached {
+		clone.RegSizeReached[id] = ok
+	}
+	clone.Transitions = append([]pifra.Transition(nil), lts.Transitions...)
+	return clone
+}
+
+// distinct reports whether left and right, as decoded from their files, are
+// not equivalent.
+func distinct(left, right pifra.Lts) bool {
+	left, right = cloneLTS(left), cloneLTS(right)
+	if prepareSide(&left, false) != nil || prepareSide(&right, true) != nil {
+		return false
+	}
+	part, _, _ := refineSides(left, right)
+	defer part.close()
+	return part.bisimilar() == nil && !part.stopped
+}
+
+// withoutStates drops the states of lts not kept, and their transitions, and
+// then the states the initial state no longer reaches. The initial state is
+// never dropped.
+func withoutStates(lts pifra.Lts, states []int, keep []bool) pifra.Lts {
+	dropped := make(map[int]bool)
+	for i, state := range states {
+		if !keep[i] {
+			dropped[state] = true
+		}
+	}
+	sub := pifra.Lts{
+		States:         make(map[int]pifra.Configuration, len(lts.States)),
+		RegSizeReached: make(map[int]bool),
+	}
+	for state, conf := range lts.States {
+		if !dropped[state] {
+			sub.States[state] = conf
+			if lts.RegSizeReached[state] {
+				sub.RegSizeReached[state] = true
+			}
+		}
+	}
+	for _, trans := range lts.Transitions {
+		if !dropped[trans.Source] && !dropped[trans.Destination] {
+			sub.Transitions = append(sub.Transitions, trans)
+		}
+	}
+	pruneUnreachable(&sub, 0)
+	return sub
+}
+
+// withoutTransitions drops the transitions of lts not kept, and then the
+// states the initial state no longer reaches.
+func withoutTransitions(lts pifra.Lts, keep []bool) pifra.Lts {
+	sub := lts
+	sub.Transitions = nil
+	for i, trans := range lts.Transitions {
+		if keep[i] {
+			sub.Transitions = append(sub.Transitions, trans)
+		}
+	}
+	pruneUnreachable(&sub, 0)
+	return sub
+}
+
+// reduce removes chunks of n items for as long as fails holds of the items
+// kept, halving the chunks until single items cannot be removed either, as in
+// delta debugging. It gives up at deadline, and returns the items kept.
+func reduce(n int, fails func(keep []bool) bool, deadline time.Time) []bool {
+	keep := make([]bool, n)
+	for i := range keep {
+		keep[i] = true
+	}
+	for chunk := (n + 1) / 2; chunk > 0; chunk /= 2 {
+		for start := 0; start < n; start += chunk {
+			if time.Now().After(deadline) {
+				return keep
+			}
+			trial := append([]bool(nil), keep...)
+			removed := false
+			for i := start; i < start+chunk && i < n; i++ {
+				removed = removed || trial[i]
+				trial[i] = false
+			}
+			if removed && fails(trial) {
+				keep = trial
+			}
+		}
+	}
+	return keep
+}
+
+// shrink greedily removes states and then transitions from each side for as
+// long as left and right stay distinct, until nothing more can be removed or
+// the time budget runs out.
+func shrink(left, right pifra.Lts, budget time.Duration) (pifra.Lts, pifra.Lts) {
+	deadline := time.Now().Add(budget)
+	lts := [2]*pifra.Lts{&left, &right}
+	// fails reports whether the pair stays distinct with one side replaced.
+	fails := func(side int, sub pifra.Lts) bool {
+		pair := [2]pifra.Lts{left, right}
+		pair[side] = sub
+		return distinct(pair[0], pair[1])
+	}
+	for progress := true; progress && time.Now().Before(deadline); {
+		progress = false
+		for side := range lts {
+			before := len(lts[side].States) + len(lts[side].Transitions)
+			states := make([]int, 0, len(lts[side].States))
+			for state := range lts[side].States {
+				if state != 0 {
+					states = append(states, state)
+				}
+			}
+			sort.Ints(states)
+			keep := reduce(len(states), func(keep []bool) bool {
+				return fails(side, withoutStates(*lts[side], states, keep))
+			}, deadline)
+			*lts[side] = withoutStates(*lts[side], states, keep)
+			keep = reduce(len(lts[side].Transitions), func(keep []bool) bool {
+				return fails(side, withoutTransitions(*lts[side], keep))
+			}, deadline)
+			*lts[side] = withoutTransitions(*lts[side], keep)
+			if len(lts[side].States)+len(lts[side].Transitions) < before {
+				progress = true
+			}
+		}
+	}
+	return left, right
+}
+
+func shrinkCommand(args []string) {
+	fs := flag.NewFlagSet("shrink", flag.ExitOnError)
+	out := fs.String("out", "", "write the reduced LTSs to `prefix`-left and prefix-right")
+	format := fs.String("format", "gob", "output format: gob, dot, graphml, tikz or aut")
+	budget := fs.Duration("budget", time.Minute, "stop shrinking after `duration`, keeping the smallest pair found")
+	fs.StringVar(equivalence, "equivalence", "strong",
+		"equivalence to preserve the failure of: strong, weak, delay or eta")
+	fs.BoolVar(weak, "weak", false, "preserve the failure of weak bisimilarity (-equivalence weak)")
+	fs.BoolVar(equivariant, "equivariant", false, "compare modulo permutations of register contents")
+	fs.BoolVar(force, "force", false, "overwrite existing output files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim shrink left.gob right.gob -out prefix\n\n"+
+			"Removes states and transitions from two inequivalent LTSs for as long as\n"+
+			"they stay inequivalent, and writes the smallest pair found.")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 2 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *weak {
+		*equivalence = "weak"
+	}
+	if _, ok := refinements[*equivalence]; !ok {
+		check(errors.New("shrink needs an equivalence decided by partition refinement"))
+	}
+	inputFiles = args
+	var decoded [2]pifra.Lts
+	for i, name := range args {
+		lts, err := decodeLTS(name)
+		check(err)
+		if _, ok := lts.States[0]; !ok {
+			check(fmt.Errorf("%s: no initial state 0", name))
+		}
+		decoded[i] = lts
+	}
+	stopOnceDistinguished = true
+	if !distinct(decoded[0], decoded[1]) {
+		fmt.Println("The LTSs are equivalent; nothing to shrink")
+		os.Exit(1)
+	}
+	// Every trial would repeat the warnings of the first comparison.
+	log.SetOutput(ioutil.Discard)
+	left, right := shrink(decoded[0], decoded[1], *budget)
+	log.SetOutput(os.Stderr)
+	ctx, stop := outputContext()
+	defer stop()
+	for i, lts := range []pifra.Lts{left, right} {
+		sub, err := sliceLTS(lts, 0, -1)
+		check(err)
+		name := fmt.Sprintf("%s-%s.%s", *out, Side(i), *format)
+		check(writeFormat(ctx, name, *format, sub))
+		log.Printf("%s: %d states and %d transitions, from %d and %d",
+			name, len(sub.States), len(sub.Transitions),
+			len(decoded[i].States), len(decoded[i].Transitions))
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReduce(t *testing.T) {
+	calls := 0
+	keep := reduce(10, func(keep []bool) bool {
+		calls++
+		return keep[3] && keep[7]
+	}, time.Now().Add(time.Minute))
+	want := make([]bool, 10)
+	want[3], want[7] = true, true
+	if !reflect.DeepEqual(keep, want) {
+		t.Errorf("kept %v, want %v", keep, want)
+	}
+	if keep := reduce(10, func([]bool) bool { return true }, time.Now()); len(keep) != 10 || !keep[0] || !keep[9] {
+		t.Errorf("kept %v past the deadline, want everything", keep)
+	}
+	if calls == 0 {
+		t.Error("fails never called")
+	}
+}
+
+// TestShrink checks on random inequivalent pairs that shrinking keeps them
+// inequivalent and never makes them larger.
+func TestShrink(t *testing.T) {
+	stopOnceDistinguished = true
+	t.Cleanup(func() { stopOnceDistinguished = false })
+	r := rand.New(rand.NewSource(1))
+	shrunk := 0
+	for i := 0; i < 100; i++ {
+		left, right := randomPair(r)
+		if !distinct(left, right) {
+			continue
+		}
+		subLeft, subRight := shrink(left, right, time.Minute)
+		if !distinct(subLeft, subRight) {
+			t.Fatalf("pair %d shrunk to an equivalent pair\nleft: %v\nright: %v",
+				i, subLeft.Transitions, subRight.Transitions)
+		}
+		if len(subLeft.States) > len(left.States) || len(subRight.States) > len(right.States) ||
+			len(subLeft.Transitions) > len(left.Transitions) || len(subRight.Transitions) > len(right.Transitions) {
+			t.Fatalf("pair %d grew when shrunk", i)
+		}
+		if len(subLeft.Transitions)+len(subRight.Transitions) < len(left.Transitions)+len(right.Transitions) {
+			shrunk++
+		}
+	}
+	if shrunk == 0 {
+		t.Error("no pair shrunk")
+	}
+}
+
+func TestShrinkCommand(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob")
+	out := filepath.Join(dir, "repro")
+	if _, stderr, code := runPisim(t, dir, "shrink", "-format", "aut", "-out", out, left, right); code != 0 {
+		t.Fatalf("status %d, want 0: %s", code, stderr)
+	}
+	for side, want := range map[string]string{
+		"left":  "des (0, 0, 1)\n",
+		"right": "des (0, 1, 2)\n(0, i, 1)\n",
+	} {
+		got, err := os.ReadFile(out + "-" + side + ".aut")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", side, got, want)
+		}
+	}
+	stdout, _, code := runPisim(t, dir, "-quiet", out+"-left.aut", out+"-right.aut")
+	if code != 1 || !strings.Contains(stdout, "Not bisimilar\n") {
+		t.Errorf("shrunk pair: status %d and %q, want not bisimilar", code, stdout)
+	}
+	stdout, _, code = runPisim(t, dir, "shrink", "-out", out, left, left)
+	if code != 1 || stdout != "The LTSs are equivalent; nothing to shrink\n" {
+		t.Errorf("equivalent pair: status %d and %q, want nothing to shrink", code, stdout)
+	}
+}
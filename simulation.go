@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var (
+	sim = flag.Bool("sim", false,
+		"check the simulation preorder in both directions instead of bisimilarity")
+	simRelation = flag.String("sim-relation", "",
+		"write the largest simulations to `prefix`-left-right.txt and prefix-right-left.txt")
+)
+
+// Pair and Relation are those of package bisim. A simulation relates the
+// simulated state, as Left, to the simulating one, as Right, whatever their
+// sides.
+type (
+	Pair     = bisim.Pair
+	Relation = bisim.Relation
+)
+
+// writeRelation writes rel as a pair list: a comment line followed by one
+// line per pair of original state IDs, named on the sides sides gives them.
+func writeRelation(name, comment string, rel Relation, sides Sides) error {
+	if err := checkOutput(name); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", comment)
+	for _, p := range rel.Pairs() {
+		fmt.Fprintf(&buf, "%s %s\n", stateName(sides, p.Left), stateName(sides, p.Right))
+	}
+	return writeFile(name, buf.Bytes())
+}
+
+// successors indexes the transitions of the given LTSs by their source state.
+func successors(ltss ...pifra.Lts) map[int][]pifra.Transition {
+	succs := make(map[int][]pifra.Transition)
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			succs[trans.Source] = append(succs[trans.Source], trans)
+		}
+	}
+	return succs
+}
+
+// matches reports whether every move of s is matched by a move of t with the
+// same label into a pair of rel.
+func matches(succs map[int][]pifra.Transition, rel Relation, s, t int) bool {
+	for _, strans := range succs[s] {
+		var ok bool
+		for _, ttrans := range succs[t] {
+			if actionOf(ttrans.Label) != actionOf(strans.Label) {
+				continue
+			}
+			if _, ok = rel[Pair{Left: strans.Destination, Right: ttrans.Destination}]; ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// simulation computes the largest simulation between the states of from and
+// the states of to that is contained in the candidate pairs.
+func simulation(succs map[int][]pifra.Transition, from, to pifra.Lts,
+	candidate func(s, t int) bool) Relation {
+	rel := make(Relation)
+	for s := range from.States {
+		for t := range to.States {
+			if candidate == nil || candidate(s, t) {
+				rel[Pair{Left: s, Right: t}] = exists
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for p := range rel {
+			if !matches(succs, rel, p.Left, p.Right) {
+				delete(rel, p)
+				changed = true
+			}
+		}
+	}
+	return rel
+}
+
+// nestedSimulation checks 2-nested simulation equivalence of the initial states
+// of left and right: a simulation in each direction whose inverse is contained
+// in the simulation preorder. The reason names the failing layer and direction.
+func nestedSimulation(left, right pifra.Lts) (bool, string) {
+	succs := successors(left, right)
+	l, r := initialStates[LeftSide], initialStates[RightSide]
+	leftSim := simulation(succs, left, right, nil)
+	rightSim := simulation(succs, right, left, nil)
+	if _, ok := leftSim[Pair{Left: l, Right: r}]; !ok {
+		return false, "layer 1: left is not simulated by right"
+	}
+	if _, ok := rightSim[Pair{Left: r, Right: l}]; !ok {
+		return false, "layer 1: right is not simulated by left"
+	}
+	leftNested := simulation(succs, left, right, func(s, t int) bool {
+		_, ok := rightSim[Pair{Left: t, Right: s}]
+		return ok
+	})
+	if _, ok := leftNested[Pair{Left: l, Right: r}]; !ok {
+		return false, "layer 2: left is not 2-nested simulated by right"
+	}
+	rightNested := simulation(succs, right, left, func(s, t int) bool {
+		_, ok := leftSim[Pair{Left: t, Right: s}]
+		return ok
+	})
+	if _, ok := rightNested[Pair{Left: r, Right: l}]; !ok {
+		return false, "layer 2: right is not 2-nested simulated by left"
+	}
+	return true, ""
+}
+
+// Certificate shows that a state is not simulated by another: after Trace,
+// which both sides can perform from the initial pair, S can do Action and T
+// cannot do it at all.
+type Certificate struct {
+	Trace  []pifra.Label
+	S, T   int
+	Action pifra.Label
+}
+
+// nonSimulation searches the pairs reachable from (s, t) by common traces
+// outside rel for the nearest one where t cannot match an action of s. One
+// exists whenever (s, t) is not in the largest simulation rel.
+func nonSimulation(succs map[int][]pifra.Transition, rel Relation, s, t int) (Certificate, bool) {
+	type node struct {
+		parent Pair
+		label  pifra.Label
+	}
+	start := Pair{Left: s, Right: t}
+	queue := []Pair{start}
+	tree := map[Pair]node{start: {}}
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		for _, strans := range succs[p.Left] {
+			matched := false
+			for _, ttrans := range succs[p.Right] {
+				if ttrans.Label != strans.Label {
+					continue
+				}
+				matched = true
+				next := Pair{Left: strans.Destination, Right: ttrans.Destination}
+				if _, ok := rel[next]; ok {
+					continue
+				}
+				if _, ok := tree[next]; ok {
+					continue
+				}
+				tree[next] = node{parent: p, label: strans.Label}
+				queue = append(queue, next)
+			}
+			if !matched {
+				var trace []pifra.Label
+				for q := p; q != start; q = tree[q].parent {
+					trace = append([]pifra.Label{tree[q].label}, trace...)
+				}
+				return Certificate{trace, p.Left, p.Right, strans.Label}, true
+			}
+		}
+	}
+	return Certificate{}, false
+}
+
+// checkSimulation reports whether each side is simulated by the other, with
+// a certificate for each failing direction, and writes the largest
+// simulations if -sim-relation is set. It returns whether both directions
+// hold.
+func checkSimulation(w io.Writer, left, right pifra.Lts) (bool, error) {
+	succs := successors(left, right)
+	sides := newSides(left, right)
+	ok := true
+	for _, dir := range []struct {
+		from, to     pifra.Lts
+		s, t         int
+		sSide, tSide Side
+	}{
+		{left, right, initialStates[LeftSide], initialStates[RightSide], LeftSide, RightSide},
+		{right, left, initialStates[RightSide], initialStates[LeftSide], RightSide, LeftSide},
+	} {
+		rel := simulation(succs, dir.from, dir.to, nil)
+		if *simRelation != "" {
+			name := fmt.Sprintf("%s-%s-%s.txt", *simRelation, dir.sSide, dir.tSide)
+			comment := fmt.Sprintf("%s simulated by %s", dir.sSide, dir.tSide)
+			if err := writeRelation(name, comment, rel, sides); err != nil {
+				return false, err
+			}
+		}
+		if _, holds := rel[Pair{Left: dir.s, Right: dir.t}]; holds {
+			fmt.Fprintf(w, "%s ≤ %s\n", dir.sSide, dir.tSide)
+			continue
+		}
+		ok = false
+		cert, found := nonSimulation(succs, rel, dir.s, dir.t)
+		if !found {
+			return false, fmt.Errorf("no certificate for %s ≰ %s", dir.sSide, dir.tSide)
+		}
+		fmt.Fprintf(w, "%s ≰ %s: after %s, %s state %s can do %s but %s state %s cannot\n",
+			dir.sSide, dir.tSide, formatTrace(cert.Trace),
+			dir.sSide, stateName(sides, cert.S), actionText(cert.Action),
+			dir.tSide, stateName(sides, cert.T))
+	}
+	return ok, nil
+}
+
+func checkEquivalence(name string, left, right pifra.Lts) (bool, string, error) {
+	switch name {
+	case "nested-sim-2":
+		ok, reason := nestedSimulation(left, right)
+		return ok, reason, nil
+	case "completed-trace":
+		ok, reason := completedTraces(left, right)
+		return ok, reason, nil
+	case "possible-futures":
+		ok, reason := possibleFutures(left, right)
+		return ok, reason, nil
+	case "failures":
+		ok, reason := failures(left, right)
+		return ok, reason, nil
+	case "trace":
+		ok, reason := traceEquivalence(left, right)
+		return ok, reason, nil
+	}
+	return false, "", fmt.Errorf("unknown equivalence %q", name)
+}
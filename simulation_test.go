@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNestedSimulation separates 2-nested simulation from ready simulation.
+// With s = b.c and t = b.c + b.(c+d), a.s + a.t and a.t are ready similar
+// both ways: s and t can both do b alone. They are not 2-nested similar, as
+// the only move a.t has to match a.s leads to t, which s does not simulate.
+func TestNestedSimulation(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 9, 10)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(2, \"3 3\", 3)\n"+
+		"(0, \"1 1\", 4)\n(4, \"2 2\", 5)\n(5, \"3 3\", 6)\n(4, \"2 2\", 7)\n(7, \"3 3\", 8)\n(7, \"4 4\", 9)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 6, 7)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(2, \"3 3\", 3)\n(1, \"2 2\", 4)\n(4, \"3 3\", 5)\n(4, \"4 4\", 6)\n")
+	stdout, _, code := runPisim(t, dir, "-quiet", "-sim", left, right)
+	if code != 0 || stdout != "left ≤ right\nright ≤ left\n" {
+		t.Fatalf("-sim: status %d and %q, want both directions", code, stdout)
+	}
+	stdout, _, code = runPisim(t, dir, "-quiet", "-equivalence", "nested-sim-2", left, right)
+	if code != 1 || !strings.Contains(stdout, "layer 2: left is not 2-nested simulated by right") {
+		t.Errorf("status %d and %q, want 1 and a failure in layer 2", code, stdout)
+	}
+	stdout, _, code = runPisim(t, dir, "-quiet", "-equivalence", "nested-sim-2", right, right)
+	if code != 0 {
+		t.Errorf("right against itself: status %d and %q, want 0", code, stdout)
+	}
+}
+
+// TestSimulationWitness checks -sim on a and a.b, where only the left is
+// simulated: the right's b after a is the certificate, and the relations
+// hold every pair a state with no moves makes, with original IDs.
+func TestSimulationWitness(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n")
+	stdout, _, code := runPisim(t, dir, "-sim", "-sim-relation", filepath.Join(dir, "rel"), left, right)
+	want := "left ≤ right\n" +
+		"right ≰ left: after <1 1>, right state 1 can do 2 2 but left state 1 cannot\n"
+	if code != 1 || stdout != want {
+		t.Errorf("status %d and %q, want 1 and %q", code, stdout, want)
+	}
+	for name, want := range map[string]string{
+		"rel-left-right.txt": "# left simulated by right\n0 0\n1 0\n1 1\n1 2\n",
+		"rel-right-left.txt": "# right simulated by left\n2 0\n2 1\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s:\n%s\nwant\n%s", name, data, want)
+		}
+	}
+}
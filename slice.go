@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// parseArgs parses the flags of fs, which may be interleaved with positional
+// arguments, and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// sliceLTS extracts the sub-LTS reachable from state within depth moves, or
+// without bound if depth is negative. States are renumbered in breadth-first
+// order so that from becomes the initial state 0. States whose outgoing
+// transitions were cut by the bound are marked in RegSizeReached, like states
+// at which pifra stopped exploring.
+func sliceLTS(lts pifra.Lts, from, depth int) (pifra.Lts, error) {
+	if _, ok := lts.States[from]; !ok {
+		return pifra.Lts{}, fmt.Errorf("no state %d", from)
+	}
+	succs := successors(lts)
+	ids := map[int]int{from: 0}
+	dist := map[int]int{from: 0}
+	queue := []int{from}
+	sub := pifra.Lts{
+		States:         make(map[int]pifra.Configuration),
+		RegSizeReached: make(map[int]bool),
+	}
+	var frontier []int
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if depth >= 0 && dist[state] == depth {
+			if len(succs[state]) > 0 {
+				frontier = append(frontier, state)
+			}
+			continue
+		}
+		for _, trans := range succs[state] {
+			if _, ok := ids[trans.Destination]; !ok {
+				ids[trans.Destination] = len(ids)
+				dist[trans.Destination] = dist[state] + 1
+				queue = append(queue, trans.Destination)
+			}
+			sub.Transitions = append(sub.Transitions, pifra.Transition{
+				Source:      ids[state],
+				Destination: ids[trans.Destination],
+				Label:       trans.Label,
+			})
+		}
+	}
+	for state, id := range ids {
+		sub.States[id] = lts.States[state]
+		if lts.RegSizeReached[state] {
+			sub.RegSizeReached[id] = true
+		}
+	}
+	for _, state := range frontier {
+		sub.RegSizeReached[ids[state]] = true
+	}
+	sub.StatesExplored = len(sub.States)
+	sub.StatesGenerated = len(sub.States)
+	return sub, nil
+}
+
+// ownClasses uniquifies lts as a left LTS, and puts each state in a class of
+// its own, named by its original ID.
+func ownClasses(lts pifra.Lts) (Bisimulation, pifra.Lts) {
+	// The IDs of lts were already checked when it was loaded.
+	uniquifyLTS(&lts, false)
+	bisim := make(Bisimulation, len(lts.States))
+	for state := range lts.States {
+		bisim[state] = inputIDs[LeftSide][state]
+	}
+	return bisim, lts
+}
+
+// ltsGraphViz renders a single LTS, labelling states by their own IDs.
+func ltsGraphViz(ctx context.Context, lts pifra.Lts) ([]byte, error) {
+	bisim, lts := ownClasses(lts)
+	return bisimGraphViz(ctx, bisim, classNames(bisim, lts), lts, initialStates[LeftSide])
+}
+
+// ltsTikZ renders a single LTS as TikZ, labelling states by their own IDs.
+func ltsTikZ(lts pifra.Lts) []byte {
+	bisim, lts := ownClasses(lts)
+	return bisimTikZ(bisim, classNames(bisim, lts), lts, initialStates[LeftSide])
+}
+
+// ltsAut renders a single LTS in Aldebaran format, which pisim reads back.
+func ltsAut(ctx context.Context, lts pifra.Lts) ([]byte, error) {
+	var buf bytes.Buffer
+	err := bisim.WriteAut(ctx, &buf, lts)
+	return buf.Bytes(), err
+}
+
+// writeFormat writes lts to name in the given output format, unless ctx is
+// done first.
+func writeFormat(ctx context.Context, name, format string, lts pifra.Lts) error {
+	var render func(context.Context, pifra.Lts) ([]byte, error)
+	switch format {
+	case "gob":
+		return writeLTS(name, lts)
+	case "tikz":
+		return writeFile(name, ltsTikZ(lts))
+	case "dot":
+		render = ltsGraphViz
+	case "graphml":
+		render = ltsGraphML
+	case "aut":
+		render = ltsAut
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	data, err := render(ctx, lts)
+	if err != nil {
+		return err
+	}
+	return writeFile(name, data)
+}
+
+func sliceCommand(args []string) {
+	fs := flag.NewFlagSet("slice", flag.ExitOnError)
+	from := fs.Int("from", 0, "extract the sub-LTS reachable from `state`")
+	depth := fs.Int("depth", -1,
+		"only follow paths of at most `n` transitions (negative for unbounded)")
+	out := fs.String("out", "", "write the sub-LTS to `file`")
+	format := fs.String("format", "gob", "output format: gob, dot, graphml, tikz or aut")
+	fs.BoolVar(force, "force", false, "overwrite an existing output file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pisim slice in.gob -from state [-depth n] -out file")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	inputFiles = args
+	lts, err := decodeLTS(args[0])
+	check(err)
+	sub, err := sliceLTS(lts, *from, *depth)
+	check(err)
+	ctx, stop := outputContext()
+	defer stop()
+	check(writeFormat(ctx, *out, *format, sub))
+}
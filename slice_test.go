@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestSliceLTS(t *testing.T) {
+	a, b := inputLabel(1, 1), inputLabel(2, 2)
+	move := func(src int, label pifra.Label, dst int) pifra.Transition {
+		return pifra.Transition{Source: src, Destination: dst, Label: label}
+	}
+	// 1 moves by b to 2, which moves back to 0 by a, and by a to the
+	// deadlocked 3. 4 is not reachable from 1.
+	lts := pifra.Lts{
+		States:         map[int]pifra.Configuration{0: {}, 1: {}, 2: {}, 3: {}, 4: {}},
+		RegSizeReached: make(map[int]bool),
+		Transitions: []pifra.Transition{
+			move(0, a, 1), move(1, b, 2), move(1, a, 3), move(2, a, 0), move(4, a, 0),
+		},
+	}
+	for _, test := range []struct {
+		name      string
+		depth     int
+		states    int
+		trans     []pifra.Transition
+		truncated map[int]bool
+	}{
+		{"depth 0", 0, 1, nil, map[int]bool{0: true}},
+		{"depth 1", 1, 3, []pifra.Transition{move(0, b, 1), move(0, a, 2)}, map[int]bool{1: true}},
+		{"unbounded", -1, 4, []pifra.Transition{move(0, b, 1), move(0, a, 2), move(1, a, 3), move(3, a, 0)},
+			map[int]bool{}},
+	} {
+		sub, err := sliceLTS(lts, 1, test.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sub.States) != test.states || !reflect.DeepEqual(sub.Transitions, test.trans) ||
+			!reflect.DeepEqual(sub.RegSizeReached, test.truncated) {
+			t.Errorf("%s: %d states, %v, truncated at %v, want %d states, %v, truncated at %v", test.name,
+				len(sub.States), sub.Transitions, sub.RegSizeReached, test.states, test.trans, test.truncated)
+		}
+	}
+	if _, err := sliceLTS(lts, 7, -1); err == nil {
+		t.Error("sliceLTS sliced from a missing state")
+	}
+}
+
+// TestWriteFormatDone checks that writeFormat writes no file under a context
+// that is done, in each format that honours it.
+func TestWriteFormatDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lts := pifra.Lts{
+		States:         map[int]pifra.Configuration{0: {}},
+		RegSizeReached: make(map[int]bool),
+	}
+	for _, format := range []string{"dot", "graphml", "aut"} {
+		name := filepath.Join(t.TempDir(), "out."+format)
+		if err := writeFormat(ctx, name, format, lts); err != context.Canceled {
+			t.Errorf("%s: got %v, want %v", format, err, context.Canceled)
+		}
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s: file written under a done context", format)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import "sort"
+
+// snapshotChunk is the number of entries in a chunk of a snapshot. A snapshot
+// shares with the previous one every chunk without changed entries, so taking
+// it copies the chunk indexes and the members of the blocks that changed,
+// rather than the whole partition.
+const snapshotChunk = 256
+
+// onSnapshot, if set, is called by partKS and partEta with a snapshot of the
+// partition at the end of every round. -watch sets it.
+var onSnapshot func(round int, s *PartitionSnapshot)
+
+// blockVersion counts the blocks created, to give each its version.
+var blockVersion int
+
+func nextBlockVersion() int {
+	blockVersion++
+	return blockVersion
+}
+
+type snapshotBase struct {
+	last *PartitionSnapshot
+}
+
+type blockView struct {
+	version int
+	members []int
+}
+
+// PartitionSnapshot is a view of a partition as it was when the snapshot was
+// taken. It never changes, so any number of goroutines may read it while the
+// refinement goes on.
+type PartitionSnapshot struct {
+	// blocks holds the views of the blocks by ID, and states the block ID of
+	// every state, or -1, in chunks of snapshotChunk entries.
+	blocks [][]*blockView
+	states [][]int
+	ids    []int
+}
+
+// Snapshot returns a snapshot of p. It must be called from the goroutine
+// refining p, between two refinement steps.
+func (p Partition) Snapshot() *PartitionSnapshot {
+	var prev *PartitionSnapshot
+	if p.snapshots != nil {
+		prev = p.snapshots.last
+	}
+	s := &PartitionSnapshot{ids: make([]int, 0, len(p.blocks))}
+	if prev != nil {
+		s.blocks = append(s.blocks, prev.blocks...)
+		s.states = append(s.states, prev.states...)
+	}
+	// Chunks shared with prev are copied before their first change.
+	ownBlocks := make(map[int]bool)
+	ownStates := make(map[int]bool)
+	for id, block := range p.blocks {
+		s.ids = append(s.ids, id)
+		if v := s.view(id); v != nil && v.version == block.version {
+			continue
+		}
+		view := &blockView{version: block.version, members: block.States()}
+		s.setView(id, view, ownBlocks)
+		for _, state := range view.members {
+			s.setState(state, id, ownStates)
+		}
+	}
+	sort.Ints(s.ids)
+	if prev != nil {
+		for _, id := range prev.ids {
+			if _, ok := p.blocks[id]; !ok {
+				s.setView(id, nil, ownBlocks)
+			}
+		}
+	}
+	if p.snapshots != nil {
+		p.snapshots.last = s
+	}
+	return s
+}
+
+func (s *PartitionSnapshot) view(id int) *blockView {
+	if c := id / snapshotChunk; id >= 0 && c < len(s.blocks) && s.blocks[c] != nil {
+		return s.blocks[c][id%snapshotChunk]
+	}
+	return nil
+}
+
+func (s *PartitionSnapshot) setView(id int, view *blockView, own map[int]bool) {
+	c := id / snapshotChunk
+	for c >= len(s.blocks) {
+		s.blocks = append(s.blocks, nil)
+	}
+	if !own[c] {
+		chunk := make([]*blockView, snapshotChunk)
+		copy(chunk, s.blocks[c])
+		s.blocks[c] = chunk
+		own[c] = true
+	}
+	s.blocks[c][id%snapshotChunk] = view
+}
+
+func (s *PartitionSnapshot) setState(state, id int, own map[int]bool) {
+	c := state / snapshotChunk
+	for c >= len(s.states) {
+		s.states = append(s.states, nil)
+	}
+	if !own[c] {
+		chunk := make([]int, snapshotChunk)
+		if s.states[c] != nil {
+			copy(chunk, s.states[c])
+		} else {
+			for i := range chunk {
+				chunk[i] = -1
+			}
+		}
+		s.states[c] = chunk
+		own[c] = true
+	}
+	s.states[c][state%snapshotChunk] = id
+}
+
+// BlockIDs returns the IDs of the blocks of the snapshot in increasing order.
+// The slice is shared and must not be modified.
+func (s *PartitionSnapshot) BlockIDs() []int {
+	return s.ids
+}
+
+// Members returns the states of the block with the given ID in increasing
+// order, or nil if there is no such block. The slice is shared and must not
+// be modified.
+func (s *PartitionSnapshot) Members(id int) []int {
+	if v := s.view(id); v != nil {
+		return v.members
+	}
+	return nil
+}
+
+// BlockOf returns the ID of the block containing state, and whether state
+// belongs to the partition.
+func (s *PartitionSnapshot) BlockOf(state int) (int, bool) {
+	if c := state / snapshotChunk; state >= 0 && c < len(s.states) && s.states[c] != nil {
+		id := s.states[c][state%snapshotChunk]
+		return id, id >= 0
+	}
+	return -1, false
+}
+
+func reportSnapshot(part Partition, round int) {
+	if onSnapshot != nil {
+		onSnapshot(round, part.Snapshot())
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// checkSnapshot reports an inconsistency of s: a block whose members are not
+// sorted or not mapped to it, or a state in no block.
+func checkSnapshot(t *testing.T, s *PartitionSnapshot, states int) {
+	seen := 0
+	for _, id := range s.BlockIDs() {
+		members := s.Members(id)
+		if len(members) == 0 || !sort.IntsAreSorted(members) {
+			t.Errorf("block %d has members %v", id, members)
+			return
+		}
+		for _, state := range members {
+			if b, ok := s.BlockOf(state); !ok || b != id {
+				t.Errorf("state %d of block %d is mapped to %d, %v", state, id, b, ok)
+				return
+			}
+		}
+		seen += len(members)
+	}
+	if seen != states {
+		t.Errorf("%d states in the blocks of the snapshot, want %d", seen, states)
+	}
+}
+
+// TestSnapshotConcurrentReader reads every snapshot of a long refinement from
+// another goroutine while the refinement goes on. Run it with -race.
+func TestSnapshotConcurrentReader(t *testing.T) {
+	setFlag(t, "no-fastpath", "true")
+	left, right := prepared(t, nondeterministicChain(300), nondeterministicChain(300))
+	states := len(left.States) + len(right.States)
+	var latest atomic.Value
+	var taken int
+	onSnapshot = func(round int, s *PartitionSnapshot) {
+		latest.Store(s)
+		taken++
+	}
+	defer func() { onSnapshot = nil }()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	reads := 0
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if s, _ := latest.Load().(*PartitionSnapshot); s != nil {
+				checkSnapshot(t, s, states)
+				reads++
+			}
+		}
+	}()
+	part := partKS(left, right)
+	close(stop)
+	wg.Wait()
+	if taken < 300 || reads == 0 {
+		t.Fatalf("%d snapshots taken, %d read", taken, reads)
+	}
+	final := latest.Load().(*PartitionSnapshot)
+	checkSnapshot(t, final, states)
+	if len(final.BlockIDs()) != len(part.blocks) {
+		t.Errorf("last snapshot has %d blocks, the partition %d", len(final.BlockIDs()), len(part.blocks))
+	}
+	for state, block := range part.states {
+		if id, _ := final.BlockOf(state); id != block.id {
+			t.Errorf("state %d is in block %d, in block %d in the last snapshot", state, block.id, id)
+		}
+	}
+}
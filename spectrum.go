@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/yungene/pifra"
+)
+
+var compareStats = flag.Bool("compare-stats", false,
+	"check the LTSs against the equivalences of the linear-time/branching-time spectrum and print a table")
+
+// spectrumChains lists the equivalences -compare-stats checks, as chains from
+// the finest to the coarsest, so that each equivalence implies those after it
+// in its chain. The first chain treats silent moves like any other, and the
+// second abstracts from them. Branching bisimilarity is not implemented; eta
+// bisimilarity, between it and weak bisimilarity, stands in for it.
+var spectrumChains = []struct {
+	name         string
+	equivalences []string
+}{
+	{"concrete", []string{"strong", "nested-sim-2", "possible-futures", "failures", "completed-trace", "trace"}},
+	{"silent moves abstracted", []string{"strong", "eta", "weak"}},
+}
+
+var spectrumNames = map[string]string{
+	"strong":           "strong bisimilarity",
+	"nested-sim-2":     "2-nested simulation",
+	"possible-futures": "possible futures",
+	"failures":         "failures",
+	"completed-trace":  "completed traces",
+	"trace":            "traces",
+	"eta":              "eta bisimilarity",
+	"weak":             "weak bisimilarity",
+}
+
+func validateCompareStats() error {
+	if !*compareStats {
+		return nil
+	}
+	if *sim || labelEquiv != nil || *prereduce {
+		return errors.New("-compare-stats cannot be combined with -sim, -label-equiv or -prereduce")
+	}
+	return nil
+}
+
+// spectrumEquivalent decides one equivalence of the spectrum for left and
+// right, prepared as for compare.
+func spectrumEquivalent(name string, left, right pifra.Lts) (bool, error) {
+	if _, ok := refinements[name]; !ok {
+		ok, _, err := checkEquivalence(name, left, right)
+		return ok, err
+	}
+	saved := *equivalence
+	defer func() { *equivalence = saved }()
+	*equivalence = name
+	part, _, _ := refineSides(left, right)
+	defer part.close()
+	return part.bisimilar() != nil, nil
+}
+
+// printSpectrum checks left and right against every equivalence of
+// spectrumChains, and prints a table of the verdicts followed by, for each
+// chain, the finest equivalence that holds and the coarsest that fails.
+func printSpectrum(w io.Writer, left, right pifra.Lts) error {
+	verdicts := make(map[string]bool)
+	// The refinements would repeat the warnings about the inputs.
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, chain := range spectrumChains {
+		for _, name := range chain.equivalences {
+			if _, done := verdicts[name]; done {
+				continue
+			}
+			ok, err := spectrumEquivalent(name, left, right)
+			if err != nil {
+				return err
+			}
+			verdicts[name] = ok
+		}
+	}
+	for _, chain := range spectrumChains {
+		fmt.Fprintf(w, "%s:\n", chain.name)
+		finest, coarsest := "", ""
+		for _, name := range chain.equivalences {
+			verdict := "differ"
+			if verdicts[name] {
+				verdict = "equivalent"
+				if finest == "" {
+					finest = name
+				}
+			} else {
+				coarsest = name
+			}
+			fmt.Fprintf(w, "  %-22s %s\n", spectrumNames[name], verdict)
+		}
+		if finest != "" {
+			fmt.Fprintf(w, "  finest equivalence that holds: %s\n", spectrumNames[finest])
+		}
+		if coarsest != "" {
+			fmt.Fprintf(w, "  coarsest equivalence that fails: %s\n", spectrumNames[coarsest])
+		}
+	}
+	return nil
+}
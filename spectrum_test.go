@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCompareStats places a.b + a.c and a.(b + c) in the spectrum: they have
+// the same completed traces but not the same failures.
+func TestCompareStats(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut",
+		"des (0, 4, 5)\n(0, \"1 1\", 1)\n(1, \"2 1\", 2)\n(0, \"1 1\", 3)\n(3, \"3 1\", 4)\n")
+	right := writeTestFile(t, dir, "right.aut",
+		"des (0, 3, 4)\n(0, \"1 1\", 1)\n(1, \"2 1\", 2)\n(1, \"3 1\", 3)\n")
+	const want = `concrete:
+  strong bisimilarity    differ
+  2-nested simulation    differ
+  possible futures       differ
+  failures               differ
+  completed traces       equivalent
+  traces                 equivalent
+  finest equivalence that holds: completed traces
+  coarsest equivalence that fails: failures
+silent moves abstracted:
+  strong bisimilarity    differ
+  eta bisimilarity       differ
+  weak bisimilarity      differ
+  coarsest equivalence that fails: weak bisimilarity
+`
+	stdout, stderr, code := runPisim(t, dir, "-compare-stats", left, right)
+	if code != 0 || stdout != want {
+		t.Errorf("status %d and\n%s\nwant 0 and\n%s\n%s", code, stdout, want, stderr)
+	}
+	_, stderr, code = runPisim(t, dir, "-compare-stats", "-sim", left, right)
+	if code == 0 || !strings.Contains(stderr, "-compare-stats cannot be combined with -sim") {
+		t.Errorf("-sim: status %d and %q, want it refused", code, stderr)
+	}
+}
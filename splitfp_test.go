@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// BenchmarkFingerprints measures partKS on a random LTS against a renaming of
+// itself, with the fingerprint fast path and with -no-fingerprints, which
+// compares every pair of destination sets in full.
+func BenchmarkFingerprints(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	lts := reference.Random(r, 2000, 10000, 4, 0.1)
+	left, right := prepared(b, lts, permuted(r, lts))
+	defer func(saved bool) { *noFingerprints = saved }(*noFingerprints)
+	for _, off := range []bool{false, true} {
+		name := "fingerprints"
+		if off {
+			name = "no fingerprints"
+		}
+		b.Run(name, func(b *testing.B) {
+			*noFingerprints = off
+			for i := 0; i < b.N; i++ {
+				if partKS(left, right).initialsSplit() {
+					b.Fatal("an LTS is not bisimilar to its renaming")
+				}
+			}
+		})
+	}
+}
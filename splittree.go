@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var explainRelation = flag.Bool("explain-relation", false,
+	"print the tree of the splits by which refinement reached the final partition")
+
+// splitNode is a block of the refinement, with the blocks it was split into.
+type splitNode struct {
+	states []int
+	// reason tells what separated the block from its siblings: the action
+	// of the split, or why the blocks were apart from the start.
+	reason   string
+	children []*splitNode
+}
+
+// splitTree records the splits of the refinement under -explain-relation,
+// from the block of all states down to the current blocks, which nodes holds
+// by block ID.
+var splitTree struct {
+	root  *splitNode
+	nodes map[int]*splitNode
+}
+
+func validateExplainRelation() error {
+	if !*explainRelation {
+		return nil
+	}
+	if _, ok := refinements[*equivalence]; !ok || *sim || *compareStats {
+		return errors.New("-explain-relation needs an equivalence decided by partition refinement, " +
+			"without -sim or -compare-stats")
+	}
+	if *prereduce || *resultJSON {
+		return errors.New("-explain-relation cannot be combined with -prereduce or -result-json")
+	}
+	return nil
+}
+
+// startSplitTree starts recording the splits of part, as it is before
+// refinement, if -explain-relation is given. A partition seeded with more
+// than one block gets them as the first children of the block of all states.
+func startSplitTree(part Partition) {
+	if !*explainRelation {
+		return
+	}
+	root := &splitNode{states: make([]int, 0, len(part.states))}
+	for state := range part.states {
+		root.states = append(root.states, state)
+	}
+	sort.Ints(root.states)
+	splitTree.root = root
+	splitTree.nodes = make(map[int]*splitNode, len(part.blocks))
+	if len(part.blocks) == 1 {
+		for id := range part.blocks {
+			splitTree.nodes[id] = root
+		}
+		return
+	}
+	for id, block := range part.blocks {
+		node := &splitNode{states: block.States(), reason: "initial partition"}
+		root.children = append(root.children, node)
+		splitTree.nodes[id] = node
+	}
+	sortSplitNodes(root.children)
+}
+
+// recordSplit records that the block with ID parent was split into children
+// for reason.
+func recordSplit(parent int, reason string, children ...Block) {
+	node, ok := splitTree.nodes[parent]
+	if !ok {
+		return
+	}
+	delete(splitTree.nodes, parent)
+	for _, block := range children {
+		child := &splitNode{states: block.States(), reason: reason}
+		node.children = append(node.children, child)
+		splitTree.nodes[block.id] = child
+	}
+	sortSplitNodes(node.children)
+}
+
+// sortSplitNodes orders nodes by their smallest state.
+func sortSplitNodes(nodes []*splitNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].states[0] < nodes[j].states[0]
+	})
+}
+
+// printSplitTree prints the recorded splits as a tree indented by depth, each
+// block with its states by side and what split it off, and the final blocks
+// with their classes under bisim, the classes of the partition.
+func printSplitTree(w io.Writer, part Partition, bisim Bisimulation) {
+	if splitTree.root == nil {
+		return
+	}
+	fmt.Fprintln(w, "refinement tree:")
+	var walk func(node *splitNode, depth int)
+	walk = func(node *splitNode, depth int) {
+		var ids [2][]int
+		for _, state := range node.states {
+			origin := part.sides[state]
+			ids[origin.Side] = append(ids[origin.Side], origin.ID)
+		}
+		sort.Ints(ids[LeftSide])
+		sort.Ints(ids[RightSide])
+		var b strings.Builder
+		b.WriteString(strings.Repeat("  ", depth+1))
+		if depth == 0 {
+			b.WriteString("all states")
+		} else {
+			fmt.Fprintf(&b, "by %s", node.reason)
+		}
+		fmt.Fprintf(&b, ": left %s, right %s", decodedNames(LeftSide, ids[LeftSide]),
+			decodedNames(RightSide, ids[RightSide]))
+		if len(node.children) == 0 {
+			fmt.Fprintf(&b, " (class %d)", bisim[node.states[0]])
+		}
+		fmt.Fprintln(w, b.String())
+		for _, child := range node.children {
+			walk(child, depth+1)
+		}
+	}
+	walk(splitTree.root, 0)
+}
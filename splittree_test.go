@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// TestSplitTree refines random pairs under -explain-relation, by
+// Kanellakis-Smolka, level by level and by η-signatures, and checks that the
+// children of every node of the split tree partition its states, and that
+// the leaves are the blocks of the final partition, each printed once with
+// its class.
+func TestSplitTree(t *testing.T) {
+	t.Cleanup(func() { splitTree.root, splitTree.nodes = nil, nil })
+	for _, test := range []struct {
+		name   string
+		flag   string
+		refine func(left, right pifra.Lts) Partition
+	}{
+		{"ks", "", partKS},
+		{"levelwise", "levelwise", partKS},
+		{"eta", "", partEta},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "explain-relation", "true")
+			setFlag(t, "no-fastpath", "true")
+			if test.flag != "" {
+				setFlag(t, test.flag, "true")
+			}
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 100; i++ {
+				left, right := randomSilentPair(r)
+				left, right = prepared(t, left, right)
+				part := test.refine(left, right)
+
+				var leaves [][]int
+				var walk func(node *splitNode)
+				walk = func(node *splitNode) {
+					if len(node.children) == 0 {
+						leaves = append(leaves, node.states)
+						return
+					}
+					var union []int
+					for _, child := range node.children {
+						union = append(union, child.states...)
+						walk(child)
+					}
+					sort.Ints(union)
+					if !reflect.DeepEqual(union, node.states) {
+						t.Fatalf("pair %d: children of %v hold %v", i, node.states, union)
+					}
+				}
+				walk(splitTree.root)
+
+				var blocks [][]int
+				for _, block := range part.blocks {
+					blocks = append(blocks, block.States())
+				}
+				sortByFirst := func(sets [][]int) {
+					sort.Slice(sets, func(i, j int) bool { return sets[i][0] < sets[j][0] })
+				}
+				sortByFirst(leaves)
+				sortByFirst(blocks)
+				if !reflect.DeepEqual(leaves, blocks) {
+					t.Fatalf("pair %d: leaves %v, want the blocks %v", i, leaves, blocks)
+				}
+
+				var buf bytes.Buffer
+				printSplitTree(&buf, part, part.classes())
+				if n := strings.Count(buf.String(), " (class "); n != len(blocks) {
+					t.Fatalf("pair %d: %d classes printed, want %d:\n%s", i, n, len(blocks), buf.String())
+				}
+			}
+		})
+	}
+}
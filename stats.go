@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var showStats = flag.Bool("stats", false,
+	"print statistics about the comparison to stderr")
+
+// counters instruments the refinement loop.
+var counters struct {
+	rounds   int  // steps of the refinement, one per split, or levels
+	splits   int  // blocks actually split
+	attempts int  // blocks tried, by an action or by their signatures
+	hopcroft bool // deterministic inputs took the fast path
+}
+
+// Class is an equivalence class of the final partition, listing the original
+// IDs of its members per side.
+type Class struct {
+	Label int
+	Left  []int
+	Right []int
+}
+
+func (c Class) size() int {
+	return len(c.Left) + len(c.Right)
+}
+
+// largestClass inverts bisim and returns the class with the most members,
+// preferring the smallest label on ties.
+func largestClass(bisim Bisimulation, sides Sides) Class {
+	classes := make(map[int]*Class)
+	for state, label := range bisim {
+		c, ok := classes[label]
+		if !ok {
+			c = &Class{Label: label}
+			classes[label] = c
+		}
+		if origin := sides[state]; origin.Side == LeftSide {
+			c.Left = append(c.Left, origin.ID)
+		} else {
+			c.Right = append(c.Right, origin.ID)
+		}
+	}
+	var largest Class
+	for _, c := range classes {
+		if c.size() > largest.size() ||
+			(c.size() == largest.size() && c.Label < largest.Label) {
+			largest = *c
+		}
+	}
+	sort.Ints(largest.Left)
+	sort.Ints(largest.Right)
+	return largest
+}
+
+func printStats(w io.Writer, part Partition, left, right pifra.Lts) {
+	fmt.Fprintf(w, "left: %d states, %d transitions\n",
+		len(left.States), len(left.Transitions))
+	fmt.Fprintf(w, "right: %d states, %d transitions\n",
+		len(right.States), len(right.Transitions))
+	printPrereduced(w)
+	fmt.Fprintf(w, "classes: %d\n", len(part.blocks))
+	if part.stopped {
+		fmt.Fprintln(w, "refinement stopped early: classes may be merged")
+	}
+	switch {
+	case counters.hopcroft:
+		fmt.Fprintf(w, "refinement: Hopcroft's algorithm on deterministic LTSs, %d splits\n",
+			counters.splits)
+	case levelBlocks != nil:
+		fmt.Fprintf(w, "refinement: %d levels, %d splits, %d blocks examined\n",
+			counters.rounds, counters.splits, counters.attempts)
+		printLevels(w)
+	default:
+		fmt.Fprintf(w, "refinement: %d steps, %d splits, %d split attempts (%d wasted)\n",
+			counters.rounds, counters.splits, counters.attempts,
+			counters.attempts-counters.splits)
+	}
+	largest := largestClass(part.classes(), part.sides)
+	fmt.Fprintf(w, "largest class: %d (%d states)\n",
+		largest.Label, largest.size())
+	fmt.Fprintf(w, "  left: %s\n", decodedNames(LeftSide, largest.Left))
+	fmt.Fprintf(w, "  right: %s\n", decodedNames(RightSide, largest.Right))
+}
+
+// decodedNames formats the states with the given decoded IDs on side.
+func decodedNames(side Side, ids []int) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = decodedName(side, id)
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLargestClass(t *testing.T) {
+	// Left states 0, 1 and 2 and right states 0 and 1.
+	sides := Sides{
+		0: {LeftSide, 0}, 2: {LeftSide, 1}, 4: {LeftSide, 2},
+		1: {RightSide, 0}, 3: {RightSide, 1},
+	}
+	for _, test := range []struct {
+		name  string
+		bisim Bisimulation
+		want  Class
+	}{
+		{"largest", Bisimulation{0: 7, 1: 7, 2: 3, 4: 3, 3: 3}, Class{3, []int{1, 2}, []int{1}}},
+		{"tie", Bisimulation{0: 7, 1: 7, 2: 3, 3: 3, 4: 5}, Class{3, []int{1}, []int{1}}},
+	} {
+		if got := largestClass(test.bisim, sides); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: largestClass() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestStatsLargestClass checks the largest class -stats reports: a.0 + a.0
+// merges both a-successors of the left with the one of the right.
+func TestStatsLargestClass(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-stats", left, right)
+	if code != 0 {
+		t.Fatalf("status %d: %s", code, stderr)
+	}
+	if want := "(3 states)\n  left: [1 2]\n  right: [1]\n"; !strings.Contains(stderr, want) {
+		t.Errorf("-stats printed\n%s\nwant the largest class\n%s", stderr, want)
+	}
+}
+
+// TestStatsAttempts checks the split counters -stats reports for a
+// nondeterministic pair, which takes the Kanellakis-Smolka refinement.
+func TestStatsAttempts(t *testing.T) {
+	dir := t.TempDir()
+	lts := "des (0, 3, 4)\n(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n(1, \"1 1\", 3)\n"
+	left := writeTestFile(t, dir, "left.aut", lts)
+	right := writeTestFile(t, dir, "right.aut", lts)
+	_, stderr, code := runPisim(t, dir, "-quiet", "-stats", left, right)
+	if code != 0 {
+		t.Fatalf("status %d: %s", code, stderr)
+	}
+	m := regexp.MustCompile(`refinement: (\d+) steps, (\d+) splits, (\d+) split attempts \((\d+) wasted\)`).
+		FindStringSubmatch(stderr)
+	if m == nil {
+		t.Fatalf("-stats printed no split counters:\n%s", stderr)
+	}
+	var n [4]int
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
+	}
+	steps, splits, attempts, wasted := n[0], n[1], n[2], n[3]
+	if splits != 2 || steps < splits || attempts < splits || wasted != attempts-splits {
+		t.Errorf("%d steps, %d splits, %d attempts and %d wasted, want 2 splits and the attempts counted",
+			steps, splits, attempts, wasted)
+	}
+}
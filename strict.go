@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/yungene/pifra"
+)
+
+var strict = flag.Bool("strict", false,
+	"fail if either LTS is truncated at the register bound, instead of warning")
+
+// truncatedStates counts the states at which pifra stopped exploring because
+// the register bound was reached.
+func truncatedStates(lts pifra.Lts) int {
+	n := 0
+	for _, reached := range lts.RegSizeReached {
+		if reached {
+			n++
+		}
+	}
+	return n
+}
+
+// checkTruncation warns about LTSs truncated at the register bound, whose
+// comparison may not carry over to the processes they were generated from,
+// and refuses them under -strict.
+func checkTruncation(left, right pifra.Lts, inputs []string) error {
+	for side, lts := range []pifra.Lts{left, right} {
+		n := truncatedStates(lts)
+		if n == 0 {
+			continue
+		}
+		if *strict {
+			return fmt.Errorf("%s LTS %s is truncated at the register bound in %d state(s); result would be unsound",
+				Side(side), inputs[side], n)
+		}
+		log.Printf("warning: %s LTS %s is truncated at the register bound in %d state(s); the result may be unsound",
+			Side(side), inputs[side], n)
+	}
+	return nil
+}
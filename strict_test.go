@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestStrict(t *testing.T) {
+	dir := t.TempDir()
+	lts := pifra.Lts{
+		States:         map[int]pifra.Configuration{0: {}, 1: {}, 2: {}},
+		RegSizeReached: map[int]bool{1: true, 2: false},
+		Transitions: []pifra.Transition{
+			{Source: 0, Destination: 1, Label: inputLabel(1, 1)},
+			{Source: 0, Destination: 2, Label: inputLabel(2, 2)},
+		},
+	}
+	truncated := writeTestLTS(t, dir, "truncated.gob", lts)
+	lts.RegSizeReached = nil
+	complete := writeTestLTS(t, dir, "complete.gob", lts)
+
+	_, stderr, code := runPisim(t, dir, "-quiet", complete, truncated)
+	if code != 0 || !strings.Contains(stderr, "warning: right LTS "+truncated+
+		" is truncated at the register bound in 1 state(s); the result may be unsound") {
+		t.Errorf("without -strict: status %d and %q, want 0 and a warning", code, stderr)
+	}
+	stdout, stderr, code := runPisim(t, dir, "-quiet", "-strict", complete, truncated)
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "right LTS "+truncated+
+		" is truncated at the register bound in 1 state(s); result would be unsound") {
+		t.Errorf("with -strict: status %d, %q and %q, want an error and no verdict", code, stdout, stderr)
+	}
+	_, stderr, code = runPisim(t, dir, "-quiet", "-strict", complete, complete)
+	if code != 0 || strings.Contains(stderr, "truncated") {
+		t.Errorf("complete LTSs with -strict: status %d and %q, want 0 and no warning", code, stderr)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/yungene/pifra"
+)
+
+var styleByKind = flag.Bool("style-by-kind", false,
+	"style dot edges by the kind of their action")
+
+// LabelKind classifies transition labels by their pi-calculus action.
+type LabelKind int
+
+const (
+	KindOther LabelKind = iota
+	KindTau
+	KindInput
+	KindFreeOutput
+	KindBoundOutput
+)
+
+func labelKind(label pifra.Label) LabelKind {
+	if IsTau(label) {
+		return KindTau
+	}
+	switch label.Symbol.Type {
+	case pifra.SymbolTypInput, pifra.SymbolTypFreshInput:
+		return KindInput
+	case pifra.SymbolTypOutput:
+		if label.Symbol2.Type == pifra.SymbolTypFreshOutput {
+			return KindBoundOutput
+		}
+		return KindFreeOutput
+	}
+	return KindOther
+}
+
+var kindStyles = map[LabelKind]string{
+	KindTau:         "style=dotted,arrowhead=odot,",
+	KindInput:       "style=dashed,arrowhead=vee,",
+	KindFreeOutput:  "style=solid,arrowhead=normal,",
+	KindBoundOutput: "style=solid,arrowhead=empty,",
+}
+
+// edgeAttrs returns the dot attributes of an edge with the given label.
+func edgeAttrs(label pifra.Label) string {
+	if !*styleByKind {
+		return ""
+	}
+	return kindStyles[labelKind(label)]
+}
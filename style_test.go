@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+func TestEdgeAttrs(t *testing.T) {
+	sym := func(typ pifra.SymbolType, value int) pifra.Symbol {
+		return pifra.Symbol{Type: typ, Value: value}
+	}
+	tests := []struct {
+		name  string
+		label pifra.Label
+		kind  LabelKind
+		attrs string
+	}{
+		{"tau", tauLabel, KindTau, "style=dotted,arrowhead=odot,"},
+		{"input", pifra.Label{Symbol: sym(pifra.SymbolTypInput, 1), Symbol2: sym(pifra.SymbolTypKnown, 2)},
+			KindInput, "style=dashed,arrowhead=vee,"},
+		{"fresh input", pifra.Label{Symbol: sym(pifra.SymbolTypFreshInput, 1), Symbol2: sym(pifra.SymbolTypKnown, 2)},
+			KindInput, "style=dashed,arrowhead=vee,"},
+		{"free output", pifra.Label{Symbol: sym(pifra.SymbolTypOutput, 1), Symbol2: sym(pifra.SymbolTypKnown, 2)},
+			KindFreeOutput, "style=solid,arrowhead=normal,"},
+		{"bound output", pifra.Label{Symbol: sym(pifra.SymbolTypOutput, 1), Symbol2: sym(pifra.SymbolTypFreshOutput, 2)},
+			KindBoundOutput, "style=solid,arrowhead=empty,"},
+		{"other", pifra.Label{Symbol: sym(pifra.SymbolTypKnown, 1)}, KindOther, ""},
+	}
+	for _, test := range tests {
+		if kind := labelKind(test.label); kind != test.kind {
+			t.Errorf("%s: labelKind() = %d, want %d", test.name, kind, test.kind)
+		}
+		if attrs := edgeAttrs(test.label); attrs != "" {
+			t.Errorf("%s: edgeAttrs() = %q without -style-by-kind", test.name, attrs)
+		}
+	}
+	setFlag(t, "style-by-kind", "true")
+	for _, test := range tests {
+		if attrs := edgeAttrs(test.label); attrs != test.attrs {
+			t.Errorf("%s: edgeAttrs() = %q, want %q", test.name, attrs, test.attrs)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var (
+	summary = flag.Bool("summary", false,
+		"on a positive verdict, describe how the classes reachable from the initial class correspond")
+	summaryDepth = flag.Int("summary-depth", 2,
+		"describe the classes at most `n` moves from the initial class")
+	summaryMax = flag.Int("summary-max", 20,
+		"describe at most `n` classes")
+)
+
+// maxSummaryMembers bounds the members listed per side of a class.
+const maxSummaryMembers = 5
+
+// classMove is the moves of the members of a class by one label: the classes
+// reached by the members of each side.
+type classMove struct {
+	label pifra.Label
+	dests [2][]int
+}
+
+// classMembers inverts bisim, listing the members of each class per side in
+// increasing order.
+func classMembers(bisim Bisimulation, sides Sides) map[int][2][]int {
+	members := make(map[int][2][]int)
+	for _, state := range sortedKeys(bisim) {
+		m := members[bisim[state]]
+		m[sides[state].Side] = append(m[sides[state].Side], state)
+		members[bisim[state]] = m
+	}
+	return members
+}
+
+// classMoves returns the moves of the members of a class, ordered by label.
+func classMoves(succs map[int][]pifra.Transition, classes Bisimulation, members [2][]int) []classMove {
+	moves := make(map[pifra.Label]*classMove)
+	seen := make(map[pifra.Label]*[2]map[int]bool)
+	for side, states := range members {
+		for _, state := range states {
+			for _, trans := range succs[state] {
+				action := actionOf(trans.Label)
+				m, ok := moves[action]
+				if !ok {
+					m = &classMove{label: action}
+					moves[action] = m
+					seen[action] = &[2]map[int]bool{{}, {}}
+				}
+				dest := classes[trans.Destination]
+				if !seen[action][side][dest] {
+					seen[action][side][dest] = true
+					m.dests[side] = append(m.dests[side], dest)
+				}
+			}
+		}
+	}
+	list := make([]classMove, 0, len(moves))
+	for _, m := range moves {
+		sort.Ints(m.dests[LeftSide])
+		sort.Ints(m.dests[RightSide])
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bisim.LabelLess(list[i].label, list[j].label)
+	})
+	return list
+}
+
+func formatMembers(sides Sides, states []int) string {
+	ids := make([]string, 0, maxSummaryMembers+1)
+	for i, state := range states {
+		if i == maxSummaryMembers {
+			ids = append(ids, fmt.Sprintf("… %d more", len(states)-i))
+			break
+		}
+		ids = append(ids, stateName(sides, state))
+	}
+	if len(ids) == 0 {
+		return "none"
+	}
+	return strings.Join(ids, ", ")
+}
+
+func formatClasses(classes []int, names map[int]string) string {
+	list := make([]string, len(classes))
+	for i, class := range classes {
+		list[i] = names[class]
+	}
+	if len(list) == 1 {
+		return "block " + list[0]
+	}
+	return "blocks " + strings.Join(list, ", ")
+}
+
+// printSummary describes, breadth-first from the class of the initial states,
+// the classes within -summary-depth moves: their members and the classes each
+// label leads to from either side.
+func printSummary(w io.Writer, bisim Bisimulation, sides Sides, initial [2]int,
+	names map[int]string, left, right pifra.Lts) {
+	succs := successors(left, right)
+	members := classMembers(bisim, sides)
+	depth := map[int]int{bisim[initial[LeftSide]]: 0}
+	queue := []int{bisim[initial[LeftSide]]}
+	for i := 0; i < len(queue); i++ {
+		if i == *summaryMax {
+			fmt.Fprintf(w, "… (stopped after %d blocks)\n", *summaryMax)
+			return
+		}
+		class := queue[i]
+		m := members[class]
+		var moves []string
+		for _, move := range classMoves(succs, bisim, m) {
+			label := actionText(move.label)
+			l, r := move.dests[LeftSide], move.dests[RightSide]
+			switch {
+			case reflect.DeepEqual(l, r) && len(l) == 1 && l[0] == class:
+				moves = append(moves, fmt.Sprintf("on %s both stay in block %s", label, names[class]))
+			case reflect.DeepEqual(l, r):
+				moves = append(moves, fmt.Sprintf("on %s both sides move to %s", label, formatClasses(l, names)))
+			case len(l) == 0:
+				moves = append(moves, fmt.Sprintf("on %s only right moves, to %s", label, formatClasses(r, names)))
+			case len(r) == 0:
+				moves = append(moves, fmt.Sprintf("on %s only left moves, to %s", label, formatClasses(l, names)))
+			default:
+				moves = append(moves, fmt.Sprintf("on %s left moves to %s and right to %s",
+					label, formatClasses(l, names), formatClasses(r, names)))
+			}
+			if depth[class] < *summaryDepth {
+				for _, dests := range move.dests {
+					for _, dest := range dests {
+						if _, ok := depth[dest]; !ok {
+							depth[dest] = depth[class] + 1
+							queue = append(queue, dest)
+						}
+					}
+				}
+			}
+		}
+		if len(moves) == 0 {
+			moves = []string{"no moves"}
+		}
+		fmt.Fprintf(w, "block %s (left: %s; right: %s) — %s\n", names[class],
+			formatMembers(sides, m[LeftSide]), formatMembers(sides, m[RightSide]), strings.Join(moves, "; "))
+	}
+}
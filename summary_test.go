@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestSummary describes the correspondence of the classes of an LTS and a
+// renaming of its states, within the depth and size bounds.
+func TestSummary(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		block0 = "block 0 (left: 0; right: 0) — on τ both stay in block 0; on 1 1 both sides move to block 5\n"
+		block5 = "block 5 (left: 3; right: 5) — on 1 1 both sides move to block 2\n"
+		block2 = "block 2 (left: 6; right: 1) — on 1 1 both sides move to block 7; on 2 1 both sides move to block 0\n"
+	)
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, block0 + block5 + block2},
+		{[]string{"-summary-depth", "0"}, block0},
+		{[]string{"-summary-depth", "1"}, block0 + block5},
+		{[]string{"-summary-max", "1"}, block0 + "… (stopped after 1 blocks)\n"},
+	} {
+		args := append(append([]string{"-quiet", "-summary"}, test.args...),
+			filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob"))
+		stdout, stderr, code := runPisim(t, t.TempDir(), args...)
+		if code != 0 || stdout != test.want {
+			t.Errorf("%v: status %d and\n%s\nwant 0 and\n%s%s", test.args, code, stdout, test.want, stderr)
+		}
+	}
+}
+
+func TestFormatMembers(t *testing.T) {
+	names := stateNames
+	t.Cleanup(func() { stateNames = names })
+	stateNames = [2]map[int]string{}
+	sides := make(Sides)
+	var states []int
+	for id := 0; id < 7; id++ {
+		sides[2*id] = Origin{LeftSide, id}
+		states = append(states, 2*id)
+	}
+	if got, want := formatMembers(sides, states), "0, 1, 2, 3, 4, … 2 more"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := formatMembers(sides, nil), "none"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+// stateNames holds, per side, the names of the states of an input written by
+// hand, by decoded ID. It is nil for a side read from a gob.
+var stateNames [2]map[int]string
+
+// stateName returns the name of a uniquified state, from the origin sides
+// gives it, for display: its name in a hand-written input, or else its ID in
+// the input.
+func stateName(sides Sides, state int) string {
+	origin := sides[state]
+	return decodedName(origin.Side, origin.ID)
+}
+
+// decodedName returns the name of the state with the decoded ID id on side.
+func decodedName(side Side, id int) string {
+	if names := stateNames[side]; names != nil {
+		return names[id]
+	}
+	return strconv.Itoa(id)
+}
+
+// parseSymbol reads a symbol as pifra prints it in labels from the start of
+// s, and returns it with the rest of s.
+func parseSymbol(s string) (pifra.Symbol, string, error) {
+	if strings.HasPrefix(s, "τ") {
+		return pifra.Symbol{Type: pifra.SymbolTypTau}, s[len("τ"):], nil
+	}
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return pifra.Symbol{}, "", fmt.Errorf("expected a number at %q", s)
+	}
+	value, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return pifra.Symbol{}, "", err
+	}
+	sym := pifra.Symbol{Type: pifra.SymbolTypKnown, Value: value}
+	rest := s[n:]
+	for _, suffix := range []struct {
+		text string
+		typ  pifra.SymbolType
+	}{
+		{"' ", pifra.SymbolTypOutput},
+		{"'", pifra.SymbolTypOutput},
+		{" ", pifra.SymbolTypInput},
+		{"●", pifra.SymbolTypFreshInput},
+		{"⊛", pifra.SymbolTypFreshOutput},
+	} {
+		if strings.HasPrefix(rest, suffix.text) {
+			sym.Type = suffix.typ
+			return sym, rest[len(suffix.text):], nil
+		}
+	}
+	return sym, rest, nil
+}
+
+// parseLabel reads a label in the form pifra gives it in graphs, such as
+// "1 2" or "1' 2", or τ, which may also be written tau.
+func parseLabel(text string) (pifra.Label, error) {
+	text = strings.TrimSpace(text)
+	if text == "τ" || text == "tau" {
+		return pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}, nil
+	}
+	first, rest, err := parseSymbol(text)
+	if err == nil && rest == "" {
+		err = fmt.Errorf("missing second symbol")
+	}
+	var second pifra.Symbol
+	if err == nil {
+		second, rest, err = parseSymbol(rest)
+	}
+	if err == nil && rest != "" {
+		err = fmt.Errorf("unexpected %q", rest)
+	}
+	if err != nil {
+		return pifra.Label{}, fmt.Errorf("label %q: %v", text, err)
+	}
+	return pifra.Label{Symbol: first, Symbol2: second}, nil
+}
+
+// namedLTS builds an LTS from hand-written transitions between named states.
+type namedLTS struct {
+	lts   pifra.Lts
+	ids   map[string]int
+	names map[int]string
+}
+
+func newNamedLTS() *namedLTS {
+	return &namedLTS{
+		lts: pifra.Lts{
+			States:         make(map[int]pifra.Configuration),
+			RegSizeReached: make(map[int]bool),
+		},
+		ids:   make(map[string]int),
+		names: make(map[int]string),
+	}
+}
+
+// state returns the ID of the state called name, numbering states in order of
+// appearance from 0, the initial state.
+func (n *namedLTS) state(name string) int {
+	id, ok := n.ids[name]
+	if !ok {
+		id = len(n.ids)
+		n.ids[name] = id
+		n.names[id] = name
+		n.lts.States[id] = pifra.Configuration{}
+	}
+	return id
+}
+
+func (n *namedLTS) add(src, label, dst string) error {
+	l, err := parseLabel(label)
+	if err != nil {
+		return err
+	}
+	n.lts.Transitions = append(n.lts.Transitions, pifra.Transition{
+		Source:      n.state(src),
+		Destination: n.state(dst),
+		Label:       l,
+	})
+	return nil
+}
+
+func (n *namedLTS) done() (pifra.Lts, map[int]string) {
+	n.lts.StatesExplored = len(n.lts.States)
+	n.lts.StatesGenerated = len(n.lts.States)
+	return n.lts, n.names
+}
+
+var (
+	autHeader     = regexp.MustCompile(`^des\s*\(\s*(\d+)\s*,\s*\d+\s*,\s*(\d+)\s*\)$`)
+	autTransition = regexp.MustCompile(`^\(\s*(\d+)\s*,\s*("(?:[^"\\]|\\.)*"|[^,]*?)\s*,\s*(\d+)\s*\)$`)
+)
+
+// autState returns the name of the state numbered text in an Aldebaran file
+// of states states, or an error if there is no such state.
+func autState(text string, states int) (string, error) {
+	n, err := strconv.Atoi(text)
+	if err != nil || n >= states {
+		return "", fmt.Errorf("state %s out of range: the header declares %d states", text, states)
+	}
+	return strconv.Itoa(n), nil
+}
+
+// readAut reads an LTS in Aldebaran format. The states keep their numbers as
+// names, and the label i stands for τ, as in CADP. All the states the header
+// declares are created, even those no transition mentions.
+func readAut(r io.Reader) (pifra.Lts, map[int]string, error) {
+	n := newNamedLTS()
+	sc := bufio.NewScanner(r)
+	line, states := 0, 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if line == 1 {
+			m := autHeader.FindStringSubmatch(strings.TrimPrefix(text, "\xef\xbb\xbf"))
+			if m == nil {
+				return pifra.Lts{}, nil, fmt.Errorf("line 1: expected des (initial, transitions, states)")
+			}
+			var err error
+			if states, err = strconv.Atoi(m[2]); err != nil {
+				return pifra.Lts{}, nil, fmt.Errorf("line 1: %v", err)
+			}
+			initial, err := autState(m[1], states)
+			if err != nil {
+				return pifra.Lts{}, nil, fmt.Errorf("line 1: initial %v", err)
+			}
+			n.state(initial)
+			for i := 0; i < states; i++ {
+				n.state(strconv.Itoa(i))
+			}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		m := autTransition.FindStringSubmatch(text)
+		if m == nil {
+			return pifra.Lts{}, nil, fmt.Errorf("line %d: expected (source, label, destination)", line)
+		}
+		src, err := autState(m[1], states)
+		if err == nil {
+			m[3], err = autState(m[3], states)
+		}
+		if err != nil {
+			return pifra.Lts{}, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		label := m[2]
+		if strings.HasPrefix(label, `"`) {
+			unquoted, err := strconv.Unquote(label)
+			if err != nil {
+				return pifra.Lts{}, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			label = unquoted
+		}
+		if label == "i" {
+			label = "τ"
+		}
+		if err := n.add(src, label, m[3]); err != nil {
+			return pifra.Lts{}, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return pifra.Lts{}, nil, err
+	}
+	if line == 0 {
+		return pifra.Lts{}, nil, fmt.Errorf("the file is empty")
+	}
+	lts, names := n.done()
+	return lts, names, nil
+}
+
+// readCSV reads an LTS written as CSV records "source,label,destination",
+// with any names for the states. The source of the first record is the
+// initial state. A first record "source,label,destination" is taken as a
+// header, and lines starting with # are comments.
+func readCSV(r io.Reader) (pifra.Lts, map[int]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	n := newNamedLTS()
+	for i := 0; ; i++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return pifra.Lts{}, nil, err
+		}
+		if i == 0 && rec[0] == "source" && rec[1] == "label" && rec[2] == "destination" {
+			continue
+		}
+		if err := n.add(rec[0], rec[1], rec[2]); err != nil {
+			line, _ := cr.FieldPos(0)
+			return pifra.Lts{}, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if len(n.ids) == 0 {
+		return pifra.Lts{}, nil, fmt.Errorf("no transitions")
+	}
+	lts, names := n.done()
+	return lts, names, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	lts, names, err := readCSV(strings.NewReader(
+		"source,label,destination\n# a comment\nidle, 1 1, busy\nbusy,tau,done\ndone,2 1,idle\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{0: "idle", 1: "busy", 2: "done"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+	if len(lts.States) != 3 || len(lts.Transitions) != 3 || !IsTau(lts.Transitions[1].Label) ||
+		lts.Transitions[2].Source != 2 || lts.Transitions[2].Destination != 0 {
+		t.Errorf("read %v", lts.Transitions)
+	}
+	for _, test := range []struct {
+		text, want string
+	}{
+		{"a,1 1\n", "wrong number of fields"},
+		{"a,1 1,b\nb,x y,a\n", `line 2: label "x y": expected a number at "x y"`},
+		{"source,label,destination\n", "no transitions"},
+	} {
+		if _, _, err := readCSV(strings.NewReader(test.text)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got %v, want an error holding %q", test.text, err, test.want)
+		}
+	}
+}
+
+func TestReadAut(t *testing.T) {
+	lts, names, err := readAut(strings.NewReader("des (2, 1, 4)\n(2, i, 01)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{0: "2", 1: "0", 2: "1", 3: "3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+	if len(lts.States) != 4 || len(lts.Transitions) != 1 || lts.Transitions[0].Source != 0 ||
+		lts.Transitions[0].Destination != 2 {
+		t.Errorf("read %d states and %v", len(lts.States), lts.Transitions)
+	}
+	for _, test := range []struct {
+		text, want string
+	}{
+		{"des (0, 1, 2)\n(0, i, 2)\n", "line 2: state 2 out of range: the header declares 2 states"},
+		{"des (3, 1, 2)\n(0, i, 1)\n", "line 1: initial state 3 out of range"},
+	} {
+		if _, _, err := readAut(strings.NewReader(test.text)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got %v, want an error holding %q", test.text, err, test.want)
+		}
+	}
+}
+
+// TestNamedStates compares an implementation with a silent step against a
+// specification written by hand with named states, and checks that the
+// outputs name the states of the specification.
+func TestNamedStates(t *testing.T) {
+	dir := t.TempDir()
+	spec := writeTestFile(t, dir, "spec.csv", "source,label,destination\nidle,1 1,busy\nbusy,2 1,idle\n")
+	impl := writeTestFile(t, dir, "impl.aut", "des (0, 3, 3)\n(0, \"1 1\", 1)\n(1, i, 2)\n(2, \"2 1\", 0)\n")
+	witness := filepath.Join(dir, "witness.txt")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-equivalence", "weak", "-witness", witness, impl, spec); code != 0 {
+		t.Fatalf("status %d, want 0: %s", code, stderr)
+	}
+	got, err := os.ReadFile(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# left right bisimulation\n0 idle\n1 busy\n2 busy\n"; string(got) != want {
+		t.Errorf("witness\n%s\nwant\n%s", got, want)
+	}
+	stdout, _, code := runPisim(t, dir, "-quiet", "-sim", impl, spec)
+	if want := "right ≰ left: after <1 1>, right state busy can do 2 1 but left state 1 cannot\n"; code != 1 ||
+		!strings.HasSuffix(stdout, want) {
+		t.Errorf("-sim: status %d and %q, want 1 and %q", code, stdout, want)
+	}
+	stdout, _, _ = runPisim(t, dir, "-quiet", "-orphans", impl, spec)
+	if !strings.Contains(stdout, "  right state busy (1 state(s)) via <1 1>\n") {
+		t.Errorf("-orphans: %q does not name state busy", stdout)
+	}
+}
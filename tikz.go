@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+)
+
+var outputFormat = flag.String("format", "dot",
+	"write the coloured LTSs as `format`: dot, or tikz for LaTeX")
+
+// Spacing of the layered layout of tikz output, in centimetres.
+const (
+	tikzColumn = 2.5
+	tikzRow    = 2.0
+)
+
+func validateFormat() error {
+	switch *outputFormat {
+	case "dot":
+		return nil
+	case "tikz":
+		if *certify {
+			return errors.New("-certify needs -format dot")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown -format %q", *outputFormat)
+}
+
+// texText escapes s for LaTeX text mode.
+func texText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\textbackslash{}`)
+		case '^':
+			b.WriteString(`\textasciicircum{}`)
+		case '~':
+			b.WriteString(`\textasciitilde{}`)
+		case '{', '}', '$', '&', '#', '_', '%':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case 'τ':
+			b.WriteString(`$\tau$`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// bisimTikZ renders lts like bisimGraphViz, as a tikzpicture for the TikZ
+// automata library. Classes are laid out in rows by their distance from the
+// initial class, with the classes it cannot reach in a last row, and the
+// labels of parallel edges are joined.
+func bisimTikZ(bisim Bisimulation, names map[int]string, lts pifra.Lts, initialState int) []byte {
+	var buf bytes.Buffer
+	dist := classDistances(bisim, lts, initialState, *renderDepth)
+	initial := make(map[int]bool)
+	accepting := make(map[int]bool)
+	last := 0
+	for _, d := range dist {
+		if d+1 > last {
+			last = d + 1
+		}
+	}
+	rows := make(map[int][]int)
+	seen := make(map[int]bool)
+	for state := range lts.States {
+		class := bisim[state]
+		if state == initialState {
+			initial[class] = true
+		}
+		if lts.RegSizeReached[state] {
+			accepting[class] = true
+		}
+		if seen[class] {
+			continue
+		}
+		seen[class] = true
+		d, ok := dist[class]
+		if !ok {
+			if *renderDepth >= 0 {
+				continue
+			}
+			d = last
+		}
+		rows[d] = append(rows[d], class)
+	}
+
+	buf.WriteString("% \\usetikzlibrary{automata}\n")
+	buf.WriteString("\\begin{tikzpicture}[shorten >=1pt,auto,>=stealth]\n")
+	shown := make(map[int]bool)
+	for row := 0; row <= last; row++ {
+		classes := rows[row]
+		sort.Ints(classes)
+		for i, class := range classes {
+			shown[class] = true
+			style := "state"
+			if initial[class] {
+				style += ",initial"
+			}
+			if accepting[class] {
+				style += ",accepting"
+			}
+			x := (float64(i) - float64(len(classes)-1)/2) * tikzColumn
+			fmt.Fprintf(&buf, "  \\node[%s] (c%d) at (%g,%g) {%s};\n",
+				style, class, x, float64(-row)*tikzRow, texText(names[class]))
+		}
+	}
+	type arc struct {
+		src, dst int
+	}
+	var arcs []arc
+	labels := make(map[arc][]string)
+	for _, edge := range quotientEdges(bisim, lts) {
+		a := arc{edge.src, edge.dst}
+		if !shown[a.src] || !shown[a.dst] {
+			continue
+		}
+		if labels[a] == nil {
+			arcs = append(arcs, a)
+		}
+		labels[a] = append(labels[a], texText(edge.label.PrettyPrintGraph()))
+	}
+	if len(arcs) > 0 {
+		buf.WriteString("  \\path[->]\n")
+		for _, a := range arcs {
+			var style string
+			if a.src == a.dst {
+				style = " [loop above]"
+			} else if _, ok := labels[arc{a.dst, a.src}]; ok {
+				style = " [bend left]"
+			}
+			fmt.Fprintf(&buf, "    (c%d) edge%s node {%s} (c%d)\n",
+				a.src, style, strings.Join(labels[a], ", "), a.dst)
+		}
+		buf.WriteString("  ;\n")
+	}
+	buf.WriteString("\\end{tikzpicture}\n")
+	return buf.Bytes()
+}
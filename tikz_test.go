@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pisim/internal/reference"
+)
+
+var (
+	tikzNode = regexp.MustCompile(`(?m)^  \\node\[([a-z,]+)\] \((c\d+)\) at \(-?[\d.]+,-?[\d.]+\) \{`)
+	tikzEdge = regexp.MustCompile(`(?m)^    \((c\d+)\) edge(?: \[[a-z ]+\])? node \{.*\} \((c\d+)\)$`)
+)
+
+// balanced reports whether the unescaped braces of tex are balanced.
+func balanced(tex string) bool {
+	depth := 0
+	for i := 0; i < len(tex); i++ {
+		switch tex[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+func TestTexText(t *testing.T) {
+	const in = `a_b{c}$d&e#f%g\h^i~j τ`
+	const want = `a\_b\{c\}\$d\&e\#f\%g\textbackslash{}h\textasciicircum{}i\textasciitilde{}j $\tau$`
+	if got := texText(in); got != want {
+		t.Errorf("texText(%q) = %q, want %q", in, got, want)
+	}
+	if !balanced(texText("{{}")) {
+		t.Error("escaped braces unbalanced")
+	}
+}
+
+// TestTikZStructure draws random LTSs, each state in a class of its own, and
+// checks that the picture is well formed: balanced braces, a node per class,
+// one initial, the truncated ones accepting, and edges between declared nodes.
+func TestTikZStructure(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		lts := reference.Random(r, 1+r.Intn(12), r.Intn(20), 3, 0.2)
+		truncated := r.Intn(len(lts.States))
+		lts.RegSizeReached[truncated] = true
+		bisim, uniq := ownClasses(cloneLTS(lts))
+		tex := string(bisimTikZ(bisim, classNames(bisim, uniq), uniq, initialStates[LeftSide]))
+
+		if !balanced(tex) || !strings.Contains(tex, "\\begin{tikzpicture}") ||
+			!strings.HasSuffix(tex, "\\end{tikzpicture}\n") {
+			t.Fatalf("LTS %d: malformed picture:\n%s", i, tex)
+		}
+		nodes := make(map[string]bool)
+		initial := 0
+		for _, m := range tikzNode.FindAllStringSubmatch(tex, -1) {
+			if nodes[m[2]] {
+				t.Fatalf("LTS %d: node %s declared twice:\n%s", i, m[2], tex)
+			}
+			nodes[m[2]] = true
+			if strings.Contains(m[1], "initial") {
+				initial++
+			}
+			if accepting := strings.Contains(m[1], "accepting"); accepting != (m[2] == "c"+strconv.Itoa(truncated)) {
+				t.Fatalf("LTS %d: node %s accepting %v, with state %d truncated:\n%s", i, m[2], accepting, truncated, tex)
+			}
+		}
+		if len(nodes) != len(lts.States) || initial != 1 {
+			t.Fatalf("LTS %d: %d nodes, %d initial, want %d and 1:\n%s", i, len(nodes), initial, len(lts.States), tex)
+		}
+		edges := tikzEdge.FindAllStringSubmatch(tex, -1)
+		for _, m := range edges {
+			if !nodes[m[1]] || !nodes[m[2]] {
+				t.Fatalf("LTS %d: edge from %s to %s of an undeclared node:\n%s", i, m[1], m[2], tex)
+			}
+		}
+		if got := strings.Count(tex, " edge"); got != len(edges) {
+			t.Fatalf("LTS %d: %d edges, %d well formed:\n%s", i, got, len(edges), tex)
+		}
+	}
+}
+
+func TestTikZFlag(t *testing.T) {
+	dir := t.TempDir()
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := filepath.Join(sides, "left.gob"), filepath.Join(sides, "permuted.gob")
+	out := filepath.Join(dir, "out")
+	if _, stderr, code := runPisim(t, dir, "-format", "tikz", left, right, out); code != 0 {
+		t.Fatalf("status %d, want 0: %s", code, stderr)
+	}
+	for _, side := range []string{"left", "right"} {
+		tex, err := os.ReadFile(out + "-" + side + ".tex")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(tikzNode.FindAllString(string(tex), -1)); n != 8 || !balanced(string(tex)) {
+			t.Errorf("%s: %d nodes, want 8, or unbalanced:\n%s", side, n, tex)
+		}
+	}
+	_, stderr, code := runPisim(t, dir, "-format", "tikz", "-certify", left, right, out)
+	if code == 0 || !strings.Contains(stderr, "-certify needs -format dot") {
+		t.Errorf("-certify: status %d and %q, want it refused", code, stderr)
+	}
+}
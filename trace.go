@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/events"
+)
+
+var traceEvents = flag.String("trace-events", "",
+	"write the refinement steps as newline-delimited JSON to `file`")
+
+// tracer records refinement events when -trace-events is given.
+var tracer *events.Writer
+
+func openTrace() (*os.File, error) {
+	if *traceEvents == "" {
+		return nil, nil
+	}
+	if err := checkOutput(*traceEvents); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(*traceEvents)
+	if err != nil {
+		return nil, err
+	}
+	tracer = events.NewWriter(file)
+	return file, nil
+}
+
+func traceBlock(b Block) events.Block {
+	return events.Block{ID: b.ID(), States: b.States()}
+}
+
+func tracePartition(part Partition) []events.Block {
+	blocks := make([]events.Block, 0, len(part.blocks))
+	for _, b := range part.Blocks() {
+		blocks = append(blocks, traceBlock(b))
+	}
+	return blocks
+}
+
+func trace(e events.Event) {
+	if tracer == nil {
+		return
+	}
+	if err := tracer.Write(e); err != nil {
+		log.Printf("trace events: %v", err)
+		tracer = nil
+	}
+}
+
+// traceSplit records the split of b into children, which the refinement made
+// by split.
+func traceSplit(b Block, split bisim.Split, children []Block) {
+	if tracer == nil {
+		return
+	}
+	e := events.Event{
+		Kind:   events.Split,
+		Round:  split.Step,
+		Parent: b.id,
+		Blocks: make([]events.Block, len(children)),
+	}
+	for i, child := range children {
+		e.Blocks[i] = traceBlock(child)
+	}
+	if split.Signature {
+		e.Reason = "signature"
+	} else {
+		e.Action = actionText(split.Action)
+	}
+	trace(e)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/events"
+)
+
+// partitionMap returns the blocks of part by ID, as events.Replay does.
+func partitionMap(part Partition) map[int][]int {
+	blocks := make(map[int][]int, len(part.blocks))
+	for _, b := range part.Blocks() {
+		blocks[b.ID()] = b.States()
+	}
+	return blocks
+}
+
+// TestTraceReplay checks that replaying the events of a refinement, by
+// Kanellakis-Smolka, level by level or by η-signatures, rebuilds the final
+// partition.
+func TestTraceReplay(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		flag   string
+		refine func(left, right pifra.Lts) Partition
+	}{
+		{"ks", "", partKS},
+		{"levelwise", "levelwise", partKS},
+		{"eta", "", partEta},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.flag != "" {
+				setFlag(t, test.flag, "true")
+			}
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 100; i++ {
+				left, right := randomSilentPair(r)
+				left, right = prepared(t, left, right)
+				var buf bytes.Buffer
+				tracer = events.NewWriter(&buf)
+				part := test.refine(left, right)
+				tracer = nil
+				replayed, err := events.Replay(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := partitionMap(part); !reflect.DeepEqual(replayed, want) {
+					t.Fatalf("pair %d: replayed %v, want %v", i, replayed, want)
+				}
+			}
+		})
+	}
+}
+
+// TestTraceEventsFile checks that -trace-events writes a stream that starts
+// with the initial partition and ends with the final one.
+func TestTraceEventsFile(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"1 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	out := filepath.Join(dir, "events.ndjson")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-trace-events", out, left, right); code != 1 {
+		t.Fatalf("status %d, want 1: %s", code, stderr)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if !bytes.HasPrefix(lines[0], []byte(`{"kind":"init"`)) || !bytes.HasPrefix(lines[len(lines)-1], []byte(`{"kind":"done"`)) {
+		t.Errorf("events from %s to %s, want from init to done", lines[0], lines[len(lines)-1])
+	}
+	blocks, err := events.Replay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Errorf("replayed %v, want the 3 classes of a.a.0 and a.0", blocks)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// step returns, for every label, the sorted set of states reached from the
+// states of set by a transition with that label.
+func step(succs map[int][]pifra.Transition, set []int) map[pifra.Label][]int {
+	seen := make(map[pifra.Label]map[int]bool)
+	next := make(map[pifra.Label][]int)
+	for _, s := range set {
+		for _, trans := range succs[s] {
+			if seen[trans.Label] == nil {
+				seen[trans.Label] = make(map[int]bool)
+			}
+			if !seen[trans.Label][trans.Destination] {
+				seen[trans.Label][trans.Destination] = true
+				next[trans.Label] = append(next[trans.Label], trans.Destination)
+			}
+		}
+	}
+	for _, states := range next {
+		sort.Ints(states)
+	}
+	return next
+}
+
+func setKey(set []int) string {
+	return fmt.Sprint(set)
+}
+
+// exploreTraces searches breadth-first through the pairs of state sets reached
+// from s and t by the same trace, as in a joint subset construction, and calls
+// differ on each pair. It returns the first, and so shortest, trace for which
+// differ gives a reason, or a nil reason if there is none.
+func exploreTraces(succs map[int][]pifra.Transition, s, t []int,
+	differ func(s, t []int) string) ([]pifra.Label, string) {
+	type node struct {
+		s, t  []int
+		trace []pifra.Label
+	}
+	seen := map[string]bool{setKey(s) + "/" + setKey(t): true}
+	queue := []node{{s: s, t: t}}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if reason := differ(n.s, n.t); reason != "" {
+			return n.trace, reason
+		}
+		snext, tnext := step(succs, n.s), step(succs, n.t)
+		labels := make([]pifra.Label, 0, len(snext)+len(tnext))
+		for label := range snext {
+			labels = append(labels, label)
+		}
+		for label := range tnext {
+			if _, ok := snext[label]; !ok {
+				labels = append(labels, label)
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return bisim.LabelLess(labels[i], labels[j])
+		})
+		for _, label := range labels {
+			s, t := snext[label], tnext[label]
+			key := setKey(s) + "/" + setKey(t)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			trace := append(append([]pifra.Label(nil), n.trace...), label)
+			queue = append(queue, node{s: s, t: t, trace: trace})
+		}
+	}
+	return nil, ""
+}
+
+// differentTraces reports a trace possible from only one of two state sets.
+func differentTraces(s, t []int) string {
+	switch {
+	case len(s) > 0 && len(t) == 0:
+		return "only left can perform it"
+	case len(s) == 0 && len(t) > 0:
+		return "only right can perform it"
+	}
+	return ""
+}
+
+// traceClasses partitions states into classes of trace equivalence, numbering
+// classes by the order of their first state.
+func traceClasses(succs map[int][]pifra.Transition, states []int) map[int]int {
+	classes := make(map[int]int, len(states))
+	var reps []int
+	for _, s := range states {
+		class := -1
+		for c, r := range reps {
+			if _, reason := exploreTraces(succs, []int{s}, []int{r},
+				differentTraces); reason == "" {
+				class = c
+				break
+			}
+		}
+		if class == -1 {
+			class = len(reps)
+			reps = append(reps, s)
+		}
+		classes[s] = class
+	}
+	return classes
+}
+
+func sortedStates(ltss ...pifra.Lts) []int {
+	var states []int
+	for _, lts := range ltss {
+		for state := range lts.States {
+			states = append(states, state)
+		}
+	}
+	sort.Ints(states)
+	return states
+}
+
+func formatTrace(trace []pifra.Label) string {
+	return "<" + strings.Join(prettyTrace(trace), ", ") + ">"
+}
+
+// possibleFutures checks possible-futures equivalence of the initial states:
+// after every trace, both sides must reach the same sets of trace-equivalence
+// classes. The reason gives a distinguishing trace and future.
+func possibleFutures(left, right pifra.Lts) (bool, string) {
+	succs := successors(left, right)
+	sides := newSides(left, right)
+	classes := traceClasses(succs, sortedStates(left, right))
+	missing := func(s, t []int) int {
+		futures := make(map[int]bool)
+		for _, state := range t {
+			futures[classes[state]] = true
+		}
+		for _, state := range s {
+			if !futures[classes[state]] {
+				return state
+			}
+		}
+		return -1
+	}
+	trace, reason := exploreTraces(succs, []int{initialStates[LeftSide]}, []int{initialStates[RightSide]}, func(s, t []int) string {
+		if state := missing(s, t); state != -1 {
+			return fmt.Sprintf("left state %s has a future right cannot match",
+				stateName(sides, state))
+		}
+		if state := missing(t, s); state != -1 {
+			return fmt.Sprintf("right state %s has a future left cannot match",
+				stateName(sides, state))
+		}
+		return ""
+	})
+	if reason == "" {
+		return true, ""
+	}
+	return false, fmt.Sprintf("after %s, %s", formatTrace(trace), reason)
+}
+
+// completedTraces checks completed-trace equivalence of the initial states:
+// both sides must have the same traces, and the same traces ending in a
+// deadlock. The reason gives a distinguishing trace.
+func completedTraces(left, right pifra.Lts) (bool, string) {
+	succs := successors(left, right)
+	deadlock := func(set []int) bool {
+		for _, state := range set {
+			if len(succs[state]) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	trace, reason := exploreTraces(succs, []int{initialStates[LeftSide]}, []int{initialStates[RightSide]}, func(s, t []int) string {
+		if reason := differentTraces(s, t); reason != "" {
+			return reason
+		}
+		switch ls, rs := deadlock(s), deadlock(t); {
+		case ls && !rs:
+			return "only left can deadlock after it"
+		case !ls && rs:
+			return "only right can deadlock after it"
+		}
+		return ""
+	})
+	if reason == "" {
+		return true, ""
+	}
+	return false, fmt.Sprintf("trace %s: %s", formatTrace(trace), reason)
+}
+
+// traceEquivalence checks trace equivalence of the initial states. The reason
+// gives the shortest trace only one side can perform.
+func traceEquivalence(left, right pifra.Lts) (bool, string) {
+	trace, reason := exploreTraces(successors(left, right), []int{initialStates[LeftSide]}, []int{initialStates[RightSide]}, differentTraces)
+	if reason == "" {
+		return true, ""
+	}
+	return false, fmt.Sprintf("trace %s: %s", formatTrace(trace), reason)
+}
+
+// initials returns the sets of actions offered by the states of set that are
+// minimal under inclusion. A set of states can refuse a set of actions exactly
+// if the actions avoid one of them, so equal families mean equal refusals.
+func initials(succs map[int][]pifra.Transition, set []int) [][]pifra.Label {
+	var all []map[pifra.Label]bool
+	for _, state := range set {
+		offered := make(map[pifra.Label]bool)
+		for _, trans := range succs[state] {
+			offered[trans.Label] = true
+		}
+		all = append(all, offered)
+	}
+	subset := func(a, b map[pifra.Label]bool) bool {
+		for label := range a {
+			if !b[label] {
+				return false
+			}
+		}
+		return true
+	}
+	var minimal [][]pifra.Label
+	seen := make(map[string]bool)
+	for i, a := range all {
+		isMinimal := true
+		for j, b := range all {
+			if j != i && subset(b, a) && (len(b) < len(a) || j < i) {
+				isMinimal = false
+				break
+			}
+		}
+		if !isMinimal {
+			continue
+		}
+		labels := make([]pifra.Label, 0, len(a))
+		for label := range a {
+			labels = append(labels, label)
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return bisim.LabelLess(labels[i], labels[j])
+		})
+		if key := strings.Join(prettyTrace(labels), ","); !seen[key] {
+			seen[key] = true
+			minimal = append(minimal, labels)
+		}
+	}
+	return minimal
+}
+
+// refusesAsMuch reports whether some set of offers has no action outside
+// offer.
+func refusesAsMuch(offers [][]pifra.Label, offer []pifra.Label) bool {
+	offered := make(map[pifra.Label]bool, len(offer))
+	for _, label := range offer {
+		offered[label] = true
+	}
+	for _, other := range offers {
+		within := true
+		for _, label := range other {
+			within = within && offered[label]
+		}
+		if within {
+			return true
+		}
+	}
+	return false
+}
+
+// failures checks failures equivalence of the initial states: after every
+// trace, both sides must be able to refuse the same sets of actions. The
+// reason gives a distinguishing trace and the actions one side can limit
+// itself to.
+func failures(left, right pifra.Lts) (bool, string) {
+	succs := successors(left, right)
+	trace, reason := exploreTraces(succs, []int{initialStates[LeftSide]}, []int{initialStates[RightSide]}, func(s, t []int) string {
+		if reason := differentTraces(s, t); reason != "" {
+			return reason
+		}
+		ls, rs := initials(succs, s), initials(succs, t)
+		for _, dir := range []struct {
+			side, other   string
+			offers, their [][]pifra.Label
+		}{{"left", "right", ls, rs}, {"right", "left", rs, ls}} {
+			for _, offer := range dir.offers {
+				if !refusesAsMuch(dir.their, offer) {
+					return fmt.Sprintf("%s can refuse every action but {%s} after it, %s cannot",
+						dir.side, strings.Join(prettyTrace(offer), ", "), dir.other)
+				}
+			}
+		}
+		return ""
+	})
+	if reason == "" {
+		return true, ""
+	}
+	return false, fmt.Sprintf("trace %s: %s", formatTrace(trace), reason)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPossibleFutures compares a.b + a.c + a.(b+c) with a.b + a.c, which are
+// failures equivalent: after a, each refusal of b+c is one of b or c. They
+// are not possible-futures equivalent, as only the left can reach a state
+// offering both b and c.
+func TestPossibleFutures(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 7, 8)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(0, \"1 1\", 3)\n(3, \"3 3\", 4)\n"+
+		"(0, \"1 1\", 5)\n(5, \"2 2\", 6)\n(5, \"3 3\", 7)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 4, 5)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(0, \"1 1\", 3)\n(3, \"3 3\", 4)\n")
+	stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", "failures", left, right)
+	if code != 0 {
+		t.Fatalf("failures: status %d and %q, want 0", code, stdout)
+	}
+	stdout, _, code = runPisim(t, dir, "-quiet", "-equivalence", "possible-futures", left, right)
+	want := "after <1 1>, left state 5 has a future right cannot match"
+	if code != 1 || !strings.Contains(stdout, want) {
+		t.Errorf("possible-futures: status %d and %q, want 1 and %q", code, stdout, want)
+	}
+	stdout, _, code = runPisim(t, dir, "-quiet", "-equivalence", "possible-futures", left, left)
+	if code != 0 {
+		t.Errorf("left against itself: status %d and %q, want 0", code, stdout)
+	}
+}
+
+// TestCompletedTraces compares a + a.b with a.b, which have the same traces,
+// but only the left can deadlock after a.
+func TestCompletedTraces(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 3, 4)\n"+
+		"(0, \"1 1\", 1)\n(0, \"1 1\", 2)\n(2, \"2 2\", 3)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n")
+	if stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", "trace", left, right); code != 0 {
+		t.Fatalf("trace: status %d and %q, want 0", code, stdout)
+	}
+	for _, files := range [][2]string{{left, right}, {right, left}} {
+		stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", "completed-trace", files[0], files[1])
+		side := "left"
+		if files[0] == right {
+			side = "right"
+		}
+		want := "Not completed-trace equivalent: trace <1 1>: only " + side + " can deadlock after it\n"
+		if code != 1 || stdout != want {
+			t.Errorf("completed-trace: status %d and %q, want 1 and %q", code, stdout, want)
+		}
+	}
+}
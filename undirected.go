@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/yungene/pifra"
+)
+
+// undirected changes what is compared: with the reverse of every transition
+// added, related states must also agree on how they are entered, and states
+// only reachable backwards take part. Since reversed labels are marked, a
+// bisimulation of the extended LTSs is also one of the originals, so
+// -undirected only ever adds distinctions; a verdict under it is about the
+// shape of the graphs rather than the behaviour of the processes.
+var undirected = flag.Bool("undirected", false,
+	"compare the LTSs as undirected graphs, adding the reverse of every transition "+
+		"with a reversed label (this no longer compares behaviour)")
+
+// reversedOffset moves the symbol type of the first symbol of a reversed label
+// below those used by pifra and by observation classes. Reversing maps a type
+// t to reversedOffset - t, so that reversing twice restores the label.
+const reversedOffset pifra.SymbolType = -16
+
+func reverseLabel(label pifra.Label) pifra.Label {
+	label.Symbol.Type = reversedOffset - label.Symbol.Type
+	return label
+}
+
+// isReversed reports whether label was made by reverseLabel.
+func isReversed(label pifra.Label) bool {
+	return label.Symbol.Type < observedClass
+}
+
+// addReverse returns lts with the reverse of every transition added.
+func addReverse(lts pifra.Lts) pifra.Lts {
+	undirected := lts
+	undirected.Transitions = make([]pifra.Transition, 0, 2*len(lts.Transitions))
+	undirected.Transitions = append(undirected.Transitions, lts.Transitions...)
+	for _, trans := range lts.Transitions {
+		undirected.Transitions = append(undirected.Transitions, pifra.Transition{
+			Source:      trans.Destination,
+			Destination: trans.Source,
+			Label:       reverseLabel(trans.Label),
+		})
+	}
+	return undirected
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestUndirected compares an LTS whose initial state is entered by a b move
+// from a state it cannot reach with one where the b move goes elsewhere.
+// Only the undirected comparison tells them apart.
+func TestUndirected(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(2, \"2 2\", 1)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 4)\n(0, \"1 1\", 1)\n(2, \"2 2\", 3)\n")
+	if stdout, stderr, code := runPisim(t, dir, "-quiet", left, right); code != 0 {
+		t.Errorf("directed: status %d, want 0: %s%s", code, stdout, stderr)
+	}
+	stdout, stderr, code := runPisim(t, dir, "-quiet", "-undirected", left, right)
+	if code != 1 || !strings.HasPrefix(stdout, "Not bisimilar") {
+		t.Errorf("undirected: status %d and %q, want not bisimilar: %s", code, stdout, stderr)
+	}
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-undirected", left, left); code != 0 {
+		t.Errorf("undirected against itself: status %d, want 0: %s", code, stderr)
+	}
+}
+
+// TestUndirectedRefines checks on random pairs that -undirected only adds
+// distinctions, and that reversing a label twice restores it.
+func TestUndirectedRefines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		left, right := randomPair(r)
+		if refinedBisimilar(t, addReverse(left), addReverse(right), "strong") &&
+			!refinedBisimilar(t, left, right, "strong") {
+			t.Fatalf("pair %d bisimilar undirected but not directed:\n%v\n%v", i, left, right)
+		}
+		for _, trans := range left.Transitions {
+			reversed := reverseLabel(trans.Label)
+			if !isReversed(reversed) || isReversed(trans.Label) || reverseLabel(reversed) != trans.Label {
+				t.Fatalf("label %v reversed to %v", trans.Label, reversed)
+			}
+		}
+	}
+}
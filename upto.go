@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"flag"
+)
+
+// upTo enables a cheap bisimulation up to bisimilarity before refinement:
+// states whose moves are identical, up to destinations already known to be
+// bisimilar, are bisimilar themselves. Refinement then computes destinations
+// once per group of such states, and never compares the members of a group.
+// The groups only catch states with literally matching moves, such as the
+// copies pifra generates for structurally congruent processes; they do
+// nothing for states that are bisimilar for deeper reasons, and only partKS
+// uses them.
+var upTo = flag.Bool("up-to", false,
+	"share the work of refinement between states with identical moves up to bisimilarity")
+
+func validateUpTo() error {
+	if *upTo && *graded {
+		return errors.New("-up-to cannot be combined with -graded, which counts moves")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pisim/internal/reference"
+)
+
+// TestUpTo checks on random pairs, whose states have copies that move as
+// they do, that partKS finds the same classes with -up-to as without.
+func TestUpTo(t *testing.T) {
+	// The fast path would decide some pairs without refining them.
+	setFlag(t, "no-fastpath", "true")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		left := reference.Random(r, 1+r.Intn(8), r.Intn(14), 2, 0)
+		left, right := prepared(t, doubled(r, left), permuted(r, left))
+		want := classes(partKS(left, right))
+
+		setFlag(t, "up-to", "true")
+		if got := classes(partKS(left, right)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("pair %d: classes %v with -up-to, want %v\nleft: %v\nright: %v",
+				i, got, want, left.Transitions, right.Transitions)
+		}
+		setFlag(t, "up-to", "false")
+	}
+}
+
+func TestUpToGraded(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-up-to", "-graded", a, a); code == 0 ||
+		!strings.Contains(stderr, "-up-to cannot be combined with -graded") {
+		t.Errorf("status %d and %q, want -graded refused", code, stderr)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+// newWitness explains why part, refined over left and right, does not make
+// them equivalent.
+func newWitness(part Partition, left, right pifra.Lts) *bisim.Witness {
+	w := new(bisim.Witness)
+	onlyLeft, onlyRight := alphabetDifference(left, right)
+	w.OnlyLeft, w.OnlyRight = prettyTrace(onlyLeft), prettyTrace(onlyRight)
+	if *backward {
+		w.Reason = backwardReason(part)
+		return w
+	}
+	if action, ok := initialDistinction(part); ok {
+		w.Action = actionText(action)
+	}
+	w.Trace = prettyTrace(distinguishingTrace(part))
+	return w
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/ltsfile"
+	"github.com/yungene/pisim/result"
+)
+
+// goldenResults are compared with the -result-json objects in testdata,
+// which consumers of schema 1 rely on byte for byte.
+var goldenResults = map[string]bisim.Result{
+	"equivalent": {
+		Equivalence: "weak",
+		Verdict:     bisim.Equivalent,
+		Stats: bisim.Stats{
+			Left:    result.Side{File: "left.gob", States: 4, Transitions: 5},
+			Right:   result.Side{File: "right.gob", States: 3, Transitions: 3},
+			Classes: 2,
+			Elapsed: 1500 * time.Millisecond,
+		},
+	},
+	"not-equivalent": {
+		Equivalence: "strong",
+		Verdict:     bisim.NotEquivalent,
+		Stats: bisim.Stats{
+			Left:    result.Side{File: "left.aut", States: 4, Transitions: 3},
+			Right:   result.Side{File: "right.aut", States: 4, Transitions: 3},
+			Classes: 6,
+		},
+		Witness: &bisim.Witness{Trace: []string{"1 1", "2 2"}},
+	},
+	"unknown": {
+		Equivalence: "strong",
+		Verdict:     bisim.Unknown,
+		Stats: bisim.Stats{
+			Left:    result.Side{File: "left.gob", States: 4, Transitions: 8},
+			Right:   result.Side{File: "right.gob", States: 4, Transitions: 8},
+			Classes: 3,
+			Levels:  []int{1, 3},
+		},
+	},
+	"backward": {
+		Equivalence: "strong",
+		Direction:   "backward",
+		Verdict:     bisim.NotEquivalent,
+		Stats: bisim.Stats{
+			Left:  result.Side{File: "left.gob", States: 2, Transitions: 1},
+			Right: result.Side{File: "right.gob", States: 2, Transitions: 0},
+		},
+		Witness: &bisim.Witness{Reason: "no state of right matches state 1 of left"},
+	},
+}
+
+func inputLabel(channel, value int) pifra.Label {
+	return pifra.Label{
+		Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: channel},
+		Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: value},
+	}
+}
+
+func TestResultJSONGolden(t *testing.T) {
+	for name, r := range goldenResults {
+		var buf bytes.Buffer
+		if err := encodeResult(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "result-"+name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Replace(buf.String(), `"version": "`+ltsfile.Version+`"`, `"version": "VERSION"`, 1)
+		if got != string(golden) {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got, golden)
+		}
+	}
+}
+
+// TestResultJSONRun decodes the objects -result-json prints for comparisons,
+// with their elapsed times cleared.
+func TestResultJSONRun(t *testing.T) {
+	sides, err := filepath.Abs(filepath.Join("testdata", "sides"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right, permuted := filepath.Join(sides, "left.gob"), filepath.Join(sides, "right.gob"),
+		filepath.Join(sides, "permuted.gob")
+	for _, test := range []struct {
+		args []string
+		code int
+		want result.Comparison
+	}{
+		{
+			[]string{left, right}, 1,
+			result.Comparison{
+				Equivalence: "strong", Verdict: result.NotEquivalent, Exhaustive: true,
+				Left:    result.Side{File: left, States: 8, Transitions: 15},
+				Right:   result.Side{File: right, States: 6, Transitions: 12},
+				Classes: 14, DistinguishingTrace: []string{"1 1"},
+			},
+		},
+		{
+			[]string{"-equivalence", "weak", left, permuted}, 0,
+			result.Comparison{
+				Equivalence: "weak", Verdict: result.Equivalent, Equivalent: true, Exhaustive: true,
+				Left:    result.Side{File: left, States: 8, Transitions: 15},
+				Right:   result.Side{File: permuted, States: 8, Transitions: 15},
+				Classes: 8,
+			},
+		},
+		{
+			[]string{"-equivalence", "trace", left, right}, 1,
+			result.Comparison{
+				Equivalence: "trace", Verdict: result.NotEquivalent, Exhaustive: true,
+				Left:   result.Side{File: left, States: 8, Transitions: 15},
+				Right:  result.Side{File: right, States: 6, Transitions: 12},
+				Reason: "trace <1 1>: only left can perform it",
+			},
+		},
+	} {
+		stdout, stderr, code := runPisim(t, t.TempDir(), append([]string{"-quiet", "-result-json"}, test.args...)...)
+		if code != test.code {
+			t.Errorf("%v: status %d, want %d: %s", test.args, code, test.code, stderr)
+		}
+		got, err := result.Decode(strings.NewReader(stdout))
+		if err != nil {
+			t.Fatalf("%v: %v in %q", test.args, err, stdout)
+		}
+		if got.ElapsedSeconds <= 0 {
+			t.Errorf("%v: elapsed %g seconds", test.args, got.ElapsedSeconds)
+		}
+		got.ElapsedSeconds = 0
+		test.want.Schema, test.want.Version = result.Schema, ltsfile.Version
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got\n%+v\nwant\n%+v", test.args, got, test.want)
+		}
+	}
+}
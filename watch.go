@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	watchFile = flag.String("watch", "",
+		"while refining, rewrite `file` every -watch-interval with the blocks of the partition reached so far, as JSON")
+	watchInterval = flag.Duration("watch-interval", time.Second,
+		"time between two rewrites of the -watch file")
+)
+
+// watchView is the JSON object of the -watch file.
+type watchView struct {
+	Round int `json:"round"`
+	// Done is set in the last view, when the refinement ends.
+	Done   bool         `json:"done"`
+	Blocks []watchBlock `json:"blocks"`
+}
+
+// watchBlock is a block of the partition, listing its states by name per side.
+type watchBlock struct {
+	ID    int      `json:"id"`
+	Left  []string `json:"left"`
+	Right []string `json:"right"`
+}
+
+// watchSnapshot is a snapshot taken at the end of a round.
+type watchSnapshot struct {
+	round int
+	snap  *PartitionSnapshot
+}
+
+// watcher rewrites the -watch file from its own goroutine, with the latest
+// snapshot the refinement stored. The snapshots never change, so the
+// goroutine reads them while the refinement goes on.
+type watcher struct {
+	name   string
+	sides  Sides
+	latest atomic.Value // of *watchSnapshot
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func validateWatch() error {
+	if *watchFile == "" {
+		return nil
+	}
+	if _, ok := refinements[*equivalence]; !ok || *sim || *compareStats {
+		return errors.New("-watch needs an equivalence decided by partition refinement, " +
+			"without -sim or -compare-stats")
+	}
+	if *prereduce {
+		return errors.New("-watch cannot be combined with -prereduce, which refines each side apart first")
+	}
+	if *watchInterval <= 0 {
+		return errors.New("-watch-interval must be positive")
+	}
+	return checkOutput(*watchFile)
+}
+
+// startWatch starts rewriting the -watch file with the partitions of the
+// states sides gives, until the returned function is called.
+func startWatch(sides Sides) func() {
+	if *watchFile == "" {
+		return func() {}
+	}
+	w := &watcher{name: *watchFile, sides: sides, stop: make(chan struct{})}
+	onSnapshot = func(round int, s *PartitionSnapshot) {
+		w.latest.Store(&watchSnapshot{round, s})
+	}
+	w.wg.Add(1)
+	go w.run()
+	return func() {
+		onSnapshot = nil
+		close(w.stop)
+		w.wg.Wait()
+	}
+}
+
+func (w *watcher) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(*watchInterval)
+	defer ticker.Stop()
+	var written *watchSnapshot
+	for {
+		select {
+		case <-ticker.C:
+			if s, _ := w.latest.Load().(*watchSnapshot); s != nil && s != written {
+				w.write(s, false)
+				written = s
+			}
+		case <-w.stop:
+			if s, _ := w.latest.Load().(*watchSnapshot); s != nil {
+				w.write(s, true)
+			}
+			return
+		}
+	}
+}
+
+// write replaces the -watch file with the view of s. A failed write is
+// logged, since the next one may succeed.
+func (w *watcher) write(s *watchSnapshot, done bool) {
+	data, err := json.MarshalIndent(w.view(s, done), "", "  ")
+	if err == nil {
+		err = replaceFile(w.name, append(data, '\n'))
+	}
+	if err != nil {
+		log.Printf("-watch: %v", err)
+	}
+}
+
+func (w *watcher) view(s *watchSnapshot, done bool) watchView {
+	v := watchView{Round: s.round, Done: done, Blocks: []watchBlock{}}
+	for _, id := range s.snap.BlockIDs() {
+		b := watchBlock{ID: id, Left: []string{}, Right: []string{}}
+		for _, state := range s.snap.Members(id) {
+			if w.sides[state].Side == RightSide {
+				b.Right = append(b.Right, stateName(w.sides, state))
+			} else {
+				b.Left = append(b.Left, stateName(w.sides, state))
+			}
+		}
+		v.Blocks = append(v.Blocks, b)
+	}
+	return v
+}
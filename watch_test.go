@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readWatch(t *testing.T, name string) watchView {
+	t.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v watchView
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// TestWatchDuringRefinement rewrites the -watch file from its goroutine
+// while a long refinement goes on. Run it with -race.
+func TestWatchDuringRefinement(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "watch.json")
+	setFlag(t, "no-fastpath", "true")
+	setFlag(t, "watch", name)
+	setFlag(t, "watch-interval", time.Millisecond.String())
+	left, right := prepared(t, nondeterministicChain(300), nondeterministicChain(300))
+	stop := startWatch(newSides(left, right))
+	part := partKS(left, right)
+	stop()
+	if onSnapshot != nil {
+		t.Error("stopping the watch left onSnapshot set")
+	}
+	v := readWatch(t, name)
+	if !v.Done || len(v.Blocks) != len(part.blocks) {
+		t.Errorf("last view: done %v with %d blocks, want done with %d", v.Done, len(v.Blocks), len(part.blocks))
+	}
+	for _, b := range v.Blocks {
+		if len(b.Left)+len(b.Right) == 0 {
+			t.Errorf("block %d is empty", b.ID)
+		}
+	}
+}
+
+func TestWatchCommand(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 2, 3)\n(0, \"1 1\", 1)\n(1, \"1 1\", 2)\n")
+	watch := filepath.Join(dir, "watch.json")
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-watch", watch, left, right); code != 1 {
+		t.Fatalf("status %d, want 1: %s", code, stderr)
+	}
+	v := readWatch(t, watch)
+	if !v.Done || len(v.Blocks) == 0 {
+		t.Errorf("got %+v, want the final partition", v)
+	}
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-watch", watch, left, right); code != 1 ||
+		!strings.Contains(stderr, "already exists") {
+		t.Errorf("status %d and %q, want an existing -watch file refused", code, stderr)
+	}
+}
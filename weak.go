@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+)
+
+var (
+	weak = flag.Bool("weak", false,
+		"check weak bisimilarity, abstracting from silent actions (-equivalence weak)")
+	tauPattern = flag.String("tau", "",
+		"also treat labels whose printed form matches the glob `pattern`, such as a label's exact text, as silent")
+)
+
+// refinements are the equivalences decided by partition refinement, mapped to
+// whether the LTSs are saturated with silent moves first. η-bisimilarity is
+// refined by signatures on the original moves instead.
+var refinements = map[string]bool{
+	"strong": false,
+	"weak":   true,
+	"delay":  true,
+	"eta":    false,
+}
+
+// tauLabel is the canonical silent action, used for saturated and hidden moves.
+var tauLabel = pifra.Label{Symbol: pifra.Symbol{Type: pifra.SymbolTypTau}}
+
+// labelMatches reports whether the printed form of label matches the glob
+// pattern.
+func labelMatches(pattern string, label pifra.Label) bool {
+	ok, err := path.Match(pattern, label.PrettyPrintGraph())
+	return err == nil && ok
+}
+
+// tauMatches caches whether the printed form of a label matches the pattern
+// tauMatched, the last -tau, so that IsTau matches each label once rather
+// than on every call.
+var (
+	tauMatched string
+	tauMatches map[pifra.Label]bool
+)
+
+// matchesTau reports whether the printed form of label matches -tau.
+func matchesTau(label pifra.Label) bool {
+	if *tauPattern == "" {
+		return false
+	}
+	if tauMatches == nil || tauMatched != *tauPattern {
+		tauMatched, tauMatches = *tauPattern, make(map[pifra.Label]bool)
+	}
+	match, ok := tauMatches[label]
+	if !ok {
+		match = labelMatches(*tauPattern, label)
+		tauMatches[label] = match
+	}
+	return match
+}
+
+// validateTau refuses a malformed -tau pattern, and warns about one that
+// matches no label of ltss, which leaves every action but pifra's own silent
+// one visible.
+func validateTau(ltss ...pifra.Lts) error {
+	if *tauPattern == "" {
+		return nil
+	}
+	if _, err := path.Match(*tauPattern, ""); err != nil {
+		return fmt.Errorf("-tau %q: %v", *tauPattern, err)
+	}
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if matchesTau(trans.Label) {
+				return nil
+			}
+		}
+	}
+	log.Printf("warning: -tau %q matches no label of the LTSs", *tauPattern)
+	return nil
+}
+
+// IsTau reports whether label is a silent action: pifra's tau, or any label
+// matching -tau. The reverse of a silent action added by -undirected is
+// silent. All code distinguishing silent actions must go through it.
+func IsTau(label pifra.Label) bool {
+	if isReversed(label) {
+		return IsTau(reverseLabel(label))
+	}
+	return label.Symbol.Type == pifra.SymbolTypTau || matchesTau(label)
+}
+
+func countTau(ltss ...pifra.Lts) int {
+	var n int
+	for _, lts := range ltss {
+		for _, trans := range lts.Transitions {
+			if IsTau(trans.Label) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// saturateSides prepares both sides for a weak or delay comparison, warning
+// when no transition is silent.
+func saturateSides(left, right pifra.Lts, trailing bool) (pifra.Lts, pifra.Lts) {
+	if countTau(left, right) == 0 {
+		log.Println("warning: weak equivalence requested but no transition is silent")
+	}
+	return bisim.Saturate(left, trailing, IsTau), bisim.Saturate(right, trailing, IsTau)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+	"github.com/yungene/pisim/bisim"
+	"github.com/yungene/pisim/internal/reference"
+)
+
+func TestValidateTau(t *testing.T) {
+	lts := pifra.Lts{Transitions: []pifra.Transition{{
+		Label: pifra.Label{
+			Symbol:  pifra.Symbol{Type: pifra.SymbolTypInput, Value: 1},
+			Symbol2: pifra.Symbol{Type: pifra.SymbolTypKnown, Value: 1},
+		},
+	}}}
+	for _, test := range []struct {
+		pattern string
+		fails   string
+		warns   bool
+	}{
+		{"", "", false},
+		{"1 1", "", false},
+		{"1 *", "", false},
+		{"4 6", "", true},
+		{"[", "syntax error", false},
+	} {
+		setFlag(t, "tau", test.pattern)
+		var logged bytes.Buffer
+		log.SetOutput(&logged)
+		err := validateTau(pifra.Lts{}, lts)
+		log.SetOutput(os.Stderr)
+		if test.fails == "" && err != nil || test.fails != "" && (err == nil || !strings.Contains(err.Error(), test.fails)) {
+			t.Errorf("-tau %q: validateTau() = %v, want %q", test.pattern, err, test.fails)
+		}
+		if warned := strings.Contains(logged.String(), "matches no label"); warned != test.warns {
+			t.Errorf("-tau %q: warned %v, want %v", test.pattern, warned, test.warns)
+		}
+	}
+}
+
+// randomSilentPair returns a small random LTS with silent moves, and either
+// another one or the same with some of its weak moves, with or without silent
+// moves after the visible action, added as transitions. Adding weak moves
+// keeps weak bisimilarity, but adding them with trailing silent moves may
+// break delay bisimilarity.
+func randomSilentPair(r *rand.Rand) (pifra.Lts, pifra.Lts) {
+	left := reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0.4)
+	if r.Intn(3) == 0 {
+		return left, reference.Random(r, 1+r.Intn(5), r.Intn(9), 2, 0.4)
+	}
+	right := cloneLTS(left)
+	for _, trans := range bisim.Saturate(left, r.Intn(2) == 0, IsTau).Transitions {
+		if r.Intn(3) == 0 {
+			right.Transitions = append(right.Transitions, trans)
+		}
+	}
+	return left, permuted(r, right)
+}
+
+func TestSaturationReference(t *testing.T) {
+	setFlag(t, "no-fastpath", "true")
+	t.Run("weak", func(t *testing.T) {
+		checkReference(t, "weak", randomSilentPair, reference.Weak)
+	})
+	t.Run("delay", func(t *testing.T) {
+		checkReference(t, "delay", randomSilentPair, reference.Delay)
+	})
+	// The pairs must tell weak from delay bisimilarity for the test to
+	// check that saturation keeps them apart.
+	r := rand.New(rand.NewSource(1))
+	differ := 0
+	for i := 0; i < 300; i++ {
+		if left, right := randomSilentPair(r); reference.Weak(left, right) != reference.Delay(left, right) {
+			differ++
+		}
+	}
+	if differ == 0 {
+		t.Error("no pair is weakly but not delay bisimilar")
+	}
+}
+
+// TestTauPattern checks that -tau changes the verdict: b.a and a are weakly
+// bisimilar once b counts as silent.
+func TestTauPattern(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 2, 3)\n(0, \"2 2\", 1)\n(1, \"1 1\", 2)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 1, 2)\n(0, \"1 1\", 1)\n")
+	_, stderr, code := runPisim(t, dir, "-quiet", "-weak", left, right)
+	if code != 1 || !strings.Contains(stderr, "no transition is silent") {
+		t.Errorf("-weak: status %d and %q, want 1 and a warning", code, stderr)
+	}
+	if _, stderr, code := runPisim(t, dir, "-quiet", "-weak", "-tau", "2 *", left, right); code != 0 {
+		t.Errorf("-weak -tau '2 *': status %d, want 0: %s", code, stderr)
+	}
+	if _, _, code := runPisim(t, dir, "-quiet", "-tau", "2 *", left, right); code != 1 {
+		t.Errorf("-tau '2 *' under strong bisimilarity: status %d, want 1", code)
+	}
+}
+
+// TestDelayExample separates delay from weak bisimilarity on the classic
+// example: a.c is weakly matched by a.(b + τ.c), which moves silently to c
+// after a, but delay bisimilarity allows no silent move after the action.
+func TestDelayExample(t *testing.T) {
+	dir := t.TempDir()
+	left := writeTestFile(t, dir, "left.aut", "des (0, 6, 7)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(1, i, 3)\n(3, \"3 3\", 4)\n(0, \"1 1\", 5)\n(5, \"3 3\", 6)\n")
+	right := writeTestFile(t, dir, "right.aut", "des (0, 4, 5)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(1, i, 3)\n(3, \"3 3\", 4)\n")
+	if stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", "weak", left, right); code != 0 {
+		t.Errorf("weak: status %d and %q, want 0", code, stdout)
+	}
+	stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", "delay", left, right)
+	if code != 1 || !strings.Contains(stdout, "initial states are distinguished by 1 1") {
+		t.Errorf("delay: status %d and %q, want 1 and the distinguishing action", code, stdout)
+	}
+}
+
+// TestEtaExamples separates η from delay bisimilarity both ways. τ.a + b and
+// τ.a + b + a are delay bisimilar, but η-bisimilarity matches the a of the
+// right only by a silent move into τ.a + b's a, whose state cannot do b.
+// The pair of TestDelayExample is η-bisimilar, since η-bisimilarity allows
+// the silent move after the action.
+func TestEtaExamples(t *testing.T) {
+	dir := t.TempDir()
+	files := func(name, left, right string) [2]string {
+		return [2]string{writeTestFile(t, dir, name+"-left.aut", left), writeTestFile(t, dir, name+"-right.aut", right)}
+	}
+	before := files("before", "des (0, 3, 4)\n(0, i, 1)\n(1, \"1 1\", 2)\n(0, \"2 2\", 3)\n",
+		"des (0, 4, 5)\n(0, i, 1)\n(1, \"1 1\", 2)\n(0, \"2 2\", 3)\n(0, \"1 1\", 4)\n")
+	after := files("after", "des (0, 6, 7)\n"+
+		"(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(1, i, 3)\n(3, \"3 3\", 4)\n(0, \"1 1\", 5)\n(5, \"3 3\", 6)\n",
+		"des (0, 4, 5)\n(0, \"1 1\", 1)\n(1, \"2 2\", 2)\n(1, i, 3)\n(3, \"3 3\", 4)\n")
+	for _, tt := range []struct {
+		files       [2]string
+		equivalence string
+		want        int
+	}{
+		{before, "weak", 0},
+		{before, "delay", 0},
+		{before, "eta", 1},
+		{after, "weak", 0},
+		{after, "delay", 1},
+		{after, "eta", 0},
+	} {
+		stdout, _, code := runPisim(t, dir, "-quiet", "-equivalence", tt.equivalence, tt.files[0], tt.files[1])
+		if code != tt.want {
+			t.Errorf("%s, %s: status %d and %q, want %d", filepath.Base(tt.files[0]), tt.equivalence, code, stdout, tt.want)
+		}
+		if code == 1 && !strings.Contains(stdout, "initial states are distinguished by 1 1") {
+			t.Errorf("%s, %s: %q does not report the distinguishing action", filepath.Base(tt.files[0]), tt.equivalence, stdout)
+		}
+	}
+}
+
+// TestEtaWitnessStable checks that the action reported to distinguish the
+// initial states under η-bisimilarity does not change from one call to the
+// next, as it did when it was drawn from a map.
+func TestEtaWitnessStable(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		left, right := randomSilentPair(r)
+		left, right = prepared(t, left, right)
+		part := partEta(left, right)
+		if !part.initialsSplit() {
+			continue
+		}
+		want, ok := initialDistinction(part)
+		if !ok {
+			t.Fatalf("pair %d: no action distinguishes the initial states", i)
+		}
+		for j := 0; j < 20; j++ {
+			if got, _ := initialDistinction(part); got != want {
+				t.Fatalf("pair %d: distinguished by %s, then by %s", i, actionText(want), actionText(got))
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/yungene/pifra"
+)
+
+var witness = flag.String("witness", "",
+	"write a bisimulation relating the initial states to `file` as a pair list")
+
+// witnessRelation returns the pairs of left and right states in the same class
+// that are reachable from the initial pair by moves with equal labels. This
+// is a bisimulation whenever the initial states are in the same class of a
+// stable partition. Otherwise, and under -backward, where the moves lead
+// towards the initial states, every pair of left and right states sharing a
+// class is returned.
+func witnessRelation(succs map[int][]pifra.Transition, bisim Bisimulation, sides Sides,
+	initial [2]int) Relation {
+	rel := make(Relation)
+	var queue []Pair
+	if bisim[initial[LeftSide]] == bisim[initial[RightSide]] && !*backward {
+		queue = []Pair{{Left: initial[LeftSide], Right: initial[RightSide]}}
+	} else {
+		members := classMembers(bisim, sides)
+		for _, state := range sortedKeys(bisim) {
+			m := members[bisim[state]]
+			delete(members, bisim[state])
+			for _, s := range m[LeftSide] {
+				for _, t := range m[RightSide] {
+					queue = append(queue, Pair{Left: s, Right: t})
+				}
+			}
+		}
+	}
+	for _, p := range queue {
+		rel[p] = exists
+	}
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		for _, strans := range succs[p.Left] {
+			for _, ttrans := range succs[p.Right] {
+				next := Pair{Left: strans.Destination, Right: ttrans.Destination}
+				if actionOf(ttrans.Label) != actionOf(strans.Label) || bisim[next.Left] != bisim[next.Right] {
+					continue
+				}
+				if _, ok := rel[next]; !ok {
+					rel[next] = exists
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return rel
+}
+
+func sortedKeys(bisim Bisimulation) []int {
+	keys := make([]int, 0, len(bisim))
+	for k := range bisim {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// verifyBisimulation checks that every move of either state of a pair in rel
+// is matched by the other state with the same label into a pair of rel.
+func verifyBisimulation(succs map[int][]pifra.Transition, rel Relation, sides Sides) error {
+	inverse := make(Relation, len(rel))
+	for p := range rel {
+		inverse[Pair{Left: p.Right, Right: p.Left}] = exists
+	}
+	for _, p := range rel.Pairs() {
+		if !matches(succs, rel, p.Left, p.Right) || !matches(succs, inverse, p.Right, p.Left) {
+			return fmt.Errorf("pair (%s, %s) is not matched", stateName(sides, p.Left), stateName(sides, p.Right))
+		}
+	}
+	return nil
+}
+
+// writeWitness writes a checked witness bisimulation to -witness.
+func writeWitness(bisim Bisimulation, sides Sides, initial [2]int, left, right pifra.Lts) error {
+	succs := successors(left, right)
+	rel := witnessRelation(succs, bisim, sides, initial)
+	if err := verifyBisimulation(succs, rel, sides); err != nil {
+		return fmt.Errorf("witness is not a bisimulation: %v", err)
+	}
+	return writeRelation(*witness, "left right "+direction()+"bisimulation", rel, sides)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yungene/pifra"
+)
+
+// readRelation reads a pair list written by writeRelation.
+func readRelation(t *testing.T, name string) map[[2]int]bool {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rel := make(map[[2]int]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "#") {
+			continue
+		}
+		var p [2]int
+		if _, err := fmt.Sscan(sc.Text(), &p[0], &p[1]); err != nil {
+			t.Fatalf("%s: %q: %v", name, sc.Text(), err)
+		}
+		rel[p] = true
+	}
+	return rel
+}
+
+// isBisimulation reports whether every move of either state of a pair in rel
+// is matched by the other with the same label into a pair of rel.
+func isBisimulation(left, right pifra.Lts, rel map[[2]int]bool) error {
+	ls, rs := successors(left), successors(right)
+	matched := func(trans pifra.Transition, succs []pifra.Transition, pair func(int) [2]int) bool {
+		for _, other := range succs {
+			if other.Label == trans.Label && rel[pair(other.Destination)] {
+				return true
+			}
+		}
+		return false
+	}
+	for p := range rel {
+		for _, trans := range ls[p[0]] {
+			if !matched(trans, rs[p[1]], func(d int) [2]int { return [2]int{trans.Destination, d} }) {
+				return fmt.Errorf("left %d -%s-> %d unmatched by right %d",
+					p[0], trans.Label.PrettyPrintGraph(), trans.Destination, p[1])
+			}
+		}
+		for _, trans := range rs[p[1]] {
+			if !matched(trans, ls[p[0]], func(d int) [2]int { return [2]int{d, trans.Destination} }) {
+				return fmt.Errorf("right %d -%s-> %d unmatched by left %d",
+					p[1], trans.Label.PrettyPrintGraph(), trans.Destination, p[0])
+			}
+		}
+	}
+	return nil
+}
+
+// TestWitness checks the relations -witness writes for bisimilar random pairs
+// against the LTSs as they were given.
+func TestWitness(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	checked := 0
+	for i := 0; i < 30; i++ {
+		dir := t.TempDir()
+		left, right := randomPair(r)
+		out := filepath.Join(dir, "witness.txt")
+		stdout, _, code := runPisim(t, dir, "-quiet", "-witness", out,
+			writeTestLTS(t, dir, "left.gob", left), writeTestLTS(t, dir, "right.gob", right))
+		if code != 0 {
+			continue
+		}
+		checked++
+		rel := readRelation(t, out)
+		if !rel[[2]int{0, 0}] {
+			t.Errorf("pair %d: witness lacks the initial pair (%q)", i, stdout)
+		}
+		if err := isBisimulation(left, right, rel); err != nil {
+			t.Errorf("pair %d: %v", i, err)
+		}
+	}
+	if checked == 0 {
+		t.Error("no bisimilar pair, so no witness was checked")
+	}
+}
+
+func TestVerifyBisimulation(t *testing.T) {
+	// 0 -a-> 2 and 1 -a-> 3, uniquified: left states are even, right odd.
+	a := inputLabel(1, 1)
+	succs := map[int][]pifra.Transition{
+		0: {{Source: 0, Destination: 2, Label: a}},
+		1: {{Source: 1, Destination: 3, Label: a}},
+	}
+	sides := Sides{0: {LeftSide, 0}, 1: {RightSide, 0}, 2: {LeftSide, 1}, 3: {RightSide, 1}}
+	if err := verifyBisimulation(succs, Relation{{Left: 0, Right: 1}: exists, {Left: 2, Right: 3}: exists}, sides); err != nil {
+		t.Errorf("bisimulation rejected: %v", err)
+	}
+	if err := verifyBisimulation(succs, Relation{{Left: 0, Right: 1}: exists}, sides); err == nil {
+		t.Error("relation missing the pair after a accepted")
+	}
+}